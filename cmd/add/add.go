@@ -19,6 +19,7 @@ var (
 	flags = []cli.Flag{
 		cmd.TargetFlag,
 		cmd.TargetPathFlag,
+		cmd.TargetMapFlag,
 		&cli.PathFlag{
 			Name:        "search-path",
 			Aliases:     []string{"p"},
@@ -30,6 +31,7 @@ var (
 			Destination: &opts.rating,
 		},
 		cmd.DryrunFlag,
+		cmd.NoBackupFlag,
 	}
 
 	opts = struct {
@@ -58,7 +60,9 @@ func exec(context *cli.Context) error {
 	lib := cmd.OpenTarget(context)
 	defer lib.Close()
 
-	tgt, ok := lib.(music.LibraryEditor)
+	cmd.Backup(context, cmd.Target, lib)
+
+	tgt, ok := music.AsEditor(lib)
 	if !ok {
 		return errors.Errorf("target library doesn't support editing")
 	}
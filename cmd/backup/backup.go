@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"primetools/cmd"
+	"primetools/pkg/backup"
+)
+
+var (
+	restoreFlags = []cli.Flag{
+		cmd.DryrunFlag,
+		cmd.NoBackupFlag,
+	}
+)
+
+func Cmd() *cli.Command {
+	return &cli.Command{
+		Name:        "backup",
+		Usage:       cmd.Usage,
+		HideHelp:    true,
+		Description: fmt.Sprintf("list and restore the backups taken before writing to a library, stored in '%s'", backup.Dir),
+		Action: func(context *cli.Context) error {
+			return errors.Errorf("unknown backup command: %s", context.Args().First())
+		},
+		Subcommands: []*cli.Command{
+			{
+				Name:            "list",
+				Usage:           "list the available backups",
+				HideHelpCommand: true,
+				Action:          list,
+			},
+			{
+				Name:            "restore",
+				Usage:           "restore the database files of a backup",
+				ArgsUsage:       "<backup id>",
+				HideHelpCommand: true,
+				Flags:           restoreFlags,
+				Action:          restore,
+			},
+		},
+	}
+}
+
+func list(context *cli.Context) error {
+	archives, err := backup.List()
+	if err != nil {
+		return err
+	}
+
+	if len(archives) == 0 {
+		logrus.Infof("no backup found in '%s'", backup.Dir)
+		return nil
+	}
+
+	for _, it := range archives {
+		logrus.Info(it.String())
+	}
+	return nil
+}
+
+func restore(context *cli.Context) error {
+	if context.Args().Len() != 1 {
+		return errors.New("expected the id of the backup to restore, see 'backup list'")
+	}
+
+	archive, err := backup.Find(context.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if cmd.IsDryRun(context) {
+		for _, it := range archive.Files {
+			logrus.Infof("[DRY] would restore '%s'", it.Path)
+		}
+		return nil
+	}
+
+	// keep the current state so a restore can be reverted as well
+	if !context.Bool(cmd.NoBackup) {
+		if _, err = backup.SnapshotFiles(archive.Paths(), archive.Library); err != nil {
+			return err
+		}
+	}
+
+	if err = archive.Restore(); err != nil {
+		return err
+	}
+
+	logrus.Infof("restored %d files from backup '%s'", len(archive.Files), archive.Id)
+	return nil
+}
@@ -1,14 +1,19 @@
 package cmd
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/gobwas/glob"
+	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 
+	"primetools/pkg/backup"
 	"primetools/pkg/enums"
+	"primetools/pkg/files"
+	"primetools/pkg/journal"
 	"primetools/pkg/music"
 	"primetools/pkg/music/factory"
 )
@@ -19,6 +24,11 @@ const (
 	SourcePath = "source-path"
 	TargetPath = "target-path"
 	Dryrun     = "dryrun"
+	NoBackup   = "no-backup"
+	Threshold  = "threshold"
+	Workers    = "workers"
+	SourceMap  = "source-map"
+	TargetMap  = "target-map"
 
 	Usage = "the swiss knife of Denon's Engine PRIME"
 )
@@ -47,11 +57,41 @@ var (
 		Aliases: []string{"tp"},
 	}
 
+	SourceMapFlag = &cli.StringSliceFlag{
+		Name:    SourceMap,
+		Aliases: []string{"sm"},
+		Usage:   "rewrite the paths looked up in the source, as from=to (ie: /media/music=M:\\Music), the first matching prefix is used",
+	}
+
+	TargetMapFlag = &cli.StringSliceFlag{
+		Name:    TargetMap,
+		Aliases: []string{"tm"},
+		Usage:   "rewrite the paths looked up in the target, as from=to (ie: M:\\Music=/media/music), the first matching prefix is used",
+	}
+
 	DryrunFlag = &cli.BoolFlag{
 		Name:    Dryrun,
 		Aliases: []string{"ro"},
 		Usage:   "read only mode",
 	}
+
+	NoBackupFlag = &cli.BoolFlag{
+		Name:  NoBackup,
+		Usage: "don't backup the library database files before writing",
+	}
+
+	ThresholdFlag = &cli.Float64Flag{
+		Name:  Threshold,
+		Usage: "minimum score between 0 and 1 of a fuzzy match on the metadata, 0 to disable",
+		Value: music.DefaultMatchThreshold,
+	}
+
+	WorkersFlag = &cli.IntFlag{
+		Name:    Workers,
+		Aliases: []string{"j"},
+		Usage:   "number of tracks loaded in parallel",
+		Value:   music.DefaultWorkers,
+	}
 )
 
 type RuleSlice struct {
@@ -93,7 +133,7 @@ func SubCmds(namenames []string, action cli.ActionFunc, flags []cli.Flag, extra
 
 type opFunc func(libtype enums.LibraryType, path string) (music.Library, error)
 
-func open(context *cli.Context, flag string, pathflag string, op opFunc) music.Library {
+func open(context *cli.Context, flag string, pathflag string, mapflag string, op opFunc) music.Library {
 	if context.String(flag) == "" {
 		logrus.Errorf("--%s cannot be empty", flag)
 		logrus.Exit(1)
@@ -105,24 +145,106 @@ func open(context *cli.Context, flag string, pathflag string, op opFunc) music.L
 		logrus.Exit(1)
 	}
 
+	paths, err := files.ParsePathMap(context.StringSlice(mapflag))
+	if err != nil {
+		logrus.Errorf("invalid --%s: %v", mapflag, err)
+		logrus.Exit(1)
+	}
+
 	lib, err := op(ltype, context.String(pathflag))
 	if err != nil {
 		logrus.Errorf("fail to open %s: %v", flag, err)
 		logrus.Exit(1)
 	}
-	return lib
+	return music.MapPaths(lib, paths)
 }
 
 func OpenTarget(context *cli.Context) music.Library {
-	return open(context, Target, TargetPath, factory.Open)
+	return open(context, Target, TargetPath, TargetMap, factory.Open)
 }
 
 func CreateTarget(context *cli.Context) music.Library {
-	return open(context, Target, TargetPath, factory.Create)
+	return open(context, Target, TargetPath, TargetMap, factory.Create)
 }
 
 func OpenSource(context *cli.Context) music.Library {
-	return open(context, Source, SourcePath, factory.Open)
+	return open(context, Source, SourcePath, SourceMap, factory.Open)
+}
+
+/*
+	Backup the database files of a library which is about to be written, nothing is done in read only mode
+*/
+func Backup(context *cli.Context, flag string, lib music.Library) {
+	if IsDryRun(context) || context.Bool(NoBackup) {
+		return
+	}
+
+	if _, err := backup.Snapshot(lib, context.String(flag)); err != nil {
+		logrus.Errorf("fail to backup %s: %v", flag, err)
+		logrus.Exit(1)
+	}
+}
+
+/*
+	Start recording the changes done to a library so they can be undone, nil in read only mode
+*/
+func StartJournal(context *cli.Context, flag string, pathflag string) *journal.Run {
+	if IsDryRun(context) {
+		return nil
+	}
+
+	ltype, err := enums.ParseLibraryType(context.String(flag))
+	if err != nil {
+		logrus.Errorf("invalid library type '%s': %v", context.String(flag), err)
+		logrus.Exit(1)
+	}
+
+	run, err := journal.Start(context.Command.FullName(), ltype, context.String(pathflag))
+	if err != nil {
+		logrus.Errorf("fail to start journal for %s: %v", flag, err)
+		logrus.Exit(1)
+	}
+	return run
+}
+
+/*
+	Fuzzy matcher against the library with the threshold of the command, nil if disabled
+*/
+func NewMatcher(context *cli.Context, lib music.Library) *music.Matcher {
+	threshold := context.Float64(Threshold)
+	if threshold <= 0 {
+		return nil
+	}
+	return music.NewMatcher(lib, threshold)
+}
+
+/*
+	Choose the best candidate, the user is prompted when several of them tie
+	unless accept is set
+*/
+func PickCandidate(track music.Track, candidates music.Candidates, accept bool) (music.Track, error) {
+	ties := candidates.Ties()
+	switch {
+	case len(ties) == 0:
+		return nil, nil
+	case len(ties) == 1 || accept:
+		logrus.Infof("'%v' fuzzy matched %v", track, ties[0])
+		return ties[0].Track, nil
+	}
+
+	items := []string{}
+	for _, it := range ties {
+		items = append(items, it.String())
+	}
+	prompt := promptui.Select{
+		Label: fmt.Sprintf("Please select the track matching '%v' (%s)", track, track.FilePath()),
+		Items: items,
+	}
+	idx, _, err := prompt.Run()
+	if err != nil {
+		return nil, err
+	}
+	return ties[idx].Track, nil
 }
 
 func (r *RuleSlice) Compile() error {
@@ -130,7 +252,7 @@ func (r *RuleSlice) Compile() error {
 	for _, it := range r.StringSlice.Value() {
 		rx, err := glob.Compile(it)
 		if err != nil {
-			return errors.Errorf("Invalid matching rule '%': %v", it, err)
+			return errors.Errorf("Invalid matching rule '%s': %v", it, err)
 		}
 		r.rules = append(r.rules, rx)
 	}
@@ -0,0 +1,73 @@
+package diff
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"primetools/cmd"
+	"primetools/pkg/enums"
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+const (
+	FormatFlag = "format"
+	OutputFlag = "output"
+)
+
+var (
+	flags = []cli.Flag{
+		cmd.SourceFlag,
+		cmd.SourcePathFlag,
+		cmd.TargetFlag,
+		cmd.TargetPathFlag,
+		cmd.SourceMapFlag,
+		cmd.TargetMapFlag,
+		&cli.PathFlag{
+			Name:    OutputFlag,
+			Aliases: []string{"o"},
+			Value:   "-",
+		},
+		&cli.GenericFlag{
+			Name:    FormatFlag,
+			Aliases: []string{"f"},
+			Value:   enums.Text.ToCliGeneric(),
+		},
+	}
+)
+
+func Cmd() *cli.Command {
+	return &cli.Command{
+		Name:        "diff",
+		Usage:       cmd.Usage,
+		HideHelp:    true,
+		Description: "compare the tracks, playlists and crates of a source and a target library",
+		Flags:       flags,
+		Action:      exec,
+	}
+}
+
+func exec(context *cli.Context) error {
+	src := cmd.OpenSource(context)
+	defer src.Close()
+
+	tgt := cmd.OpenTarget(context)
+	defer tgt.Close()
+
+	logrus.Info("comparing libraries...")
+
+	diff, err := music.Diff(src, tgt)
+	if err != nil {
+		return errors.Cause(err)
+	}
+
+	logrus.Infof("%d tracks only in source, %d only in target, %d with different metadata, %d playlists and %d crates differ",
+		len(diff.OnlyInSource), len(diff.OnlyInTarget), len(diff.Tracks), len(diff.Playlists), len(diff.Crates))
+
+	output := context.String(OutputFlag)
+	format, _ := context.Generic(FormatFlag).(*enums.FormatType)
+
+	err = files.WriteTo(output, *format, diff)
+	return errors.Cause(err)
+}
@@ -23,6 +23,7 @@ var (
 	flags = []cli.Flag{
 		cmd.SourceFlag,
 		cmd.SourcePathFlag,
+		cmd.WorkersFlag,
 		&cli.PathFlag{
 			Name:    OutputFlag,
 			Aliases: []string{"o"},
@@ -102,7 +103,7 @@ func exec(context *cli.Context) error {
 	case enums.Tracks:
 		logrus.Info("Tracks in library:")
 		tracks := []music.Track{}
-		err = src.ForEachTrack(func(index int, total int, track music.Track) error {
+		err = music.ForEachTrackParallel(src, context.Int(cmd.Workers), nil, func(index int, total int, track music.Track) error {
 			tracks = append(tracks, track)
 			return nil
 		})
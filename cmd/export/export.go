@@ -21,6 +21,7 @@ var (
 		cmd.TargetFlag,
 		cmd.TargetPathFlag,
 		cmd.DryrunFlag,
+		cmd.NoBackupFlag,
 	}
 )
 
@@ -41,6 +42,8 @@ func exec(context *cli.Context) error {
 	tgtlib := cmd.CreateTarget(context)
 	defer tgtlib.Close()
 
+	cmd.Backup(context, cmd.Target, tgtlib)
+
 	target, ok := tgtlib.(music.LibraryExporter)
 	if !ok {
 		return errors.New("target library type doesn't support export")
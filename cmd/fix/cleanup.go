@@ -0,0 +1,156 @@
+package fix
+
+import (
+	"fmt"
+
+	"github.com/manifoldco/promptui"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"primetools/cmd"
+	"primetools/pkg/enums"
+	"primetools/pkg/files"
+	"primetools/pkg/journal"
+	"primetools/pkg/music"
+	"primetools/pkg/music/factory"
+	flib "primetools/pkg/music/files"
+)
+
+/*
+	Remove the tracks whose file is missing from disk and can't be found in
+	the search path, the ones which can are left to 'fix missing'. The tracks
+	whose drive or root folder is missing too are kept, the drive might only
+	be unplugged.
+*/
+func fixOrphans(context *cli.Context, src music.Library, run *journal.Run) error {
+	file, err := factory.Open(enums.File, opts.searchPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if lib, ok := file.(*flib.FileLibrary); ok && opts.fingerprint {
+		lib.EnableFingerprints()
+	}
+	matcher := cmd.NewMatcher(context, file)
+
+	orphans := music.Tracks{}
+	roots := map[string]bool{}
+	unplugged := 0
+	err = src.ForEachTrack(func(index int, total int, track music.Track) error {
+		if files.Exists(track.FilePath()) {
+			return nil
+		}
+		root := files.RootFolder(track.FilePath())
+		if _, ok := roots[root]; !ok {
+			roots[root] = files.Exists(root)
+			if !roots[root] {
+				logrus.Warnf("'%s' doesn't exists, its tracks are kept since the drive might only be unplugged", root)
+			}
+		}
+		if !roots[root] {
+			unplugged++
+			return nil
+		}
+		if len(file.Matches(track)) > 0 || (matcher != nil && len(matcher.Match(track).Ties()) > 0) {
+			logrus.Infof("file '%s' is missing from disk but has a match, see 'fix missing'", track.FilePath())
+			return nil
+		}
+		logrus.Warnf("file '%s' is missing from disk and has no match", track.FilePath())
+		orphans = append(orphans, track)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("found %d orphan tracks, skipped %d tracks whose root folder is missing", len(orphans), unplugged)
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	cleaner, ok := music.AsCleaner(src)
+	if !ok {
+		return errors.Errorf("library '%s' doesn't support removing tracks", src)
+	}
+	if cmd.IsDryRun(context) {
+		logrus.Infof("[DRY] would remove %d tracks", len(orphans))
+		return nil
+	}
+	if !opts.accept && !confirm(fmt.Sprintf("Remove %d tracks from %s, only the backup can restore them", len(orphans), src)) {
+		return nil
+	}
+
+	if err = cleaner.RemoveTracks(orphans); err != nil {
+		return err
+	}
+	for _, it := range orphans {
+		run.TrackRemoved(it)
+	}
+	logrus.Infof("removed %d tracks, only the backup taken before can restore them", len(orphans))
+	return nil
+}
+
+/*
+	Report the broken entries, the empty lists or the detached tracks, then fix
+	them once confirmed. Detached tracks are only fixed for the databases given
+	with --detach, the others might only be on an unplugged drive.
+*/
+func fixReferences(context *cli.Context, src music.Library, typ enums.FixType) error {
+	cleaner, ok := music.AsCleaner(src)
+	if !ok {
+		return errors.Errorf("library '%s' doesn't support fixing %s references", src, typ)
+	}
+
+	detach := context.StringSlice("detach")
+	check := map[enums.FixType]func(fix bool) ([]string, error){
+		enums.Broken: cleaner.BrokenEntries,
+		enums.Empty:  cleaner.EmptyLists,
+		enums.Detached: func(fix bool) ([]string, error) {
+			if !fix {
+				return cleaner.DetachedTracks(nil)
+			}
+			return cleaner.DetachedTracks(detach)
+		},
+	}[typ]
+
+	found, err := check(false)
+	for _, it := range found {
+		logrus.Warnf("%s", it)
+	}
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("found %d %s references", len(found), typ)
+	if len(found) == 0 {
+		return nil
+	}
+	if typ == enums.Detached && len(detach) == 0 {
+		logrus.Infof("the drives of these databases might only be unplugged, give the uuid of the ones which are gone for good with --detach")
+		return nil
+	}
+	if cmd.IsDryRun(context) {
+		logrus.Infof("[DRY] would fix %d %s references", len(found), typ)
+		return nil
+	}
+	if !opts.accept && !confirm(fmt.Sprintf("Fix %d %s references of %s", len(found), typ, src)) {
+		return nil
+	}
+
+	fixed, err := check(true)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("fixed %d %s references", len(fixed), typ)
+	return nil
+}
+
+func confirm(label string) bool {
+	prompt := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+	}
+	_, err := prompt.Run()
+	return err == nil
+}
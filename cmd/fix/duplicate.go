@@ -0,0 +1,272 @@
+package fix
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/manifoldco/promptui"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"primetools/cmd"
+	"primetools/pkg/enums"
+	"primetools/pkg/files"
+	"primetools/pkg/journal"
+	"primetools/pkg/music"
+)
+
+/*
+	Metadata of the duplicates folded into the track which is kept
+*/
+type mergedMeta struct {
+	rating    music.Rating
+	added     time.Time
+	playCount int
+}
+
+/*
+	Report the tracks found more than once in the library and merge each group
+	into one track when the library supports it
+*/
+func fixDuplicates(context *cli.Context, src music.Library, run *journal.Run) error {
+	groups, err := findDuplicates(src)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		logrus.Info("no duplicate file were found")
+		return nil
+	}
+
+	logrus.Infof("found %d duplicates in database", len(groups))
+	for _, group := range groups {
+		logrus.Infof("%d copies of '%s':\n  %s", len(group), group[0], strings.Join(group.Filepaths(), "\n  "))
+	}
+
+	merger, ok := music.AsMerger(src)
+	if !ok {
+		logrus.Warnf("merging duplicates is not supported for %s", src)
+		return nil
+	}
+
+	merged := 0
+	removed := 0
+	for _, group := range groups {
+		survivor := canonicalTrack(group)
+
+		if !opts.accept && !cmd.IsDryRun(context) {
+			items := []string{}
+			for _, it := range group {
+				items = append(items, describeTrack(it))
+			}
+			sprompt := promptui.Select{
+				Label: fmt.Sprintf("Please select the track to keep for '%s'", group[0]),
+				Items: append(items, "skip"),
+			}
+			idx, _, err := sprompt.Run()
+			if err != nil {
+				return err
+			}
+			if idx == len(group) {
+				continue
+			}
+			survivor = group[idx]
+		}
+
+		duplicates := music.Tracks{}
+		for _, it := range group {
+			if it != survivor {
+				duplicates = append(duplicates, it)
+			}
+		}
+		old, meta := foldMeta(survivor, duplicates)
+
+		if cmd.IsDryRun(context) {
+			logrus.Infof("[DRY] would keep '%s' with rating %v, added %s, played %d times and remove:\n  %s",
+				survivor.FilePath(), meta.rating, formatDate(meta.added), meta.playCount, strings.Join(duplicates.Filepaths(), "\n  "))
+			continue
+		}
+
+		if err = merger.MergeTracks(survivor, duplicates); err != nil {
+			logrus.Errorf("fail to merge duplicates of '%s': %v", survivor.FilePath(), err)
+			continue
+		}
+		logrus.Infof("merged %d duplicates into '%s'", len(duplicates), survivor.FilePath())
+		merged++
+		removed += len(duplicates)
+
+		for _, it := range duplicates {
+			run.TrackRemoved(it)
+		}
+		applyMeta(run, survivor, old, meta)
+	}
+
+	logrus.Infof("merged %d duplicates into %d tracks", removed, merged)
+	return nil
+}
+
+/*
+	Group the tracks with the same path, metadata or audio content, a track
+	being in at most one group
+*/
+func findDuplicates(lib music.Library) ([]music.Tracks, error) {
+	tracks := music.Tracks{}
+	err := lib.ForEachTrack(func(index int, total int, track music.Track) error {
+		tracks = append(tracks, track)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// union find of the track indexes, the tracks sharing any key are joined
+	parent := make([]int, len(tracks))
+	for i := range parent {
+		parent[i] = i
+	}
+	root := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	seen := map[string]int{}
+	join := func(key string, i int) {
+		if first, ok := seen[key]; ok {
+			parent[root(i)] = root(first)
+		} else {
+			seen[key] = i
+		}
+	}
+
+	bySize := map[int64][]int{}
+	for i, it := range tracks {
+		join("path:"+strings.ToLower(it.FilePath()), i)
+		// the tracks without any tags would all be the same
+		if it.Title() != "" {
+			join("meta:"+music.TrackHash(it), i)
+		}
+		if size, err := files.AudioSize(it.FilePath()); err == nil {
+			bySize[size] = append(bySize[size], i)
+		}
+	}
+
+	// only the tracks with the same audio size are hashed
+	for _, list := range bySize {
+		if len(list) < 2 {
+			continue
+		}
+		for _, i := range list {
+			if hash := music.ContentHash(tracks[i]); hash != "" {
+				join("content:"+hash, i)
+			}
+		}
+	}
+	if err = files.SaveAudioHashes(); err != nil {
+		logrus.Warnf("%v", err)
+	}
+
+	groups := map[int]music.Tracks{}
+	order := []int{}
+	for i, it := range tracks {
+		r := root(i)
+		if _, ok := groups[r]; !ok {
+			order = append(order, r)
+		}
+		groups[r] = append(groups[r], it)
+	}
+
+	out := []music.Tracks{}
+	for _, r := range order {
+		if len(groups[r]) > 1 {
+			out = append(out, groups[r])
+		}
+	}
+	return out, nil
+}
+
+/*
+	Track kept by default: the one whose file exists, then the best rated, the
+	most played and the earliest added
+*/
+func canonicalTrack(group music.Tracks) music.Track {
+	list := append(music.Tracks{}, group...)
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if ea, eb := files.Exists(a.FilePath()), files.Exists(b.FilePath()); ea != eb {
+			return ea
+		}
+		if a.Rating() != b.Rating() {
+			return a.Rating() > b.Rating()
+		}
+		if a.PlayCount() != b.PlayCount() {
+			return a.PlayCount() > b.PlayCount()
+		}
+		return a.Added().Before(b.Added())
+	})
+	return list[0]
+}
+
+/*
+	Metadata of the track before and after folding the best rating, the
+	earliest added date and the play counts of the duplicates into it
+*/
+func foldMeta(track music.Track, duplicates music.Tracks) (mergedMeta, mergedMeta) {
+	old := mergedMeta{
+		rating:    track.Rating(),
+		added:     track.Added(),
+		playCount: track.PlayCount(),
+	}
+
+	meta := old
+	for _, it := range duplicates {
+		if it.Rating() > meta.rating {
+			meta.rating = it.Rating()
+		}
+		if added := it.Added(); !added.IsZero() && (meta.added.IsZero() || added.Before(meta.added)) {
+			meta.added = added
+		}
+		meta.playCount += it.PlayCount()
+	}
+	return old, meta
+}
+
+func applyMeta(run *journal.Run, track music.Track, old mergedMeta, meta mergedMeta) {
+	apply := func(stype enums.SyncType, old interface{}, new interface{}, write func() error) {
+		if err := write(); err != nil {
+			logrus.Warnf("fail to update %s of '%s': %v", stype, track.FilePath(), err)
+			return
+		}
+		run.TrackChanged(track, stype, old, new)
+	}
+
+	if meta.rating != old.rating {
+		apply(enums.Ratings, old.rating, meta.rating, func() error {
+			return track.SetRating(meta.rating)
+		})
+	}
+	if !meta.added.Equal(old.added) {
+		apply(enums.Added, old.added, meta.added, func() error {
+			return track.SetAdded(meta.added)
+		})
+	}
+	if meta.playCount != old.playCount {
+		apply(enums.PlayCount, old.playCount, meta.playCount, func() error {
+			return track.SetPlayCount(meta.playCount)
+		})
+	}
+}
+
+func describeTrack(track music.Track) string {
+	return fmt.Sprintf("%s (rating %v, played %d times, added %s)", track.FilePath(), track.Rating(), track.PlayCount(), formatDate(track.Added()))
+}
+
+func formatDate(date time.Time) string {
+	if date.IsZero() {
+		return "unknown"
+	}
+	return date.Format(time.RFC822)
+}
@@ -3,6 +3,7 @@ package fix
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
@@ -12,15 +13,20 @@ import (
 	"primetools/cmd"
 	"primetools/pkg/enums"
 	"primetools/pkg/files"
+	"primetools/pkg/fingerprint"
 	"primetools/pkg/music"
 	"primetools/pkg/music/factory"
+	flib "primetools/pkg/music/files"
 )
 
 var (
 	flags = []cli.Flag{
 		cmd.SourceFlag,
 		cmd.SourcePathFlag,
+		cmd.SourceMapFlag,
 		cmd.DryrunFlag,
+		cmd.NoBackupFlag,
+		cmd.ThresholdFlag,
 		&cli.BoolFlag{
 			Name:        "yes",
 			Aliases:     []string{"y"},
@@ -33,11 +39,21 @@ var (
 			Usage:       "path to search for music file",
 			Destination: &opts.searchPath,
 		},
+		&cli.BoolFlag{
+			Name:        "fingerprint",
+			Usage:       "also compare the acoustic fingerprint of mp3, flac and wav files",
+			Destination: &opts.fingerprint,
+		},
+		&cli.StringSliceFlag{
+			Name:  "detach",
+			Usage: "uuid of a database which is gone for good, 'fix detached' only detaches the tracks of these databases",
+		},
 	}
 
 	opts = struct {
-		accept     bool
-		searchPath string
+		accept      bool
+		searchPath  string
+		fingerprint bool
 	}{}
 )
 
@@ -53,7 +69,8 @@ func Cmd() *cli.Command {
 		},
 		Subcommands: cmd.SubCmds(enums.FixTypeNames(), exec, flags, func(cmd *cli.Command) {
 			cmd.Before = func(context *cli.Context) error {
-				if !files.Exists(opts.searchPath) {
+				// only missing files are searched for
+				if searched(cmd.Name) && !files.Exists(opts.searchPath) {
 					return errors.Errorf("search path '%s' doesn't exists", opts.searchPath)
 				}
 				return nil
@@ -66,6 +83,11 @@ func exec(context *cli.Context) error {
 	src := cmd.OpenSource(context)
 	defer src.Close()
 
+	cmd.Backup(context, cmd.Source, src)
+
+	run := cmd.StartJournal(context, cmd.Source, cmd.SourcePath)
+	defer run.Close()
+
 	typ, err := enums.ParseFixType(strings.ToLower(context.Command.Name))
 	if err != nil {
 		return err
@@ -75,34 +97,29 @@ func exec(context *cli.Context) error {
 
 	switch typ {
 	case enums.Duplicate:
-		tracks := map[string]music.Track{}
-		duplicates := map[string][]music.Track{}
-
-		src.ForEachTrack(func(index int, total int, track music.Track) error {
-			path := strings.ToLower(track.FilePath())
-
-			if _, ok := tracks[path]; ok {
-				duplicates[path] = append(duplicates[path], track, tracks[path])
-			}
-			tracks[path] = track
-			return nil
-		})
-
-		if len(duplicates) > 0 {
-			logrus.Infof("found %d duplicates in database", len(duplicates))
-			for _, dup := range duplicates {
-				for _, t := range dup {
-					logrus.Infof("%s", t.FilePath())
-				}
-			}
-		} else {
-			logrus.Info("no duplicate file were found")
+		err = fixDuplicates(context, src, run)
+		if err == nil && opts.fingerprint {
+			err = logSimilarTracks(src)
 		}
+	case enums.Orphan:
+		err = fixOrphans(context, src, run)
+	case enums.Broken, enums.Empty, enums.Detached:
+		err = fixReferences(context, src, typ)
 	case enums.Missing:
+		editor, ok := music.AsEditor(src)
+		if !ok {
+			return errors.Errorf("library '%s' doesn't support moving tracks", src)
+		}
+
 		file, err := factory.Open(enums.File, opts.searchPath)
 		if err != nil {
 			return err
 		}
+		defer file.Close()
+		if lib, ok := file.(*flib.FileLibrary); ok && opts.fingerprint {
+			lib.EnableFingerprints()
+		}
+		matcher := cmd.NewMatcher(context, file)
 
 		err = src.ForEachTrack(func(index int, total int, track music.Track) error {
 			if !files.Exists(track.FilePath()) {
@@ -110,6 +127,17 @@ func exec(context *cli.Context) error {
 
 				matches := file.Matches(track)
 
+				// the tags might have been edited, ie: "ft." instead of "feat."
+				if len(matches) == 0 && matcher != nil {
+					match, err := cmd.PickCandidate(track, matcher.Match(track), opts.accept || cmd.IsDryRun(context))
+					if err != nil {
+						return err
+					}
+					if match != nil {
+						matches = append(matches, match)
+					}
+				}
+
 				if len(matches) > 0 {
 					if len(matches) > 1 {
 						logrus.Infof("found %d matching tracks", len(matches))
@@ -141,7 +169,12 @@ func exec(context *cli.Context) error {
 						return errors.New("invalid match selection")
 					}
 
-					return src.MoveTrack(track, match.FilePath())
+					oldpath := track.FilePath()
+					if err := editor.MoveTrack(track, match.FilePath()); err != nil {
+						return err
+					}
+					run.TrackMoved(oldpath, match.FilePath())
+					return nil
 				} else {
 					logrus.Errorf("could not find a match for '%v'", track)
 				}
@@ -152,3 +185,45 @@ func exec(context *cli.Context) error {
 
 	return err
 }
+
+func searched(name string) bool {
+	return name == strings.ToLower(enums.Missing.String()) || name == strings.ToLower(enums.Orphan.String())
+}
+
+/*
+	Log the tracks which sound the same, ie: the same recording in another format
+*/
+func logSimilarTracks(lib music.Library) error {
+	start := time.Now()
+	logrus.Infof("comparing acoustic fingerprints of %s", lib)
+
+	index := fingerprint.NewIndex()
+	similar := 0
+	err := lib.ForEachTrack(func(i int, total int, track music.Track) error {
+		path := track.FilePath()
+		if !fingerprint.Supported(path) {
+			return nil
+		}
+
+		print, err := fingerprint.Of(path)
+		if err != nil {
+			logrus.Warnf("%v", err)
+			return nil
+		}
+
+		for _, it := range index.Search(print) {
+			if it.Key != path {
+				logrus.Infof("'%s' sounds like '%s' (%.0f%%)", path, it.Key, 100*it.Score)
+				similar++
+			}
+		}
+		index.Add(path, print)
+		return nil
+	})
+
+	if e := fingerprint.SaveCache(); e != nil {
+		logrus.Warnf("%v", e)
+	}
+	logrus.Infof("found %d similar tracks out of %d fingerprints in %v", similar, index.Len(), time.Since(start))
+	return err
+}
@@ -11,6 +11,7 @@ import (
 	"primetools/cmd"
 	"primetools/pkg/enums"
 	"primetools/pkg/files"
+	"primetools/pkg/journal"
 	"primetools/pkg/music"
 )
 
@@ -18,7 +19,10 @@ var (
 	flags = []cli.Flag{
 		cmd.TargetFlag,
 		cmd.TargetPathFlag,
+		cmd.TargetMapFlag,
 		cmd.DryrunFlag,
+		cmd.NoBackupFlag,
+		cmd.ThresholdFlag,
 		&cli.PathFlag{
 			Name:        "source",
 			Aliases:     []string{"s"},
@@ -31,12 +35,12 @@ var (
 			Usage:       "Names of crate/playlist to import, can be glob (*something*), if none is given, will import all object in dump file.",
 			Destination: &opts.rules.StringSlice,
 		},
-		// &cli.BoolFlag{
-		// 	Name:        "yes",
-		// 	Aliases:     []string{"y"},
-		// 	DefaultText: "Do not prompt for write confirmation",
-		// 	Destination: &opts.accept,
-		// },
+		&cli.BoolFlag{
+			Name:        "yes",
+			Aliases:     []string{"y"},
+			Usage:       "Do not prompt to choose between fuzzy matches, use the best one",
+			Destination: &opts.accept,
+		},
 		&cli.BoolFlag{
 			Name:        "ignore-missing",
 			Aliases:     []string{"i"},
@@ -79,6 +83,11 @@ func exec(context *cli.Context) error {
 	target := cmd.OpenTarget(context)
 	defer target.Close()
 
+	cmd.Backup(context, cmd.Target, target)
+
+	run := cmd.StartJournal(context, cmd.Target, cmd.TargetPath)
+	defer run.Close()
+
 	if !files.Exists(opts.source) {
 		return errors.Errorf("file '%s' doesn't exists or is invalid", opts.source)
 	}
@@ -97,8 +106,9 @@ func exec(context *cli.Context) error {
 		return err
 	}
 
+	matcher := cmd.NewMatcher(context, target)
 	for _, list := range lists {
-		err = importList(context, list, target)
+		err = importList(context, run, list, target, matcher)
 		if err != nil {
 			logrus.Errorf("failed to import '%s' '%s': %v", opts.objType, list.Path, err)
 		}
@@ -107,12 +117,12 @@ func exec(context *cli.Context) error {
 	return nil
 }
 
-func importList(context *cli.Context, list music.MarshallTracklist, lib music.Library) error {
+func importList(context *cli.Context, run *journal.Run, list music.MarshallTracklist, lib music.Library, matcher *music.Matcher) error {
 	var err error
 
-	target, ok := lib.(music.LibraryEditor)
+	target, ok := music.AsEditor(lib)
 	if !ok {
-		return errors.Errorf("library type %s doesn't support edition", )
+		return errors.Errorf("library type %s doesn't support edition", lib)
 	}
 
 	if !opts.rules.Match(list.Path) {
@@ -136,14 +146,25 @@ func importList(context *cli.Context, list music.MarshallTracklist, lib music.Li
 		return errors.Errorf("failed to create %s '%s': %v", opts.objType, list.Path, err)
 	}
 
-	oldCount := len(targetList.Tracks())
+	oldTracks := targetList.Tracks()
+	oldCount := len(oldTracks)
 
 	var newList music.Tracks
 
 	for _, track := range list.Tracks {
 		matches := target.Matches(track.Interface())
 
-		// todo: add prompt to choose the match ?
+		// the metadata might differ slightly, ie: "ft." instead of "feat."
+		if len(matches) == 0 && matcher != nil {
+			match, err := cmd.PickCandidate(track.Interface(), matcher.Match(track.Interface()), opts.accept || cmd.IsDryRun(context))
+			if err != nil {
+				return err
+			}
+			if match != nil {
+				matches = append(matches, match)
+			}
+		}
+
 		if len(matches) > 0 {
 			newList = append(newList, matches[0])
 		} else if opts.ignoreNotFound {
@@ -159,6 +180,7 @@ func importList(context *cli.Context, list music.MarshallTracklist, lib music.Li
 		if err != nil {
 			return err
 		}
+		run.TracksChanged(opts.objType, targetList.Path(), oldTracks, newList)
 	} else {
 		msg = "[DRY] " + msg
 	}
@@ -0,0 +1,121 @@
+package relocate
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"primetools/cmd"
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+var (
+	flags = []cli.Flag{
+		cmd.TargetFlag,
+		cmd.TargetPathFlag,
+		&cli.StringFlag{
+			Name:        "from",
+			Usage:       "folder the tracks were moved from",
+			Required:    true,
+			Destination: &opts.from,
+		},
+		&cli.StringFlag{
+			Name:        "to",
+			Usage:       "folder the tracks were moved to",
+			Required:    true,
+			Destination: &opts.to,
+		},
+		cmd.DryrunFlag,
+		cmd.NoBackupFlag,
+	}
+
+	opts = struct {
+		from string
+		to   string
+	}{}
+)
+
+func Cmd() *cli.Command {
+	return &cli.Command{
+		Name:        "relocate",
+		Usage:       cmd.Usage,
+		Description: "move every track of the target library under a folder to another one",
+		Flags:       flags,
+		Action:      exec,
+	}
+}
+
+func exec(context *cli.Context) error {
+	paths, err := files.ParsePathMap([]string{opts.from + "=" + opts.to})
+	if err != nil {
+		return err
+	}
+
+	lib := cmd.OpenTarget(context)
+	defer lib.Close()
+
+	tgt, ok := music.AsEditor(lib)
+	if !ok {
+		return errors.Errorf("target library doesn't support editing")
+	}
+
+	start := time.Now()
+	count := 0
+	moves := []music.TrackMove{}
+	missing := 0
+
+	err = lib.ForEachTrack(func(index int, total int, track music.Track) error {
+		count++
+
+		oldpath := track.FilePath()
+		newpath := paths.Apply(oldpath)
+		if newpath == oldpath {
+			return nil
+		}
+
+		if !files.Exists(newpath) {
+			logrus.Warnf("'%s' doesn't exists, '%s' is not relocated", newpath, oldpath)
+			missing++
+			return nil
+		}
+
+		moves = append(moves, music.TrackMove{Track: track, Path: newpath})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if cmd.IsDryRun(context) {
+		for _, it := range moves {
+			logrus.Infof("[DRY] would move '%s' to '%s'", it.Track.FilePath(), it.Path)
+		}
+	} else if len(moves) > 0 {
+		cmd.Backup(context, cmd.Target, lib)
+
+		run := cmd.StartJournal(context, cmd.Target, cmd.TargetPath)
+		defer run.Close()
+
+		// the tracks report their new path once moved
+		oldpaths := map[music.Track]string{}
+		for _, it := range moves {
+			oldpaths[it.Track] = it.Track.FilePath()
+		}
+
+		// the tracks moved before a failure are journaled to be undone
+		moved, err := music.MoveTracks(tgt, moves)
+		for _, it := range moved {
+			run.TrackMoved(oldpaths[it.Track], it.Path)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "%d of %d tracks relocated", len(moved), len(moves))
+		}
+	}
+
+	logrus.Infof("processed %d tracks, %d relocated, %d missing at their new location, %d outside of '%s', duration: %s",
+		count, len(moves), missing, count-len(moves)-missing, opts.from, time.Since(start))
+	return nil
+}
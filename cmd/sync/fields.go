@@ -0,0 +1,198 @@
+package sync
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"primetools/pkg/enums"
+	"primetools/pkg/journal"
+	"primetools/pkg/music"
+)
+
+// bpm difference under which two tracks are considered to have the same tempo
+const bpmTolerance = 0.01
+
+/*
+	How the values of a synced field are compared and displayed
+*/
+type field struct {
+	name string
+	// value without anything to sync, ie: a track never analyzed
+	empty func(value interface{}) bool
+	equal func(a interface{}, b interface{}) bool
+	// nil for the fields without any order, the highest policy doesn't apply
+	less   func(a interface{}, b interface{}) bool
+	format func(value interface{}) string
+}
+
+var fields = map[enums.SyncType]field{
+	enums.Ratings: {
+		name:  "rating",
+		empty: never,
+		equal: same,
+		less: func(a interface{}, b interface{}) bool {
+			return a.(music.Rating) < b.(music.Rating)
+		},
+		format: plain,
+	},
+	enums.Added: {
+		name:   "added",
+		empty:  never,
+		equal:  sameTime,
+		less:   before,
+		format: date,
+	},
+	enums.Modified: {
+		name:   "modified",
+		empty:  never,
+		equal:  sameTime,
+		less:   before,
+		format: date,
+	},
+	enums.PlayCount: {
+		name:  "play count",
+		empty: never,
+		equal: same,
+		less: func(a interface{}, b interface{}) bool {
+			return a.(int) < b.(int)
+		},
+		format: plain,
+	},
+	enums.BPM: {
+		name: "bpm",
+		empty: func(value interface{}) bool {
+			return value.(float64) <= 0
+		},
+		equal: func(a interface{}, b interface{}) bool {
+			return math.Abs(a.(float64)-b.(float64)) <= bpmTolerance
+		},
+		less: func(a interface{}, b interface{}) bool {
+			return a.(float64) < b.(float64)
+		},
+		format: func(value interface{}) string {
+			return fmt.Sprintf("%.2f", value)
+		},
+	},
+	enums.Key: {
+		name: "key",
+		empty: func(value interface{}) bool {
+			return !value.(music.Key).Valid()
+		},
+		equal:  same,
+		format: plain,
+	},
+	enums.Genre: {
+		name:   "genre",
+		empty:  blank,
+		equal:  same,
+		format: plain,
+	},
+	enums.Comment: {
+		name:   "comment",
+		empty:  blank,
+		equal:  same,
+		format: plain,
+	},
+	enums.Cues: {
+		name: "cues",
+		empty: func(value interface{}) bool {
+			return value.(*music.PerformanceData).Empty()
+		},
+		equal: func(a interface{}, b interface{}) bool {
+			return a.(*music.PerformanceData).Equal(b.(*music.PerformanceData))
+		},
+		format: func(value interface{}) string {
+			data := value.(*music.PerformanceData)
+			return fmt.Sprintf("%d hot cues, %d loops, %d grid markers", len(data.HotCues), len(data.Loops), len(data.BeatGrid))
+		},
+	},
+}
+
+func never(value interface{}) bool {
+	return false
+}
+
+func blank(value interface{}) bool {
+	return value.(string) == ""
+}
+
+func same(a interface{}, b interface{}) bool {
+	return a == b
+}
+
+func sameTime(a interface{}, b interface{}) bool {
+	return a.(time.Time).String() == b.(time.Time).String()
+}
+
+func before(a interface{}, b interface{}) bool {
+	return a.(time.Time).Before(b.(time.Time))
+}
+
+func plain(value interface{}) string {
+	return fmt.Sprintf("%v", value)
+}
+
+func date(value interface{}) string {
+	return value.(time.Time).Format(time.RFC822)
+}
+
+/*
+	Current value of the field, the cues are only readable from performance tracks
+*/
+func readField(track music.Track, stype enums.SyncType) (interface{}, error) {
+	return journal.FieldValue(track, stype)
+}
+
+/*
+	Values of the field on both sides as they are compared, the play counts are
+	compared as played or not when one side only records that
+*/
+func compared(stype enums.SyncType, srct music.Track, track music.Track, sv interface{}, tv interface{}) (interface{}, interface{}) {
+	if stype != enums.PlayCount {
+		return sv, tv
+	}
+	_, sonly := srct.(music.PlayedOnlyTrack)
+	_, tonly := track.(music.PlayedOnlyTrack)
+	if sonly || tonly {
+		return played(sv.(int)), played(tv.(int))
+	}
+	return sv, tv
+}
+
+func played(count int) int {
+	if count > 0 {
+		return 1
+	}
+	return 0
+}
+
+func writeField(track music.Track, stype enums.SyncType, value interface{}) error {
+	switch stype {
+	case enums.Ratings:
+		return track.SetRating(value.(music.Rating))
+	case enums.Added:
+		return track.SetAdded(value.(time.Time))
+	case enums.Modified:
+		return track.SetModified(value.(time.Time))
+	case enums.PlayCount:
+		return track.SetPlayCount(value.(int))
+	case enums.BPM:
+		return track.SetBPM(value.(float64))
+	case enums.Key:
+		return track.SetKey(value.(music.Key))
+	case enums.Genre:
+		return track.SetGenre(value.(string))
+	case enums.Comment:
+		return track.SetComment(value.(string))
+	case enums.Cues:
+		ptrack, ok := track.(music.PerformanceTrack)
+		if !ok {
+			return errors.New("library doesn't support cues")
+		}
+		return ptrack.SetPerformanceData(value.(*music.PerformanceData))
+	}
+	return errors.Errorf("unsupported field %s", stype)
+}
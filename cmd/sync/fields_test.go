@@ -0,0 +1,44 @@
+package sync
+
+import (
+	"testing"
+
+	"primetools/pkg/enums"
+	"primetools/pkg/music"
+)
+
+type countTrack struct {
+	music.Track
+}
+
+type playedTrack struct {
+	music.Track
+}
+
+func (playedTrack) PlayedOnly() {}
+
+func TestComparedPlayCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		srct   music.Track
+		track  music.Track
+		sv, tv int
+		equal  bool
+	}{
+		{"both counted", countTrack{}, countTrack{}, 5, 1, false},
+		{"played target", countTrack{}, playedTrack{}, 5, 1, true},
+		{"played source", playedTrack{}, countTrack{}, 1, 3, true},
+		{"not played target", countTrack{}, playedTrack{}, 5, 0, false},
+		{"never played", countTrack{}, playedTrack{}, 0, 0, true},
+	}
+
+	f := fields[enums.PlayCount]
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+			sv, tv := compared(enums.PlayCount, it.srct, it.track, it.sv, it.tv)
+			if equal := f.equal(sv, tv); equal != it.equal {
+				t.Errorf("%v and %v compared as equal %v", it.sv, it.tv, equal)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package sync
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"primetools/pkg/enums"
+	"primetools/pkg/files"
+)
+
+// folder where the state of the bidirectional syncs is kept
+var StateDir = files.ExpandHomePath("~/.primetools/sync")
+
+/*
+	Values of the fields on both sides at the end of the last bidirectional
+	sync between two libraries, a side whose value differs from it was changed
+	since. Values are only kept as hashes.
+*/
+type state struct {
+	Source  string
+	Target  string
+	Updated time.Time
+	// file path of the track => field => hashes
+	Tracks map[string]map[string]fieldState
+
+	path string
+}
+
+type fieldState struct {
+	Source string
+	Target string
+}
+
+/*
+	State of the last sync between the libraries, empty if they were never synced
+*/
+func loadState(source string, target string) (*state, error) {
+	name := fmt.Sprintf("%x.json", sha1.Sum([]byte(source+"\n"+target)))
+	s := &state{
+		Source: source,
+		Target: target,
+		Tracks: map[string]map[string]fieldState{},
+		path:   filepath.Join(StateDir, name),
+	}
+
+	content, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(content, s)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to read sync state '%s'", s.path)
+	}
+	return s, nil
+}
+
+func (s *state) get(path string, stype enums.SyncType) (fieldState, bool) {
+	it, ok := s.Tracks[path][stype.String()]
+	return it, ok
+}
+
+func (s *state) set(path string, stype enums.SyncType, source interface{}, target interface{}) {
+	if s.Tracks[path] == nil {
+		s.Tracks[path] = map[string]fieldState{}
+	}
+	s.Tracks[path][stype.String()] = fieldState{
+		Source: valueHash(source),
+		Target: valueHash(target),
+	}
+}
+
+func (s *state) save() error {
+	s.Updated = time.Now()
+	content, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "fail to serialize sync state")
+	}
+	if err = os.MkdirAll(StateDir, 0755); err != nil {
+		return errors.Wrapf(err, "fail to create sync state folder '%s'", StateDir)
+	}
+	return files.WriteFileAtomic(s.path, content)
+}
+
+func valueHash(value interface{}) string {
+	content, _ := json.Marshal(value)
+	return fmt.Sprintf("%x", sha1.Sum(content))
+}
@@ -2,15 +2,19 @@ package sync
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
+	gosync "sync"
 	"time"
 
+	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 
 	"primetools/cmd"
 	"primetools/pkg/enums"
+	"primetools/pkg/journal"
 	"primetools/pkg/music"
 )
 
@@ -20,20 +24,74 @@ var (
 		cmd.SourcePathFlag,
 		cmd.TargetFlag,
 		cmd.TargetPathFlag,
+		cmd.SourceMapFlag,
+		cmd.TargetMapFlag,
 		cmd.DryrunFlag,
+		cmd.NoBackupFlag,
+		cmd.WorkersFlag,
 		&cli.BoolFlag{
 			Name: "force",
 			Aliases: []string{"f"},
 			Usage: "force update (don't do any comparaison",
 			Destination: &opts.force,
 		},
+		&cli.BoolFlag{
+			Name:        "bidirectional",
+			Aliases:     []string{"b"},
+			Usage:       "also sync the changes done in the target since the last sync back to the source",
+			Destination: &opts.bidirectional,
+		},
+		&cli.StringSliceFlag{
+			Name:        "fields",
+			Usage:       fmt.Sprintf("fields synced by 'sync all', all of them by default [%s]", strings.Join(enums.SyncTypeNames(), ", ")),
+			Destination: &opts.fields,
+		},
+		&cli.StringSliceFlag{
+			Name:        "policy",
+			Usage:       fmt.Sprintf("how a field which differs is resolved, for all fields or as field=policy [%s]", strings.Join(enums.SyncPolicyNames(), ", ")),
+			Destination: &opts.policies,
+		},
 	}
 
 	opts = struct{
-		force bool
+		force         bool
+		bidirectional bool
+		fields        cli.StringSlice
+		policies      cli.StringSlice
 	}{}
 )
 
+// subcommand syncing several fields at once
+const allFields = "all"
+
+type side int
+
+const (
+	sideNone side = iota
+	sideSource
+	sideTarget
+)
+
+type syncer struct {
+	context  *cli.Context
+	policies map[enums.SyncType]enums.SyncPolicy
+	// journals of the target and, when bidirectional, of the source
+	run  *journal.Run
+	srun *journal.Run
+	// nil unless bidirectional
+	last *state
+
+	// the changes of a track are logged together when syncing several fields
+	combined bool
+	pending  map[side][]string
+	updated  bool
+	nocues   bool
+
+	changed   int
+	errors    int
+	conflicts int
+}
+
 func Cmd() *cli.Command {
 	return &cli.Command{
 		Name: "sync",
@@ -42,116 +100,426 @@ func Cmd() *cli.Command {
 		},
 		Usage:       cmd.Usage,
 		HideHelp:    true,
-		Description: fmt.Sprintf("sync assets from a source to a destination [%s]", strings.Join(enums.SyncTypeNames(), ", ")),
-		Subcommands: cmd.SubCmds(enums.SyncTypeNames(), exec, flags, nil),
+		Description: fmt.Sprintf("sync assets from a source to a destination [%s]", strings.Join(append(enums.SyncTypeNames(), "All"), ", ")),
+		Subcommands: cmd.SubCmds(append(enums.SyncTypeNames(), "All"), exec, flags, nil),
 		Flags:       flags,
 	}
 }
 
 func exec(context *cli.Context) error {
+	stypes, err := syncTypes(context.Command.Name, opts.fields.Value())
+	if err != nil {
+		return err
+	}
+
+	s := &syncer{
+		context:  context,
+		combined: len(stypes) > 1,
+		pending:  map[side][]string{},
+	}
+	if s.policies, err = parsePolicies(opts.policies.Value()); err != nil {
+		return err
+	}
+
 	src := cmd.OpenSource(context)
 	defer src.Close()
 
 	tgt := cmd.OpenTarget(context)
 	defer tgt.Close()
 
+	cmd.Backup(context, cmd.Target, tgt)
+
+	s.run = cmd.StartJournal(context, cmd.Target, cmd.TargetPath)
+	defer s.run.Close()
+
+	if opts.bidirectional {
+		cmd.Backup(context, cmd.Source, src)
+
+		s.srun = cmd.StartJournal(context, cmd.Source, cmd.SourcePath)
+		defer s.srun.Close()
+
+		s.last, err = loadState(libraryName(context, cmd.Source, cmd.SourcePath), libraryName(context, cmd.Target, cmd.TargetPath))
+		if err != nil {
+			return err
+		}
+	}
+
 	count := 0
 	notfound := 0
-	changed := 0
-	errorsc := 0
 
 	start := time.Now()
 
-	err := tgt.ForEachTrack(func(index int, total int, track music.Track) error {
-		count++
+	// the source tracks are looked up and loaded by the workers
+	sources := gosync.Map{}
+	prefetch := func(index int, total int, track music.Track) error {
+		if srct := src.Track(track.FilePath()); srct != nil {
+			music.Load(srct)
+			sources.Store(index, srct)
+		}
+		return nil
+	}
 
-		// if strings.Contains(track.FilePath(), "hunger") {
-		// 	logrus.Print(track.String())
-		// }
+	err = music.ForEachTrackParallel(tgt, context.Int(cmd.Workers), prefetch, func(index int, total int, track music.Track) error {
+		count++
 
-		srct := src.Track(track.FilePath())
+		var srct music.Track
+		if it, ok := sources.Load(index); ok {
+			srct = it.(music.Track)
+			sources.Delete(index)
+		}
 		if srct == nil {
 			logrus.Warnf("not match found for '%s' in %v", track, cmd.SourceFlag.Value)
 			notfound++
 			return nil
 		}
 
-		stype, err := enums.ParseSyncType(context.Command.Name)
+		s.updated = false
+		for _, stype := range stypes {
+			if err := s.syncField(stype, srct, track); err != nil {
+				return err
+			}
+		}
+		s.flush(track)
+
+		if s.updated {
+			s.changed++
+		}
+		return nil
+	})
+
+	if s.last != nil && !cmd.IsDryRun(context) {
+		if e := s.last.save(); e != nil {
+			logrus.Errorf("%v", e)
+		}
+	}
+
+	logrus.Infof("processed %d files, %d updated, %d skipped, %d errors, %d not found, %d conflicts, duration: %s",
+		count, s.changed, count-s.changed-notfound, s.errors, notfound, s.conflicts, time.Since(start))
+	return err
+}
+
+/*
+	Fields synced by the command, 'all' syncs the --fields or every field
+*/
+func syncTypes(command string, names []string) ([]enums.SyncType, error) {
+	if command != allFields {
+		if len(names) > 0 {
+			return nil, errors.Errorf("--fields is only supported by 'sync %s'", allFields)
+		}
+		stype, err := enums.ParseSyncType(command)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		return []enums.SyncType{stype}, nil
+	}
 
-		switch stype {
-		case enums.Modified:
-			if opts.force || srct.Modified().String() != track.Modified().String() {
-				changed++
-				msg := fmt.Sprintf("updating modified for '%s': %v => %v", track, track.Modified().Format(time.RFC822), srct.Modified().Format(time.RFC822))
-				if !cmd.IsDryRun(context) {
-					logrus.Info(msg)
-					err := track.SetModified(srct.Modified())
-					if err != nil {
-						logrus.Errorf("failed to sync modified date for '%s': %v", srct.Title(), err)
-						errorsc++
-					}
-				} else {
-					logrus.Info("[DRY] ", msg)
-				}
-			}
-		case enums.Added:
-			// left := srct.Added().String()
-			// right := track.Added().String()
-			// println(left, right)
-			if opts.force || srct.Added().String() != track.Added().String() {
-				changed++
-				msg := fmt.Sprintf("updating added for '%s': %v => %v", track, track.Added().Format(time.RFC822), srct.Added().Format(time.RFC822))
-				if !cmd.IsDryRun(context) {
-					logrus.Info(msg)
-					err := track.SetAdded(srct.Added())
-					if err != nil {
-						errorsc++
-						logrus.Errorf("failed to sync added date for '%s': %v", srct.Title(), err)
-					}
-				} else {
-					logrus.Info("[DRY] ", msg)
+	if len(names) == 0 {
+		names = enums.SyncTypeNames()
+	}
+
+	out := []enums.SyncType{}
+	seen := map[enums.SyncType]bool{}
+	for _, it := range strings.Split(strings.Join(names, ","), ",") {
+		stype, err := enums.ParseSyncType(strings.ToLower(strings.TrimSpace(it)))
+		if err != nil {
+			return nil, err
+		}
+		if !seen[stype] {
+			seen[stype] = true
+			out = append(out, stype)
+		}
+	}
+	return out, nil
+}
+
+/*
+	Policy of every field, values are either a policy for all the fields or
+	field=policy. Ratings keep the highest value by default, the other fields
+	take the source value unless the sync is bidirectional where play counts
+	keep the highest value and the most recently modified track wins for the
+	others.
+*/
+func parsePolicies(values []string) (map[enums.SyncType]enums.SyncPolicy, error) {
+	out := map[enums.SyncType]enums.SyncPolicy{}
+	for stype := range fields {
+		switch {
+		case stype == enums.Ratings:
+			out[stype] = enums.Highest
+		case stype == enums.PlayCount && opts.bidirectional:
+			out[stype] = enums.Highest
+		case opts.bidirectional:
+			out[stype] = enums.Newest
+		default:
+			out[stype] = enums.SourceWins
+		}
+	}
+
+	for _, it := range values {
+		name, value := "", it
+		if idx := strings.Index(it, "="); idx >= 0 {
+			name, value = it[:idx], it[idx+1:]
+		}
+
+		policy, err := enums.ParseSyncPolicy(strings.ToLower(value))
+		if err != nil {
+			return nil, err
+		}
+
+		if name == "" {
+			for stype, f := range fields {
+				// only the ordered fields can keep the highest value
+				if policy != enums.Highest || f.less != nil {
+					out[stype] = policy
 				}
 			}
-		case enums.PlayCount:
-			if opts.force || srct.PlayCount() != track.PlayCount() {
-				changed++
-				msg := fmt.Sprintf("updating play count for '%s': %v => %v", track, srct.PlayCount(), srct.PlayCount())
-				if !cmd.IsDryRun(context) {
-					logrus.Info(msg)
-					err := track.SetPlayCount(srct.PlayCount())
-					if err != nil {
-						errorsc++
-						logrus.Errorf("failed to sync playcount for '%s': %v", srct.Title(), err)
-					}
-				} else {
-					logrus.Info("[DRY] ", msg)
-				}
+			continue
+		}
+
+		stype, err := enums.ParseSyncType(strings.ToLower(name))
+		if err != nil {
+			return nil, err
+		}
+		if policy == enums.Highest && fields[stype].less == nil {
+			return nil, errors.Errorf("%s policy is not supported for %s", policy, stype)
+		}
+		out[stype] = policy
+	}
+	return out, nil
+}
+
+/*
+	Name of the library in the sync state, its type and path
+*/
+func libraryName(context *cli.Context, flag string, pathflag string) string {
+	name := context.String(flag)
+	if ltype, err := enums.ParseLibraryType(name); err == nil {
+		name = ltype.String()
+	}
+	path := context.String(pathflag)
+	if abs, err := filepath.Abs(path); path != "" && err == nil {
+		path = abs
+	}
+	return name + ":" + path
+}
+
+func (s *syncer) syncField(stype enums.SyncType, srct music.Track, track music.Track) error {
+	f := fields[stype]
+
+	if stype == enums.Cues {
+		if s.nocues {
+			return nil
+		}
+		err := supportsCues(srct, track)
+		if err != nil && s.combined {
+			// the other fields are still synced
+			logrus.Warnf("%v, skipping cues", err)
+			s.nocues = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	sv, err := readField(srct, stype)
+	if err != nil {
+		s.errors++
+		logrus.Errorf("failed to read %s for '%s': %v", f.name, srct.Title(), err)
+		return nil
+	}
+	tv, err := readField(track, stype)
+	if err != nil {
+		s.errors++
+		logrus.Errorf("failed to read %s for '%s': %v", f.name, track.Title(), err)
+		return nil
+	}
+	// the values written and journaled are the ones read
+	cs, ct := compared(stype, srct, track, sv, tv)
+
+	winner, err := s.winner(stype, srct, track, cs, ct)
+	if err != nil {
+		return err
+	}
+
+	switch winner {
+	case sideSource:
+		if !s.update(track, stype, tv, sv, s.run, sideTarget) {
+			return nil
+		}
+	case sideTarget:
+		if !s.update(srct, stype, sv, tv, s.srun, sideSource) {
+			return nil
+		}
+	default:
+		if !f.equal(cs, ct) {
+			return nil
+		}
+	}
+
+	// remember the values on both sides once they are in sync
+	if s.last != nil && !cmd.IsDryRun(s.context) {
+		sv, serr := readField(srct, stype)
+		tv, terr := readField(track, stype)
+		if serr == nil && terr == nil {
+			sv, tv = compared(stype, srct, track, sv, tv)
+			s.last.set(track.FilePath(), stype, sv, tv)
+		}
+	}
+	return nil
+}
+
+func supportsCues(srct music.Track, track music.Track) error {
+	if _, ok := srct.(music.PerformanceTrack); !ok {
+		return errors.Errorf("source library doesn't support cues")
+	}
+	if _, ok := track.(music.PerformanceTrack); !ok {
+		return errors.Errorf("target library doesn't support cues")
+	}
+	return nil
+}
+
+/*
+	Side whose value is kept, none if there is nothing to update
+*/
+func (s *syncer) winner(stype enums.SyncType, srct music.Track, track music.Track, sv interface{}, tv interface{}) (side, error) {
+	f := fields[stype]
+
+	if opts.force && s.last == nil {
+		if f.empty(sv) {
+			return sideNone, nil
+		}
+		return sideSource, nil
+	}
+
+	if f.equal(sv, tv) {
+		return sideNone, nil
+	}
+
+	keep := func(winner side) side {
+		value := sv
+		if winner == sideTarget {
+			value = tv
+			// the source is only written by a bidirectional sync
+			if s.last == nil {
+				return sideNone
 			}
-		case enums.Ratings:
-			if opts.force || srct.Rating() > track.Rating() {
-				changed++
-				msg := fmt.Sprintf("updating rating for '%s': %v => %v", track, track.Rating(), srct.Rating())
-				if !cmd.IsDryRun(context) {
-					logrus.Info(msg)
-					err := track.SetRating(srct.Rating())
-					if err != nil {
-						errorsc++
-						logrus.Errorf("failed to sync rating for '%s': %v", srct.Title(), err)
-					}
-				} else {
-					logrus.Info("[DRY] ", msg)
-				}
+		}
+		if f.empty(value) {
+			return sideNone
+		}
+		return winner
+	}
+
+	// a value which didn't change since the last sync is replaced by the other one
+	if s.last != nil && !opts.force {
+		if last, ok := s.last.get(track.FilePath(), stype); ok {
+			schanged := valueHash(sv) != last.Source
+			tchanged := valueHash(tv) != last.Target
+			switch {
+			case schanged && !tchanged:
+				return keep(sideSource), nil
+			case tchanged && !schanged:
+				return keep(sideTarget), nil
 			}
+			// changed on both sides
+			s.conflicts++
+		}
+	}
 
+	switch s.policies[stype] {
+	case enums.SourceWins:
+		return keep(sideSource), nil
+	case enums.TargetWins:
+		return keep(sideTarget), nil
+	case enums.Highest:
+		if f.less(tv, sv) {
+			return keep(sideSource), nil
+		}
+		return keep(sideTarget), nil
+	case enums.Newest:
+		smod, tmod := srct.Modified(), track.Modified()
+		switch {
+		case smod.After(tmod):
+			return keep(sideSource), nil
+		case tmod.After(smod):
+			return keep(sideTarget), nil
+		}
+		logrus.Warnf("%s of '%s' differs but both tracks were modified at %s, skipping", f.name, track, tmod.Format(time.RFC822))
+		return sideNone, nil
+	case enums.Prompt:
+		if cmd.IsDryRun(s.context) {
+			logrus.Infof("[DRY] %s of '%s' differs: %s (source) / %s (target)", f.name, track, f.format(sv), f.format(tv))
+			return sideNone, nil
+		}
+		prompt := promptui.Select{
+			Label: fmt.Sprintf("%s of '%s' differs", f.name, track),
+			Items: []string{
+				fmt.Sprintf("keep source: %s", f.format(sv)),
+				fmt.Sprintf("keep target: %s", f.format(tv)),
+				"skip",
+			},
+		}
+		idx, _, err := prompt.Run()
+		if err != nil {
+			return sideNone, err
 		}
+		return keep([]side{sideSource, sideTarget, sideNone}[idx]), nil
+	}
+	return sideNone, nil
+}
 
-		return nil
-	})
+/*
+	Write the value into the track, false if it failed or in read only mode
+*/
+func (s *syncer) update(track music.Track, stype enums.SyncType, old interface{}, value interface{}, run *journal.Run, where side) bool {
+	f := fields[stype]
+	s.updated = true
 
-	logrus.Infof("processed %d files, %d updated, %d skipped, %d errors, %d not found, duration: %s",
-		count, changed, count-changed-notfound, errorsc, notfound, time.Since(start))
-	return err
+	if s.combined {
+		s.pending[where] = append(s.pending[where], fmt.Sprintf("%s %s => %s", f.name, f.format(old), f.format(value)))
+	} else {
+		msg := fmt.Sprintf("updating %s for '%s'%s: %s => %s", f.name, track, where.suffix(), f.format(old), f.format(value))
+		if cmd.IsDryRun(s.context) {
+			logrus.Info("[DRY] ", msg)
+		} else {
+			logrus.Info(msg)
+		}
+	}
+
+	if cmd.IsDryRun(s.context) {
+		return false
+	}
+	if err := writeField(track, stype, value); err != nil {
+		s.errors++
+		logrus.Errorf("failed to sync %s for '%s': %v", f.name, track.Title(), err)
+		return false
+	}
+	run.TrackChanged(track, stype, old, value)
+	return true
+}
+
+/*
+	Log the changes of all the fields of the track at once
+*/
+func (s *syncer) flush(track music.Track) {
+	prefix := ""
+	if cmd.IsDryRun(s.context) {
+		prefix = "[DRY] "
+	}
+	for _, where := range []side{sideTarget, sideSource} {
+		if changes := s.pending[where]; len(changes) > 0 {
+			logrus.Infof("%supdating '%s'%s: %s", prefix, track, where.suffix(), strings.Join(changes, ", "))
+		}
+		delete(s.pending, where)
+	}
+}
+
+/*
+	Suffix of the messages about the changes done to a side
+*/
+func (w side) suffix() string {
+	if w == sideSource {
+		return " in source"
+	}
+	return ""
 }
@@ -0,0 +1,112 @@
+package undo
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"primetools/cmd"
+	"primetools/pkg/backup"
+	"primetools/pkg/journal"
+	"primetools/pkg/music/factory"
+)
+
+var (
+	flags = []cli.Flag{
+		cmd.DryrunFlag,
+		cmd.NoBackupFlag,
+		&cli.BoolFlag{
+			Name:        "list",
+			Aliases:     []string{"l"},
+			Usage:       "list the journals which can be undone",
+			Destination: &opts.list,
+		},
+	}
+
+	opts = struct {
+		list bool
+	}{}
+)
+
+func Cmd() *cli.Command {
+	return &cli.Command{
+		Name:        "undo",
+		Usage:       cmd.Usage,
+		ArgsUsage:   "[run id]",
+		Description: fmt.Sprintf("revert the changes of a sync, fix or import run, the last one if no id is given, journals are stored in '%s'", journal.Dir),
+		Flags:       flags,
+		Action:      exec,
+	}
+}
+
+func exec(context *cli.Context) error {
+	if opts.list {
+		return list()
+	}
+
+	if context.Args().Len() > 1 {
+		return errors.New("expected at most one run id, see 'undo --list'")
+	}
+
+	run, err := journal.Find(context.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if err = run.Revertible(); err != nil {
+		return err
+	}
+	changes, err := run.Changes()
+	if err != nil {
+		return err
+	}
+
+	if cmd.IsDryRun(context) {
+		for idx := len(changes) - 1; idx >= 0; idx-- {
+			it := changes[idx]
+			logrus.Infof("[DRY] would restore %s of '%s%s' to %s", it.Field, it.Track, it.List, string(it.Old))
+		}
+		return nil
+	}
+
+	logrus.Infof("reverting %d changes of '%s'", len(changes), run)
+
+	lib, err := factory.Open(run.Library, run.Path)
+	if err != nil {
+		return errors.Wrapf(err, "fail to open %s library", run.Library)
+	}
+	defer lib.Close()
+
+	if !context.Bool(cmd.NoBackup) {
+		if _, err = backup.Snapshot(lib, run.Library.String()); err != nil {
+			return err
+		}
+	}
+
+	undo, err := journal.Start(context.Command.FullName()+" "+run.Id, run.Library, run.Path)
+	if err != nil {
+		return err
+	}
+	defer undo.Close()
+
+	return run.Revert(lib, undo)
+}
+
+func list() error {
+	runs, err := journal.List()
+	if err != nil {
+		return err
+	}
+
+	if len(runs) == 0 {
+		logrus.Infof("no journal found in '%s'", journal.Dir)
+		return nil
+	}
+
+	for _, it := range runs {
+		logrus.Info(it.String())
+	}
+	return nil
+}
@@ -11,11 +11,18 @@ import (
 
 	"primetools/cmd"
 	"primetools/cmd/add"
+	"primetools/cmd/backup"
+	"primetools/cmd/diff"
 	"primetools/cmd/dump"
 	"primetools/cmd/fix"
 	_import "primetools/cmd/import"
+	"primetools/cmd/relocate"
 	"primetools/cmd/sync"
 	"primetools/cmd/test"
+	"primetools/cmd/undo"
+	"primetools/pkg/files"
+	"primetools/pkg/fingerprint"
+	flib "primetools/pkg/music/files"
 )
 
 func main() {
@@ -38,6 +45,19 @@ func main() {
 			_import.Cmd(),
 			test.Cmd(),
 			export.Cmd(),
+			backup.Cmd(),
+			undo.Cmd(),
+			diff.Cmd(),
+			relocate.Cmd(),
+		},
+		After: func(context *cli.Context) error {
+			if err := fingerprint.SaveCache(); err != nil {
+				logrus.Warnf("%v", err)
+			}
+			if err := flib.SaveIndex(); err != nil {
+				logrus.Warnf("%v", err)
+			}
+			return files.SaveAudioHashes()
 		},
 		// Before: func(context *cli.Context) error {
 		// 	if context.Bool(cmd.Dryrun) {
@@ -0,0 +1,329 @@
+package backup
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+const (
+	manifestName = "manifest.json"
+	archiveExt   = ".zip"
+	idFormat     = "20060102-150405"
+)
+
+// folder where the archives are stored
+var Dir = files.ExpandHomePath("~/.primetools/backups")
+
+// sqlite keeps uncommitted pages next to the database
+var sqliteCompanions = []string{"-wal", "-shm", "-journal"}
+
+type Manifest struct {
+	Library string
+	Created time.Time
+	Files   []Entry
+}
+
+/*
+	File stored in the archive under Name, restored at Path
+*/
+type Entry struct {
+	Name string
+	Path string
+	Size int64
+}
+
+type Archive struct {
+	Id   string
+	Path string
+	Manifest
+}
+
+/*
+	Copy every existing database file of the library into a timestamped archive,
+	return nil when the library doesn't have anything to backup
+*/
+func Snapshot(lib music.Library, name string) (*Archive, error) {
+	flib, ok := music.AsFiles(lib)
+	if !ok {
+		logrus.Infof("library '%s' doesn't have any database file to backup", name)
+		return nil, nil
+	}
+
+	return SnapshotFiles(flib.DatabaseFiles(), name)
+}
+
+/*
+	Copy the files which exists into a timestamped archive, return nil when none exists
+*/
+func SnapshotFiles(paths []string, name string) (*Archive, error) {
+	paths = existingFiles(paths)
+	if len(paths) == 0 {
+		logrus.Infof("library '%s' doesn't have any database file to backup", name)
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "fail to create backup folder '%s'", Dir)
+	}
+
+	archive := &Archive{
+		Id: time.Now().Format(idFormat) + "-" + strings.ToLower(name),
+		Manifest: Manifest{
+			Library: name,
+			Created: time.Now(),
+		},
+	}
+	archive.Path = filepath.Join(Dir, archive.Id+archiveExt)
+	for idx := 1; files.Exists(archive.Path); idx++ {
+		archive.Id = fmt.Sprintf("%s-%s-%d", time.Now().Format(idFormat), strings.ToLower(name), idx)
+		archive.Path = filepath.Join(Dir, archive.Id+archiveExt)
+	}
+
+	logrus.Infof("backing up %d files of library '%s' into '%s'", len(paths), name, archive.Path)
+
+	if err := archive.write(paths); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+/*
+	All archives of the backup folder, the oldest first
+*/
+func List() ([]*Archive, error) {
+	matches, err := filepath.Glob(filepath.Join(Dir, "*"+archiveExt))
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to list backup folder '%s'", Dir)
+	}
+
+	out := []*Archive{}
+	for _, it := range matches {
+		archive, err := open(it)
+		if err != nil {
+			logrus.Warnf("ignoring invalid backup '%s': %v", it, err)
+			continue
+		}
+		out = append(out, archive)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Created.Before(out[j].Created)
+	})
+	return out, nil
+}
+
+func Find(id string) (*Archive, error) {
+	path := filepath.Join(Dir, strings.TrimSuffix(id, archiveExt)+archiveExt)
+	if !files.Exists(path) {
+		return nil, errors.Errorf("backup '%s' doesn't exists in '%s'", id, Dir)
+	}
+	return open(path)
+}
+
+/*
+	Write back every file of the archive at its original location, the stale
+	sqlite journals are removed so they don't get replayed over the restored database
+*/
+func (a *Archive) Restore() error {
+	reader, err := zip.OpenReader(a.Path)
+	if err != nil {
+		return errors.Wrapf(err, "fail to open backup '%s'", a.Path)
+	}
+	defer reader.Close()
+
+	byName := map[string]*zip.File{}
+	for _, it := range reader.File {
+		byName[it.Name] = it
+	}
+
+	restored := map[string]bool{}
+	for _, it := range a.Files {
+		restored[it.Path] = true
+	}
+
+	for _, it := range a.Files {
+		file, ok := byName[it.Name]
+		if !ok {
+			return errors.Errorf("backup '%s' is missing file '%s'", a.Id, it.Name)
+		}
+
+		content, err := readZipFile(file)
+		if err != nil {
+			return errors.Wrapf(err, "fail to read '%s' from backup '%s'", it.Name, a.Id)
+		}
+
+		logrus.Infof("restoring '%s'", it.Path)
+		if err = os.MkdirAll(filepath.Dir(it.Path), 0755); err != nil {
+			return errors.Wrapf(err, "fail to create folder for '%s'", it.Path)
+		}
+		if err = files.WriteFileAtomic(it.Path, content); err != nil {
+			return err
+		}
+
+		for _, suffix := range sqliteCompanions {
+			companion := it.Path + suffix
+			if !restored[companion] && files.Exists(companion) {
+				if err = os.Remove(companion); err != nil {
+					return errors.Wrapf(err, "fail to remove stale file '%s'", companion)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Archive) Paths() []string {
+	out := []string{}
+	for _, it := range a.Files {
+		out = append(out, it.Path)
+	}
+	return out
+}
+
+func (a *Archive) String() string {
+	return fmt.Sprintf("%s: %s, %d files, %s", a.Id, a.Library, len(a.Files), a.Created.Format(time.RFC822))
+}
+
+/*
+	Write the archive, it is removed on failure so a partial one is never
+	listed nor restored
+*/
+func (a *Archive) write(paths []string) (err error) {
+	out, err := os.Create(a.Path)
+	if err != nil {
+		return errors.Wrapf(err, "fail to create backup '%s'", a.Path)
+	}
+	defer func() {
+		if cerr := out.Close(); cerr != nil && err == nil {
+			err = errors.Wrapf(cerr, "fail to write backup '%s'", a.Path)
+		}
+		if err != nil {
+			os.Remove(a.Path)
+		}
+	}()
+
+	writer := zip.NewWriter(out)
+
+	for idx, path := range paths {
+		entry := Entry{
+			Name: fmt.Sprintf("%03d_%s", idx, filepath.Base(path)),
+			Path: path,
+			Size: files.Size(path),
+		}
+		if err = addZipFile(writer, entry); err != nil {
+			return err
+		}
+		a.Files = append(a.Files, entry)
+	}
+
+	content, err := json.MarshalIndent(a.Manifest, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "fail to serialize backup manifest")
+	}
+	mwriter, err := writer.Create(manifestName)
+	if err != nil {
+		return errors.Wrapf(err, "fail to write backup '%s'", a.Path)
+	}
+	if _, err = mwriter.Write(content); err != nil {
+		return errors.Wrapf(err, "fail to write backup '%s'", a.Path)
+	}
+
+	if err = writer.Close(); err != nil {
+		return errors.Wrapf(err, "fail to write backup '%s'", a.Path)
+	}
+	return nil
+}
+
+func open(path string) (*Archive, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to open backup '%s'", path)
+	}
+	defer reader.Close()
+
+	archive := &Archive{
+		Id:   strings.TrimSuffix(filepath.Base(path), archiveExt),
+		Path: path,
+	}
+
+	for _, it := range reader.File {
+		if it.Name != manifestName {
+			continue
+		}
+		content, err := readZipFile(it)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fail to read manifest of backup '%s'", path)
+		}
+		if err = json.Unmarshal(content, &archive.Manifest); err != nil {
+			return nil, errors.Wrapf(err, "fail to parse manifest of backup '%s'", path)
+		}
+		return archive, nil
+	}
+	return nil, errors.Errorf("backup '%s' doesn't have a manifest", path)
+}
+
+func addZipFile(writer *zip.Writer, entry Entry) error {
+	in, err := os.Open(entry.Path)
+	if err != nil {
+		return errors.Wrapf(err, "fail to open '%s' for backup", entry.Path)
+	}
+	defer in.Close()
+
+	out, err := writer.Create(entry.Name)
+	if err != nil {
+		return errors.Wrapf(err, "fail to add '%s' to backup", entry.Path)
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		return errors.Wrapf(err, "fail to add '%s' to backup", entry.Path)
+	}
+	return nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	in, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return ioutil.ReadAll(in)
+}
+
+/*
+	Absolute path of files which exists with their sqlite companions, without duplicates
+*/
+func existingFiles(paths []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+
+	add := func(path string) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if !seen[path] && files.Exists(path) && !files.IsDir(path) {
+			seen[path] = true
+			out = append(out, path)
+		}
+	}
+
+	for _, it := range paths {
+		add(it)
+		for _, suffix := range sqliteCompanions {
+			add(it + suffix)
+		}
+	}
+	return out
+}
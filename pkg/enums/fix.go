@@ -6,6 +6,10 @@ package enums
 ENUM(
 	Duplicate
 	Missing
+	Orphan
+	Broken
+	Empty
+	Detached
 )
 */
 type FixType int
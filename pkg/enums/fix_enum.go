@@ -14,13 +14,25 @@ const (
 	Duplicate FixType = iota
 	// Missing is a FixType of type Missing
 	Missing
+	// Orphan is a FixType of type Orphan
+	Orphan
+	// Broken is a FixType of type Broken
+	Broken
+	// Empty is a FixType of type Empty
+	Empty
+	// Detached is a FixType of type Detached
+	Detached
 )
 
-const _FixTypeName = "DuplicateMissing"
+const _FixTypeName = "DuplicateMissingOrphanBrokenEmptyDetached"
 
 var _FixTypeNames = []string{
 	_FixTypeName[0:9],
 	_FixTypeName[9:16],
+	_FixTypeName[16:22],
+	_FixTypeName[22:28],
+	_FixTypeName[28:33],
+	_FixTypeName[33:41],
 }
 
 // FixTypeNames returns a list of possible string values of FixType.
@@ -33,6 +45,10 @@ func FixTypeNames() []string {
 var _FixTypeMap = map[FixType]string{
 	0: _FixTypeName[0:9],
 	1: _FixTypeName[9:16],
+	2: _FixTypeName[16:22],
+	3: _FixTypeName[22:28],
+	4: _FixTypeName[28:33],
+	5: _FixTypeName[33:41],
 }
 
 // String implements the Stringer interface.
@@ -44,10 +60,18 @@ func (x FixType) String() string {
 }
 
 var _FixTypeValue = map[string]FixType{
-	_FixTypeName[0:9]:                   0,
-	strings.ToLower(_FixTypeName[0:9]):  0,
-	_FixTypeName[9:16]:                  1,
-	strings.ToLower(_FixTypeName[9:16]): 1,
+	_FixTypeName[0:9]:                    0,
+	strings.ToLower(_FixTypeName[0:9]):   0,
+	_FixTypeName[9:16]:                   1,
+	strings.ToLower(_FixTypeName[9:16]):  1,
+	_FixTypeName[16:22]:                  2,
+	strings.ToLower(_FixTypeName[16:22]): 2,
+	_FixTypeName[22:28]:                  3,
+	strings.ToLower(_FixTypeName[22:28]): 3,
+	_FixTypeName[28:33]:                  4,
+	strings.ToLower(_FixTypeName[28:33]): 4,
+	_FixTypeName[33:41]:                  5,
+	strings.ToLower(_FixTypeName[33:41]): 5,
 }
 
 // ParseFixType attempts to convert a string to a FixType
@@ -9,10 +9,6 @@ import (
 
 //go:generate go-enum -f=$GOFILE --marshal --names --lower --noprefix --sql
 
-// todo: add Eventually these
-//	Traktor
-//  Serato
-
 /*
 ENUM(
 	ITunes
@@ -21,6 +17,9 @@ ENUM(
 	Rekordbox
 	EngineDJ
 	Traktor
+	Serato
+	Mixxx
+	PlaylistFile
 )
 */
 type LibraryType int
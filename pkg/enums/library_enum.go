@@ -23,9 +23,15 @@ const (
 	EngineDJ
 	// Traktor is a LibraryType of type Traktor.
 	Traktor
+	// Serato is a LibraryType of type Serato.
+	Serato
+	// Mixxx is a LibraryType of type Mixxx.
+	Mixxx
+	// PlaylistFile is a LibraryType of type PlaylistFile.
+	PlaylistFile
 )
 
-const _LibraryTypeName = "ITunesPRIMEFileRekordboxEngineDJTraktor"
+const _LibraryTypeName = "ITunesPRIMEFileRekordboxEngineDJTraktorSeratoMixxxPlaylistFile"
 
 var _LibraryTypeNames = []string{
 	_LibraryTypeName[0:6],
@@ -34,6 +40,9 @@ var _LibraryTypeNames = []string{
 	_LibraryTypeName[15:24],
 	_LibraryTypeName[24:32],
 	_LibraryTypeName[32:39],
+	_LibraryTypeName[39:45],
+	_LibraryTypeName[45:50],
+	_LibraryTypeName[50:62],
 }
 
 // LibraryTypeNames returns a list of possible string values of LibraryType.
@@ -50,6 +59,9 @@ var _LibraryTypeMap = map[LibraryType]string{
 	3: _LibraryTypeName[15:24],
 	4: _LibraryTypeName[24:32],
 	5: _LibraryTypeName[32:39],
+	6: _LibraryTypeName[39:45],
+	7: _LibraryTypeName[45:50],
+	8: _LibraryTypeName[50:62],
 }
 
 // String implements the Stringer interface.
@@ -73,6 +85,12 @@ var _LibraryTypeValue = map[string]LibraryType{
 	strings.ToLower(_LibraryTypeName[24:32]): 4,
 	_LibraryTypeName[32:39]:                  5,
 	strings.ToLower(_LibraryTypeName[32:39]): 5,
+	_LibraryTypeName[39:45]:                  6,
+	strings.ToLower(_LibraryTypeName[39:45]): 6,
+	_LibraryTypeName[45:50]:                  7,
+	strings.ToLower(_LibraryTypeName[45:50]): 7,
+	_LibraryTypeName[50:62]:                  8,
+	strings.ToLower(_LibraryTypeName[50:62]): 8,
 }
 
 // ParseLibraryType attempts to convert a string to a LibraryType
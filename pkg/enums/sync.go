@@ -8,6 +8,24 @@ ENUM(
 	Added
 	Modified
 	PlayCount
+	BPM
+	Key
+	Genre
+	Comment
+	Cues
 )
 */
 type SyncType int
+
+/*
+	How a field changed on both sides of a bidirectional sync is resolved
+
+ENUM(
+	Newest
+	Highest
+	SourceWins
+	TargetWins
+	Prompt
+)
+*/
+type SyncPolicy int
@@ -18,15 +18,30 @@ const (
 	Modified
 	// PlayCount is a SyncType of type PlayCount
 	PlayCount
+	// BPM is a SyncType of type BPM
+	BPM
+	// Key is a SyncType of type Key
+	Key
+	// Genre is a SyncType of type Genre
+	Genre
+	// Comment is a SyncType of type Comment
+	Comment
+	// Cues is a SyncType of type Cues
+	Cues
 )
 
-const _SyncTypeName = "RatingsAddedModifiedPlayCount"
+const _SyncTypeName = "RatingsAddedModifiedPlayCountBPMKeyGenreCommentCues"
 
 var _SyncTypeNames = []string{
 	_SyncTypeName[0:7],
 	_SyncTypeName[7:12],
 	_SyncTypeName[12:20],
 	_SyncTypeName[20:29],
+	_SyncTypeName[29:32],
+	_SyncTypeName[32:35],
+	_SyncTypeName[35:40],
+	_SyncTypeName[40:47],
+	_SyncTypeName[47:51],
 }
 
 // SyncTypeNames returns a list of possible string values of SyncType.
@@ -41,6 +56,11 @@ var _SyncTypeMap = map[SyncType]string{
 	1: _SyncTypeName[7:12],
 	2: _SyncTypeName[12:20],
 	3: _SyncTypeName[20:29],
+	4: _SyncTypeName[29:32],
+	5: _SyncTypeName[32:35],
+	6: _SyncTypeName[35:40],
+	7: _SyncTypeName[40:47],
+	8: _SyncTypeName[47:51],
 }
 
 // String implements the Stringer interface.
@@ -60,6 +80,16 @@ var _SyncTypeValue = map[string]SyncType{
 	strings.ToLower(_SyncTypeName[12:20]): 2,
 	_SyncTypeName[20:29]:                  3,
 	strings.ToLower(_SyncTypeName[20:29]): 3,
+	_SyncTypeName[29:32]:                  4,
+	strings.ToLower(_SyncTypeName[29:32]): 4,
+	_SyncTypeName[32:35]:                  5,
+	strings.ToLower(_SyncTypeName[32:35]): 5,
+	_SyncTypeName[35:40]:                  6,
+	strings.ToLower(_SyncTypeName[35:40]): 6,
+	_SyncTypeName[40:47]:                  7,
+	strings.ToLower(_SyncTypeName[40:47]): 7,
+	_SyncTypeName[47:51]:                  8,
+	strings.ToLower(_SyncTypeName[47:51]): 8,
 }
 
 // ParseSyncType attempts to convert a string to a SyncType
@@ -112,3 +142,113 @@ func (x *SyncType) Scan(value interface{}) error {
 func (x SyncType) Value() (driver.Value, error) {
 	return x.String(), nil
 }
+
+const (
+	// Newest is a SyncPolicy of type Newest
+	Newest SyncPolicy = iota
+	// Highest is a SyncPolicy of type Highest
+	Highest
+	// SourceWins is a SyncPolicy of type SourceWins
+	SourceWins
+	// TargetWins is a SyncPolicy of type TargetWins
+	TargetWins
+	// Prompt is a SyncPolicy of type Prompt
+	Prompt
+)
+
+const _SyncPolicyName = "NewestHighestSourceWinsTargetWinsPrompt"
+
+var _SyncPolicyNames = []string{
+	_SyncPolicyName[0:6],
+	_SyncPolicyName[6:13],
+	_SyncPolicyName[13:23],
+	_SyncPolicyName[23:33],
+	_SyncPolicyName[33:39],
+}
+
+// SyncPolicyNames returns a list of possible string values of SyncPolicy.
+func SyncPolicyNames() []string {
+	tmp := make([]string, len(_SyncPolicyNames))
+	copy(tmp, _SyncPolicyNames)
+	return tmp
+}
+
+var _SyncPolicyMap = map[SyncPolicy]string{
+	0: _SyncPolicyName[0:6],
+	1: _SyncPolicyName[6:13],
+	2: _SyncPolicyName[13:23],
+	3: _SyncPolicyName[23:33],
+	4: _SyncPolicyName[33:39],
+}
+
+// String implements the Stringer interface.
+func (x SyncPolicy) String() string {
+	if str, ok := _SyncPolicyMap[x]; ok {
+		return str
+	}
+	return fmt.Sprintf("SyncPolicy(%d)", x)
+}
+
+var _SyncPolicyValue = map[string]SyncPolicy{
+	_SyncPolicyName[0:6]:                    0,
+	strings.ToLower(_SyncPolicyName[0:6]):   0,
+	_SyncPolicyName[6:13]:                   1,
+	strings.ToLower(_SyncPolicyName[6:13]):  1,
+	_SyncPolicyName[13:23]:                  2,
+	strings.ToLower(_SyncPolicyName[13:23]): 2,
+	_SyncPolicyName[23:33]:                  3,
+	strings.ToLower(_SyncPolicyName[23:33]): 3,
+	_SyncPolicyName[33:39]:                  4,
+	strings.ToLower(_SyncPolicyName[33:39]): 4,
+}
+
+// ParseSyncPolicy attempts to convert a string to a SyncPolicy
+func ParseSyncPolicy(name string) (SyncPolicy, error) {
+	if x, ok := _SyncPolicyValue[name]; ok {
+		return x, nil
+	}
+	return SyncPolicy(0), fmt.Errorf("%s is not a valid SyncPolicy, try [%s]", name, strings.Join(_SyncPolicyNames, ", "))
+}
+
+// MarshalText implements the text marshaller method
+func (x SyncPolicy) MarshalText() ([]byte, error) {
+	return []byte(x.String()), nil
+}
+
+// UnmarshalText implements the text unmarshaller method
+func (x *SyncPolicy) UnmarshalText(text []byte) error {
+	name := string(text)
+	tmp, err := ParseSyncPolicy(name)
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// Scan implements the Scanner interface.
+func (x *SyncPolicy) Scan(value interface{}) error {
+	var name string
+
+	switch v := value.(type) {
+	case string:
+		name = v
+	case []byte:
+		name = string(v)
+	case nil:
+		*x = SyncPolicy(0)
+		return nil
+	}
+
+	tmp, err := ParseSyncPolicy(name)
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (x SyncPolicy) Value() (driver.Value, error) {
+	return x.String(), nil
+}
@@ -0,0 +1,383 @@
+package files
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// file where the audio hashes are kept between runs
+var AudioHashCache = ExpandHomePath("~/.primetools/audiohash.json")
+
+type audioHashEntry struct {
+	Size      int64
+	Modified  time.Time
+	AudioSize int64
+	Hash      string `json:",omitempty"`
+}
+
+var audioHashes = struct {
+	sync.Mutex
+	loaded  bool
+	dirty   bool
+	entries map[string]*audioHashEntry
+}{}
+
+// byte range of the file containing audio
+type audioRegion struct {
+	offset int64
+	length int64
+}
+
+/*
+	Size of the audio payload of the file, without any tags
+*/
+func AudioSize(path string) (int64, error) {
+	entry, err := audioEntry(path, false)
+	if err != nil {
+		return 0, err
+	}
+	return entry.AudioSize, nil
+}
+
+/*
+	Hash of the audio payload of the file, ID3, APE and Vorbis tags are skipped
+	so it doesn't change when the file is retagged. Hashes are cached per path,
+	the last known hash is returned for a file which doesn't exists anymore.
+*/
+func AudioHash(path string) (string, error) {
+	entry, err := audioEntry(path, true)
+	if err != nil {
+		return "", err
+	}
+	return entry.Hash, nil
+}
+
+/*
+	Write the audio hashes computed since the last save to the cache file
+*/
+func SaveAudioHashes() error {
+	audioHashes.Lock()
+	defer audioHashes.Unlock()
+
+	if !audioHashes.dirty {
+		return nil
+	}
+
+	content, err := json.Marshal(audioHashes.entries)
+	if err != nil {
+		return errors.Wrap(err, "fail to serialize audio hashes")
+	}
+	if err = os.MkdirAll(filepath.Dir(AudioHashCache), 0755); err != nil {
+		return errors.Wrap(err, "fail to create audio hash cache folder")
+	}
+	if err = WriteFileAtomic(AudioHashCache, content); err != nil {
+		return err
+	}
+	audioHashes.dirty = false
+	return nil
+}
+
+func audioEntry(path string, withHash bool) (*audioHashEntry, error) {
+	key := NormalizePath(path)
+
+	audioHashes.Lock()
+	loadAudioHashes()
+	cached := audioHashes.entries[key]
+	audioHashes.Unlock()
+
+	valid := func(entry *audioHashEntry) bool {
+		return entry != nil && (!withHash || entry.Hash != "")
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		if valid(cached) {
+			return cached, nil
+		}
+		return nil, errors.Wrapf(err, "fail to stat file '%s'", path)
+	}
+
+	if valid(cached) && cached.Size == stat.Size() && cached.Modified.Equal(stat.ModTime()) {
+		return cached, nil
+	}
+
+	entry := &audioHashEntry{
+		Size:     stat.Size(),
+		Modified: stat.ModTime(),
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to open file '%s'", path)
+	}
+	defer file.Close()
+
+	regions, err := audioRegions(file, stat.Size())
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to find audio in file '%s'", path)
+	}
+
+	for _, it := range regions {
+		entry.AudioSize += it.length
+	}
+
+	if withHash {
+		hash := sha1.New()
+		for _, it := range regions {
+			if _, err = io.Copy(hash, io.NewSectionReader(file, it.offset, it.length)); err != nil {
+				return nil, errors.Wrapf(err, "fail to read file '%s'", path)
+			}
+		}
+		entry.Hash = fmt.Sprintf("%x", hash.Sum(nil))
+	}
+
+	audioHashes.Lock()
+	audioHashes.entries[key] = entry
+	audioHashes.dirty = true
+	audioHashes.Unlock()
+	return entry, nil
+}
+
+func loadAudioHashes() {
+	if audioHashes.loaded {
+		return
+	}
+	audioHashes.loaded = true
+	audioHashes.entries = map[string]*audioHashEntry{}
+
+	content, err := ioutil.ReadFile(AudioHashCache)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(content, &audioHashes.entries)
+	}
+	if err != nil {
+		logrus.Warnf("ignoring audio hash cache '%s': %v", AudioHashCache, err)
+		audioHashes.entries = map[string]*audioHashEntry{}
+	}
+}
+
+/*
+	Find where the audio is stored depending on the container
+*/
+func audioRegions(file io.ReaderAt, size int64) ([]audioRegion, error) {
+	start := int64(0)
+	// some files have more than one id3 tag at their start
+	for {
+		header := readAt(file, start, 10)
+		if len(header) < 10 || string(header[:3]) != "ID3" {
+			break
+		}
+		start += 10 + (int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9]))
+		if header[5]&0x10 != 0 {
+			// footer
+			start += 10
+		}
+	}
+
+	head := readAt(file, start, 12)
+	switch {
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return flacRegions(file, start, size)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return oggRegions(file, start, size)
+	case bytes.HasPrefix(head, []byte("RIFF")):
+		return chunkRegions(file, start, binary.LittleEndian, "data")
+	case bytes.HasPrefix(head, []byte("FORM")):
+		return chunkRegions(file, start, binary.BigEndian, "SSND")
+	case len(head) == 12 && string(head[4:8]) == "ftyp":
+		return mp4Regions(file, start, size)
+	default:
+		// raw stream, ie: mp3
+		end := trailingTags(file, size)
+		if end < start {
+			return nil, errors.New("tags overlap")
+		}
+		return []audioRegion{{start, end - start}}, nil
+	}
+}
+
+func flacRegions(file io.ReaderAt, start int64, size int64) ([]audioRegion, error) {
+	pos := start + 4
+	for {
+		header := readAt(file, pos, 4)
+		if len(header) < 4 {
+			return nil, errors.New("truncated flac metadata")
+		}
+		pos += 4 + (int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3]))
+		if header[0]&0x80 != 0 {
+			break
+		}
+	}
+
+	end := trailingTags(file, size)
+	if end < pos {
+		return nil, errors.New("truncated flac metadata")
+	}
+	return []audioRegion{{pos, end - pos}}, nil
+}
+
+/*
+	Data of the audio pages, header pages (comments included) have a granule
+	position of 0
+*/
+func oggRegions(file io.ReaderAt, start int64, size int64) ([]audioRegion, error) {
+	regions := []audioRegion{}
+	pos := start
+	for pos < size {
+		header := readAt(file, pos, 27)
+		if len(header) < 27 || string(header[:4]) != "OggS" {
+			return nil, errors.New("invalid ogg page")
+		}
+		count := int64(header[26])
+		segments := readAt(file, pos+27, count)
+		if int64(len(segments)) < count {
+			return nil, errors.New("truncated ogg page")
+		}
+
+		length := int64(0)
+		for _, it := range segments {
+			length += int64(it)
+		}
+		data := pos + 27 + count
+		if binary.LittleEndian.Uint64(header[6:14]) != 0 && length > 0 {
+			regions = append(regions, audioRegion{data, length})
+		}
+		pos = data + length
+	}
+	return regions, nil
+}
+
+func chunkRegions(file io.ReaderAt, start int64, order binary.ByteOrder, audio string) ([]audioRegion, error) {
+	header := readAt(file, start, 8)
+	if len(header) < 8 {
+		return nil, errors.New("truncated header")
+	}
+	end := start + 8 + int64(order.Uint32(header[4:8]))
+
+	regions := []audioRegion{}
+	pos := start + 12
+	for pos+8 <= end {
+		header = readAt(file, pos, 8)
+		if len(header) < 8 {
+			break
+		}
+		length := int64(order.Uint32(header[4:8]))
+		if string(header[:4]) == audio {
+			regions = append(regions, audioRegion{pos + 8, length})
+		}
+		// chunks are padded to an even size
+		pos += 8 + length + length%2
+	}
+
+	if len(regions) == 0 {
+		return nil, errors.Errorf("missing %s chunk", audio)
+	}
+	return regions, nil
+}
+
+func mp4Regions(file io.ReaderAt, start int64, size int64) ([]audioRegion, error) {
+	regions := []audioRegion{}
+	pos := start
+	for pos+8 <= size {
+		header := readAt(file, pos, 16)
+		if len(header) < 8 {
+			break
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		offset := int64(8)
+		if length == 1 {
+			if len(header) < 16 {
+				return nil, errors.New("truncated atom")
+			}
+			length = int64(binary.BigEndian.Uint64(header[8:16]))
+			offset = 16
+		} else if length == 0 {
+			length = size - pos
+		}
+		if length < offset {
+			return nil, errors.New("invalid atom size")
+		}
+
+		if string(header[4:8]) == "mdat" {
+			regions = append(regions, audioRegion{pos + offset, length - offset})
+		}
+		pos += length
+	}
+
+	if len(regions) == 0 {
+		return nil, errors.New("missing mdat atom")
+	}
+	return regions, nil
+}
+
+/*
+	Offset of the end of the audio, before any ID3v1, APE or Lyrics3 tags. A
+	tag whose size doesn't fit in the file ends the lookup, otherwise a
+	malformed one could be found again and again.
+*/
+func trailingTags(file io.ReaderAt, end int64) int64 {
+	for {
+		if end >= 128 && string(readAt(file, end-128, 3)) == "TAG" {
+			end -= 128
+			continue
+		}
+
+		if end >= 32 {
+			footer := readAt(file, end-32, 32)
+			if len(footer) == 32 && string(footer[:8]) == "APETAGEX" {
+				// size includes the footer but not the header
+				size := int64(binary.LittleEndian.Uint32(footer[12:16]))
+				if binary.LittleEndian.Uint32(footer[20:24])&(1<<31) != 0 {
+					size += 32
+				}
+				if size < 32 || size > end {
+					return end
+				}
+				end -= size
+				continue
+			}
+		}
+
+		if end >= 15 && string(readAt(file, end-9, 9)) == "LYRICS200" {
+			if length, err := strconv.Atoi(string(readAt(file, end-15, 6))); err == nil {
+				if length < 0 || int64(length)+15 > end {
+					return end
+				}
+				end -= int64(length) + 15
+				continue
+			}
+		}
+
+		if end < 0 {
+			end = 0
+		}
+		return end
+	}
+}
+
+/*
+	Read up to length bytes, less if the end of the file is reached
+*/
+func readAt(file io.ReaderAt, offset int64, length int64) []byte {
+	if offset < 0 || length <= 0 {
+		return nil
+	}
+	buf := make([]byte, length)
+	n, _ := file.ReadAt(buf, offset)
+	return buf[:n]
+}
@@ -0,0 +1,53 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func apeFooter(size uint32, flags uint32) []byte {
+	footer := make([]byte, 32)
+	copy(footer, "APETAGEX")
+	binary.LittleEndian.PutUint32(footer[8:12], 2000)
+	binary.LittleEndian.PutUint32(footer[12:16], size)
+	binary.LittleEndian.PutUint32(footer[20:24], flags)
+	return footer
+}
+
+func TestTrailingTags(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xAA}, 200)
+	id3v1 := append([]byte("TAG"), make([]byte, 125)...)
+	apeItems := make([]byte, 16)
+
+	tests := []struct {
+		name    string
+		content []byte
+		end     int64
+	}{
+		{"none", audio, 200},
+		{"id3v1", append(append([]byte{}, audio...), id3v1...), 200},
+		{"ape", concat(audio, apeItems, apeFooter(48, 0)), 200},
+		{"ape with header", concat(audio, apeFooter(48, 1<<31), apeItems, apeFooter(48, 1<<31)), 200},
+		{"ape and id3v1", concat(audio, apeItems, apeFooter(48, 0), id3v1), 200},
+		{"ape of size 0", concat(audio, apeFooter(0, 0)), 232},
+		{"ape larger than the file", concat(audio, apeFooter(1<<30, 0)), 232},
+	}
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+			end := trailingTags(bytes.NewReader(it.content), int64(len(it.content)))
+			if end != it.end {
+				t.Errorf("audio ends at %d, expected %d", end, it.end)
+			}
+		})
+	}
+}
+
+func concat(parts ...[]byte) []byte {
+	out := []byte{}
+	for _, it := range parts {
+		out = append(out, it...)
+	}
+	return out
+}
@@ -1,6 +1,8 @@
 package files
 
 import (
+	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/deepakjois/gousbdrivedetector"
@@ -34,3 +36,43 @@ func DiskPartitions() ([]string, error) {
 
 	return out, nil
 }
+
+// folders where the drives are mounted, the drive being a sub folder
+var mountFolders = []string{"Volumes", "mnt", "media/*", "run/media/*"}
+
+/*
+	Folder at the root of the drive of the path: the first folder of the drive
+	letter on windows, the folder of the drive when mounted under one of the
+	mount folders (ie: /media/<user>/<drive>), otherwise the first folder
+*/
+func RootFolder(fpath string) string {
+	fpath = filepath.ToSlash(fpath)
+	prefix := filepath.VolumeName(fpath)
+	fpath = fpath[len(prefix):]
+	if strings.HasPrefix(fpath, "/") {
+		prefix += "/"
+	}
+
+	parts := strings.Split(strings.Trim(fpath, "/"), "/")
+	depth := 1
+	if prefix == "/" {
+		for _, it := range mountFolders {
+			size := strings.Count(it, "/") + 1
+			if ok, _ := path.Match(it, strings.Join(parts[:minInt(size, len(parts))], "/")); ok && size+1 > depth {
+				depth = size + 1
+			}
+		}
+	}
+	if depth >= len(parts) {
+		// no folder between the root and the file
+		depth = len(parts) - 1
+	}
+	return prefix + strings.Join(parts[:depth], "/")
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,32 @@
+package files
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestRootFolder(t *testing.T) {
+	tests := map[string]string{
+		"/home/dj/Music/track.mp3":              "/home",
+		"/media/dj/USB/Music/track.mp3":         "/media/dj/USB",
+		"/run/media/dj/USB/track.mp3":           "/run/media/dj/USB",
+		"/Volumes/USB/Music/track.mp3":          "/Volumes/USB",
+		"/mnt/usb/track.mp3":                    "/mnt/usb",
+		"/mnt/track.mp3":                        "/mnt",
+		"/track.mp3":                            "/",
+		"Music/track.mp3":                       "Music",
+		"M:/Music/Techno/track.mp3":             "M:/Music",
+		"//server/share/Music/Techno/track.mp3": "//server/share/Music",
+	}
+	if runtime.GOOS != "windows" {
+		// the drive letter is only a folder
+		tests["M:/Music/Techno/track.mp3"] = "M:"
+		tests["//server/share/Music/Techno/track.mp3"] = "/server"
+	}
+
+	for path, expected := range tests {
+		if root := RootFolder(path); root != expected {
+			t.Errorf("root folder of '%s' is '%s' instead of '%s'", path, root, expected)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package files
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+	Ordered prefix rewrite rules between the paths of two machines or drives,
+	ie: "/media/music=M:\Music". Prefixes are compared without case and with
+	either slashes, the first matching rule is applied.
+*/
+type PathMap []pathRule
+
+type pathRule struct {
+	from string
+	to   string
+}
+
+/*
+	Parse rules written as from=to
+*/
+func ParsePathMap(rules []string) (PathMap, error) {
+	out := PathMap{}
+	for _, it := range rules {
+		idx := strings.Index(it, "=")
+		if idx <= 0 || idx == len(it)-1 {
+			return nil, errors.Errorf("invalid path mapping '%s', expected from=to", it)
+		}
+		out = append(out, pathRule{
+			from: foldPath(it[:idx]),
+			to:   strings.TrimRight(it[idx+1:], `/\`),
+		})
+	}
+	return out, nil
+}
+
+/*
+	Rewrite the prefix of the path with the first matching rule, the rest of
+	the path takes the slashes of the new prefix. Unmatched paths are returned
+	as is.
+*/
+func (m PathMap) Apply(path string) string {
+	for _, it := range m {
+		n := len(it.from)
+		if len(path) < n || foldPath(path[:n]) != it.from {
+			continue
+		}
+		// only whole folder names
+		if len(path) > n && path[n] != '/' && path[n] != '\\' {
+			continue
+		}
+
+		rest := strings.ReplaceAll(path[n:], `\`, "/")
+		if strings.Contains(it.to, `\`) && !strings.Contains(it.to, "/") {
+			rest = strings.ReplaceAll(rest, "/", `\`)
+		}
+		return it.to + rest
+	}
+	return path
+}
+
+func foldPath(path string) string {
+	path = strings.ReplaceAll(path, `\`, "/")
+	return strings.ToLower(strings.TrimRight(path, "/"))
+}
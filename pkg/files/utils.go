@@ -32,6 +32,20 @@ const (
 	URLPathPrefix = "file://localhost/"
 )
 
+// extensions of the music files which tags can be read and written
+var MusicExtensions = []string{
+	".aif",
+	".aiff",
+	".flac",
+	".m4a",
+	".mp3",
+	".mp4",
+	".oga",
+	".ogg",
+	".opus",
+	".wav",
+}
+
 func ExpandHomePath(path string) string {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -51,7 +65,13 @@ func Exists(path string) bool {
 }
 
 func IsMusicFile(path string) bool {
-	return filepath.Ext(path) == ".mp3" || filepath.Ext(path) == ".flac"
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, it := range MusicExtensions {
+		if it == ext {
+			return true
+		}
+	}
+	return false
 }
 
 func Size(path string) int64 {
@@ -107,16 +127,30 @@ func RemoveAccent(path string) string {
 
 // file://localhost/m:/Techno/-=%20Ambient%20=-/Bluetech/2005%20-%20Sines%20And%20Singularities/01%20-%20Enter%20The%20Lovely.mp3
 func ConvertUrlFilePath(path string) string {
+	prefixed := strings.HasPrefix(path, URLPathPrefix)
 	path = strings.Replace(path, URLPathPrefix, "", 1)
 	path, _ = url.PathUnescape(path)
 	path = html.UnescapeString(path)
 	if runtime.GOOS == "windows" {
 		path = NormalizePath(path)
+	} else if prefixed && !strings.HasPrefix(path, "/") {
+		// the url host eat the leading slash of unix paths
+		path = "/" + path
 	}
 	// path = RemoveAccent(path)
 	return path
 }
 
+/*
+	Reverse of ConvertUrlFilePath, ie: m:/Techno/01 - Track.mp3 -> file://localhost/m:/Techno/01%20-%20Track.mp3
+*/
+func ConvertFilePathToUrl(path string) string {
+	path = filepath.ToSlash(path)
+	path = strings.TrimPrefix(path, "/")
+	u := url.URL{Path: path}
+	return URLPathPrefix + u.EscapedPath()
+}
+
 /*
 	Find the absolute path, with forward slash and on windows, with lowercase
 */
@@ -161,6 +195,32 @@ func ReadFrom(path string, data interface{}) error {
 	return nil
 }
 
+/*
+	Write content into a temporary file next to path and rename it over path, so a
+	failed write never leave a truncated file behind
+*/
+func WriteFileAtomic(path string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "fail to create temporary file for '%s'", path)
+	}
+
+	_, err = tmp.Write(content)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrapf(err, "fail to write temporary file for '%s'", path)
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrapf(err, "fail to replace file '%s'", path)
+	}
+	return nil
+}
+
 func WriteTo(opath string, format enums.FormatType, data interface{}) error {
 
 	var err error
@@ -0,0 +1,90 @@
+package fingerprint
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+/*
+	Big endian bit reader, reading past the end returns zeros and marks the
+	reader as overrun
+*/
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (b *bitReader) overrun() bool {
+	return b.pos > len(b.data)*8
+}
+
+func (b *bitReader) eof() bool {
+	return b.pos >= len(b.data)*8
+}
+
+/*
+	Next n bits without consuming them, n must be 32 or less
+*/
+func (b *bitReader) peek(n int) uint32 {
+	if n == 0 {
+		return 0
+	}
+
+	idx := b.pos >> 3
+	var v uint64
+	if idx >= 0 && idx+8 <= len(b.data) {
+		v = binary.BigEndian.Uint64(b.data[idx:])
+	} else {
+		for i := 0; i < 8; i++ {
+			v <<= 8
+			if idx+i < len(b.data) {
+				v |= uint64(b.data[idx+i])
+			}
+		}
+	}
+	return uint32(v << uint(b.pos&7) >> uint(64-n))
+}
+
+func (b *bitReader) bits(n int) uint32 {
+	v := b.peek(n)
+	b.pos += n
+	return v
+}
+
+func (b *bitReader) bit() bool {
+	return b.bits(1) == 1
+}
+
+/*
+	Two's complement value on n bits
+*/
+func (b *bitReader) signed(n int) int32 {
+	if n == 0 {
+		return 0
+	}
+	return int32(b.bits(n)<<uint(32-n)) >> uint(32-n)
+}
+
+/*
+	Number of zeros before the next one
+*/
+func (b *bitReader) unary() int {
+	count := 0
+	for !b.eof() {
+		v := b.peek(32)
+		if v == 0 {
+			count += 32
+			b.pos += 32
+			continue
+		}
+		zeros := bits.LeadingZeros32(v)
+		b.pos += zeros + 1
+		return count + zeros
+	}
+	b.pos++
+	return count
+}
+
+func (b *bitReader) align() {
+	b.pos = (b.pos + 7) &^ 7
+}
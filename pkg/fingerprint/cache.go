@@ -0,0 +1,133 @@
+package fingerprint
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+)
+
+// file where the fingerprints are kept between runs
+var CacheFile = files.ExpandHomePath("~/.primetools/fingerprints.json")
+
+type cacheEntry struct {
+	Size        int64
+	Modified    time.Time
+	Fingerprint []byte
+}
+
+var cache = struct {
+	sync.Mutex
+	loaded  bool
+	dirty   bool
+	entries map[string]*cacheEntry
+}{}
+
+/*
+	Fingerprint of the file, fingerprints are cached per path so the last known
+	fingerprint is returned for a file which doesn't exists anymore.
+*/
+func Of(path string) (Fingerprint, error) {
+	key := files.NormalizePath(path)
+
+	cache.Lock()
+	loadCache()
+	cached := cache.entries[key]
+	cache.Unlock()
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		if cached != nil {
+			return unpack(cached.Fingerprint), nil
+		}
+		return nil, errors.Wrapf(err, "fail to stat file '%s'", path)
+	}
+
+	if cached != nil && cached.Size == stat.Size() && cached.Modified.Equal(stat.ModTime()) {
+		return unpack(cached.Fingerprint), nil
+	}
+
+	print, err := Compute(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.Lock()
+	cache.entries[key] = &cacheEntry{
+		Size:        stat.Size(),
+		Modified:    stat.ModTime(),
+		Fingerprint: pack(print),
+	}
+	cache.dirty = true
+	cache.Unlock()
+	return print, nil
+}
+
+/*
+	Write the fingerprints computed since the last save to the cache file
+*/
+func SaveCache() error {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if !cache.dirty {
+		return nil
+	}
+
+	content, err := json.Marshal(cache.entries)
+	if err != nil {
+		return errors.Wrap(err, "fail to serialize fingerprints")
+	}
+	if err = os.MkdirAll(filepath.Dir(CacheFile), 0755); err != nil {
+		return errors.Wrap(err, "fail to create fingerprint cache folder")
+	}
+	if err = files.WriteFileAtomic(CacheFile, content); err != nil {
+		return err
+	}
+	cache.dirty = false
+	return nil
+}
+
+func loadCache() {
+	if cache.loaded {
+		return
+	}
+	cache.loaded = true
+	cache.entries = map[string]*cacheEntry{}
+
+	content, err := ioutil.ReadFile(CacheFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(content, &cache.entries)
+	}
+	if err != nil {
+		logrus.Warnf("ignoring fingerprint cache '%s': %v", CacheFile, err)
+		cache.entries = map[string]*cacheEntry{}
+	}
+}
+
+func pack(print Fingerprint) []byte {
+	out := make([]byte, 4*len(print))
+	for i, it := range print {
+		binary.LittleEndian.PutUint32(out[4*i:], it)
+	}
+	return out
+}
+
+func unpack(content []byte) Fingerprint {
+	out := make(Fingerprint, len(content)/4)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint32(content[4*i:])
+	}
+	return out
+}
@@ -0,0 +1,310 @@
+package fingerprint
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+/*
+	The fingerprint follows the design of chromaprint: the audio is resampled,
+	split into overlapping frames whose spectrum is folded into the 12 notes
+	of the chromatic scale, then a set of filters over the chroma image is
+	quantized into 2 bits each, which gives a 32 bits value per frame.
+*/
+
+const (
+	chromaRate    = 11025
+	chromaFrame   = 4096
+	chromaOverlap = chromaFrame - chromaFrame/3
+	chromaMinFreq = 28
+	chromaMaxFreq = 3520
+	chromaBands   = 12
+
+	resampleTaps  = 16
+	resampleSteps = 256
+)
+
+var chromaSmoothing = []float64{0.25, 0.75, 1.0, 0.75, 0.25}
+
+type classifier struct {
+	filter    int
+	y         int
+	height    int
+	width     int
+	quantizer [3]float64
+}
+
+var classifiers = []classifier{
+	{0, 4, 3, 15, [3]float64{1.98215, 2.35817, 2.63523}},
+	{4, 4, 6, 15, [3]float64{-1.03809, -0.651211, -0.282167}},
+	{1, 0, 4, 16, [3]float64{-0.298702, 0.119262, 0.558497}},
+	{3, 8, 2, 12, [3]float64{-0.105439, 0.0153946, 0.135898}},
+	{3, 4, 4, 8, [3]float64{-0.142891, 0.0258736, 0.200632}},
+	{4, 0, 3, 5, [3]float64{-0.826319, -0.590612, -0.368214}},
+	{1, 2, 2, 9, [3]float64{-0.557409, -0.233035, 0.0534525}},
+	{2, 7, 3, 4, [3]float64{-0.0646826, 0.00620476, 0.0784847}},
+	{2, 6, 2, 16, [3]float64{-0.192387, -0.029699, 0.215855}},
+	{2, 1, 3, 2, [3]float64{-0.0397818, -0.00568076, 0.0292026}},
+	{5, 10, 1, 15, [3]float64{-0.53823, -0.369934, -0.190235}},
+	{3, 6, 2, 10, [3]float64{-0.124877, 0.0296483, 0.139239}},
+	{2, 1, 1, 14, [3]float64{-0.101475, 0.0225617, 0.231971}},
+	{3, 5, 6, 4, [3]float64{-0.0799915, -0.00729616, 0.063262}},
+	{1, 9, 2, 12, [3]float64{-0.272556, 0.019424, 0.302559}},
+	{3, 4, 2, 14, [3]float64{-0.164292, -0.0321188, 0.0846339}},
+}
+
+func fingerprint(in *audio) Fingerprint {
+	samples := resample(in.samples, in.rate, chromaRate)
+	image := chroma(samples)
+	if len(image) == 0 {
+		return nil
+	}
+
+	// integral image, so any rectangle sum is 4 lookups
+	integral := make([][chromaBands]float64, len(image))
+	for x, row := range image {
+		for y, v := range row {
+			sum := v
+			if x > 0 {
+				sum += integral[x-1][y]
+			}
+			if y > 0 {
+				sum += integral[x][y-1]
+			}
+			if x > 0 && y > 0 {
+				sum -= integral[x-1][y-1]
+			}
+			integral[x][y] = sum
+		}
+	}
+
+	area := func(x1, y1, x2, y2 int) float64 {
+		if x2 <= x1 || y2 <= y1 {
+			return 0
+		}
+		sum := integral[x2-1][y2-1]
+		if x1 > 0 {
+			sum -= integral[x1-1][y2-1]
+		}
+		if y1 > 0 {
+			sum -= integral[x2-1][y1-1]
+		}
+		if x1 > 0 && y1 > 0 {
+			sum += integral[x1-1][y1-1]
+		}
+		return sum
+	}
+
+	width := 0
+	for _, c := range classifiers {
+		if c.width > width {
+			width = c.width
+		}
+	}
+
+	var out Fingerprint
+	for x := 0; x+width <= len(image); x++ {
+		value := uint32(0)
+		for _, c := range classifiers {
+			v := c.apply(area, x)
+			// gray coded so close values only differ by one bit
+			q := 0
+			for q < 3 && v >= c.quantizer[q] {
+				q++
+			}
+			value = value<<2 | [4]uint32{0, 1, 3, 2}[q]
+		}
+		out = append(out, value)
+	}
+	return out
+}
+
+func (c *classifier) apply(area func(x1, y1, x2, y2 int) float64, x int) float64 {
+	y, w, h := c.y, c.width, c.height
+	compare := func(a, b float64) float64 {
+		return math.Log((1 + a) / (1 + b))
+	}
+
+	switch c.filter {
+	case 0:
+		return compare(area(x, y, x+w, y+h), 0)
+	case 1:
+		// top half against the bottom half
+		mid := y + h/2
+		return compare(area(x, mid, x+w, y+h), area(x, y, x+w, mid))
+	case 2:
+		// left half against the right half
+		mid := x + w/2
+		return compare(area(mid, y, x+w, y+h), area(x, y, mid, y+h))
+	case 3:
+		// diagonal quarters
+		mx, my := x+w/2, y+h/2
+		a := area(x, my, mx, y+h) + area(mx, y, x+w, my)
+		b := area(x, y, mx, my) + area(mx, my, x+w, y+h)
+		return compare(a, b)
+	case 4:
+		// middle third against the outer thirds
+		y1, y2 := y+h/3, y+2*h/3
+		return compare(area(x, y1, x+w, y2), area(x, y, x+w, y1)+area(x, y2, x+w, y+h))
+	case 5:
+		x1, x2 := x+w/3, x+2*w/3
+		return compare(area(x1, y, x2, y+h), area(x, y, x1, y+h)+area(x2, y, x+w, y+h))
+	}
+	return 0
+}
+
+/*
+	Windowed sinc resampling, the cutoff is under the lowest nyquist
+	frequency so it also filters out the aliasing
+*/
+func resample(in []float32, from int, to int) []float32 {
+	if from == to {
+		return in
+	}
+
+	ratio := float64(from) / float64(to)
+	cutoff := math.Min(1, 1/ratio) * 0.95
+	count := int(float64(len(in)) / ratio)
+	out := make([]float32, count)
+
+	// the kernel is tabulated, every 1/resampleSteps of an input sample
+	taps := int(math.Ceil(resampleTaps * math.Max(1, ratio)))
+	kernel := make([]float64, taps*resampleSteps+1)
+	for i := range kernel {
+		x := float64(i) / resampleSteps
+		kernel[i] = sinc(x*cutoff) * blackman(x, float64(taps))
+	}
+
+	for i := range out {
+		center := float64(i) * ratio
+		sum, norm := 0.0, 0.0
+		for j := int(center) - taps + 1; j <= int(center)+taps; j++ {
+			if j < 0 || j >= len(in) {
+				continue
+			}
+			weight := kernel[int(math.Abs(float64(j)-center)*resampleSteps+0.5)]
+			sum += float64(in[j]) * weight
+			norm += weight
+		}
+		if norm != 0 {
+			out[i] = float32(sum / norm)
+		}
+	}
+	return out
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+func blackman(x float64, half float64) float64 {
+	if x < -half || x > half {
+		return 0
+	}
+	p := (x + half) / (2 * half)
+	return 0.42 - 0.5*math.Cos(2*math.Pi*p) + 0.08*math.Cos(4*math.Pi*p)
+}
+
+/*
+	Normalized and smoothed chroma vector of each frame
+*/
+func chroma(samples []float32) [][chromaBands]float64 {
+	window := make([]float64, chromaFrame)
+	for i := range window {
+		window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(chromaFrame-1))
+	}
+
+	// note of each frequency bin in the chroma range
+	notes := make([]int, chromaFrame/2)
+	for i := range notes {
+		notes[i] = -1
+		freq := float64(i) * chromaRate / chromaFrame
+		if freq < chromaMinFreq || freq > chromaMaxFreq {
+			continue
+		}
+		octave := math.Log2(freq / (440.0 / 16))
+		note := int(chromaBands * (octave - math.Floor(octave)))
+		notes[i] = note % chromaBands
+	}
+
+	var raw [][chromaBands]float64
+	buffer := make([]complex128, chromaFrame)
+	step := chromaFrame - chromaOverlap
+	for start := 0; start+chromaFrame <= len(samples); start += step {
+		for i := range buffer {
+			buffer[i] = complex(float64(samples[start+i])*window[i], 0)
+		}
+		fft(buffer)
+
+		var bands [chromaBands]float64
+		for i, note := range notes {
+			if note >= 0 {
+				magnitude := cmplx.Abs(buffer[i])
+				bands[note] += magnitude * magnitude
+			}
+		}
+		raw = append(raw, bands)
+	}
+
+	if len(raw) < len(chromaSmoothing) {
+		return nil
+	}
+
+	out := make([][chromaBands]float64, 0, len(raw)-len(chromaSmoothing)+1)
+	for x := 0; x+len(chromaSmoothing) <= len(raw); x++ {
+		var row [chromaBands]float64
+		for i, coef := range chromaSmoothing {
+			for b := range row {
+				row[b] += raw[x+i][b] * coef
+			}
+		}
+
+		norm := 0.0
+		for _, v := range row {
+			norm += v * v
+		}
+		norm = math.Sqrt(norm)
+		for b := range row {
+			if norm < 0.01 {
+				row[b] = 0
+			} else {
+				row[b] /= norm
+			}
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+/*
+	In place radix-2 fft, the length must be a power of 2
+*/
+func fft(data []complex128) {
+	n := len(data)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := data[start+k], data[start+k+size/2]*w
+				data[start+k] = a + b
+				data[start+k+size/2] = a - b
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package fingerprint
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// only the start of a track is fingerprinted
+const maxSeconds = 120
+
+// extensions of the files which can be decoded
+var Extensions = []string{".flac", ".mp3", ".wav"}
+
+/*
+	Mono samples between -1 and 1
+*/
+type audio struct {
+	rate    int
+	samples []float32
+}
+
+func (a *audio) full() bool {
+	return len(a.samples) >= a.rate*maxSeconds
+}
+
+func Supported(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, it := range Extensions {
+		if it == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func decode(path string) (*audio, error) {
+	if !Supported(path) {
+		return nil, errors.Errorf("fingerprint of '%s' files is not supported", filepath.Ext(path))
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to read file '%s'", path)
+	}
+
+	var out *audio
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".flac":
+		out, err = decodeFLAC(content)
+	case ".mp3":
+		out, err = decodeMP3(content)
+	case ".wav":
+		out, err = decodeWAV(content)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to decode file '%s'", path)
+	}
+
+	if limit := out.rate * maxSeconds; len(out.samples) > limit {
+		out.samples = out.samples[:limit]
+	}
+	return out, nil
+}
+
+/*
+	Offset after the ID3v2 tags at the start of the content
+*/
+func skipID3(content []byte) int {
+	pos := 0
+	for len(content) >= pos+10 && string(content[pos:pos+3]) == "ID3" {
+		header := content[pos : pos+10]
+		pos += 10 + (int(header[6])<<21 | int(header[7])<<14 | int(header[8])<<7 | int(header[9]))
+		if header[5]&0x10 != 0 {
+			// footer
+			pos += 10
+		}
+	}
+	return pos
+}
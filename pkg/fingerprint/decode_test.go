@@ -0,0 +1,128 @@
+package fingerprint
+
+import (
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+/*
+	The fixtures of testdata are tiny synthetic files: the wav and flac hold the
+	same 2304 frames of two tones on 16 bits stereo, the flac frames being
+	verbatim, fixed and left/side coded. The mp3 is 5 mono frames of a single
+	spectral line, behind an ID3v2 tag.
+*/
+func fixture(t *testing.T, name string) []byte {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+var decoders = map[string]func(content []byte) (*audio, error){
+	"tone.wav":  decodeWAV,
+	"tone.flac": decodeFLAC,
+	"tone.mp3":  decodeMP3,
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		fixture string
+		rate    int
+		samples int
+	}{
+		{"tone.wav", 44100, 2304},
+		{"tone.flac", 44100, 2304},
+		{"tone.mp3", 44100, 5 * mp3Granule * 2},
+	}
+
+	for _, it := range tests {
+		t.Run(it.fixture, func(t *testing.T) {
+			decoded, err := decode(filepath.Join("testdata", it.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.rate != it.rate || len(decoded.samples) != it.samples {
+				t.Fatalf("decoded %d samples at %d Hz instead of %d at %d Hz", len(decoded.samples), decoded.rate, it.samples, it.rate)
+			}
+
+			peak := 0.0
+			for _, s := range decoded.samples {
+				peak = math.Max(peak, math.Abs(float64(s)))
+			}
+			if peak < 0.01 || peak > 1 {
+				t.Errorf("peak is %v", peak)
+			}
+		})
+	}
+}
+
+func TestDecodeLossless(t *testing.T) {
+	wav, err := decodeWAV(fixture(t, "tone.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flac, err := decodeFLAC(fixture(t, "tone.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 440 Hz at half scale on the left, 660 Hz at quarter scale on the right
+	for i, s := range wav.samples {
+		left := math.Round(0.5 * 32767 * math.Sin(2*math.Pi*440*float64(i)/44100))
+		right := math.Round(0.25 * 32767 * math.Sin(2*math.Pi*660*float64(i)/44100))
+		if expected := (left + right) / 2 / 32768; math.Abs(float64(s)-expected) > 1e-6 {
+			t.Fatalf("wav sample %d is %v instead of %v", i, s, expected)
+		}
+		if math.Abs(float64(flac.samples[i]-s)) > 1e-6 {
+			t.Fatalf("flac sample %d is %v instead of %v", i, flac.samples[i], s)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	garbage := make([]byte, 4096)
+	random.Read(garbage)
+
+	for name, decoder := range decoders {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decoder(garbage); err == nil {
+				t.Error("garbage is decoded")
+			}
+			if _, err := decoder(nil); err == nil {
+				t.Error("empty content is decoded")
+			}
+
+			// truncated anywhere, the frames before the cut are kept at most
+			content := fixture(t, name)
+			for size := 0; size < len(content); size++ {
+				decoded, err := decoder(content[:size])
+				if err == nil && len(decoded.samples) > 2304*5 {
+					t.Fatalf("decoded %d samples out of %d bytes", len(decoded.samples), size)
+				}
+			}
+
+			// corrupted bytes after the headers
+			for i := 0; i < 200; i++ {
+				corrupted := append([]byte{}, content...)
+				for j := 0; j < 8; j++ {
+					corrupted[64+random.Intn(len(corrupted)-64)] = byte(random.Intn(256))
+				}
+				decoder(corrupted)
+			}
+		})
+	}
+}
+
+func TestSupported(t *testing.T) {
+	if !Supported("/music/track.FLAC") || Supported("/music/track.ogg") {
+		t.Error("supported extensions are wrong")
+	}
+	if _, err := Compute("/music/track.ogg"); err == nil {
+		t.Error("ogg file is decoded")
+	}
+}
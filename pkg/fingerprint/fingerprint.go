@@ -0,0 +1,145 @@
+package fingerprint
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// minimum similarity for two fingerprints to be of the same recording
+const Threshold = 0.5
+
+const (
+	// maximum shift between two fingerprints, about 10 seconds
+	maxOffset = 80
+	// minimum number of overlapping frames to compare two fingerprints
+	minOverlap = 20
+	// number of frames indexed for the search
+	indexedFrames = 240
+	// minimum number of shared values to compare against an indexed fingerprint
+	minShared = 8
+)
+
+/*
+	Acoustic fingerprint of the start of a track, one value every 1365 samples at
+	11025 Hz. Unlike the content hash, it matches the same recording in other
+	formats or bitrates.
+*/
+type Fingerprint []uint32
+
+/*
+	Decode the file and compute its fingerprint, the cache is not used
+*/
+func Compute(path string) (Fingerprint, error) {
+	decoded, err := decode(path)
+	if err != nil {
+		return nil, err
+	}
+	return fingerprint(decoded), nil
+}
+
+/*
+	Similarity between 0 and 1 of the fingerprints, aligned on the offset with
+	the less bit errors. Unrelated recordings are close to 0.
+*/
+func Similarity(a Fingerprint, b Fingerprint) float64 {
+	best := 0.0
+	for offset := -maxOffset; offset <= maxOffset; offset++ {
+		// a[i] is compared to b[i+offset]
+		start, end := 0, len(a)
+		if offset < 0 {
+			start = -offset
+		}
+		if len(b)-offset < end {
+			end = len(b) - offset
+		}
+		if end-start < minOverlap {
+			continue
+		}
+
+		errs := 0
+		for i := start; i < end; i++ {
+			errs += bits.OnesCount32(a[i] ^ b[i+offset])
+		}
+
+		score := 1 - 2*float64(errs)/float64(32*(end-start))
+		if score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+type Match struct {
+	Key   string
+	Score float64
+}
+
+/*
+	Search fingerprints similar to a given one, the candidates are the ones
+	sharing some exact values before being compared.
+*/
+type Index struct {
+	prints map[string]Fingerprint
+	values map[uint32][]string
+}
+
+func NewIndex() *Index {
+	return &Index{
+		prints: map[string]Fingerprint{},
+		values: map[uint32][]string{},
+	}
+}
+
+func (i *Index) Len() int {
+	return len(i.prints)
+}
+
+func (i *Index) Add(key string, print Fingerprint) {
+	if _, ok := i.prints[key]; ok || len(print) == 0 {
+		return
+	}
+	i.prints[key] = print
+	for value := range distinct(print) {
+		i.values[value] = append(i.values[value], key)
+	}
+}
+
+/*
+	Indexed fingerprints similar to the given one, best match first
+*/
+func (i *Index) Search(print Fingerprint) (matches []Match) {
+	shared := map[string]int{}
+	for value := range distinct(print) {
+		for _, key := range i.values[value] {
+			shared[key]++
+		}
+	}
+
+	for key, count := range shared {
+		if count < minShared {
+			continue
+		}
+		if score := Similarity(print, i.prints[key]); score >= Threshold {
+			matches = append(matches, Match{Key: key, Score: score})
+		}
+	}
+
+	sort.Slice(matches, func(a, b int) bool {
+		if matches[a].Score == matches[b].Score {
+			return matches[a].Key < matches[b].Key
+		}
+		return matches[a].Score > matches[b].Score
+	})
+	return
+}
+
+func distinct(print Fingerprint) map[uint32]bool {
+	if len(print) > indexedFrames {
+		print = print[:indexedFrames]
+	}
+	out := map[uint32]bool{}
+	for _, it := range print {
+		out[it] = true
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"primetools/pkg/files"
+)
+
+/*
+	Notes of half a second with a few harmonics, delayed by offset seconds and
+	with some noise added
+*/
+func melody(notes []float64, rate int, offset float64, noise float64) *audio {
+	random := rand.New(rand.NewSource(int64(rate)))
+	note := rate / 2
+	out := &audio{rate: rate, samples: make([]float32, int(offset*float64(rate))+note*len(notes))}
+	for i := range out.samples {
+		t := float64(i)/float64(rate) - offset
+		v := 0.0
+		if t >= 0 {
+			freq := notes[int(t*2)%len(notes)]
+			for h := 1.0; h <= 3; h++ {
+				v += math.Sin(2*math.Pi*freq*h*t) / h
+			}
+		}
+		out.samples[i] = float32(0.3*v + noise*(random.Float64()*2-1))
+	}
+	return out
+}
+
+var (
+	// 32 notes of a minor scale from A3
+	tune = []float64{
+		220, 261.63, 329.63, 293.66, 246.94, 329.63, 392, 349.23,
+		220, 196, 261.63, 246.94, 293.66, 349.23, 329.63, 440,
+		392, 329.63, 293.66, 220, 246.94, 261.63, 196, 174.61,
+		220, 293.66, 349.23, 392, 329.63, 261.63, 246.94, 220,
+	}
+	// the same notes shuffled into another tune
+	other = []float64{
+		392, 174.61, 440, 220, 329.63, 196, 246.94, 293.66,
+		261.63, 349.23, 220, 392, 246.94, 329.63, 196, 293.66,
+		440, 220, 261.63, 349.23, 174.61, 329.63, 246.94, 392,
+		293.66, 196, 220, 261.63, 329.63, 349.23, 220, 246.94,
+	}
+)
+
+func TestSimilarity(t *testing.T) {
+	print := fingerprint(melody(tune, 44100, 0, 0))
+	if len(print) < minOverlap {
+		t.Fatalf("fingerprint of %d values", len(print))
+	}
+	if score := Similarity(print, print); score != 1 {
+		t.Errorf("fingerprint scores %v against itself", score)
+	}
+
+	// another rate, a second of silence in front and some noise
+	same := fingerprint(melody(tune, 22050, 1, 0.05))
+	if score := Similarity(print, same); score < 0.8 {
+		t.Errorf("same tune scores %v", score)
+	}
+
+	different := fingerprint(melody(other, 44100, 0, 0))
+	if score := Similarity(print, different); score > 0.4 {
+		t.Errorf("other tune scores %v", score)
+	}
+
+	if score := Similarity(print, print[:minOverlap-1]); score != 0 {
+		t.Errorf("too short fingerprint scores %v", score)
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	index := NewIndex()
+	index.Add("tune", fingerprint(melody(tune, 44100, 0, 0)))
+	index.Add("other", fingerprint(melody(other, 44100, 0, 0)))
+	index.Add("empty", nil)
+	if index.Len() != 2 {
+		t.Fatalf("%d fingerprints indexed instead of 2", index.Len())
+	}
+
+	matches := index.Search(fingerprint(melody(tune, 22050, 1, 0.05)))
+	if len(matches) != 1 || matches[0].Key != "tune" {
+		t.Errorf("matches are %+v", matches)
+	}
+}
+
+/*
+	16 bits mono wave file of the samples
+*/
+func writeWAV(t *testing.T, path string, in *audio) {
+	data := &bytes.Buffer{}
+	for _, it := range in.samples {
+		binary.Write(data, binary.LittleEndian, int16(it*32767))
+	}
+
+	content := &bytes.Buffer{}
+	content.WriteString("RIFF")
+	binary.Write(content, binary.LittleEndian, uint32(36+data.Len()))
+	content.WriteString("WAVEfmt ")
+	binary.Write(content, binary.LittleEndian, []uint32{16, 1 | 1<<16, uint32(in.rate), uint32(in.rate * 2), 2 | 16<<16})
+	content.WriteString("data")
+	binary.Write(content, binary.LittleEndian, uint32(data.Len()))
+	content.Write(data.Bytes())
+
+	if err := ioutil.WriteFile(path, content.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	CacheFile = filepath.Join(t.TempDir(), "fingerprints.json")
+	cache.loaded = false
+
+	path := filepath.Join(t.TempDir(), "tune.wav")
+	writeWAV(t, path, melody(tune, 11025, 0, 0))
+
+	print, err := Of(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(print) == 0 || !reflect.DeepEqual(unpack(pack(print)), print) {
+		t.Fatalf("fingerprint of %d values isn't packed back", len(print))
+	}
+	if err = SaveCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a modified file is fingerprinted again
+	cache.loaded = false
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path, []byte("RIFF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Of(path); err == nil {
+		t.Error("modified file isn't fingerprinted again")
+	}
+
+	// the last fingerprint saved is kept once the file is gone
+	cache.loaded = false
+	if err = os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := Of(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cached, print) {
+		t.Error("cached fingerprint differs")
+	}
+	if entry := cache.entries[files.NormalizePath(path)]; entry == nil || entry.Size != stat.Size() {
+		t.Errorf("cache entry is %+v", entry)
+	}
+}
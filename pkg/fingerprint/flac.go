@@ -0,0 +1,353 @@
+package fingerprint
+
+import (
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	flacIndependent = 7
+	flacLeftSide    = 8
+	flacSideRight   = 9
+	flacMidSide     = 10
+)
+
+type flacStream struct {
+	rate     int
+	channels int
+	depth    int
+	buffers  [][]int32
+}
+
+func decodeFLAC(content []byte) (*audio, error) {
+	pos := skipID3(content)
+	if len(content) < pos+4 || string(content[pos:pos+4]) != "fLaC" {
+		return nil, errors.New("not a flac file")
+	}
+	pos += 4
+
+	stream := &flacStream{}
+	for {
+		if pos+4 > len(content) {
+			return nil, errors.New("truncated metadata")
+		}
+		header := content[pos]
+		length := int(content[pos+1])<<16 | int(content[pos+2])<<8 | int(content[pos+3])
+		pos += 4
+		if pos+length > len(content) {
+			return nil, errors.New("truncated metadata")
+		}
+
+		if header&0x7f == 0 && length >= 18 {
+			// STREAMINFO: 20 bits rate, 3 bits channels - 1, 5 bits depth - 1
+			packed := binary.BigEndian.Uint64(content[pos+10 : pos+18])
+			stream.rate = int(packed >> 44)
+			stream.channels = int(packed>>41&0x7) + 1
+			stream.depth = int(packed>>36&0x1f) + 1
+		}
+		pos += length
+
+		if header&0x80 != 0 {
+			break
+		}
+	}
+
+	if stream.rate == 0 {
+		return nil, errors.New("missing stream info")
+	}
+
+	out := &audio{rate: stream.rate}
+	rd := &bitReader{data: content[pos:]}
+	for !out.full() && !rd.eof() {
+		block, depth, err := stream.frame(rd)
+		if err != nil {
+			// ignore anything after the last valid frame, ie: ID3v1 tags
+			if len(out.samples) > 0 {
+				break
+			}
+			return nil, err
+		}
+
+		scale := float64(int64(1)<<uint(depth-1)) * float64(len(block))
+		for i := range block[0] {
+			sum := int64(0)
+			for _, it := range block {
+				sum += int64(it[i])
+			}
+			out.samples = append(out.samples, float32(float64(sum)/scale))
+		}
+	}
+	return out, nil
+}
+
+/*
+	Decode the next frame, return the samples of every channel and their depth
+*/
+func (s *flacStream) frame(rd *bitReader) ([][]int32, int, error) {
+	rd.align()
+	if rd.bits(14) != 0x3ffe {
+		return nil, 0, errors.New("lost frame sync")
+	}
+	rd.bits(2) // reserved, blocking strategy
+
+	sizeCode := rd.bits(4)
+	rateCode := rd.bits(4)
+	assignment := int(rd.bits(4))
+	depthCode := rd.bits(3)
+	rd.bits(1)
+
+	// frame or sample number, utf-8 like coded
+	first := rd.bits(8)
+	if ones := bits.LeadingZeros8(^uint8(first)); ones > 1 {
+		rd.bits(8 * (ones - 1))
+	}
+
+	size := 0
+	switch {
+	case sizeCode == 1:
+		size = 192
+	case sizeCode >= 2 && sizeCode <= 5:
+		size = 576 << (sizeCode - 2)
+	case sizeCode == 6:
+		size = int(rd.bits(8)) + 1
+	case sizeCode == 7:
+		size = int(rd.bits(16)) + 1
+	case sizeCode >= 8:
+		size = 256 << (sizeCode - 8)
+	default:
+		return nil, 0, errors.New("reserved block size")
+	}
+
+	switch rateCode {
+	case 12:
+		rd.bits(8)
+	case 13, 14:
+		rd.bits(16)
+	case 15:
+		return nil, 0, errors.New("invalid sample rate")
+	}
+	rd.bits(8) // crc-8
+
+	depth := 0
+	switch depthCode {
+	case 0:
+		depth = s.depth
+	case 1:
+		depth = 8
+	case 2:
+		depth = 12
+	case 4:
+		depth = 16
+	case 5:
+		depth = 20
+	case 6:
+		depth = 24
+	case 7:
+		depth = 32
+	default:
+		return nil, 0, errors.New("reserved sample size")
+	}
+
+	channels := assignment + 1
+	if assignment > flacIndependent {
+		if assignment > flacMidSide {
+			return nil, 0, errors.New("reserved channel assignment")
+		}
+		channels = 2
+	}
+
+	for len(s.buffers) < channels {
+		s.buffers = append(s.buffers, nil)
+	}
+	block := s.buffers[:channels]
+
+	for ch := range block {
+		if cap(block[ch]) < size {
+			block[ch] = make([]int32, size)
+		}
+		block[ch] = block[ch][:size]
+
+		// the side channel has an extra bit
+		bits := depth
+		if (assignment == flacLeftSide && ch == 1) || (assignment == flacSideRight && ch == 0) || (assignment == flacMidSide && ch == 1) {
+			bits++
+		}
+		if err := flacSubframe(rd, block[ch], bits); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	rd.align()
+	rd.bits(16) // crc-16
+	if rd.overrun() {
+		return nil, 0, errors.New("truncated frame")
+	}
+
+	switch assignment {
+	case flacLeftSide:
+		for i, side := range block[1] {
+			block[1][i] = block[0][i] - side
+		}
+	case flacSideRight:
+		for i, side := range block[0] {
+			block[0][i] = side + block[1][i]
+		}
+	case flacMidSide:
+		for i, side := range block[1] {
+			mid := block[0][i]<<1 | side&1
+			block[0][i] = (mid + side) >> 1
+			block[1][i] = (mid - side) >> 1
+		}
+	}
+	return block, depth, nil
+}
+
+func flacSubframe(rd *bitReader, out []int32, bits int) error {
+	if rd.bit() {
+		return errors.New("invalid subframe padding")
+	}
+	kind := int(rd.bits(6))
+
+	wasted := 0
+	if rd.bit() {
+		wasted = rd.unary() + 1
+		bits -= wasted
+	}
+	if bits <= 0 || bits > 32 {
+		return errors.Errorf("unsupported sample size of %d bits", bits)
+	}
+
+	switch {
+	case kind == 0:
+		value := rd.signed(bits)
+		for i := range out {
+			out[i] = value
+		}
+	case kind == 1:
+		for i := range out {
+			out[i] = rd.signed(bits)
+		}
+	case kind >= 8 && kind <= 12:
+		order := kind - 8
+		if order > len(out) {
+			return errors.New("predictor order larger than the block")
+		}
+		for i := 0; i < order; i++ {
+			out[i] = rd.signed(bits)
+		}
+		if err := flacResidual(rd, out, order); err != nil {
+			return err
+		}
+		flacFixed(out, order)
+	case kind >= 32:
+		order := kind - 31
+		if order > len(out) {
+			return errors.New("predictor order larger than the block")
+		}
+		for i := 0; i < order; i++ {
+			out[i] = rd.signed(bits)
+		}
+		precision := int(rd.bits(4)) + 1
+		if precision == 16 {
+			return errors.New("invalid coefficient precision")
+		}
+		shift := rd.signed(5)
+		if shift < 0 {
+			return errors.New("negative lpc shift")
+		}
+		coefs := make([]int64, order)
+		for i := range coefs {
+			coefs[i] = int64(rd.signed(precision))
+		}
+		if err := flacResidual(rd, out, order); err != nil {
+			return err
+		}
+		for i := order; i < len(out); i++ {
+			sum := int64(0)
+			for j, c := range coefs {
+				sum += c * int64(out[i-1-j])
+			}
+			out[i] += int32(sum >> uint(shift))
+		}
+	default:
+		return errors.New("reserved subframe type")
+	}
+
+	if wasted > 0 {
+		for i := range out {
+			out[i] <<= uint(wasted)
+		}
+	}
+	return nil
+}
+
+/*
+	Rice coded residual, stored after the warmup samples
+*/
+func flacResidual(rd *bitReader, out []int32, order int) error {
+	method := rd.bits(2)
+	if method > 1 {
+		return errors.New("reserved residual coding method")
+	}
+	paramBits, escape := 4, uint32(15)
+	if method == 1 {
+		paramBits, escape = 5, 31
+	}
+
+	partitionOrder := uint(rd.bits(4))
+	partitionSize := len(out) >> partitionOrder
+	if partitionSize < order || partitionSize<<partitionOrder != len(out) {
+		return errors.New("invalid residual partition order")
+	}
+
+	idx := order
+	for p := 0; p < 1<<partitionOrder; p++ {
+		count := partitionSize
+		if p == 0 {
+			count -= order
+		}
+
+		param := rd.bits(paramBits)
+		if param == escape {
+			bits := int(rd.bits(5))
+			for i := 0; i < count; i++ {
+				out[idx] = rd.signed(bits)
+				idx++
+			}
+			continue
+		}
+
+		for i := 0; i < count; i++ {
+			value := uint32(rd.unary())<<param | rd.bits(int(param))
+			out[idx] = int32(value>>1) ^ -int32(value&1)
+			idx++
+		}
+		if rd.overrun() {
+			return errors.New("truncated residual")
+		}
+	}
+	return nil
+}
+
+func flacFixed(out []int32, order int) {
+	switch order {
+	case 1:
+		for i := 1; i < len(out); i++ {
+			out[i] += out[i-1]
+		}
+	case 2:
+		for i := 2; i < len(out); i++ {
+			out[i] += 2*out[i-1] - out[i-2]
+		}
+	case 3:
+		for i := 3; i < len(out); i++ {
+			out[i] += 3*out[i-1] - 3*out[i-2] + out[i-3]
+		}
+	case 4:
+		for i := 4; i < len(out); i++ {
+			out[i] += 4*out[i-1] - 6*out[i-2] + 4*out[i-3] - out[i-4]
+		}
+	}
+}
@@ -0,0 +1,866 @@
+package fingerprint
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	mp3Version25 = 0
+	mp3Version2  = 2
+	mp3Version1  = 3
+
+	mp3JointStereo = 1
+	mp3Mono        = 3
+
+	mp3Granule = 576
+)
+
+var mp3Bitrates = [2][16]int{
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+var mp3SampleRates = [4][3]int{
+	mp3Version1:  {44100, 48000, 32000},
+	mp3Version2:  {22050, 24000, 16000},
+	mp3Version25: {11025, 12000, 8000},
+}
+
+/*
+	Scalefactor bands boundaries, long then short blocks, indexed by sample rate
+*/
+var mp3Bands = map[int][2][]int{
+	44100: {
+		{0, 4, 8, 12, 16, 20, 24, 30, 36, 44, 52, 62, 74, 90, 110, 134, 162, 196, 238, 288, 342, 418, 576},
+		{0, 4, 8, 12, 16, 22, 30, 40, 52, 66, 84, 106, 136, 192},
+	},
+	48000: {
+		{0, 4, 8, 12, 16, 20, 24, 30, 36, 42, 50, 60, 72, 88, 106, 128, 156, 190, 230, 276, 330, 384, 576},
+		{0, 4, 8, 12, 16, 22, 28, 38, 50, 64, 80, 100, 126, 192},
+	},
+	32000: {
+		{0, 4, 8, 12, 16, 20, 24, 30, 36, 44, 54, 66, 82, 102, 126, 156, 194, 240, 296, 364, 448, 550, 576},
+		{0, 4, 8, 12, 16, 22, 30, 42, 58, 78, 104, 138, 180, 192},
+	},
+	22050: {
+		{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+		{0, 4, 8, 12, 18, 24, 32, 42, 56, 74, 100, 132, 174, 192},
+	},
+	24000: {
+		{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 114, 136, 162, 194, 232, 278, 332, 394, 464, 540, 576},
+		{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 136, 180, 192},
+	},
+	16000: {
+		{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+		{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+	},
+	12000: {
+		{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+		{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+	},
+	11025: {
+		{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+		{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+	},
+	8000: {
+		{0, 12, 24, 36, 48, 60, 72, 88, 108, 132, 160, 192, 232, 280, 336, 400, 476, 566, 568, 570, 572, 574, 576},
+		{0, 8, 16, 24, 36, 52, 72, 96, 124, 160, 162, 164, 166, 192},
+	},
+}
+
+var (
+	mp3Slen = [2][16]int{
+		{0, 0, 0, 0, 3, 1, 1, 1, 2, 2, 2, 3, 3, 3, 4, 4},
+		{0, 1, 2, 3, 0, 1, 2, 3, 1, 2, 3, 1, 2, 3, 2, 3},
+	}
+
+	// number of scalefactor bands of each slen for the low sampling frequencies
+	mp3LsfBands = [6][3][4]int{
+		{{6, 5, 5, 5}, {9, 9, 9, 9}, {6, 9, 9, 9}},
+		{{6, 5, 7, 3}, {9, 9, 12, 6}, {6, 9, 12, 6}},
+		{{11, 10, 0, 0}, {18, 18, 0, 0}, {15, 18, 0, 0}},
+		{{7, 7, 7, 0}, {12, 12, 12, 0}, {6, 15, 12, 0}},
+		{{6, 6, 6, 3}, {12, 9, 9, 6}, {6, 12, 9, 6}},
+		{{8, 8, 5, 0}, {15, 12, 9, 0}, {6, 18, 9, 0}},
+	}
+
+	mp3Pretab = [22]int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 3, 3, 3, 2, 0}
+
+	mp3Linbits = [32]int{
+		16: 1, 17: 2, 18: 3, 19: 4, 20: 6, 21: 8, 22: 10, 23: 13,
+		24: 4, 25: 5, 26: 6, 27: 7, 28: 8, 29: 9, 30: 11, 31: 13,
+	}
+
+	mp3AliasCoefs = [8]float64{-0.6, -0.535, -0.33, -0.185, -0.095, -0.041, -0.0142, -0.0037}
+)
+
+var (
+	mp3Trees   [34][]int32
+	mp3Pow43   [8207]float64
+	mp3Cs      [8]float32
+	mp3Ca      [8]float32
+	mp3Windows [4][36]float32
+	mp3Cos36   [18][36]float32
+	mp3Cos12   [6][12]float32
+	mp3Matrix  [64][32]float32
+)
+
+func init() {
+	for t, codes := range mp3HuffmanCodes {
+		if len(codes) > 0 {
+			mp3Trees[t] = mp3Tree(codes)
+		}
+	}
+
+	for i := range mp3Pow43 {
+		mp3Pow43[i] = math.Pow(float64(i), 4.0/3.0)
+	}
+
+	for i, c := range mp3AliasCoefs {
+		norm := math.Sqrt(1 + c*c)
+		mp3Cs[i] = float32(1 / norm)
+		mp3Ca[i] = float32(c / norm)
+	}
+
+	for i := 0; i < 36; i++ {
+		mp3Windows[0][i] = float32(math.Sin(math.Pi / 36 * (float64(i) + 0.5)))
+	}
+	for i := 0; i < 36; i++ {
+		switch {
+		case i < 18:
+			mp3Windows[1][i] = mp3Windows[0][i]
+		case i < 24:
+			mp3Windows[1][i] = 1
+		case i < 30:
+			mp3Windows[1][i] = float32(math.Sin(math.Pi / 12 * (float64(i-18) + 0.5)))
+		}
+		switch {
+		case i < 6:
+		case i < 12:
+			mp3Windows[3][i] = float32(math.Sin(math.Pi / 12 * (float64(i-6) + 0.5)))
+		case i < 18:
+			mp3Windows[3][i] = 1
+		default:
+			mp3Windows[3][i] = mp3Windows[0][i]
+		}
+		if i < 12 {
+			mp3Windows[2][i] = float32(math.Sin(math.Pi / 12 * (float64(i) + 0.5)))
+		}
+	}
+
+	for i := 0; i < 18; i++ {
+		for j := 0; j < 36; j++ {
+			mp3Cos36[i][j] = float32(math.Cos(math.Pi / 72 * float64(2*j+1+18) * float64(2*i+1)))
+		}
+	}
+	for i := 0; i < 6; i++ {
+		for j := 0; j < 12; j++ {
+			mp3Cos12[i][j] = float32(math.Cos(math.Pi / 24 * float64(2*j+1+6) * float64(2*i+1)))
+		}
+	}
+	for i := 0; i < 64; i++ {
+		for j := 0; j < 32; j++ {
+			mp3Matrix[i][j] = float32(math.Cos(float64((16+i)*(2*j+1)) * math.Pi / 64))
+		}
+	}
+}
+
+/*
+	Binary decoding tree, a node is a pair of children, a negative child is a
+	leaf holding ^value
+*/
+func mp3Tree(codes []uint32) []int32 {
+	tree := []int32{0, 0}
+	for _, it := range codes {
+		length := int(it >> 27)
+		value := int32(it >> 19 & 0xff)
+		code := it & 0x7ffff
+
+		node := 0
+		for i := length - 1; i >= 0; i-- {
+			branch := node + int(code>>uint(i)&1)
+			if i == 0 {
+				tree[branch] = ^value
+				break
+			}
+			if tree[branch] == 0 {
+				tree[branch] = int32(len(tree))
+				tree = append(tree, 0, 0)
+			}
+			node = int(tree[branch])
+		}
+	}
+	return tree
+}
+
+func mp3Huffman(rd *bitReader, tree []int32) int {
+	node := int32(0)
+	for i := 0; i < 20; i++ {
+		node = tree[node+int32(rd.bits(1))]
+		if node < 0 {
+			return int(^node)
+		}
+	}
+	return 0
+}
+
+type mp3Header struct {
+	version    int
+	channels   int
+	mode       int
+	modeExt    int
+	rate       int
+	protection bool
+	size       int
+}
+
+func (h *mp3Header) lsf() bool {
+	return h.version != mp3Version1
+}
+
+func mp3ParseHeader(b []byte) (h mp3Header, ok bool) {
+	if b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return
+	}
+
+	h.version = int(b[1] >> 3 & 3)
+	layer := b[1] >> 1 & 3
+	bitrate := int(b[2] >> 4)
+	rate := int(b[2] >> 2 & 3)
+	if h.version == 1 || layer != 1 || bitrate == 0 || bitrate == 15 || rate == 3 {
+		// reserved values, other layers or free format
+		return
+	}
+
+	h.protection = b[1]&1 == 0
+	h.rate = mp3SampleRates[h.version][rate]
+	h.mode = int(b[3] >> 6)
+	h.modeExt = int(b[3] >> 4 & 3)
+	h.channels = 2
+	if h.mode == mp3Mono {
+		h.channels = 1
+	}
+
+	padding := int(b[2] >> 1 & 1)
+	if h.lsf() {
+		h.size = 72000*mp3Bitrates[1][bitrate]/h.rate + padding
+	} else {
+		h.size = 144000*mp3Bitrates[0][bitrate]/h.rate + padding
+	}
+	return h, true
+}
+
+type mp3Channel struct {
+	part23     int
+	bigValues  int
+	globalGain int
+	compress   int
+	switching  bool
+	blockType  int
+	mixed      bool
+	tables     [3]int
+	subGain    [3]int
+	region0    int
+	region1    int
+	preflag    bool
+	sfScale    int
+	count1     int
+	scalefacL  [22]int
+	scalefacS  [13][3]int
+	nonzero    int
+}
+
+func (c *mp3Channel) short() bool {
+	return c.switching && c.blockType == 2
+}
+
+type mp3Decoder struct {
+	header    mp3Header
+	scfsi     [2][4]bool
+	granules  [2][2]mp3Channel
+	reservoir []byte
+	lines     [2][mp3Granule]float32
+	overlap   [2][32][18]float32
+	synth     [1024]float32
+	subbands  int
+	out       []float32
+}
+
+func decodeMP3(content []byte) (*audio, error) {
+	pos := skipID3(content)
+	d := &mp3Decoder{}
+
+	var first *mp3Header
+	for pos+4 <= len(content) {
+		h, ok := mp3ParseHeader(content[pos:])
+		if ok && first != nil && (h.version != first.version || h.rate != first.rate) {
+			ok = false
+		}
+		if ok && first == nil {
+			// the next frame must follow, otherwise it was a false sync
+			next := pos + h.size
+			if next+4 <= len(content) {
+				if _, valid := mp3ParseHeader(content[next:]); !valid {
+					ok = false
+				}
+			}
+		}
+		if !ok || pos+h.size > len(content) {
+			pos++
+			continue
+		}
+
+		if first == nil {
+			first = &h
+			// only the subbands under the half of the fingerprint sample
+			// rate are needed, the others are left out of the synthesis
+			d.subbands = int(math.Ceil(chromaRate / 2 / (float64(h.rate) / 64)))
+			if d.subbands > 32 {
+				d.subbands = 32
+			}
+		}
+
+		d.header = h
+		d.frame(content[pos : pos+h.size])
+		pos += h.size
+
+		if len(d.out) >= h.rate*maxSeconds {
+			break
+		}
+	}
+
+	if first == nil {
+		return nil, errors.New("no mpeg layer III frame found")
+	}
+	return &audio{rate: first.rate, samples: d.out}, nil
+}
+
+func (d *mp3Decoder) frame(frame []byte) {
+	h := &d.header
+	offset := 4
+	if h.protection {
+		offset += 2
+	}
+
+	granules := 2
+	sideSize := 32
+	if h.lsf() {
+		granules = 1
+		sideSize = 17
+		if h.channels == 1 {
+			sideSize = 9
+		}
+	} else if h.channels == 1 {
+		sideSize = 17
+	}
+	if offset+sideSize > len(frame) {
+		return
+	}
+
+	rd := &bitReader{data: frame[offset : offset+sideSize]}
+	begin := d.sideInfo(rd, granules)
+
+	// main data can start in the previous frames
+	start := len(d.reservoir) - begin
+	d.reservoir = append(d.reservoir, frame[offset+sideSize:]...)
+	if start < 0 {
+		for gr := 0; gr < granules; gr++ {
+			d.out = append(d.out, make([]float32, mp3Granule)...)
+		}
+		d.trimReservoir()
+		return
+	}
+
+	main := &bitReader{data: d.reservoir[start:]}
+	for gr := 0; gr < granules; gr++ {
+		for ch := 0; ch < h.channels; ch++ {
+			c := &d.granules[gr][ch]
+			end := main.pos + c.part23
+			if h.lsf() {
+				d.lsfScalefactors(main, c, ch)
+			} else {
+				d.scalefactors(main, gr, ch)
+			}
+			d.huffman(main, c, end, &d.lines[ch])
+			main.pos = end
+			d.requantize(c, &d.lines[ch])
+		}
+		d.stereo(gr)
+
+		mono := d.lines[0][:]
+		for ch := 0; ch < h.channels; ch++ {
+			c := &d.granules[gr][ch]
+			d.antialias(c, &d.lines[ch])
+			d.hybrid(c, ch, &d.lines[ch])
+			if ch > 0 {
+				for i, it := range d.lines[ch] {
+					mono[i] = (mono[i] + it) / 2
+				}
+			}
+		}
+		d.synthesis(mono)
+	}
+	d.trimReservoir()
+}
+
+func (d *mp3Decoder) trimReservoir() {
+	// main data can't start more than 511 bytes before the frame
+	if len(d.reservoir) > 4096 {
+		d.reservoir = append(d.reservoir[:0], d.reservoir[len(d.reservoir)-1024:]...)
+	}
+}
+
+/*
+	Parse the side information, return the start of the main data as an offset
+	before the frame main data
+*/
+func (d *mp3Decoder) sideInfo(rd *bitReader, granules int) int {
+	h := &d.header
+	var begin int
+	if h.lsf() {
+		begin = int(rd.bits(8))
+		rd.bits(h.channels)
+	} else {
+		begin = int(rd.bits(9))
+		if h.channels == 1 {
+			rd.bits(5)
+		} else {
+			rd.bits(3)
+		}
+		for ch := 0; ch < h.channels; ch++ {
+			for band := 0; band < 4; band++ {
+				d.scfsi[ch][band] = rd.bit()
+			}
+		}
+	}
+
+	bands := mp3Bands[h.rate][0]
+	for gr := 0; gr < granules; gr++ {
+		for ch := 0; ch < h.channels; ch++ {
+			c := &d.granules[gr][ch]
+			c.part23 = int(rd.bits(12))
+			c.bigValues = int(rd.bits(9))
+			if c.bigValues > mp3Granule/2 {
+				c.bigValues = mp3Granule / 2
+			}
+			c.globalGain = int(rd.bits(8))
+			if h.lsf() {
+				c.compress = int(rd.bits(9))
+			} else {
+				c.compress = int(rd.bits(4))
+			}
+
+			c.switching = rd.bit()
+			if c.switching {
+				c.blockType = int(rd.bits(2))
+				c.mixed = rd.bit()
+				c.tables[0] = int(rd.bits(5))
+				c.tables[1] = int(rd.bits(5))
+				c.tables[2] = 0
+				for w := 0; w < 3; w++ {
+					c.subGain[w] = int(rd.bits(3))
+				}
+				c.region0 = bands[8]
+				if c.blockType == 2 {
+					c.region0 = 36
+				}
+				c.region1 = mp3Granule
+			} else {
+				c.blockType = 0
+				c.mixed = false
+				for i := 0; i < 3; i++ {
+					c.tables[i] = int(rd.bits(5))
+				}
+				c.subGain = [3]int{}
+				r0 := int(rd.bits(4))
+				r1 := int(rd.bits(3))
+				c.region0 = bands[minInt(r0+1, 22)]
+				c.region1 = bands[minInt(r0+r1+2, 22)]
+			}
+
+			if h.lsf() {
+				c.preflag = false
+			} else {
+				c.preflag = rd.bit()
+			}
+			c.sfScale = int(rd.bits(1))
+			c.count1 = int(rd.bits(1))
+		}
+	}
+	return begin
+}
+
+func (d *mp3Decoder) scalefactors(rd *bitReader, gr int, ch int) {
+	c := &d.granules[gr][ch]
+	slen1 := mp3Slen[0][c.compress]
+	slen2 := mp3Slen[1][c.compress]
+
+	if c.short() {
+		start := 0
+		if c.mixed {
+			for sfb := 0; sfb < 8; sfb++ {
+				c.scalefacL[sfb] = int(rd.bits(slen1))
+			}
+			start = 3
+		}
+		for sfb := start; sfb < 12; sfb++ {
+			slen := slen1
+			if sfb >= 6 {
+				slen = slen2
+			}
+			for w := 0; w < 3; w++ {
+				c.scalefacS[sfb][w] = int(rd.bits(slen))
+			}
+		}
+		c.scalefacS[12] = [3]int{}
+		return
+	}
+
+	groups := [5]int{0, 6, 11, 16, 21}
+	for g := 0; g < 4; g++ {
+		slen := slen1
+		if g >= 2 {
+			slen = slen2
+		}
+		for sfb := groups[g]; sfb < groups[g+1]; sfb++ {
+			if gr == 1 && d.scfsi[ch][g] {
+				c.scalefacL[sfb] = d.granules[0][ch].scalefacL[sfb]
+			} else {
+				c.scalefacL[sfb] = int(rd.bits(slen))
+			}
+		}
+	}
+	c.scalefacL[21] = 0
+}
+
+func (d *mp3Decoder) lsfScalefactors(rd *bitReader, c *mp3Channel, ch int) {
+	var slen [4]int
+	table := 0
+	if ch == 1 && d.header.mode == mp3JointStereo && d.header.modeExt&1 != 0 {
+		// intensity stereo channel
+		sfc := c.compress >> 1
+		switch {
+		case sfc < 180:
+			slen = [4]int{sfc / 36, sfc % 36 / 6, sfc % 36 % 6, 0}
+			table = 3
+		case sfc < 244:
+			sfc -= 180
+			slen = [4]int{sfc % 64 >> 4, sfc % 16 >> 2, sfc % 4, 0}
+			table = 4
+		default:
+			sfc -= 244
+			slen = [4]int{sfc / 3, sfc % 3, 0, 0}
+			table = 5
+		}
+	} else {
+		sfc := c.compress
+		switch {
+		case sfc < 400:
+			slen = [4]int{(sfc >> 4) / 5, (sfc >> 4) % 5, sfc & 15 >> 2, sfc & 3}
+		case sfc < 500:
+			sfc -= 400
+			slen = [4]int{(sfc >> 2) / 5, (sfc >> 2) % 5, sfc & 3, 0}
+			table = 1
+		default:
+			sfc -= 500
+			slen = [4]int{sfc / 3, sfc % 3, 0, 0}
+			table = 2
+			c.preflag = true
+		}
+	}
+
+	block := 0
+	if c.short() {
+		block = 1
+		if c.mixed {
+			block = 2
+		}
+	}
+
+	var values [39]int
+	n := 0
+	for i, count := range mp3LsfBands[table][block] {
+		for j := 0; j < count; j++ {
+			values[n] = int(rd.bits(slen[i]))
+			n++
+		}
+	}
+
+	c.scalefacL = [22]int{}
+	c.scalefacS = [13][3]int{}
+	switch block {
+	case 0:
+		copy(c.scalefacL[:], values[:21])
+	case 1:
+		for i := 0; i < 36; i++ {
+			c.scalefacS[i/3][i%3] = values[i]
+		}
+	case 2:
+		copy(c.scalefacL[:6], values[:6])
+		for i := 0; i < 27; i++ {
+			c.scalefacS[3+i/3][i%3] = values[6+i]
+		}
+	}
+}
+
+func (d *mp3Decoder) huffman(rd *bitReader, c *mp3Channel, end int, lines *[mp3Granule]float32) {
+	*lines = [mp3Granule]float32{}
+
+	limit := c.bigValues * 2
+	i := 0
+	for ; i < limit; i += 2 {
+		region := 2
+		if i < c.region0 {
+			region = 0
+		} else if i < c.region1 {
+			region = 1
+		}
+
+		table := c.tables[region]
+		tree := mp3Trees[table]
+		if table >= 24 {
+			tree = mp3Trees[24]
+		} else if table >= 16 {
+			tree = mp3Trees[16]
+		}
+		if tree == nil {
+			continue
+		}
+
+		value := mp3Huffman(rd, tree)
+		x, y := value>>4, value&15
+		lines[i] = float32(mp3Value(rd, x, mp3Linbits[table]))
+		lines[i+1] = float32(mp3Value(rd, y, mp3Linbits[table]))
+	}
+
+	tree := mp3Trees[32+c.count1]
+	for i+4 <= mp3Granule && rd.pos < end {
+		value := mp3Huffman(rd, tree)
+		var quad [4]float32
+		for j := 0; j < 4; j++ {
+			if value>>uint(3-j)&1 != 0 {
+				quad[j] = 1
+				if rd.bit() {
+					quad[j] = -1
+				}
+			}
+		}
+		if rd.pos > end {
+			// the last quadruple overran the granule
+			break
+		}
+		copy(lines[i:], quad[:])
+		i += 4
+	}
+	c.nonzero = i
+}
+
+func mp3Value(rd *bitReader, value int, linbits int) int {
+	if linbits > 0 && value == 15 {
+		value += int(rd.bits(linbits))
+	}
+	if value != 0 && rd.bit() {
+		return -value
+	}
+	return value
+}
+
+func (d *mp3Decoder) requantize(c *mp3Channel, lines *[mp3Granule]float32) {
+	bands := mp3Bands[d.header.rate]
+	multiplier := 0.5 * float64(1+c.sfScale)
+	gain := 0.25 * float64(c.globalGain-210)
+
+	scale := func(from, to int, exponent float64) {
+		factor := math.Exp2(exponent)
+		for i := from; i < to && i < c.nonzero; i++ {
+			v := lines[i]
+			if v < 0 {
+				lines[i] = float32(-mp3Pow43[int(-v)] * factor)
+			} else if v > 0 {
+				lines[i] = float32(mp3Pow43[int(v)] * factor)
+			}
+		}
+	}
+
+	long := func(sfb int) float64 {
+		sf := c.scalefacL[sfb]
+		if c.preflag {
+			sf += mp3Pretab[sfb]
+		}
+		return gain - multiplier*float64(sf)
+	}
+
+	if !c.short() {
+		for sfb := 0; sfb < 22; sfb++ {
+			scale(bands[0][sfb], bands[0][sfb+1], long(sfb))
+		}
+		return
+	}
+
+	start := 0
+	if c.mixed {
+		for sfb := 0; sfb < 8 && bands[0][sfb] < 36; sfb++ {
+			scale(bands[0][sfb], bands[0][sfb+1], long(sfb))
+		}
+		start = 3
+	}
+	for sfb := start; sfb < 13; sfb++ {
+		width := bands[1][sfb+1] - bands[1][sfb]
+		for w := 0; w < 3; w++ {
+			from := bands[1][sfb]*3 + w*width
+			exponent := gain - 2*float64(c.subGain[w]) - multiplier*float64(c.scalefacS[sfb][w])
+			scale(from, from+width, exponent)
+		}
+	}
+
+	// interleave the windows, each subband then holds 6 values of each window
+	var reordered [mp3Granule]float32
+	for sfb := start; sfb < 13; sfb++ {
+		width := bands[1][sfb+1] - bands[1][sfb]
+		base := bands[1][sfb] * 3
+		for w := 0; w < 3; w++ {
+			for j := 0; j < width; j++ {
+				reordered[base+3*j+w] = lines[base+w*width+j]
+			}
+		}
+	}
+	from := bands[1][start] * 3
+	copy(lines[from:], reordered[from:])
+}
+
+func (d *mp3Decoder) stereo(gr int) {
+	h := &d.header
+	if h.mode != mp3JointStereo || h.modeExt&2 == 0 {
+		return
+	}
+
+	// intensity stereo only changes the balance of the channels, which is lost
+	// once mixed down to mono, only the mid side lines are converted
+	left, right := &d.granules[gr][0], &d.granules[gr][1]
+	limit := right.nonzero
+	if h.modeExt&1 == 0 && left.nonzero > limit {
+		limit = left.nonzero
+	}
+	for i := 0; i < limit; i++ {
+		m, s := d.lines[0][i], d.lines[1][i]
+		d.lines[0][i] = (m + s) * math.Sqrt2 / 2
+		d.lines[1][i] = (m - s) * math.Sqrt2 / 2
+	}
+	if limit > left.nonzero {
+		left.nonzero = limit
+	}
+	if limit > right.nonzero {
+		right.nonzero = limit
+	}
+}
+
+func (d *mp3Decoder) antialias(c *mp3Channel, lines *[mp3Granule]float32) {
+	subbands := d.subbands
+	if c.short() {
+		if !c.mixed {
+			return
+		}
+		subbands = 2
+	}
+
+	for sb := 1; sb < subbands; sb++ {
+		for i := 0; i < 8; i++ {
+			lo, hi := 18*sb-1-i, 18*sb+i
+			a, b := lines[lo], lines[hi]
+			lines[lo] = a*mp3Cs[i] - b*mp3Ca[i]
+			lines[hi] = b*mp3Cs[i] + a*mp3Ca[i]
+		}
+	}
+}
+
+/*
+	Inverse MDCT of each subband with overlap add, followed by the frequency
+	inversion of the odd subbands
+*/
+func (d *mp3Decoder) hybrid(c *mp3Channel, ch int, lines *[mp3Granule]float32) {
+	for sb := 0; sb < 32; sb++ {
+		in := lines[sb*18 : sb*18+18]
+		if sb >= d.subbands {
+			for i := range in {
+				in[i] = 0
+			}
+			d.overlap[ch][sb] = [18]float32{}
+			continue
+		}
+
+		blockType := c.blockType
+		if c.switching && c.mixed && sb < 2 {
+			blockType = 0
+		}
+
+		var out [36]float32
+		if blockType == 2 {
+			for w := 0; w < 3; w++ {
+				for p := 0; p < 12; p++ {
+					sum := float32(0)
+					for m := 0; m < 6; m++ {
+						sum += in[w+3*m] * mp3Cos12[m][p]
+					}
+					out[6*w+p+6] += sum * mp3Windows[2][p]
+				}
+			}
+		} else {
+			for p := 0; p < 36; p++ {
+				sum := float32(0)
+				for m := 0; m < 18; m++ {
+					sum += in[m] * mp3Cos36[m][p]
+				}
+				out[p] = sum * mp3Windows[blockType][p]
+			}
+		}
+
+		for i := 0; i < 18; i++ {
+			in[i] = out[i] + d.overlap[ch][sb][i]
+			d.overlap[ch][sb][i] = out[i+18]
+		}
+		if sb%2 == 1 {
+			for i := 1; i < 18; i += 2 {
+				in[i] = -in[i]
+			}
+		}
+	}
+}
+
+/*
+	Polyphase filter bank, from the 32 subbands back to time samples
+*/
+func (d *mp3Decoder) synthesis(lines []float32) {
+	var u [512]float32
+	for slot := 0; slot < 18; slot++ {
+		copy(d.synth[64:], d.synth[:1024-64])
+		for i := 0; i < 64; i++ {
+			sum := float32(0)
+			for sb := 0; sb < d.subbands; sb++ {
+				sum += mp3Matrix[i][sb] * lines[sb*18+slot]
+			}
+			d.synth[i] = sum
+		}
+
+		for i := 0; i < 512; i += 64 {
+			copy(u[i:i+32], d.synth[i<<1:i<<1+32])
+			copy(u[i+32:i+64], d.synth[i<<1+96:i<<1+128])
+		}
+
+		for i := 0; i < 32; i++ {
+			sum := float32(0)
+			for j := i; j < 512; j += 32 {
+				sum += u[j] * mp3SynthesisWindow[j]
+			}
+			d.out = append(d.out, sum)
+		}
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,417 @@
+package fingerprint
+
+/*
+	Huffman codes of the layer III tables, packed as length << 27 | value << 19 | code.
+	The value is x << 4 | y for the pairs and v << 3 | w << 2 | x << 1 | y for
+	the quadruples of tables 32 and 33.
+*/
+var mp3HuffmanCodes = [34][]uint32{
+	1: {
+		0x08000001, 0x10800001, 0x18880000, 0x18080001,
+	},
+	2: {
+		0x08000001, 0x18880001, 0x18080002, 0x18800003, 0x28900001, 0x29080002,
+		0x29000003, 0x31100000, 0x30100001,
+	},
+	3: {
+		0x10880001, 0x10080002, 0x10000003, 0x18800001, 0x28900001, 0x29080002,
+		0x29000003, 0x31100000, 0x30100001,
+	},
+	5: {
+		0x08000001, 0x18880001, 0x18080002, 0x18800003, 0x31880001, 0x30900004,
+		0x31080005, 0x30100006, 0x31000007, 0x39900001, 0x38980004, 0x38180005,
+		0x39800006, 0x39100007, 0x41980000, 0x41180001,
+	},
+	6: {
+		0x10880002, 0x18080003, 0x18800006, 0x18000007, 0x20900003, 0x21080004,
+		0x21000005, 0x28980002, 0x29880003, 0x29100004, 0x28100005, 0x31180001,
+		0x31900002, 0x31800003, 0x39980000, 0x38180001,
+	},
+	7: {
+		0x08000001, 0x18080002, 0x18800003, 0x20880003, 0x29080004, 0x30900007,
+		0x3010000a, 0x3100000b, 0x38a00005, 0x3a080006, 0x3a000007, 0x3898000a,
+		0x3988000b, 0x3980000c, 0x3910000d, 0x40a80003, 0x42880004, 0x42800006,
+		0x41200008, 0x42100009, 0x40200010, 0x41180011, 0x41900012, 0x40180013,
+		0x49a80002, 0x4a200003, 0x49280004, 0x4a900005, 0x4828000a, 0x49a0000b,
+		0x4a18000e, 0x4998000f, 0x52a80000, 0x52280001, 0x52a00002, 0x52980003,
+	},
+	8: {
+		0x10880001, 0x10000003, 0x18080004, 0x18800005, 0x20900002, 0x21080003,
+		0x31100005, 0x30100006, 0x31000007, 0x3a080005, 0x40a80003, 0x42880004,
+		0x41200007, 0x42100008, 0x40a00009, 0x4020000c, 0x4200000d, 0x4118000e,
+		0x4190000f, 0x40980010, 0x41880011, 0x40180012, 0x41800013, 0x4a980001,
+		0x49280003, 0x4a900004, 0x48280005, 0x49a0000a, 0x4a18000b, 0x4a80000c,
+		0x4998000d, 0x52280001, 0x51a80004, 0x52200005, 0x5aa80000, 0x5aa00001,
+	},
+	9: {
+		0x18880004, 0x18080005, 0x18800006, 0x18000007, 0x20900005, 0x21080006,
+		0x21000007, 0x28980005, 0x29880006, 0x29100008, 0x28100009, 0x30a00006,
+		0x32080007, 0x31180008, 0x31900009, 0x3018000e, 0x3180000f, 0x3a880004,
+		0x39a00005, 0x3a180006, 0x39200008, 0x3a100009, 0x3998000a, 0x3a00000b,
+		0x41a80001, 0x42980002, 0x42200004, 0x41280005, 0x42900006, 0x40a80007,
+		0x4280000e, 0x4020000f, 0x4aa80000, 0x4a280001, 0x4aa00006, 0x48280007,
+	},
+	10: {
+		0x08000001, 0x18080002, 0x18800003, 0x20880003, 0x30900008, 0x31080009,
+		0x3010000a, 0x3100000b, 0x3898000c, 0x3988000d, 0x3980000e, 0x3910000f,
+		0x40b80007, 0x43880008, 0x40b0000c, 0x4308000d, 0x4300000e, 0x40a00012,
+		0x42080013, 0x42000014, 0x41180015, 0x41900016, 0x40180017, 0x49380006,
+		0x4b900007, 0x4b800009, 0x4b10000a, 0x4830000c, 0x49b00012, 0x49300013,
+		0x48a80015, 0x4a880016, 0x4828001e, 0x4a80001f, 0x49200020, 0x4a100021,
+		0x49980022, 0x48200023, 0x52380003, 0x53a00004, 0x52b00005, 0x53280006,
+		0x51b80007, 0x53980008, 0x52300009, 0x5318000b, 0x53200010, 0x50380011,
+		0x52280016, 0x51a80017, 0x5298001a, 0x5220001b, 0x51280028, 0x52900029,
+		0x51a0002e, 0x5218002f, 0x5bb80000, 0x5b380001, 0x5bb00002, 0x5ab80003,
+		0x5ba80004, 0x5b300005, 0x5aa80014, 0x5aa00015,
+	},
+	11: {
+		0x10000003, 0x18880003, 0x18080004, 0x18800005, 0x20900004, 0x29080007,
+		0x2810000a, 0x2900000b, 0x3098000a, 0x3188000b, 0x3110000d, 0x3b880004,
+		0x3b100009, 0x38b0000b, 0x3b08000c, 0x39180012, 0x39900013, 0x38180018,
+		0x39800019, 0x41380005, 0x43900006, 0x40b8000a, 0x4380000b, 0x41b0000c,
+		0x4318000d, 0x4300000e, 0x40a80011, 0x41300014, 0x40300015, 0x4288001a,
+		0x41a0001b, 0x4280001c, 0x4120001e, 0x4210001f, 0x40a00020, 0x42080021,
+		0x40200022, 0x42000023, 0x49b80005, 0x4b980006, 0x4a300007, 0x4b20000e,
+		0x4838000f, 0x4a20001e, 0x4928001f, 0x4a900020, 0x48280021, 0x4a18003a,
+		0x4998003b, 0x53b80000, 0x53380001, 0x53b00002, 0x53a80003, 0x53300004,
+		0x52380005, 0x53a00006, 0x52b00008, 0x53280009, 0x52280010, 0x52a00011,
+		0x51a80012, 0x52980013, 0x5ab8000e, 0x5aa8000f,
+	},
+	12: {
+		0x18880005, 0x18080006, 0x18800007, 0x20900006, 0x21080007, 0x20000009,
+		0x28980009, 0x2988000a, 0x2910000b, 0x28100010, 0x29000011, 0x3198000c,
+		0x3208000d, 0x3118000e, 0x3190000f, 0x31800011, 0x3930000a, 0x3b10000b,
+		0x3b08000c, 0x38a80010, 0x3a880011, 0x39a00012, 0x3a180013, 0x39200015,
+		0x3a100016, 0x38a00017, 0x3a000020, 0x38180021, 0x42b00004, 0x41b80005,
+		0x41380007, 0x43900008, 0x42300009, 0x4320000a, 0x40b8000b, 0x4388000c,
+		0x41b0000e, 0x4318000f, 0x42280010, 0x42a00011, 0x42200012, 0x40b0001a,
+		0x4300001b, 0x41a8001c, 0x4298001d, 0x4128001e, 0x4290001f, 0x42800028,
+		0x40200029, 0x4bb00001, 0x4ab80002, 0x4ba80003, 0x4b300004, 0x4a380005,
+		0x4ba00006, 0x4b280007, 0x4b98000c, 0x4aa8000d, 0x4838001a, 0x4b80001b,
+		0x48300026, 0x48280027, 0x53b80000, 0x53380001,
+	},
+	13: {
+		0x08000001, 0x18800003, 0x20880004, 0x20080005, 0x3090000c, 0x3108000d,
+		0x3010000e, 0x3100000f, 0x3a080010, 0x38980013, 0x39880014, 0x38180015,
+		0x39800016, 0x39100017, 0x44080014, 0x40a8001a, 0x4288001b, 0x40a0001f,
+		0x40200022, 0x42000023, 0x41180024, 0x41900025, 0x48c80018, 0x4c880019,
+		0x4940001d, 0x4c10001e, 0x48c0001f, 0x48b80021, 0x4b880022, 0x4840002a,
+		0x4c00002b, 0x48b0002c, 0x4b08002d, 0x4830002e, 0x4b00002f, 0x49280031,
+		0x4a900032, 0x48280033, 0x49a00038, 0x4a180039, 0x4a80003a, 0x4920003b,
+		0x4a10003c, 0x4998003d, 0x55900017, 0x50d80018, 0x55880019, 0x5150001e,
+		0x5510001f, 0x50d00020, 0x55080021, 0x55000023, 0x54980025, 0x51480028,
+		0x54900029, 0x51c0002b, 0x5418002c, 0x50480034, 0x54800035, 0x52400036,
+		0x54200037, 0x53900038, 0x51b80040, 0x51380041, 0x52a80046, 0x50380047,
+		0x53800048, 0x51b00049, 0x5318004a, 0x5228004b, 0x52a0004c, 0x5130004d,
+		0x5310004e, 0x51a8004f, 0x52980060, 0x52200061, 0x5e880015, 0x59e0001a,
+		0x5960001b, 0x5e10001c, 0x5ad8001d, 0x58e0001f, 0x5e080020, 0x5e000022,
+		0x59d80025, 0x5d980026, 0x59580028, 0x5d20002a, 0x5ca0002c, 0x58580034,
+		0x5d800035, 0x5cb00036, 0x5a500037, 0x59d00038, 0x5d180039, 0x5ac8003a,
+		0x5ca8003b, 0x58500044, 0x5b400045, 0x5c300048, 0x5a480049, 0x59c8004c,
+		0x5ac0004d, 0x5c28004e, 0x5b38004f, 0x5ab80054, 0x5ba80055, 0x5b30005a,
+		0x5a38005b, 0x5ba0005c, 0x5ab0005d, 0x5b28005e, 0x5b98005f, 0x5a300072,
+		0x5b200073, 0x60f8000e, 0x6788000f, 0x67800010, 0x67100014, 0x60f00016,
+		0x67080017, 0x6630001e, 0x61e8001f, 0x61680021, 0x66900022, 0x60e80023,
+		0x65b80024, 0x66180027, 0x62580029, 0x6068002c, 0x6680002d, 0x6450002e,
+		0x6540002f, 0x62600030, 0x66200031, 0x63580032, 0x65b00033, 0x65a8003c,
+		0x6448003d, 0x64c00042, 0x60600043, 0x65a00046, 0x63500047, 0x65300048,
+		0x63c80049, 0x6440004e, 0x62d0004f, 0x65280052, 0x63480053, 0x63c00056,
+		0x64380057, 0x63b8005a, 0x63b0005b, 0x69f8000e, 0x69780010, 0x6f900011,
+		0x68780013, 0x6d580015, 0x6a700017, 0x69f00019, 0x6dc8001a, 0x6dd00022,
+		0x6f280023, 0x6f200024, 0x6c600025, 0x6b680026, 0x6f180027, 0x6970002a,
+		0x6870002b, 0x6f000030, 0x6ae80031, 0x6ea80032, 0x6be00033, 0x6e380034,
+		0x6a680035, 0x6c580036, 0x6dc00037, 0x6ea00038, 0x6cd00039, 0x6d48003a,
+		0x6b60003b, 0x6e980040, 0x6bd80041, 0x6ae0004a, 0x6e28004b, 0x6cc8004c,
+		0x6bd0004d, 0x6d380050, 0x6cb80051, 0x77b8000b, 0x76d0000c, 0x7378000f,
+		0x77400010, 0x72f80011, 0x74e80012, 0x76c80013, 0x77a80014, 0x77380015,
+		0x75600016, 0x75d80017, 0x72780018, 0x77a00019, 0x7798001b, 0x7468001e,
+		0x76c0001f, 0x73700024, 0x74e00025, 0x76480028, 0x72f00029, 0x73e8002c,
+		0x76b8002d, 0x76400030, 0x76b00031, 0x74d80036, 0x75500037, 0x7f600006,
+		0x7ee80007, 0x7df00009, 0x7f58000a, 0x7cf8000b, 0x7fc8000c, 0x7f50000d,
+		0x7de8000e, 0x7ed8000f, 0x7c780010, 0x7fc00011, 0x7e600012, 0x7c700014,
+		0x7d68001a, 0x7de0001b, 0x7e58001c, 0x7fb0001d, 0x7e500034, 0x7f300035,
+		0x87f80001, 0x87780002, 0x86f80003, 0x87700004, 0x86780005, 0x86f00006,
+		0x85f80007, 0x87d80008, 0x86700009, 0x86e0000a, 0x87d00010, 0x86680011,
+		0x85700026, 0x84f00027, 0x83f8002a, 0x83f0002b, 0x8f680001, 0x8d780016,
+		0x8f480017, 0x97e80001, 0x9ff00000, 0x9fe00001,
+	},
+	15: {
+		0x18880005, 0x18000007, 0x2008000c, 0x2080000d, 0x2910000f, 0x28900010,
+		0x29080011, 0x28100012, 0x29000013, 0x32080016, 0x31180018, 0x31900019,
+		0x3098001b, 0x3188001c, 0x3180001d, 0x3b080020, 0x39280022, 0x3a900023,
+		0x38a80024, 0x3a880025, 0x39a00027, 0x3a180028, 0x39200029, 0x3a10002a,
+		0x3998002b, 0x38a0002e, 0x3820002f, 0x3a000034, 0x38180035, 0x44880022,
+		0x41400028, 0x44100029, 0x40c0002a, 0x4408002b, 0x41380030, 0x43900031,
+		0x43200032, 0x40b80033, 0x42a80034, 0x43880035, 0x41b00037, 0x43180038,
+		0x42280039, 0x42a0003a, 0x4130003b, 0x4310003c, 0x40b0003d, 0x41a8003f,
+		0x42980042, 0x42200043, 0x4028004c, 0x4280004d, 0x4e10001e, 0x4d980025,
+		0x4d900028, 0x4d88002a, 0x4d18002f, 0x4ac80030, 0x4ca80031, 0x49500032,
+		0x4d100033, 0x48d00034, 0x4d080035, 0x4b400037, 0x4c300038, 0x4a480039,
+		0x4ca0003a, 0x49c8003b, 0x4c98003c, 0x4ac0003e, 0x4c28003f, 0x49480040,
+		0x4b380041, 0x4bb00042, 0x4c900043, 0x48c80046, 0x4c800047, 0x4a400048,
+		0x4c200049, 0x4ab8004a, 0x4ba8004b, 0x49c0004c, 0x4c18004d, 0x4b30004e,
+		0x4a38004f, 0x4ba00058, 0x48400059, 0x4c00005a, 0x4ab0005b, 0x4b28005c,
+		0x49b8005d, 0x4b98005e, 0x4a30005f, 0x4838006c, 0x4b80006d, 0x4830007c,
+		0x4b00007d, 0x56a00022, 0x56980026, 0x56900027, 0x50e80029, 0x53d8002a,
+		0x55b8002b, 0x5688002c, 0x5628002e, 0x5450002f, 0x55400030, 0x52600031,
+		0x56200032, 0x53580033, 0x55b00034, 0x51e00036, 0x56180037, 0x53d00038,
+		0x55380039, 0x5530003a, 0x5160003e, 0x52d8003f, 0x55a80040, 0x50e00041,
+		0x54480042, 0x54c00043, 0x56080044, 0x52580045, 0x55a00046, 0x53500047,
+		0x51d80048, 0x53c80049, 0x54b8004c, 0x5440004d, 0x5158004e, 0x52d0004f,
+		0x55280052, 0x50d80053, 0x55800056, 0x53480057, 0x54b00058, 0x52500059,
+		0x5520005a, 0x53c0005b, 0x5438005c, 0x51d0005d, 0x5050006c, 0x5500006d,
+		0x53b8007a, 0x5048007b, 0x5e580010, 0x5fb00011, 0x5fa80014, 0x5bf00015,
+		0x5f380016, 0x5d600017, 0x5e500018, 0x5dd80019, 0x5a78001b, 0x5fa0001c,
+		0x59f8001d, 0x5f98001e, 0x5ec0001f, 0x5f300020, 0x59780021, 0x5f900022,
+		0x58f80024, 0x5f880025, 0x5ce00026, 0x5e480027, 0x5af00028, 0x5d580029,
+		0x5dd0002a, 0x5f28002b, 0x5be8002c, 0x5eb8002d, 0x5a70002e, 0x5f20002f,
+		0x5c600030, 0x5e400031, 0x59f00032, 0x5b680033, 0x5eb00034, 0x5f180035,
+		0x5cd80036, 0x5dc80037, 0x59700038, 0x5d500039, 0x5f10003a, 0x58f0003b,
+		0x5f08003c, 0x5ae8003e, 0x5ea8003f, 0x5be00040, 0x5e380041, 0x5a680042,
+		0x5c580043, 0x5dc00046, 0x5cd00047, 0x5d480048, 0x5b600049, 0x5e30004a,
+		0x59e8004b, 0x59680050, 0x58680051, 0x5ae0005a, 0x5e80005b, 0x5cc8006a,
+		0x5860006b, 0x5e000076, 0x58580077, 0x67700002, 0x67d80006, 0x66e80008,
+		0x65780009, 0x67d0000a, 0x65f0000b, 0x6758000c, 0x6668000d, 0x66e0000e,
+		0x64f8000f, 0x67c80010, 0x67500011, 0x65e80012, 0x66d80013, 0x64780014,
+		0x67c00015, 0x66600016, 0x64f00017, 0x67480018, 0x63f80019, 0x67b8001a,
+		0x6568001b, 0x66d0001c, 0x65e0001d, 0x6378001e, 0x64700024, 0x67400025,
+		0x62f80026, 0x64e80027, 0x66c80034, 0x64680035, 0x63700046, 0x67800047,
+		0x6070007a, 0x6700007b, 0x6ff80000, 0x6f780001, 0x6ff00002, 0x6ef80003,
+		0x6fe80006, 0x6e780007, 0x6fe00008, 0x6ef00009, 0x6f68000a, 0x6df8000b,
+		0x6e70000e, 0x6f60000f, 0x6d70003e, 0x6878003f,
+	},
+	16: {
+		0x08000001, 0x18800003, 0x20880004, 0x20080005, 0x3090000c, 0x3108000d,
+		0x3010000e, 0x3100000f, 0x38980014, 0x39880015, 0x39100017, 0x47f80003,
+		0x47900007, 0x40f80009, 0x4788000a, 0x4288001e, 0x40a00023, 0x42080024,
+		0x41180026, 0x41900027, 0x4018002c, 0x4180002d, 0x4a780009, 0x4fa0000a,
+		0x4f98000b, 0x4f80000c, 0x49780010, 0x48780011, 0x48b8002f, 0x4b880030,
+		0x4b100034, 0x48b00035, 0x4b080036, 0x4a980038, 0x4928003a, 0x4a90003b,
+		0x48a8003e, 0x4828003f, 0x49a00040, 0x4a180041, 0x4a800042, 0x49200043,
+		0x4a100044, 0x49980045, 0x4820004a, 0x4a00004b, 0x55780004, 0x54780007,
+		0x53f80008, 0x57b80009, 0x5378000a, 0x57b0000b, 0x52f80010, 0x57a80011,
+		0x51f8001a, 0x55100043, 0x50d00044, 0x51480048, 0x54900049, 0x50c8004b,
+		0x5488004c, 0x54100051, 0x50c00053, 0x54080054, 0x54000055, 0x51b80057,
+		0x53980058, 0x5138005a, 0x5390005b, 0x5038005d, 0x53800062, 0x51b00063,
+		0x53180064, 0x52280065, 0x52a00066, 0x51300067, 0x5030006e, 0x5300006f,
+		0x51a80072, 0x52200073, 0x5f780000, 0x5ff00001, 0x5ef80002, 0x5fe80003,
+		0x5e780004, 0x5fe00005, 0x5df80006, 0x5fd80007, 0x5fd0000a, 0x5cf8000b,
+		0x5fc8000c, 0x5fc0000d, 0x5f100066, 0x58e8006b, 0x5960006e, 0x5d980073,
+		0x59580075, 0x5d900076, 0x58d80077, 0x5d880078, 0x5d18007d, 0x5950007f,
+		0x5d080081, 0x5ca00083, 0x5b380085, 0x5850008a, 0x5d00008b, 0x59c8008c,
+		0x5c98008d, 0x5ac0008e, 0x5c28008f, 0x5bb00094, 0x58480095, 0x5c80009a,
+		0x5a40009b, 0x5c20009c, 0x5ba8009d, 0x59c0009e, 0x5c18009f, 0x5b3000a0,
+		0x594000a1, 0x5a3800a4, 0x5ba000a5, 0x584000ac, 0x5ab000ad, 0x5b2800b2,
+		0x5a3000b3, 0x5b2000b8, 0x5aa800b9, 0x671800bb, 0x606800c3, 0x61e000c7,
+		0x60e000c9, 0x660000ca, 0x617000ce, 0x60f000cf, 0x669800d0, 0x616800d1,
+		0x669000d2, 0x668800d3, 0x61d800d4, 0x662000d8, 0x635800d9, 0x661800da,
+		0x653800db, 0x661000de, 0x65a800df, 0x660800e0, 0x606000e1, 0x625800e2,
+		0x65a000e3, 0x635000e4, 0x653000e5, 0x62d000e8, 0x652800e9, 0x605800f2,
+		0x658000f3, 0x634800f4, 0x64b000f5, 0x625000f6, 0x652000f7, 0x63c000f8,
+		0x643800f9, 0x61d000fc, 0x62c800fd, 0x64a80100, 0x63400101, 0x64300104,
+		0x63b80105, 0x62480108, 0x62b80109, 0x6de800df, 0x6cf00160, 0x6f300166,
+		0x6ce00167, 0x6a70016a, 0x6e40016c, 0x69f0016d, 0x6b68016e, 0x6f080171,
+		0x6ea00172, 0x6bd80174, 0x68700178, 0x6f000179, 0x6ae8017a, 0x6ea8017b,
+		0x6be0017c, 0x6e38017d, 0x6a68017e, 0x6c58017f, 0x6cd00180, 0x6b600181,
+		0x6e300182, 0x69e80183, 0x6ae00184, 0x6e280185, 0x6c500188, 0x6d400189,
+		0x6cc8018a, 0x6a60018b, 0x6db0018c, 0x6bd0018d, 0x6ad80190, 0x6c480191,
+		0x6cc00196, 0x6bc80197, 0x6cb801aa, 0x6c4001ab, 0x777001b2, 0x75f001b4,
+		0x766801b5, 0x757001b7, 0x766001b8, 0x765001bb, 0x72f001bd, 0x75e002c2,
+		0x765802c3, 0x747002c4, 0x774002c5, 0x74e802c6, 0x773802c7, 0x75d802c8,
+		0x746802c9, 0x76c002ca, 0x737002cb, 0x755802d0, 0x75d002d1, 0x772802d2,
+		0x76b802d3, 0x772002d6, 0x746002d7, 0x76b002de, 0x74d802df, 0x75c802e0,
+		0x755002e1, 0x75c002e6, 0x754802e7, 0x75b802ea, 0x768002eb, 0x7ef00361,
+		0x7f480362, 0x7f680366, 0x7f580367, 0x7ee0036c, 0x7ed8036d, 0x7d680372,
+		0x7ed00373, 0x7bf00374, 0x7d600375, 0x7e480378, 0x7be80379, 0x867006c0,
+		0x875006c6, 0x86c806c7, 0x8f600d82, 0x8ee80d83,
+	},
+	24: {
+		0x27f80003, 0x2088000c, 0x2008000d, 0x2080000e, 0x2000000f, 0x28900015,
+		0x29080016, 0x30980026, 0x31880027, 0x31100029, 0x3010002e, 0x3100002f,
+		0x3fd00004, 0x3fc80006, 0x3fc00007, 0x3fb80009, 0x3b78000a, 0x3fb0000b,
+		0x3af8000c, 0x3fa8000d, 0x3a78000e, 0x3fa0000f, 0x39f80010, 0x3f980011,
+		0x39780012, 0x3f900013, 0x3f880014, 0x3a880042, 0x39200044, 0x3a100045,
+		0x39980046, 0x38a00047, 0x3a080048, 0x3918004a, 0x3990004b, 0x38180050,
+		0x39800051, 0x47780000, 0x47f00001, 0x46f80002, 0x47e80003, 0x46780004,
+		0x47e00005, 0x45f80006, 0x47d80007, 0x4578000a, 0x44f8000b, 0x44780010,
+		0x43f80011, 0x40f8002a, 0x4780002b, 0x4398006d, 0x4390006f, 0x42300070,
+		0x43200071, 0x42a80072, 0x43880073, 0x41b00074, 0x43180075, 0x42280076,
+		0x42a00077, 0x41300078, 0x43100079, 0x40b0007a, 0x4308007b, 0x41a8007d,
+		0x4298007e, 0x4220007f, 0x41280080, 0x42900081, 0x40a80082, 0x41a00086,
+		0x42180087, 0x40200092, 0x42000093, 0x48780058, 0x4da000a4, 0x4d9800a8,
+		0x4c4000a9, 0x4d9000ab, 0x4cb000ae, 0x4d2000af, 0x4c3800b1, 0x49d000b2,
+		0x4d1800b3, 0x4ac800b4, 0x4ca800b5, 0x495000b6, 0x4d1000b7, 0x4d0800b8,
+		0x4b4000b9, 0x4c3000ba, 0x4bb800bb, 0x4a4800bc, 0x4ca000bd, 0x49c800be,
+		0x4c9800bf, 0x4ac000c0, 0x4c2800c1, 0x494800c2, 0x4b3800c3, 0x4bb000c4,
+		0x4c9000c5, 0x48c800c6, 0x4c8800c7, 0x4a4000c8, 0x4c2000c9, 0x4ab800ca,
+		0x4ba800cb, 0x49c000cc, 0x4c1800cd, 0x4b3000ce, 0x494000cf, 0x4c1000d0,
+		0x48c000d1, 0x4a3800d2, 0x4ba000d3, 0x4c0800d4, 0x4ab000d6, 0x4b2800d7,
+		0x48b800d8, 0x49b800dc, 0x493800dd, 0x483000f8, 0x4b0000f9, 0x48280106,
+		0x4a800107, 0x57300103, 0x56480105, 0x52f00106, 0x55d00107, 0x57280108,
+		0x56b8010a, 0x5720010b, 0x5460010c, 0x5640010d, 0x51f0010f, 0x53680110,
+		0x56b00111, 0x57180112, 0x54d80113, 0x55c80114, 0x55500115, 0x57100116,
+		0x50f00117, 0x57080118, 0x52e80119, 0x56a8011a, 0x53e0011b, 0x5638011c,
+		0x5268011d, 0x5458011e, 0x55c0011f, 0x56a00120, 0x54d00121, 0x55480122,
+		0x53600123, 0x56300124, 0x51e80125, 0x56980126, 0x51680127, 0x56900128,
+		0x50e80129, 0x53d8012a, 0x55b8012b, 0x5688012c, 0x52e0012d, 0x5628012e,
+		0x5450012f, 0x55400130, 0x54c80131, 0x52600132, 0x56200133, 0x53580134,
+		0x55b00135, 0x51e00137, 0x56180138, 0x53d00139, 0x5538013a, 0x5160013b,
+		0x5610013c, 0x52d8013d, 0x55a8013e, 0x50e0013f, 0x54480140, 0x54c00141,
+		0x56080142, 0x52580143, 0x51d80145, 0x50d00147, 0x5350014a, 0x5530014b,
+		0x53c8014c, 0x54b8014d, 0x5480014f, 0x51580154, 0x52d00155, 0x55280158,
+		0x50d80159, 0x5588015a, 0x5348015b, 0x52500160, 0x53c00161, 0x504001aa,
+		0x540001ab, 0x503801b2, 0x538001b3, 0x5f700164, 0x5ef00165, 0x5f680166,
+		0x5e700167, 0x5f600168, 0x5ee80169, 0x5df0016a, 0x5f58016b, 0x5e68016c,
+		0x5ee0016d, 0x5d70016e, 0x5f50016f, 0x5de80170, 0x5ed80171, 0x5e600172,
+		0x5cf00173, 0x5f480174, 0x5d680175, 0x5ed00176, 0x5de00177, 0x5e580178,
+		0x5c700179, 0x5f40017a, 0x5ce8017b, 0x5ec8017c, 0x5bf0017d, 0x5f38017e,
+		0x5d60017f, 0x5e500200, 0x5dd80201, 0x5c680202, 0x5ec00203, 0x58680205,
+		0x5b700208, 0x5ce00209, 0x5d580212, 0x5be80213, 0x5a70021c, 0x5970021d,
+		0x5e80026c, 0x5860026d, 0x5e000288, 0x58580289, 0x5d80028c, 0x5850028d,
+		0x5d00029c, 0x5848029d, 0x60700408, 0x67000409,
+	},
+	32: {
+		0x08000001, 0x20100004, 0x20080005, 0x20200006, 0x20400007, 0x28480003,
+		0x28300004, 0x28180005, 0x28500006, 0x28600007, 0x30580000, 0x30780001,
+		0x30680002, 0x30700003, 0x30380004, 0x30280005,
+	},
+	33: {
+		0x20780000, 0x20700001, 0x20680002, 0x20600003, 0x20580004, 0x20500005,
+		0x20480006, 0x20400007, 0x20380008, 0x20300009, 0x2028000a, 0x2020000b,
+		0x2018000c, 0x2010000d, 0x2008000e, 0x2000000f,
+	},
+}
+
+/*
+	Synthesis window of the polyphase filter bank
+*/
+var mp3SynthesisWindow = [512]float32{
+	0.000000000, -0.000015259, -0.000015259, -0.000015259,
+	-0.000015259, -0.000015259, -0.000015259, -0.000030518,
+	-0.000030518, -0.000030518, -0.000030518, -0.000045776,
+	-0.000045776, -0.000061035, -0.000061035, -0.000076294,
+	-0.000076294, -0.000091553, -0.000106812, -0.000106812,
+	-0.000122070, -0.000137329, -0.000152588, -0.000167847,
+	-0.000198364, -0.000213623, -0.000244141, -0.000259399,
+	-0.000289917, -0.000320435, -0.000366211, -0.000396729,
+	-0.000442505, -0.000473022, -0.000534058, -0.000579834,
+	-0.000625610, -0.000686646, -0.000747681, -0.000808716,
+	-0.000885010, -0.000961304, -0.001037598, -0.001113892,
+	-0.001205444, -0.001296997, -0.001388550, -0.001480103,
+	-0.001586914, -0.001693726, -0.001785278, -0.001907349,
+	-0.002014160, -0.002120972, -0.002243042, -0.002349854,
+	-0.002456665, -0.002578735, -0.002685547, -0.002792358,
+	-0.002899170, -0.002990723, -0.003082275, -0.003173828,
+	0.003250122, 0.003326416, 0.003387451, 0.003433228,
+	0.003463745, 0.003479004, 0.003479004, 0.003463745,
+	0.003417969, 0.003372192, 0.003280640, 0.003173828,
+	0.003051758, 0.002883911, 0.002700806, 0.002487183,
+	0.002227783, 0.001937866, 0.001617432, 0.001266479,
+	0.000869751, 0.000442505, -0.000030518, -0.000549316,
+	-0.001098633, -0.001693726, -0.002334595, -0.003005981,
+	-0.003723145, -0.004486084, -0.005294800, -0.006118774,
+	-0.007003784, -0.007919312, -0.008865356, -0.009841919,
+	-0.010848999, -0.011886597, -0.012939453, -0.014022827,
+	-0.015121460, -0.016235352, -0.017349243, -0.018463135,
+	-0.019577026, -0.020690918, -0.021789551, -0.022857666,
+	-0.023910522, -0.024932861, -0.025909424, -0.026840210,
+	-0.027725220, -0.028533936, -0.029281616, -0.029937744,
+	-0.030532837, -0.031005859, -0.031387329, -0.031661987,
+	-0.031814575, -0.031845093, -0.031738281, -0.031478882,
+	0.031082153, 0.030517578, 0.029785156, 0.028884888,
+	0.027801514, 0.026535034, 0.025085449, 0.023422241,
+	0.021575928, 0.019531250, 0.017257690, 0.014801025,
+	0.012115479, 0.009231567, 0.006134033, 0.002822876,
+	-0.000686646, -0.004394531, -0.008316040, -0.012420654,
+	-0.016708374, -0.021179199, -0.025817871, -0.030609131,
+	-0.035552979, -0.040634155, -0.045837402, -0.051132202,
+	-0.056533813, -0.061996460, -0.067520142, -0.073059082,
+	-0.078628540, -0.084182739, -0.089706421, -0.095169067,
+	-0.100540161, -0.105819702, -0.110946655, -0.115921021,
+	-0.120697021, -0.125259399, -0.129562378, -0.133590698,
+	-0.137298584, -0.140670776, -0.143676758, -0.146255493,
+	-0.148422241, -0.150115967, -0.151306152, -0.151962280,
+	-0.152069092, -0.151596069, -0.150497437, -0.148773193,
+	-0.146362305, -0.143264771, -0.139450073, -0.134887695,
+	-0.129577637, -0.123474121, -0.116577148, -0.108856201,
+	0.100311279, 0.090927124, 0.080688477, 0.069595337,
+	0.057617188, 0.044784546, 0.031082153, 0.016510010,
+	0.001068115, -0.015228271, -0.032379150, -0.050354004,
+	-0.069168091, -0.088775635, -0.109161377, -0.130310059,
+	-0.152206421, -0.174789429, -0.198059082, -0.221984863,
+	-0.246505737, -0.271591187, -0.297210693, -0.323318481,
+	-0.349868774, -0.376800537, -0.404083252, -0.431655884,
+	-0.459472656, -0.487472534, -0.515609741, -0.543823242,
+	-0.572036743, -0.600219727, -0.628295898, -0.656219482,
+	-0.683914185, -0.711318970, -0.738372803, -0.765029907,
+	-0.791213989, -0.816864014, -0.841949463, -0.866363525,
+	-0.890090942, -0.913055420, -0.935195923, -0.956481934,
+	-0.976852417, -0.996246338, -1.014617920, -1.031936646,
+	-1.048156738, -1.063217163, -1.077117920, -1.089782715,
+	-1.101211548, -1.111373901, -1.120223999, -1.127746582,
+	-1.133926392, -1.138763428, -1.142211914, -1.144287109,
+	1.144989014, 1.144287109, 1.142211914, 1.138763428,
+	1.133926392, 1.127746582, 1.120223999, 1.111373901,
+	1.101211548, 1.089782715, 1.077117920, 1.063217163,
+	1.048156738, 1.031936646, 1.014617920, 0.996246338,
+	0.976852417, 0.956481934, 0.935195923, 0.913055420,
+	0.890090942, 0.866363525, 0.841949463, 0.816864014,
+	0.791213989, 0.765029907, 0.738372803, 0.711318970,
+	0.683914185, 0.656219482, 0.628295898, 0.600219727,
+	0.572036743, 0.543823242, 0.515609741, 0.487472534,
+	0.459472656, 0.431655884, 0.404083252, 0.376800537,
+	0.349868774, 0.323318481, 0.297210693, 0.271591187,
+	0.246505737, 0.221984863, 0.198059082, 0.174789429,
+	0.152206421, 0.130310059, 0.109161377, 0.088775635,
+	0.069168091, 0.050354004, 0.032379150, 0.015228271,
+	-0.001068115, -0.016510010, -0.031082153, -0.044784546,
+	-0.057617188, -0.069595337, -0.080688477, -0.090927124,
+	0.100311279, 0.108856201, 0.116577148, 0.123474121,
+	0.129577637, 0.134887695, 0.139450073, 0.143264771,
+	0.146362305, 0.148773193, 0.150497437, 0.151596069,
+	0.152069092, 0.151962280, 0.151306152, 0.150115967,
+	0.148422241, 0.146255493, 0.143676758, 0.140670776,
+	0.137298584, 0.133590698, 0.129562378, 0.125259399,
+	0.120697021, 0.115921021, 0.110946655, 0.105819702,
+	0.100540161, 0.095169067, 0.089706421, 0.084182739,
+	0.078628540, 0.073059082, 0.067520142, 0.061996460,
+	0.056533813, 0.051132202, 0.045837402, 0.040634155,
+	0.035552979, 0.030609131, 0.025817871, 0.021179199,
+	0.016708374, 0.012420654, 0.008316040, 0.004394531,
+	0.000686646, -0.002822876, -0.006134033, -0.009231567,
+	-0.012115479, -0.014801025, -0.017257690, -0.019531250,
+	-0.021575928, -0.023422241, -0.025085449, -0.026535034,
+	-0.027801514, -0.028884888, -0.029785156, -0.030517578,
+	0.031082153, 0.031478882, 0.031738281, 0.031845093,
+	0.031814575, 0.031661987, 0.031387329, 0.031005859,
+	0.030532837, 0.029937744, 0.029281616, 0.028533936,
+	0.027725220, 0.026840210, 0.025909424, 0.024932861,
+	0.023910522, 0.022857666, 0.021789551, 0.020690918,
+	0.019577026, 0.018463135, 0.017349243, 0.016235352,
+	0.015121460, 0.014022827, 0.012939453, 0.011886597,
+	0.010848999, 0.009841919, 0.008865356, 0.007919312,
+	0.007003784, 0.006118774, 0.005294800, 0.004486084,
+	0.003723145, 0.003005981, 0.002334595, 0.001693726,
+	0.001098633, 0.000549316, 0.000030518, -0.000442505,
+	-0.000869751, -0.001266479, -0.001617432, -0.001937866,
+	-0.002227783, -0.002487183, -0.002700806, -0.002883911,
+	-0.003051758, -0.003173828, -0.003280640, -0.003372192,
+	-0.003417969, -0.003463745, -0.003479004, -0.003479004,
+	-0.003463745, -0.003433228, -0.003387451, -0.003326416,
+	0.003250122, 0.003173828, 0.003082275, 0.002990723,
+	0.002899170, 0.002792358, 0.002685547, 0.002578735,
+	0.002456665, 0.002349854, 0.002243042, 0.002120972,
+	0.002014160, 0.001907349, 0.001785278, 0.001693726,
+	0.001586914, 0.001480103, 0.001388550, 0.001296997,
+	0.001205444, 0.001113892, 0.001037598, 0.000961304,
+	0.000885010, 0.000808716, 0.000747681, 0.000686646,
+	0.000625610, 0.000579834, 0.000534058, 0.000473022,
+	0.000442505, 0.000396729, 0.000366211, 0.000320435,
+	0.000289917, 0.000259399, 0.000244141, 0.000213623,
+	0.000198364, 0.000167847, 0.000152588, 0.000137329,
+	0.000122070, 0.000106812, 0.000106812, 0.000091553,
+	0.000076294, 0.000076294, 0.000061035, 0.000061035,
+	0.000045776, 0.000045776, 0.000030518, 0.000030518,
+	0.000030518, 0.000030518, 0.000015259, 0.000015259,
+	0.000015259, 0.000015259, 0.000015259, 0.000015259,
+}
@@ -0,0 +1,96 @@
+package fingerprint
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	wavePCM        = 1
+	waveFloat      = 3
+	waveExtensible = 0xfffe
+)
+
+func decodeWAV(content []byte) (*audio, error) {
+	if len(content) < 12 || string(content[:4]) != "RIFF" || string(content[8:12]) != "WAVE" {
+		return nil, errors.New("not a wave file")
+	}
+
+	var format, channels, depth int
+	var rate int
+	var data []byte
+
+	pos := 12
+	for pos+8 <= len(content) {
+		id := string(content[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(content[pos+4 : pos+8]))
+		pos += 8
+		end := pos + size
+		if end > len(content) || end < pos {
+			// truncated file, use what is there
+			end = len(content)
+		}
+
+		chunk := content[pos:end]
+		switch id {
+		case "fmt ":
+			if len(chunk) < 16 {
+				return nil, errors.New("invalid format chunk")
+			}
+			format = int(binary.LittleEndian.Uint16(chunk[0:2]))
+			channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+			rate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			depth = int(binary.LittleEndian.Uint16(chunk[14:16]))
+			if format == waveExtensible && len(chunk) >= 26 {
+				// the sub format guid starts with the format code
+				format = int(binary.LittleEndian.Uint16(chunk[24:26]))
+			}
+		case "data":
+			data = chunk
+		}
+		pos = end + size%2
+	}
+
+	if channels == 0 || rate == 0 || data == nil {
+		return nil, errors.New("missing format or data chunk")
+	}
+
+	var sample func(b []byte) float64
+	switch {
+	case format == wavePCM && depth == 8:
+		sample = func(b []byte) float64 { return float64(int(b[0])-128) / 128 }
+	case format == wavePCM && depth == 16:
+		sample = func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }
+	case format == wavePCM && depth == 24:
+		sample = func(b []byte) float64 {
+			return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+		}
+	case format == wavePCM && depth == 32:
+		sample = func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }
+	case format == waveFloat && depth == 32:
+		sample = func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }
+	case format == waveFloat && depth == 64:
+		sample = func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }
+	default:
+		return nil, errors.Errorf("unsupported wave format %d with %d bits", format, depth)
+	}
+
+	width := depth / 8
+	frames := len(data) / (width * channels)
+	if frames > rate*maxSeconds {
+		frames = rate * maxSeconds
+	}
+
+	out := &audio{rate: rate, samples: make([]float32, frames)}
+	for i := range out.samples {
+		sum := 0.0
+		for ch := 0; ch < channels; ch++ {
+			offset := (i*channels + ch) * width
+			sum += sample(data[offset : offset+width])
+		}
+		out.samples[i] = float32(sum / float64(channels))
+	}
+	return out, nil
+}
@@ -0,0 +1,284 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/enums"
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+const (
+	// track was moved to a new path, values are the file paths
+	FieldPath = "Path"
+	// content of a tracklist was replaced, values are the file paths of the tracks
+	FieldTracks = "Tracks"
+	// track was removed from the library, the old value is its file path
+	FieldRemoved = "Removed"
+
+	journalExt = ".jsonl"
+	undoneExt  = ".undone"
+	idFormat   = "20060102-150405"
+)
+
+// folder where the journals are stored
+var Dir = files.ExpandHomePath("~/.primetools/journal")
+
+/*
+	Journal of the changes done to a library by one command, the file is a json
+	header followed by one json change per line
+*/
+type Run struct {
+	Id      string
+	Command string
+	Library enums.LibraryType
+	Path    string `json:",omitempty"`
+	Started time.Time
+
+	file  *os.File
+	path  string
+	count int
+}
+
+/*
+	Field changed on a track or a tracklist, Field is a SyncType name for track metadata
+*/
+type Change struct {
+	Time   time.Time
+	Field  string
+	Track  string           `json:",omitempty"`
+	List   string           `json:",omitempty"`
+	Object enums.ObjectType `json:",omitempty"`
+	Old    json.RawMessage
+	New    json.RawMessage
+}
+
+/*
+	Start recording the changes done to a library, path is the one used to open it
+*/
+func Start(command string, libtype enums.LibraryType, path string) (*Run, error) {
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "fail to create journal folder '%s'", Dir)
+	}
+
+	r := &Run{
+		Id:      time.Now().Format(idFormat),
+		Command: command,
+		Library: libtype,
+		Path:    path,
+		Started: time.Now(),
+	}
+	r.path = filepath.Join(Dir, r.Id+journalExt)
+	for idx := 1; files.Exists(r.path) || files.Exists(r.path+undoneExt); idx++ {
+		r.Id = fmt.Sprintf("%s-%d", r.Started.Format(idFormat), idx)
+		r.path = filepath.Join(Dir, r.Id+journalExt)
+	}
+
+	var err error
+	r.file, err = os.Create(r.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to create journal '%s'", r.path)
+	}
+
+	if err = r.writeLine(r); err != nil {
+		r.file.Close()
+		os.Remove(r.path)
+		return nil, err
+	}
+	return r, nil
+}
+
+/*
+	All journals which weren't undone, the oldest first
+*/
+func List() ([]*Run, error) {
+	matches, err := filepath.Glob(filepath.Join(Dir, "*"+journalExt))
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to list journal folder '%s'", Dir)
+	}
+
+	out := []*Run{}
+	for _, it := range matches {
+		r, _, err := read(it)
+		if err != nil {
+			logrus.Warnf("ignoring invalid journal '%s': %v", it, err)
+			continue
+		}
+		out = append(out, r)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Started.Before(out[j].Started)
+	})
+	return out, nil
+}
+
+/*
+	Find a journal by id, or the last one when id is empty
+*/
+func Find(id string) (*Run, error) {
+	if id == "" {
+		runs, err := List()
+		if err != nil {
+			return nil, err
+		}
+		if len(runs) == 0 {
+			return nil, errors.Errorf("no journal found in '%s'", Dir)
+		}
+		return runs[len(runs)-1], nil
+	}
+
+	path := filepath.Join(Dir, strings.TrimSuffix(id, journalExt)+journalExt)
+	if files.Exists(path + undoneExt) {
+		return nil, errors.Errorf("journal '%s' was already undone", id)
+	}
+	if !files.Exists(path) {
+		return nil, errors.Errorf("journal '%s' doesn't exists in '%s'", id, Dir)
+	}
+
+	r, _, err := read(path)
+	return r, err
+}
+
+func (r *Run) Changes() ([]Change, error) {
+	_, changes, err := read(r.path)
+	return changes, err
+}
+
+/*
+	Record a metadata change done on a track, nothing is done on a nil run
+*/
+func (r *Run) TrackChanged(track music.Track, field enums.SyncType, old interface{}, new interface{}) {
+	if r == nil {
+		return
+	}
+	r.record(Change{Field: field.String(), Track: track.FilePath()}, old, new)
+}
+
+func (r *Run) TrackMoved(oldpath string, newpath string) {
+	if r == nil {
+		return
+	}
+	r.record(Change{Field: FieldPath, Track: newpath}, oldpath, newpath)
+}
+
+func (r *Run) TracksChanged(object enums.ObjectType, list string, old music.Tracks, new music.Tracks) {
+	if r == nil {
+		return
+	}
+	r.record(Change{Field: FieldTracks, List: list, Object: object}, old.Filepaths(), new.Filepaths())
+}
+
+/*
+	Record a track removed from the library, ie: a merged duplicate or an
+	orphan. It can't be added back with its metadata, so the run can only be
+	reverted from a backup.
+*/
+func (r *Run) TrackRemoved(track music.Track) {
+	if r == nil {
+		return
+	}
+	r.record(Change{Field: FieldRemoved, Track: track.FilePath()}, track.FilePath(), nil)
+}
+
+/*
+	Close the journal, it is deleted if nothing was recorded
+*/
+func (r *Run) Close() {
+	if r == nil || r.file == nil {
+		return
+	}
+	r.file.Close()
+	r.file = nil
+
+	if r.count == 0 {
+		os.Remove(r.path)
+	} else {
+		logrus.Infof("%d changes recorded in journal '%s', revert them with 'undo %s'", r.count, r.Id, r.Id)
+	}
+}
+
+func (r *Run) String() string {
+	changes, _ := r.Changes()
+	return fmt.Sprintf("%s: '%s' on %s, %d changes, %s", r.Id, r.Command, r.Library, len(changes), r.Started.Format(time.RFC822))
+}
+
+func (r *Run) markUndone() error {
+	if err := os.Rename(r.path, r.path+undoneExt); err != nil {
+		return errors.Wrapf(err, "fail to mark journal '%s' as undone", r.Id)
+	}
+	return nil
+}
+
+func (r *Run) record(change Change, old interface{}, new interface{}) {
+	var err error
+	change.Time = time.Now()
+
+	if change.Old, err = json.Marshal(old); err == nil {
+		change.New, err = json.Marshal(new)
+	}
+	if err == nil {
+		err = r.writeLine(change)
+	}
+
+	if err != nil {
+		logrus.Errorf("failed to record change of %s for '%s%s' in journal: %v", change.Field, change.Track, change.List, err)
+		return
+	}
+	r.count++
+}
+
+func (r *Run) writeLine(value interface{}) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrap(err, "fail to serialize journal entry")
+	}
+	if _, err = r.file.Write(append(content, '\n')); err != nil {
+		return errors.Wrapf(err, "fail to write journal '%s'", r.path)
+	}
+	return nil
+}
+
+func read(path string) (*Run, []Change, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "fail to open journal '%s'", path)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	if !scanner.Scan() {
+		return nil, nil, errors.Errorf("journal '%s' is empty", path)
+	}
+
+	r := &Run{path: path}
+	if err = json.Unmarshal(scanner.Bytes(), r); err != nil {
+		return nil, nil, errors.Wrapf(err, "fail to parse journal '%s'", path)
+	}
+
+	changes := []Change{}
+	for scanner.Scan() {
+		change := Change{}
+		if err = json.Unmarshal(scanner.Bytes(), &change); err != nil {
+			return nil, nil, errors.Wrapf(err, "fail to parse journal '%s'", path)
+		}
+		changes = append(changes, change)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, nil, errors.Wrapf(err, "fail to read journal '%s'", path)
+	}
+	return r, changes, nil
+}
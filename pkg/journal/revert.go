@@ -0,0 +1,267 @@
+package journal
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/enums"
+	"primetools/pkg/music"
+)
+
+/*
+	Apply the inverse of every change, the last one first, the reverted changes are
+	recorded into undo so they can be reverted too. The journal is marked as undone
+	only if every change was reverted.
+*/
+func (r *Run) Revert(lib music.Library, undo *Run) error {
+	if err := r.Revertible(); err != nil {
+		return err
+	}
+	changes, err := r.Changes()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for idx := len(changes) - 1; idx >= 0; idx-- {
+		if err = revert(lib, changes[idx], undo); err != nil {
+			failed++
+			logrus.Errorf("failed to revert %s of '%s%s': %v", changes[idx].Field, changes[idx].Track, changes[idx].List, err)
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d of %d changes couldn't be reverted", failed, len(changes))
+	}
+	return r.markUndone()
+}
+
+/*
+	Fail when the run removed tracks from the library, none of its changes is
+	reverted then since the backup taken before the run restores all of them
+*/
+func (r *Run) Revertible() error {
+	changes, err := r.Changes()
+	if err != nil {
+		return err
+	}
+
+	removed := 0
+	for _, it := range changes {
+		if it.Field == FieldRemoved {
+			removed++
+		}
+	}
+	if removed > 0 {
+		return errors.Errorf("'%s' removed %d tracks from the library which can't be added back, restore the backup taken before it instead, see 'backup list'", r.Command, removed)
+	}
+	return nil
+}
+
+func revert(lib music.Library, change Change, undo *Run) error {
+	switch change.Field {
+	case FieldPath:
+		editor, ok := music.AsEditor(lib)
+		if !ok {
+			return errors.Errorf("library '%s' doesn't support moving tracks", lib)
+		}
+
+		oldpath := ""
+		if err := json.Unmarshal(change.Old, &oldpath); err != nil {
+			return errors.Wrap(err, "invalid journal value")
+		}
+
+		track := lib.Track(change.Track)
+		if track == nil {
+			return errors.Errorf("track not found in library")
+		}
+
+		logrus.Infof("moving '%s' back to '%s'", change.Track, oldpath)
+		if err := editor.MoveTrack(track, oldpath); err != nil {
+			return err
+		}
+		undo.TrackMoved(change.Track, oldpath)
+
+	case FieldTracks:
+		paths := []string{}
+		if err := json.Unmarshal(change.Old, &paths); err != nil {
+			return errors.Wrap(err, "invalid journal value")
+		}
+
+		list := findList(lib, change.Object, change.List)
+		if list == nil {
+			return errors.Errorf("%s not found in library", change.Object)
+		}
+
+		tracks := music.Tracks{}
+		for _, it := range paths {
+			if track := lib.Track(it); track != nil {
+				tracks = append(tracks, track)
+			} else {
+				logrus.Warnf("track '%s' of %s '%s' is no longer in library", it, change.Object, change.List)
+			}
+		}
+
+		current := list.Tracks()
+		logrus.Infof("restoring %s '%s' from %d => %d items", change.Object, change.List, len(current), len(tracks))
+		if err := list.SetTracks(tracks); err != nil {
+			return err
+		}
+		undo.TracksChanged(change.Object, change.List, current, tracks)
+
+	default:
+		field, err := enums.ParseSyncType(change.Field)
+		if err != nil {
+			return err
+		}
+
+		track := lib.Track(change.Track)
+		if track == nil {
+			return errors.Errorf("track not found in library")
+		}
+
+		current, err := FieldValue(track, field)
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("restoring %s of '%s'", field, track)
+		old, err := setFieldValue(track, field, change.Old)
+		if err != nil {
+			return err
+		}
+		undo.TrackChanged(track, field, current, old)
+	}
+	return nil
+}
+
+/*
+	Current value of a track field as recorded in the journal
+*/
+func FieldValue(track music.Track, field enums.SyncType) (interface{}, error) {
+	switch field {
+	case enums.Ratings:
+		return track.Rating(), nil
+	case enums.Added:
+		return track.Added(), nil
+	case enums.Modified:
+		return track.Modified(), nil
+	case enums.PlayCount:
+		return track.PlayCount(), nil
+	case enums.BPM:
+		return track.BPM(), nil
+	case enums.Key:
+		return track.Key(), nil
+	case enums.Genre:
+		return track.Genre(), nil
+	case enums.Comment:
+		return track.Comment(), nil
+	case enums.Cues:
+		ptrack, ok := track.(music.PerformanceTrack)
+		if !ok {
+			return nil, errors.New("library doesn't support cues")
+		}
+		return ptrack.PerformanceData()
+	default:
+		return nil, errors.Errorf("unsupported field %s", field)
+	}
+}
+
+/*
+	Decode a journal value and write it into the track, return the decoded value
+*/
+func setFieldValue(track music.Track, field enums.SyncType, raw json.RawMessage) (interface{}, error) {
+	var err error
+
+	decode := func(value interface{}) error {
+		if err := json.Unmarshal(raw, value); err != nil {
+			return errors.Wrap(err, "invalid journal value")
+		}
+		return nil
+	}
+
+	switch field {
+	case enums.Ratings:
+		var value music.Rating
+		if err = decode(&value); err == nil {
+			err = track.SetRating(value)
+		}
+		return value, err
+	case enums.Added, enums.Modified:
+		var value time.Time
+		if err = decode(&value); err == nil {
+			if field == enums.Added {
+				err = track.SetAdded(value)
+			} else {
+				err = track.SetModified(value)
+			}
+		}
+		return value, err
+	case enums.PlayCount:
+		var value int
+		if err = decode(&value); err == nil {
+			err = track.SetPlayCount(value)
+		}
+		return value, err
+	case enums.BPM:
+		var value float64
+		if err = decode(&value); err == nil {
+			err = track.SetBPM(value)
+		}
+		return value, err
+	case enums.Key:
+		var value music.Key
+		if err = decode(&value); err != nil {
+			return value, err
+		}
+		// the track had no key before
+		if clearer, ok := track.(music.KeyClearer); ok && !value.Valid() {
+			return value, clearer.ClearKey()
+		}
+		return value, track.SetKey(value)
+	case enums.Genre:
+		var value string
+		if err = decode(&value); err == nil {
+			err = track.SetGenre(value)
+		}
+		return value, err
+	case enums.Comment:
+		var value string
+		if err = decode(&value); err == nil {
+			err = track.SetComment(value)
+		}
+		return value, err
+	case enums.Cues:
+		ptrack, ok := track.(music.PerformanceTrack)
+		if !ok {
+			return nil, errors.New("library doesn't support cues")
+		}
+		value := &music.PerformanceData{}
+		if err = decode(value); err != nil {
+			return value, err
+		}
+		// the slots set by the change must be unset too
+		if replacer, ok := ptrack.(music.PerformanceReplacer); ok {
+			return value, replacer.ReplacePerformanceData(value)
+		}
+		return value, ptrack.SetPerformanceData(value)
+	default:
+		return nil, errors.Errorf("unsupported field %s", field)
+	}
+}
+
+func findList(lib music.Library, object enums.ObjectType, path string) music.Tracklist {
+	lists := lib.Playlists()
+	if object == enums.Crates {
+		lists = lib.Crates()
+	}
+	for _, it := range lists {
+		if it.Path() == path {
+			return it
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package journal
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"primetools/pkg/enums"
+	"primetools/pkg/music"
+)
+
+/*
+	Track which rejects an invalid key like Engine DJ and PRIME
+*/
+type testTrack struct {
+	music.Track
+	path string
+	key  music.Key
+}
+
+func (t *testTrack) FilePath() string {
+	return t.path
+}
+
+func (t *testTrack) String() string {
+	return t.path
+}
+
+func (t *testTrack) Key() music.Key {
+	return t.key
+}
+
+func (t *testTrack) SetKey(key music.Key) error {
+	if !key.Valid() {
+		return errors.New("invalid key")
+	}
+	t.key = key
+	return nil
+}
+
+func (t *testTrack) ClearKey() error {
+	t.key = music.KeyUnknown
+	return nil
+}
+
+/*
+	Track whose SetPerformanceData merges into the existing slots like Engine DJ
+*/
+type testPerformanceTrack struct {
+	testTrack
+	data *music.PerformanceData
+}
+
+func (t *testPerformanceTrack) PerformanceData() (*music.PerformanceData, error) {
+	return t.data, nil
+}
+
+func (t *testPerformanceTrack) SetPerformanceData(data *music.PerformanceData) error {
+	t.data.HotCues = append(t.data.HotCues, data.HotCues...)
+	return nil
+}
+
+func (t *testPerformanceTrack) ReplacePerformanceData(data *music.PerformanceData) error {
+	t.data = data
+	return nil
+}
+
+type testLibrary struct {
+	music.Library
+	tracks map[string]music.Track
+}
+
+func (l *testLibrary) Track(filename string) music.Track {
+	return l.tracks[filename]
+}
+
+func (l *testLibrary) String() string {
+	return "test library"
+}
+
+func startRun(t *testing.T, command string) *Run {
+	run, err := Start(command, enums.EngineDJ, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+func TestRevertKeyOfTrackWithoutKey(t *testing.T) {
+	Dir = t.TempDir()
+
+	track := &testTrack{path: "/music/track.mp3"}
+	lib := &testLibrary{tracks: map[string]music.Track{track.path: track}}
+
+	// sync key onto a track which had none
+	key, _ := music.ParseKey("Am")
+	run := startRun(t, "sync key")
+	if err := track.SetKey(key); err != nil {
+		t.Fatal(err)
+	}
+	run.TrackChanged(track, enums.Key, music.KeyUnknown, key)
+	run.Close()
+
+	undo := startRun(t, "undo")
+	defer undo.Close()
+	if err := run.Revert(lib, undo); err != nil {
+		t.Fatal(err)
+	}
+
+	if track.key != music.KeyUnknown {
+		t.Errorf("key is %v instead of none", track.key)
+	}
+	if _, err := Find(run.Id); err == nil {
+		t.Error("run isn't marked undone")
+	}
+}
+
+func TestRevertCuesOfTrackWithoutCues(t *testing.T) {
+	Dir = t.TempDir()
+
+	track := &testPerformanceTrack{testTrack: testTrack{path: "/music/track.mp3"}, data: &music.PerformanceData{}}
+	lib := &testLibrary{tracks: map[string]music.Track{track.path: track}}
+
+	run := startRun(t, "sync cues")
+	cues := &music.PerformanceData{HotCues: []music.HotCue{{Index: 2}}}
+	if err := track.SetPerformanceData(cues); err != nil {
+		t.Fatal(err)
+	}
+	run.TrackChanged(track, enums.Cues, &music.PerformanceData{}, cues)
+	run.Close()
+
+	undo := startRun(t, "undo")
+	defer undo.Close()
+	if err := run.Revert(lib, undo); err != nil {
+		t.Fatal(err)
+	}
+
+	if !track.data.Empty() {
+		t.Errorf("cues %+v are left", track.data.HotCues)
+	}
+}
+
+func TestRevertRunWithRemovedTracks(t *testing.T) {
+	Dir = t.TempDir()
+
+	survivor := &testTrack{path: "/music/track.mp3"}
+	duplicate := &testTrack{path: "/music/copy.mp3"}
+	lib := &testLibrary{tracks: map[string]music.Track{survivor.path: survivor}}
+
+	key, _ := music.ParseKey("Am")
+	run := startRun(t, "fix duplicate")
+	if err := survivor.SetKey(key); err != nil {
+		t.Fatal(err)
+	}
+	run.TrackChanged(survivor, enums.Key, music.KeyUnknown, key)
+	run.TrackRemoved(duplicate)
+	run.Close()
+
+	undo := startRun(t, "undo")
+	defer undo.Close()
+	if err := run.Revert(lib, undo); err == nil {
+		t.Fatal("run which removed a track is reverted")
+	}
+
+	// nothing is reverted, the backup restores all of it
+	if survivor.key != key {
+		t.Errorf("key is %v instead of %v", survivor.key, key)
+	}
+	if _, err := Find(run.Id); err != nil {
+		t.Errorf("run is marked undone: %v", err)
+	}
+}
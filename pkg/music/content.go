@@ -0,0 +1,97 @@
+package music
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+)
+
+/*
+	Hash of the audio content of the track file, the tags are not part of it
+	so it still matches after the track was retagged. Empty if the file was
+	never read.
+*/
+func ContentHash(track Track) string {
+	hash, err := files.AudioHash(track.FilePath())
+	if err != nil {
+		logrus.Debugf("no content hash for '%s': %v", track.FilePath(), err)
+		return ""
+	}
+	return hash
+}
+
+/*
+	Index the tracks of a library by audio content. Tracks are first grouped by
+	audio size, which only needs their headers, so only the tracks with the
+	same size are hashed.
+*/
+type ContentIndex struct {
+	mutex  sync.Mutex
+	bySize map[int64]Tracks
+}
+
+/*
+	Tracks of the library with the same audio content as the track
+*/
+func (c *ContentIndex) Matches(lib Library, track Track) (matches Tracks) {
+	if track == nil {
+		return
+	}
+
+	size, err := files.AudioSize(track.FilePath())
+	if err != nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.bySize == nil {
+		start := time.Now()
+		logrus.Infof("constructing audio content index of %s", lib)
+
+		c.bySize = map[int64]Tracks{}
+		err = lib.ForEachTrack(func(index int, total int, it Track) error {
+			if size, err := files.AudioSize(it.FilePath()); err == nil {
+				c.bySize[size] = append(c.bySize[size], it)
+			}
+			return nil
+		})
+		if err != nil {
+			logrus.Errorf("%v", err)
+		}
+		if err = files.SaveAudioHashes(); err != nil {
+			logrus.Warnf("%v", err)
+		}
+		logrus.Infof("processed %d audio sizes in %v", len(c.bySize), time.Since(start))
+	}
+
+	candidates := c.bySize[size]
+	if len(candidates) == 0 {
+		return
+	}
+
+	hash := ContentHash(track)
+	if hash == "" {
+		return
+	}
+
+	for _, it := range candidates {
+		if ContentHash(it) == hash {
+			matches = append(matches, it)
+		}
+	}
+	return
+}
+
+/*
+	Forget the indexed tracks, ie: once some of them were removed from the library
+*/
+func (c *ContentIndex) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.bySize = nil
+}
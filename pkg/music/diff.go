@@ -0,0 +1,219 @@
+package music
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+	Differences between a source and a target library, tracks are matched by file path
+*/
+type LibraryDiff struct {
+	Source       string          `json:"source"`
+	Target       string          `json:"target"`
+	OnlyInSource []string        `json:"onlyInSource,omitempty" yaml:"onlyInSource,omitempty"`
+	OnlyInTarget []string        `json:"onlyInTarget,omitempty" yaml:"onlyInTarget,omitempty"`
+	Tracks       []TrackDiff     `json:"tracks,omitempty" yaml:"tracks,omitempty"`
+	Playlists    []TracklistDiff `json:"playlists,omitempty" yaml:"playlists,omitempty"`
+	Crates       []TracklistDiff `json:"crates,omitempty" yaml:"crates,omitempty"`
+}
+
+type TrackDiff struct {
+	Path   string      `json:"path"`
+	Fields []FieldDiff `json:"fields"`
+}
+
+type FieldDiff struct {
+	Field  string `json:"field"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+/*
+	Difference of a playlist/crate, OnlyIn is set when the list exists in a single
+	library, otherwise the tracks are those missing from the other side
+*/
+type TracklistDiff struct {
+	Path         string   `json:"path"`
+	OnlyIn       string   `json:"onlyIn,omitempty" yaml:"onlyIn,omitempty"`
+	OnlyInSource []string `json:"onlyInSource,omitempty" yaml:"onlyInSource,omitempty"`
+	OnlyInTarget []string `json:"onlyInTarget,omitempty" yaml:"onlyInTarget,omitempty"`
+	Reordered    bool     `json:"reordered,omitempty" yaml:"reordered,omitempty"`
+}
+
+func Diff(src Library, tgt Library) (*LibraryDiff, error) {
+	diff := &LibraryDiff{
+		Source: src.String(),
+		Target: tgt.String(),
+	}
+
+	err := src.ForEachTrack(func(index int, total int, track Track) error {
+		other := tgt.Track(track.FilePath())
+		if other == nil {
+			diff.OnlyInSource = append(diff.OnlyInSource, track.FilePath())
+		} else if fields := diffTrack(track, other); len(fields) > 0 {
+			diff.Tracks = append(diff.Tracks, TrackDiff{Path: track.FilePath(), Fields: fields})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tgt.ForEachTrack(func(index int, total int, track Track) error {
+		if src.Track(track.FilePath()) == nil {
+			diff.OnlyInTarget = append(diff.OnlyInTarget, track.FilePath())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(diff.OnlyInSource)
+	sort.Strings(diff.OnlyInTarget)
+	sort.Slice(diff.Tracks, func(i, j int) bool {
+		return diff.Tracks[i].Path < diff.Tracks[j].Path
+	})
+
+	diff.Playlists = diffTracklists(src.Playlists(), tgt.Playlists())
+	diff.Crates = diffTracklists(src.Crates(), tgt.Crates())
+	return diff, nil
+}
+
+func (d LibraryDiff) Empty() bool {
+	return len(d.OnlyInSource) == 0 && len(d.OnlyInTarget) == 0 && len(d.Tracks) == 0 &&
+		len(d.Playlists) == 0 && len(d.Crates) == 0
+}
+
+/*
+	Text report used by the text output format
+*/
+func (d LibraryDiff) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "source: %s\ntarget: %s\n", d.Source, d.Target)
+
+	if d.Empty() {
+		b.WriteString("libraries are identical\n")
+		return b.String()
+	}
+
+	writePaths(b, "tracks only in source", d.OnlyInSource)
+	writePaths(b, "tracks only in target", d.OnlyInTarget)
+
+	if len(d.Tracks) > 0 {
+		fmt.Fprintf(b, "\n%d tracks with different metadata:\n", len(d.Tracks))
+		for _, it := range d.Tracks {
+			fmt.Fprintf(b, "  %s\n", it.Path)
+			for _, field := range it.Fields {
+				fmt.Fprintf(b, "    %s: %s => %s\n", field.Field, field.Target, field.Source)
+			}
+		}
+	}
+
+	writeTracklists(b, "playlists", d.Playlists)
+	writeTracklists(b, "crates", d.Crates)
+	return b.String()
+}
+
+func diffTrack(src Track, tgt Track) (fields []FieldDiff) {
+	add := func(field string, left interface{}, right interface{}) {
+		l, r := fmt.Sprint(left), fmt.Sprint(right)
+		if l != r {
+			fields = append(fields, FieldDiff{Field: field, Source: l, Target: r})
+		}
+	}
+
+	add("Rating", src.Rating(), tgt.Rating())
+	add("Added", src.Added(), tgt.Added())
+	add("Modified", src.Modified(), tgt.Modified())
+	add("PlayCount", src.PlayCount(), tgt.PlayCount())
+	return
+}
+
+func diffTracklists(src []Tracklist, tgt []Tracklist) []TracklistDiff {
+	tgtByPath := map[string]Tracklist{}
+	for _, it := range tgt {
+		tgtByPath[it.Path()] = it
+	}
+
+	out := []TracklistDiff{}
+	for _, it := range src {
+		other, ok := tgtByPath[it.Path()]
+		if !ok {
+			out = append(out, TracklistDiff{Path: it.Path(), OnlyIn: "source"})
+			continue
+		}
+		delete(tgtByPath, it.Path())
+
+		diff := TracklistDiff{Path: it.Path()}
+		left, right := it.Tracks().Filepaths(), other.Tracks().Filepaths()
+		diff.OnlyInSource = missingFrom(left, right)
+		diff.OnlyInTarget = missingFrom(right, left)
+		diff.Reordered = len(diff.OnlyInSource) == 0 && len(diff.OnlyInTarget) == 0 &&
+			strings.Join(left, "\n") != strings.Join(right, "\n")
+
+		if len(diff.OnlyInSource) > 0 || len(diff.OnlyInTarget) > 0 || diff.Reordered {
+			out = append(out, diff)
+		}
+	}
+
+	for path := range tgtByPath {
+		out = append(out, TracklistDiff{Path: path, OnlyIn: "target"})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+/*
+	Paths of left which are not in right
+*/
+func missingFrom(left []string, right []string) (out []string) {
+	set := map[string]bool{}
+	for _, it := range right {
+		set[it] = true
+	}
+	for _, it := range left {
+		if !set[it] {
+			out = append(out, it)
+		}
+	}
+	return
+}
+
+func writePaths(b *strings.Builder, title string, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n%d %s:\n", len(paths), title)
+	for _, it := range paths {
+		fmt.Fprintf(b, "  %s\n", it)
+	}
+}
+
+func writeTracklists(b *strings.Builder, kind string, lists []TracklistDiff) {
+	if len(lists) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n%d %s differ:\n", len(lists), kind)
+	for _, it := range lists {
+		switch {
+		case it.OnlyIn != "":
+			fmt.Fprintf(b, "  %s: only in %s\n", it.Path, it.OnlyIn)
+		case it.Reordered:
+			fmt.Fprintf(b, "  %s: same tracks in a different order\n", it.Path)
+		default:
+			fmt.Fprintf(b, "  %s: %d tracks only in source, %d only in target\n", it.Path, len(it.OnlyInSource), len(it.OnlyInTarget))
+			for _, path := range it.OnlyInSource {
+				fmt.Fprintf(b, "    + %s\n", path)
+			}
+			for _, path := range it.OnlyInTarget {
+				fmt.Fprintf(b, "    - %s\n", path)
+			}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package enginedj
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/music"
+)
+
+/*
+	Remove the tracks from their databases and from their playlists
+*/
+func (l *Library) RemoveTracks(tracks music.Tracks) error {
+	byDB := map[*EngineDJDB][]*Track{}
+	for _, it := range tracks {
+		track, ok := it.(*Track)
+		if !ok {
+			return errors.Errorf("invalid track type for '%s'", it)
+		}
+		byDB[track.src] = append(byDB[track.src], track)
+	}
+
+	// the removed tracks must not be matched anymore
+	l.hashCache = nil
+	l.contentIndex.Reset()
+
+	for db, list := range byDB {
+		if err := db.removeTracks(list); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Library) BrokenEntries(fix bool) ([]string, error) {
+	return l.check(fix, (*EngineDJDB).brokenEntries)
+}
+
+func (l *Library) EmptyLists(fix bool) ([]string, error) {
+	return l.check(fix, (*EngineDJDB).emptyLists)
+}
+
+/*
+	The databases of the other drives are only opened for a library which
+	isn't an export, otherwise all of them would look detached. A database
+	which isn't attached might only be on an unplugged drive, so its tracks are
+	only reported unless its uuid is in detach.
+*/
+func (l *Library) DetachedTracks(detach []string) ([]string, error) {
+	if l.main.IsExported() {
+		return nil, errors.Errorf("'%s' is an export, the databases of the other drives are not opened", l.main.origin)
+	}
+	uuids := map[string]bool{}
+	for _, it := range detach {
+		uuids[it] = true
+	}
+	return l.check(len(uuids) > 0, func(db *EngineDJDB, fix bool) ([]string, error) {
+		return db.detachedTracks(uuids)
+	})
+}
+
+func (l *Library) check(fix bool, fct func(db *EngineDJDB, fix bool) ([]string, error)) ([]string, error) {
+	out, err := fct(l.main, fix)
+	if err != nil {
+		return out, err
+	}
+	for _, db := range l.dbs {
+		found, err := fct(db, fix)
+		out = append(out, found...)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func (l *EngineDJDB) removeTracks(tracks []*Track) error {
+	tx, err := l.sql.Beginx()
+	if err != nil {
+		return errors.Wrapf(err, "fail to start transaction on '%s'", l.path)
+	}
+
+	for _, it := range tracks {
+		if err = l.deleteTrack(tx, it.entry.Id); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "fail to remove '%s'", it)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrapf(err, "fail to commit removal on '%s'", l.path)
+	}
+
+	for _, it := range tracks {
+		l.forgetTrack(it.entry.Id)
+	}
+	return nil
+}
+
+func (l *EngineDJDB) brokenEntries(fix bool) ([]string, error) {
+	entities := []playListEntityEntry{}
+	query := `SELECT * FROM PlaylistEntity WHERE databaseUuid = ? AND trackId NOT IN (SELECT id FROM Track)`
+	if err := l.sql.Select(&entities, query, l.UUID); err != nil {
+		return nil, errors.Wrapf(err, "fail to fetch playlist entities of '%s'", l.path)
+	}
+
+	out := []string{}
+	for _, it := range entities {
+		name := fmt.Sprintf("%d", it.ListId.Int32)
+		if list, err := l.fetchListWith(int(it.ListId.Int32)); err == nil && list != nil {
+			name = list.Path()
+		}
+		out = append(out, fmt.Sprintf("playlist '%s' refers to the deleted track %d in '%s'", name, it.TrackId.Int32, l.origin))
+	}
+
+	if !fix || len(entities) == 0 {
+		return out, nil
+	}
+
+	tx, err := l.sql.Beginx()
+	if err != nil {
+		return out, errors.Wrapf(err, "fail to start transaction on '%s'", l.path)
+	}
+	for _, it := range entities {
+		if err = unlinkEntity(tx, it); err != nil {
+			tx.Rollback()
+			return out, errors.Wrapf(err, "fail to delete playlist entity %d of '%s'", it.Id, l.path)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return out, errors.Wrapf(err, "fail to commit playlist entities removal on '%s'", l.path)
+	}
+	return out, nil
+}
+
+/*
+	Playlists without any track nor child. Removing them can leave their parent
+	empty, so they are looked up again until none is left.
+*/
+func (l *EngineDJDB) emptyLists(fix bool) ([]string, error) {
+	out := []string{}
+	for {
+		entries := []playlistEntry{}
+		query := `SELECT * FROM Playlist WHERE NOT EXISTS (SELECT 1 FROM PlaylistEntity WHERE listId = Playlist.id) AND NOT EXISTS (SELECT 1 FROM Playlist AS child WHERE child.parentListId = Playlist.id)`
+		if err := l.sql.Select(&entries, query); err != nil {
+			return out, errors.Wrapf(err, "fail to fetch playlists of '%s'", l.path)
+		}
+
+		for _, it := range entries {
+			out = append(out, fmt.Sprintf("empty playlist '%s' in '%s'", newList(l, it).Path(), l.origin))
+		}
+
+		if !fix || len(entries) == 0 {
+			return out, nil
+		}
+		if err := l.deleteLists(entries); err != nil {
+			return out, err
+		}
+	}
+}
+
+/*
+	Siblings are a linked list through nextListId, the previous sibling of a
+	deleted playlist points to its next one
+*/
+func (l *EngineDJDB) deleteLists(entries []playlistEntry) error {
+	tx, err := l.sql.Beginx()
+	if err != nil {
+		return errors.Wrapf(err, "fail to start transaction on '%s'", l.path)
+	}
+
+	for _, it := range entries {
+		if _, err = tx.Exec(`DELETE FROM Playlist WHERE id = ?`, it.Id); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "fail to delete playlist '%s'", it.Title.String)
+		}
+		_, err = tx.Exec(`UPDATE Playlist SET nextListId = ? WHERE parentListId = ? AND nextListId = ?`, it.NextListId, it.ParentListId, it.Id)
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "fail to relink the siblings of playlist '%s'", it.Title.String)
+		}
+		logrus.Infof("deleted playlist '%s' from EngineDJ database", it.Title.String)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrapf(err, "fail to commit playlist removal on '%s'", l.path)
+	}
+	return nil
+}
+
+/*
+	Tracks whose origin is a database which isn't attached, the ones of the
+	databases in detach become tracks of their own database. The origin is
+	also the database a track was copied from, ie: the desktop collection of
+	the tracks of a stick.
+*/
+func (l *EngineDJDB) detachedTracks(detach map[string]bool) ([]string, error) {
+	entries := []trackEntry{}
+	query := `SELECT * FROM Track WHERE originDatabaseUuid IS NOT NULL AND originDatabaseUuid != ''`
+	if err := l.sql.Unsafe().Select(&entries, query); err != nil {
+		return nil, errors.Wrapf(err, "fail to fetch tracks of '%s'", l.path)
+	}
+
+	out := []string{}
+	detached := []trackEntry{}
+	for _, it := range entries {
+		uuid := it.OriginDatabaseUuid.String
+		if _, ok := l.lib.dbs[uuid]; ok || uuid == l.UUID || uuid == l.lib.main.UUID {
+			continue
+		}
+		out = append(out, fmt.Sprintf("'%s' refers to the database %s which isn't attached", newTrack(l, it).FilePath(), uuid))
+		if detach[uuid] {
+			detached = append(detached, it)
+		}
+	}
+
+	if len(detached) == 0 {
+		return out, nil
+	}
+
+	tx, err := l.sql.Beginx()
+	if err != nil {
+		return out, errors.Wrapf(err, "fail to start transaction on '%s'", l.path)
+	}
+	for _, it := range detached {
+		_, err = tx.Exec(`UPDATE Track SET originDatabaseUuid = NULL, originTrackId = NULL WHERE id = ?`, it.Id)
+		if err != nil {
+			tx.Rollback()
+			return out, errors.Wrapf(err, "fail to detach track %d of '%s'", it.Id, l.path)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return out, errors.Wrapf(err, "fail to commit detached tracks on '%s'", l.path)
+	}
+	return out, nil
+}
@@ -3,8 +3,10 @@ package enginedj
 import (
 	"database/sql"
 	"fmt"
+	"math"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
@@ -16,18 +18,21 @@ import (
 
 type EngineDJDB struct {
 	UUID     string
+	path     string
 	origin   string
 	info     string
 	sql      *sqlx.DB
 	total    int
 	trackIds map[string]trackEntry
 	lib      *Library
+	played   int64
 }
 
 func OpenDB(path string, lib *Library) (*EngineDJDB, error) {
 	p := &EngineDJDB{
 		trackIds: map[string]trackEntry{},
 		lib:      lib,
+		path:     path,
 	}
 
 	logrus.Infof("opening EngineDJ database located at '%s'", path)
@@ -53,6 +58,7 @@ func OpenDB(path string, lib *Library) (*EngineDJDB, error) {
 		return nil, errors.Wrapf(err, "failed to fetch EngineDJ database information")
 	}
 	p.UUID = info.UUID
+	p.played = info.PlayedIndicator.Int64
 
 	if err = p.buildIdsMap(); err != nil {
 		p.Close()
@@ -65,6 +71,13 @@ func OpenDB(path string, lib *Library) (*EngineDJDB, error) {
 	return p, nil
 }
 
+/*
+	The database and its performance data companion (p.db)
+*/
+func (l *EngineDJDB) databaseFiles() []string {
+	return []string{l.path, filepath.Join(filepath.Dir(l.path), "p.db")}
+}
+
 func (l *EngineDJDB) IsExported() bool {
 	query := `select count(*) from Pack`
 	count := 0
@@ -75,37 +88,72 @@ func (l *EngineDJDB) IsExported() bool {
 	return count != 0
 }
 
-func (l *EngineDJDB) createList(path string, folder bool) *TrackList {
-	split := strings.Split(path, "/")
-	name := split[len(split)-1]
-	ppath := strings.Replace(path, "/", ";", -1) + ";"
+/*
+	Siblings playlists are a linked list through nextListId, 0 being the last one.
+	The new playlist is appended after the last sibling of its parent.
 
-	logrus.Infof("creating playlist '%s in EngineDJ database", path)
+	Engine DJ databases have triggers which relink the sibling pointing to
+	-(1 + nextListId) on insert, the last sibling is marked that way so the linking
+	is right with or without them.
+*/
+func (l *EngineDJDB) createList(title string, parentId int) (*TrackList, error) {
+	logrus.Infof("creating playlist '%s' in EngineDJ database '%s'", title, l.origin)
 
-	query := `INSERT INTO Playlist (title, path, isFolder, isExplicitlyExported, id) VALUES (?, ?, ?, 1, (SELECT max(id) + 1 FROM List))`
-	_, err := l.sql.Exec(query, name, ppath, folder)
+	tx, err := l.sql.Beginx()
 	if err != nil {
-		logrus.Errorf("failed to create playlist '%s': %v", path, err)
-		return nil
+		return nil, errors.Wrapf(err, "failed start db transaction for playlist '%s'", title)
+	}
+
+	lastId := 0
+	err = tx.Get(&lastId, `SELECT id FROM Playlist WHERE parentListId = ? AND nextListId = 0`, parentId)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to fetch last sibling of playlist '%s'", title)
+	}
+
+	if lastId != 0 {
+		_, err = tx.Exec(`UPDATE Playlist SET nextListId = -1 WHERE id = ?`, lastId)
+		if err != nil {
+			tx.Rollback()
+			return nil, errors.Wrapf(err, "failed to unlink last sibling of playlist '%s'", title)
+		}
 	}
 
-	list, err := l.fetchList(path)
+	query := `INSERT INTO Playlist (title, parentListId, isPersisted, nextListId, lastEditTime, isExplicitlyExported) VALUES (?, ?, 1, 0, ?, 1)`
+	res, err := tx.Exec(query, title, parentId, time.Now().UTC().Format(DateTimeFormat))
 	if err != nil {
-		logrus.Errorf("failed to create playlist '%s': %v", path, err)
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to create playlist '%s'", title)
 	}
-	return list
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to create playlist '%s'", title)
+	}
+
+	if lastId != 0 {
+		_, err = tx.Exec(`UPDATE Playlist SET nextListId = ? WHERE id = ?`, id, lastId)
+		if err != nil {
+			tx.Rollback()
+			return nil, errors.Wrapf(err, "failed to link playlist '%s' to its sibling", title)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Wrapf(err, "fail to commit transaction for playlist '%s'", title)
+	}
+
+	return l.fetchListWith(int(id))
 }
 
-func (l *EngineDJDB) fetchList(path string) (*TrackList, error) {
-	ppath := strings.Replace(path, "/", ";", -1) + ";"
+func (l *EngineDJDB) fetchChild(parentId int, title string) (*TrackList, error) {
 	entry := playlistEntry{}
-	err := l.sql.Get(&entry, `SELECT * FROM Playlist WHERE path = ?`, ppath)
+	err := l.sql.Get(&entry, `SELECT * FROM Playlist WHERE parentListId = ? AND title = ?`, parentId, title)
 	if err != nil {
 		if err != sql.ErrNoRows {
-			return nil, errors.Wrapf(err, "fail to fetch playlist '%s'", path)
-		} else {
-			return nil, nil
+			return nil, errors.Wrapf(err, "fail to fetch playlist '%s'", title)
 		}
+		return nil, nil
 	}
 	return &TrackList{
 		src:   l,
@@ -113,6 +161,23 @@ func (l *EngineDJDB) fetchList(path string) (*TrackList, error) {
 	}, nil
 }
 
+/*
+	Walk the playlist tree from the root to find the playlist with that path
+*/
+func (l *EngineDJDB) fetchList(path string) (*TrackList, error) {
+	var list *TrackList
+	parentId := 0
+	for _, title := range strings.Split(path, "/") {
+		var err error
+		list, err = l.fetchChild(parentId, title)
+		if list == nil || err != nil {
+			return nil, err
+		}
+		parentId = list.entry.Id
+	}
+	return list, nil
+}
+
 func (l *EngineDJDB) fetchLists() []TrackList {
 	lists, err := l.fetchListEntries()
 	if err != nil {
@@ -152,10 +217,10 @@ func (l *EngineDJDB) fetchListEntries() ([]playlistEntry, error) {
 	// filter out folders
 	for _, it := range lists {
 		children := 0
-		query = `SELECT COUNT(*) from PlaylistAllChildren WHERE PlaylistAllChildren.id = ?`
+		query = `SELECT COUNT(*) FROM Playlist WHERE parentListId = ?`
 		err = l.sql.Get(&children, query, it.Id)
 		if err != nil {
-			logrus.Errorf("failed to get child count for playlist '%s'", it.Title)
+			logrus.Errorf("failed to get child count for playlist '%s'", it.Title.String)
 		}
 		if children == 0 {
 			out = append(out, it)
@@ -179,10 +244,7 @@ func (l *EngineDJDB) buildIdsMap() error {
 		return errors.Wrapf(err, "failed to fetch track ids")
 	}
 	for _, e := range entries {
-		fpath := e.Path.String
-		if !filepath.IsAbs(fpath) {
-			fpath = files.NormalizePath(l.origin + "/" + fpath)
-		}
+		fpath := l.absPath(e.Path.String)
 		// fpath = files.RemoveAccent(fpath)
 		if _, ok := l.trackIds[fpath]; ok {
 			logrus.Warnf("duplicate entry in sqlite for path '%s'", fpath)
@@ -192,6 +254,108 @@ func (l *EngineDJDB) buildIdsMap() error {
 	return nil
 }
 
+/*
+	Paths of the tracks are relative to the Database2 folder
+*/
+func (l *EngineDJDB) absPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return files.NormalizePath(l.origin + "/" + path)
+}
+
+/*
+	Path of the file relative to the database, as stored in the Track table
+*/
+func (l *EngineDJDB) relPath(path string) string {
+	rpath, err := filepath.Rel(l.origin, path)
+	if err != nil {
+		return path
+	}
+	return rpath
+}
+
+/*
+	Key the entry of a moved track by its new path
+*/
+func (l *EngineDJDB) moveEntry(trackId int, oldpath string, rpath string) {
+	entry, ok := l.trackIds[oldpath]
+	if !ok || entry.Id != trackId {
+		return
+	}
+	delete(l.trackIds, oldpath)
+	entry.Path = sql.NullString{String: rpath, Valid: true}
+	entry.Filename = sql.NullString{String: filepath.Base(rpath), Valid: true}
+	l.trackIds[l.absPath(rpath)] = entry
+}
+
+/*
+	Tell if the file is on the drive of the database, which is in the
+	"Engine Library/Database2" folder at its root
+*/
+func (l *EngineDJDB) onDrive(path string) bool {
+	root := filepath.Dir(filepath.Dir(l.origin))
+	rel, err := filepath.Rel(root, files.NormalizePath(path))
+	rel = filepath.ToSlash(rel)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+/*
+	Insert the track with the metadata read from the tags of the file, the
+	track is its own origin like the ones imported by Engine DJ
+*/
+func (l *EngineDJDB) addTrack(path string, meta music.Track) (*Track, error) {
+	path = files.NormalizePath(path)
+	rpath, err := filepath.Rel(l.origin, path)
+	if err != nil {
+		rpath = path
+	}
+	rpath = filepath.ToSlash(rpath)
+
+	tx, err := l.sql.Beginx()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed start db transaction to add '%s'", path)
+	}
+
+	var key interface{}
+	if k := meta.Key(); k.Valid() {
+		key = k.Engine()
+	}
+
+	query := `INSERT INTO Track (title, artist, album, genre, comment, key, length, bpm, bpmAnalyzed, year, path, filename, fileType, fileBytes, dateCreated, dateAdded, isPlayed, isAnalyzed, isAvailable, isMetadataImported, originDatabaseUuid) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, 0, 1, 1, ?)`
+	res, err := tx.Exec(query,
+		meta.Title(), meta.Artist(), meta.Album(), meta.Genre(), meta.Comment(), key,
+		int(meta.Duration().Seconds()), int(math.Round(meta.BPM())), meta.BPM(), meta.Year(),
+		rpath, filepath.Base(path), strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."), files.Size(path),
+		meta.Modified().UTC().Format(DateTimeFormat), time.Now().UTC().Format(DateTimeFormat), l.UUID)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to add track '%s'", path)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to add track '%s'", path)
+	}
+
+	if _, err = tx.Exec(`UPDATE Track SET originTrackId = id WHERE id = ?`, id); err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to set origin of track '%s'", path)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Wrapf(err, "fail to commit transaction for '%s'", path)
+	}
+	logrus.Infof("added '%s' to EngineDJ database '%s'", path, l.origin)
+
+	entry := trackEntry{}
+	if err = l.sql.Unsafe().Get(&entry, `SELECT * FROM Track WHERE id = ?`, id); err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch added track '%s'", path)
+	}
+	l.trackIds[l.absPath(rpath)] = entry
+	return newTrack(l, entry), nil
+}
+
 func (l *EngineDJDB) Track(filename string) music.Track {
 	// if filename == "m:/techno/-= ambient =-/arutani/arutani - the mermaid girl ft. ăvem.mp3" {
 	// 	println("qawewqeq")
@@ -231,3 +395,90 @@ func (l *EngineDJDB) Tracks() ([]*Track, error) {
 
 	return tracks, nil
 }
+
+/*
+	Point the playlist entities of the duplicates to the track and delete them.
+	A track can only be once in a playlist, so the entity of a duplicate in a
+	playlist which already contains the track is unlinked from the chain.
+*/
+func (l *EngineDJDB) mergeTracks(track *Track, duplicates []*Track) error {
+	tx, err := l.sql.Beginx()
+	if err != nil {
+		return errors.Wrapf(err, "fail to start transaction on '%s'", l.path)
+	}
+
+	fail := func(err error, it *Track) error {
+		tx.Rollback()
+		return errors.Wrapf(err, "fail to merge '%s' into '%s'", it, track)
+	}
+
+	for _, it := range duplicates {
+		entities := []playListEntityEntry{}
+		query := `SELECT * FROM PlaylistEntity WHERE trackId = ? AND databaseUuid = ? AND listId IN (SELECT listId FROM PlaylistEntity WHERE trackId = ? AND databaseUuid = ?)`
+		if err = tx.Select(&entities, query, it.entry.Id, l.UUID, track.entry.Id, l.UUID); err != nil {
+			return fail(err, it)
+		}
+
+		for _, entity := range entities {
+			if err = unlinkEntity(tx, entity); err != nil {
+				return fail(err, it)
+			}
+		}
+
+		_, err = tx.Exec(`UPDATE PlaylistEntity SET trackId = ? WHERE trackId = ? AND databaseUuid = ?`, track.entry.Id, it.entry.Id, l.UUID)
+		if err != nil {
+			return fail(err, it)
+		}
+		if err = l.deleteTrack(tx, it.entry.Id); err != nil {
+			return fail(err, it)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrapf(err, "fail to commit merge on '%s'", l.path)
+	}
+
+	for _, it := range duplicates {
+		l.forgetTrack(it.entry.Id)
+	}
+	return nil
+}
+
+/*
+	Delete the track row and unlink its playlist entities
+*/
+func (l *EngineDJDB) deleteTrack(tx *sqlx.Tx, trackId int) error {
+	entities := []playListEntityEntry{}
+	err := tx.Select(&entities, `SELECT * FROM PlaylistEntity WHERE trackId = ? AND databaseUuid = ?`, trackId, l.UUID)
+	if err != nil {
+		return err
+	}
+	for _, it := range entities {
+		if err = unlinkEntity(tx, it); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`DELETE FROM Track WHERE id = ?`, trackId)
+	return err
+}
+
+/*
+	Remove the entity from the chain of its playlist, its predecessor points
+	to its successor
+*/
+func unlinkEntity(tx *sqlx.Tx, entity playListEntityEntry) error {
+	_, err := tx.Exec(`UPDATE PlaylistEntity SET nextEntityId = ? WHERE listId = ? AND nextEntityId = ?`, entity.NextEntityId, entity.ListId, entity.Id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM PlaylistEntity WHERE id = ?`, entity.Id)
+	return err
+}
+
+func (l *EngineDJDB) forgetTrack(id int) {
+	for path, it := range l.trackIds {
+		if it.Id == id {
+			delete(l.trackIds, path)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package enginedj
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// tables of db_types.go with the columns read and written by primetools
+var testSchema = []string{
+	`CREATE TABLE Information (id INTEGER PRIMARY KEY AUTOINCREMENT, uuid TEXT, schemaVersionMajor INTEGER, schemaVersionMinor INTEGER, schemaVersionPatch INTEGER, currentPlayedIndiciator INTEGER)`,
+	`CREATE TABLE Pack (id INTEGER PRIMARY KEY AUTOINCREMENT)`,
+	`CREATE TABLE Track (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, length INTEGER, bpm INTEGER, year INTEGER, path TEXT, filename TEXT,
+		bitrate INTEGER, bpmAnalyzed REAL, fileBytes INTEGER, title TEXT, artist TEXT, album TEXT, genre TEXT,
+		comment TEXT, key INTEGER, rating INTEGER, timeLastPlayed DATETIME, isPlayed BOOLEAN, fileType TEXT,
+		isAnalyzed BOOLEAN, dateCreated DATETIME, dateAdded DATETIME, isAvailable BOOLEAN, playedIndicator INTEGER,
+		isMetadataImported BOOLEAN, originDatabaseUuid TEXT, originTrackId INTEGER, beatData BLOB, quickCues BLOB,
+		loops BLOB,
+		CONSTRAINT C_originDatabaseUuid_originTrackId UNIQUE (originDatabaseUuid, originTrackId),
+		CONSTRAINT C_path UNIQUE (path)
+	)`,
+	`CREATE TABLE Playlist (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT, parentListId INTEGER, isPersisted BOOLEAN,
+		nextListId INTEGER, lastEditTime DATETIME, isExplicitlyExported BOOLEAN,
+		CONSTRAINT C_NAME_UNIQUE_FOR_PARENT UNIQUE (title, parentListId),
+		CONSTRAINT C_NEXT_LIST_ID_UNIQUE_FOR_PARENT UNIQUE (parentListId, nextListId)
+	)`,
+	`CREATE TABLE PlaylistEntity (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, listId INTEGER, trackId INTEGER, databaseUuid TEXT,
+		nextEntityId INTEGER, membershipReference INTEGER,
+		CONSTRAINT C_NAME_UNIQUE_FOR_LIST UNIQUE (listId, databaseUuid, trackId),
+		FOREIGN KEY (listId) REFERENCES Playlist (id) ON DELETE CASCADE
+	)`,
+}
+
+/*
+	Empty database at the root of a new drive, ie: <root>/Engine Library/Database2/m.db
+*/
+func testDB(t *testing.T, uuid string) string {
+	path := filepath.Join(t.TempDir(), "Engine Library", "Database2", "m.db")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sqlx.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, it := range testSchema {
+		if _, err = db.Exec(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+	query := `INSERT INTO Information (uuid, schemaVersionMajor, schemaVersionMinor, schemaVersionPatch, currentPlayedIndiciator) VALUES (?, 2, 18, 0, 42)`
+	if _, err = db.Exec(query, uuid); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+/*
+	Library of the main database and the attached ones, each on its own drive
+*/
+func testLibrary(t *testing.T, main string, attached ...string) *Library {
+	lib := &Library{dbs: map[string]*EngineDJDB{}}
+
+	var err error
+	if lib.main, err = OpenDB(testDB(t, main), lib); err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range attached {
+		db, err := OpenDB(testDB(t, it), lib)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lib.dbs[db.UUID] = db
+	}
+	t.Cleanup(lib.Close)
+	return lib
+}
+
+/*
+	Insert a track whose file is at path relative to the database folder, it
+	is its own origin unless origin is given as uuid and track id
+*/
+func addTestTrack(t *testing.T, db *EngineDJDB, path string, origin ...interface{}) *Track {
+	query := `INSERT INTO Track (path, filename, title, originDatabaseUuid) VALUES (?, ?, ?, ?)`
+	res, err := db.sql.Exec(query, path, filepath.Base(path), filepath.Base(path), db.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+
+	if len(origin) == 2 {
+		_, err = db.sql.Exec(`UPDATE Track SET originDatabaseUuid = ?, originTrackId = ? WHERE id = ?`, origin[0], origin[1], id)
+	} else {
+		_, err = db.sql.Exec(`UPDATE Track SET originTrackId = id WHERE id = ?`, id)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.trackIds = map[string]trackEntry{}
+	if err = db.buildIdsMap(); err != nil {
+		t.Fatal(err)
+	}
+	track, ok := db.Track(db.absPath(path)).(*Track)
+	if !ok {
+		t.Fatalf("track '%s' not found", path)
+	}
+	return track
+}
+
+func TestClearKey(t *testing.T) {
+	lib := testLibrary(t, "main")
+	track := addTestTrack(t, lib.main, "../../Music/track.mp3")
+
+	key := track.Key()
+	if key.Valid() {
+		t.Fatalf("new track has the key %v", key)
+	}
+	key = 5
+	if err := track.SetKey(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := track.ClearKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := 0
+	if err := lib.main.sql.Get(&stored, `SELECT COUNT(*) FROM Track WHERE key IS NULL`); err != nil {
+		t.Fatal(err)
+	}
+	if stored != 1 || track.Key().Valid() {
+		t.Errorf("key isn't cleared")
+	}
+}
+
+func TestSetPlayCount(t *testing.T) {
+	lib := testLibrary(t, "main")
+	track := addTestTrack(t, lib.main, "../../Music/track.mp3")
+
+	if err := track.SetPlayCount(3); err != nil {
+		t.Fatal(err)
+	}
+	if track.PlayCount() != 1 || track.entry.PlayedIndicator.Int64 != 42 || !track.entry.LastPlayed.Valid {
+		t.Fatalf("track isn't played: %+v", track.entry)
+	}
+
+	// a played track isn't written again
+	if _, err := lib.main.sql.Exec(`UPDATE Track SET playedIndicator = 7`); err != nil {
+		t.Fatal(err)
+	}
+	if err := track.SetPlayCount(5); err != nil {
+		t.Fatal(err)
+	}
+	indicator := 0
+	if err := lib.main.sql.Get(&indicator, `SELECT playedIndicator FROM Track WHERE id = ?`, track.entry.Id); err != nil {
+		t.Fatal(err)
+	}
+	if indicator != 7 {
+		t.Errorf("played track was written again")
+	}
+
+	if err := track.SetPlayCount(0); err != nil {
+		t.Fatal(err)
+	}
+	if track.PlayCount() != 0 || track.entry.PlayedIndicator.Valid || track.entry.LastPlayed.Valid {
+		t.Errorf("track is still played: %+v", track.entry)
+	}
+}
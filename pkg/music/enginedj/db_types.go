@@ -4,6 +4,9 @@ import (
 	"database/sql"
 )
 
+// format of the DATETIME columns written by Engine DJ
+const DateTimeFormat = "2006-01-02 15:04:05"
+
 /*
 CREATE TABLE Track (
   id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -64,17 +67,25 @@ type trackEntry struct {
 	Album sql.NullString `db:"album"`
 	Artist sql.NullString `db:"artist"`
 
-	Length   sql.NullInt32  `db:"length"`
-	BPM      sql.NullInt32  `db:"bpm"`
-	Year     sql.NullInt32  `db:"year"`
-	Path     sql.NullString `db:"path"`
-	Filename sql.NullString `db:"filename"`
-	Bitrate  sql.NullInt32  `db:"bitrate"`
-	Size     sql.NullInt32  `db:"fileBytes"`
+	Length      sql.NullInt32   `db:"length"`
+	BPM         sql.NullInt32   `db:"bpm"`
+	BPMAnalyzed sql.NullFloat64 `db:"bpmAnalyzed"`
+	Year        sql.NullInt32   `db:"year"`
+	Path        sql.NullString  `db:"path"`
+	Filename    sql.NullString  `db:"filename"`
+	Bitrate     sql.NullInt32   `db:"bitrate"`
+	Size        sql.NullInt32   `db:"fileBytes"`
+	Genre       sql.NullString  `db:"genre"`
+	Comment     sql.NullString  `db:"comment"`
+	Key         sql.NullInt32   `db:"key"`
 
 	Rating  sql.NullInt32 `db:"rating"`
-	Created sql.NullTime `json:"dateCreated"`
-	Added   sql.NullTime `json:"dateAdded"`
+	Created sql.NullTime  `db:"dateCreated"`
+	Added   sql.NullTime  `db:"dateAdded"`
+
+	IsPlayed        sql.NullBool  `db:"isPlayed"`
+	PlayedIndicator sql.NullInt64 `db:"playedIndicator"`
+	LastPlayed      sql.NullTime  `db:"timeLastPlayed"`
 
 	OriginTrackId      sql.NullInt32  `db:"originTrackId"`
 	OriginDatabaseUuid sql.NullString `db:"originDatabaseUuid"`
@@ -130,4 +141,7 @@ type infoEntry struct {
 	SchemaVersionMajor int    `db:"schemaVersionMajor"`
 	SchemaVersionMinor int    `db:"schemaVersionMinor"`
 	SchemaVersionPatch int    `db:"schemaVersionPatch"`
+
+	// playedIndicator of the tracks played with this database
+	PlayedIndicator sql.NullInt64 `db:"currentPlayedIndiciator"`
 }
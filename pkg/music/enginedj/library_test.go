@@ -0,0 +1,113 @@
+package enginedj
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+
+	"primetools/pkg/music"
+)
+
+/*
+	Absolute path of a file at the root of the drive of db
+*/
+func drivePath(db *EngineDJDB, name string) string {
+	return db.absPath("../../" + name)
+}
+
+func storedPath(t *testing.T, db *EngineDJDB, trackId int) string {
+	path := ""
+	if err := db.sql.Get(&path, `SELECT path FROM Track WHERE id = ?`, trackId); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMoveTracks(t *testing.T) {
+	lib := testLibrary(t, "main", "usb")
+	usb := lib.dbs["usb"]
+
+	local := addTestTrack(t, lib.main, "../../Music/a.mp3")
+	original := addTestTrack(t, usb, "../../Music/b.mp3")
+	rpath, err := filepath.Rel(lib.main.origin, original.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := addTestTrack(t, lib.main, rpath, "usb", original.entry.Id)
+
+	moves := []music.TrackMove{
+		{Track: local, Path: drivePath(lib.main, "Moved/a.mp3")},
+		{Track: copied, Path: drivePath(usb, "Moved/b.mp3")},
+	}
+	oldpath := copied.FilePath()
+	done, err := lib.MoveTracks(moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 {
+		t.Fatalf("%d moves done instead of 2", len(done))
+	}
+
+	// both copies of the track are moved, each relative to its database
+	if path := storedPath(t, usb, original.entry.Id); path != "../../Moved/b.mp3" {
+		t.Errorf("path in the origin database is '%s'", path)
+	}
+	for _, it := range moves {
+		if it.Track.FilePath() != it.Path {
+			t.Errorf("track reports '%s' instead of '%s'", it.Track.FilePath(), it.Path)
+		}
+		if lib.Track(it.Path) == nil {
+			t.Errorf("'%s' isn't found at its new path", it.Path)
+		}
+	}
+	if lib.main.Track(oldpath) != nil || usb.Track(oldpath) != nil {
+		t.Errorf("'%s' is still found at its previous path", oldpath)
+	}
+}
+
+func TestMoveTracksCommitFailure(t *testing.T) {
+	lib := testLibrary(t, "main", "usb")
+	usb := lib.dbs["usb"]
+
+	local := addTestTrack(t, lib.main, "../../Music/a.mp3")
+	external := addTestTrack(t, usb, "../../Music/b.mp3")
+
+	// a reader of the usb database keeps its commit from getting the lock
+	usb.sql.SetMaxOpenConns(1)
+	if _, err := usb.sql.Exec(`PRAGMA busy_timeout = 0`); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := sqlx.Open("sqlite3", usb.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	tx, err := reader.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	count := 0
+	if err = tx.Get(&count, `SELECT COUNT(*) FROM Track`); err != nil {
+		t.Fatal(err)
+	}
+
+	oldpath := external.FilePath()
+	done, err := lib.MoveTracks([]music.TrackMove{
+		{Track: local, Path: drivePath(lib.main, "Moved/a.mp3")},
+		{Track: external, Path: drivePath(usb, "Moved/b.mp3")},
+	})
+	if err == nil {
+		t.Fatal("failing commit isn't reported")
+	}
+	if len(done) != 1 || done[0].Track != local {
+		t.Fatalf("moves done are %+v instead of the first one", done)
+	}
+	if path := storedPath(t, lib.main, local.entry.Id); path != "../../Moved/a.mp3" {
+		t.Errorf("committed path is '%s'", path)
+	}
+	if external.FilePath() != oldpath || usb.Track(oldpath) == nil {
+		t.Errorf("track of the failed commit reports '%s'", external.FilePath())
+	}
+}
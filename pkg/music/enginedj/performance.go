@@ -0,0 +1,546 @@
+package enginedj
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"math"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"primetools/pkg/music"
+)
+
+/*
+	Performance blobs stored in the Track table, beatData and quickCues are
+	compressed like Qt's qCompress (big endian uncompressed size followed by a
+	zlib stream), loops are stored raw
+*/
+type performanceEntry struct {
+	BeatData  []byte `db:"beatData"`
+	QuickCues []byte `db:"quickCues"`
+	Loops     []byte `db:"loops"`
+}
+
+type beatMarker struct {
+	Offset    float64
+	Beat      int64
+	BeatCount int32
+	Unknown   int32
+}
+
+type beatData struct {
+	SampleRate  float64
+	SampleCount float64
+	IsSet       bool
+	Default     []beatMarker
+	Adjusted    []beatMarker
+}
+
+type quickCue struct {
+	Label  string
+	Offset float64
+	Color  color.RGBA
+}
+
+type quickCues struct {
+	Cues           []quickCue
+	MainCue        float64
+	IsMainAdjusted bool
+	DefaultMainCue float64
+}
+
+type loop struct {
+	Label      string
+	Start      float64
+	End        float64
+	IsStartSet bool
+	IsEndSet   bool
+	Color      color.RGBA
+}
+
+// sample offset of unset cues and loops
+const unsetOffset = -1
+
+func uncompress(blob []byte) ([]byte, error) {
+	if len(blob) < 4 {
+		return nil, errors.New("compressed blob is too small")
+	}
+	size := binary.BigEndian.Uint32(blob[:4])
+	reader, err := zlib.NewReader(bytes.NewReader(blob[4:]))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to uncompress blob")
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to uncompress blob")
+	}
+	if uint32(len(content)) != size {
+		return nil, errors.Errorf("uncompressed blob size mismatch, %d instead of %d", len(content), size)
+	}
+	return content, nil
+}
+
+func compress(content []byte) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, uint32(len(content)))
+	writer := zlib.NewWriter(buf)
+	writer.Write(content)
+	writer.Close()
+	return buf.Bytes()
+}
+
+func decodeBeatData(blob []byte) (data beatData, err error) {
+	content, err := uncompress(blob)
+	if err != nil {
+		return
+	}
+	reader := bytes.NewReader(content)
+
+	isSet := uint8(0)
+	for _, it := range []interface{}{&data.SampleRate, &data.SampleCount, &isSet} {
+		if err = binary.Read(reader, binary.BigEndian, it); err != nil {
+			return data, errors.Wrap(err, "failed to decode beat data")
+		}
+	}
+	data.IsSet = isSet != 0
+
+	if data.Default, err = decodeBeatMarkers(reader); err != nil {
+		return
+	}
+	data.Adjusted, err = decodeBeatMarkers(reader)
+	return
+}
+
+/*
+	Markers count is big endian but the markers themselves are little endian
+*/
+func decodeBeatMarkers(reader io.Reader) ([]beatMarker, error) {
+	count := int64(0)
+	if err := binary.Read(reader, binary.BigEndian, &count); err != nil {
+		return nil, errors.Wrap(err, "failed to decode beat markers count")
+	}
+	if count < 0 || count > math.MaxUint16 {
+		return nil, errors.Errorf("invalid beat markers count %d", count)
+	}
+	markers := make([]beatMarker, count)
+	if err := binary.Read(reader, binary.LittleEndian, markers); err != nil {
+		return nil, errors.Wrap(err, "failed to decode beat markers")
+	}
+	return markers, nil
+}
+
+func (b beatData) encode() []byte {
+	buf := &bytes.Buffer{}
+	isSet := uint8(0)
+	if b.IsSet {
+		isSet = 1
+	}
+	binary.Write(buf, binary.BigEndian, b.SampleRate)
+	binary.Write(buf, binary.BigEndian, b.SampleCount)
+	binary.Write(buf, binary.BigEndian, isSet)
+	for _, markers := range [][]beatMarker{b.Default, b.Adjusted} {
+		binary.Write(buf, binary.BigEndian, int64(len(markers)))
+		binary.Write(buf, binary.LittleEndian, markers)
+	}
+	return compress(buf.Bytes())
+}
+
+func decodeQuickCues(blob []byte) (data quickCues, err error) {
+	content, err := uncompress(blob)
+	if err != nil {
+		return
+	}
+	reader := bytes.NewReader(content)
+
+	count := int64(0)
+	if err = binary.Read(reader, binary.BigEndian, &count); err != nil {
+		return data, errors.Wrap(err, "failed to decode quick cues count")
+	}
+	if count < 0 || count > math.MaxUint8 {
+		return data, errors.Errorf("invalid quick cues count %d", count)
+	}
+
+	for i := int64(0); i < count; i++ {
+		cue := quickCue{}
+		if cue.Label, err = readLabel(reader); err != nil {
+			return data, err
+		}
+		if err = binary.Read(reader, binary.BigEndian, &cue.Offset); err != nil {
+			return data, errors.Wrap(err, "failed to decode quick cue")
+		}
+		if cue.Color, err = readColor(reader); err != nil {
+			return data, err
+		}
+		data.Cues = append(data.Cues, cue)
+	}
+
+	adjusted := uint8(0)
+	for _, it := range []interface{}{&data.MainCue, &adjusted, &data.DefaultMainCue} {
+		if err = binary.Read(reader, binary.BigEndian, it); err != nil {
+			return data, errors.Wrap(err, "failed to decode main cue")
+		}
+	}
+	data.IsMainAdjusted = adjusted != 0
+	return data, nil
+}
+
+func (q quickCues) encode() []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, int64(len(q.Cues)))
+	for _, it := range q.Cues {
+		writeLabel(buf, it.Label)
+		binary.Write(buf, binary.BigEndian, it.Offset)
+		writeColor(buf, it.Color)
+	}
+	adjusted := uint8(0)
+	if q.IsMainAdjusted {
+		adjusted = 1
+	}
+	binary.Write(buf, binary.BigEndian, q.MainCue)
+	binary.Write(buf, binary.BigEndian, adjusted)
+	binary.Write(buf, binary.BigEndian, q.DefaultMainCue)
+	return compress(buf.Bytes())
+}
+
+func decodeLoops(blob []byte) (loops []loop, err error) {
+	reader := bytes.NewReader(blob)
+
+	count := int64(0)
+	if err = binary.Read(reader, binary.LittleEndian, &count); err != nil {
+		return nil, errors.Wrap(err, "failed to decode loops count")
+	}
+	if count < 0 || count > math.MaxUint8 {
+		return nil, errors.Errorf("invalid loops count %d", count)
+	}
+
+	for i := int64(0); i < count; i++ {
+		it := loop{}
+		if it.Label, err = readLabel(reader); err != nil {
+			return nil, err
+		}
+		flags := [2]uint8{}
+		for _, field := range []interface{}{&it.Start, &it.End, &flags} {
+			if err = binary.Read(reader, binary.LittleEndian, field); err != nil {
+				return nil, errors.Wrap(err, "failed to decode loop")
+			}
+		}
+		it.IsStartSet = flags[0] != 0
+		it.IsEndSet = flags[1] != 0
+		if it.Color, err = readColor(reader); err != nil {
+			return nil, err
+		}
+		loops = append(loops, it)
+	}
+	return loops, nil
+}
+
+func encodeLoops(loops []loop) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, int64(len(loops)))
+	for _, it := range loops {
+		writeLabel(buf, it.Label)
+		binary.Write(buf, binary.LittleEndian, it.Start)
+		binary.Write(buf, binary.LittleEndian, it.End)
+		binary.Write(buf, binary.LittleEndian, [2]bool{it.IsStartSet, it.IsEndSet})
+		writeColor(buf, it.Color)
+	}
+	return buf.Bytes()
+}
+
+func readLabel(reader io.Reader) (string, error) {
+	size := uint8(0)
+	if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+		return "", errors.Wrap(err, "failed to decode label")
+	}
+	label := make([]byte, size)
+	if _, err := io.ReadFull(reader, label); err != nil {
+		return "", errors.Wrap(err, "failed to decode label")
+	}
+	return string(label), nil
+}
+
+func writeLabel(writer io.Writer, label string) {
+	if len(label) > math.MaxUint8 {
+		label = label[:math.MaxUint8]
+	}
+	binary.Write(writer, binary.BigEndian, uint8(len(label)))
+	writer.Write([]byte(label))
+}
+
+/*
+	Colors are stored as ARGB
+*/
+func readColor(reader io.Reader) (color.RGBA, error) {
+	argb := [4]uint8{}
+	if err := binary.Read(reader, binary.BigEndian, &argb); err != nil {
+		return color.RGBA{}, errors.Wrap(err, "failed to decode color")
+	}
+	return color.RGBA{A: argb[0], R: argb[1], G: argb[2], B: argb[3]}, nil
+}
+
+func writeColor(writer io.Writer, c color.RGBA) {
+	writer.Write([]byte{c.A, c.R, c.G, c.B})
+}
+
+func toDuration(offset float64, sampleRate float64) time.Duration {
+	return time.Duration(offset / sampleRate * float64(time.Second))
+}
+
+func toOffset(position time.Duration, sampleRate float64) float64 {
+	return position.Seconds() * sampleRate
+}
+
+func (t *Track) fetchPerformance() (entry performanceEntry, err error) {
+	query := `SELECT beatData, quickCues, loops FROM Track WHERE id = ?`
+	err = t.src.sql.Get(&entry, query, t.entry.Id)
+	return entry, errors.Wrapf(err, "failed to fetch performance data of track '%s'", t.String())
+}
+
+func (t *Track) PerformanceData() (*music.PerformanceData, error) {
+	entry, err := t.fetchPerformance()
+	if err != nil {
+		return nil, err
+	}
+
+	data := &music.PerformanceData{
+		BPM: t.BPM(),
+	}
+
+	if len(entry.BeatData) == 0 {
+		// track not analyzed by Engine DJ
+		return data, nil
+	}
+
+	beats, err := decodeBeatData(entry.BeatData)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid beat data for track '%s'", t.String())
+	}
+	if beats.SampleRate <= 0 {
+		return data, nil
+	}
+
+	markers := beats.Adjusted
+	if len(markers) == 0 {
+		markers = beats.Default
+	}
+	for idx, it := range markers {
+		marker := music.BeatMarker{
+			Position: toDuration(it.Offset, beats.SampleRate),
+			Beat:     int(it.Beat),
+		}
+		if idx+1 < len(markers) && it.BeatCount > 0 {
+			seconds := (markers[idx+1].Offset - it.Offset) / beats.SampleRate
+			marker.BPM = float64(it.BeatCount) * 60 / seconds
+		} else if idx > 0 {
+			marker.BPM = data.BeatGrid[idx-1].BPM
+		}
+		data.BeatGrid = append(data.BeatGrid, marker)
+	}
+
+	if len(entry.QuickCues) > 0 {
+		cues, err := decodeQuickCues(entry.QuickCues)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid quick cues for track '%s'", t.String())
+		}
+		for idx, it := range cues.Cues {
+			if it.Offset == unsetOffset {
+				continue
+			}
+			data.HotCues = append(data.HotCues, music.HotCue{
+				Index:    idx,
+				Name:     it.Label,
+				Position: toDuration(it.Offset, beats.SampleRate),
+				Color:    it.Color,
+			})
+		}
+	}
+
+	if len(entry.Loops) > 0 {
+		loops, err := decodeLoops(entry.Loops)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid loops for track '%s'", t.String())
+		}
+		for idx, it := range loops {
+			if !it.IsStartSet || !it.IsEndSet {
+				continue
+			}
+			data.Loops = append(data.Loops, music.Loop{
+				Index: idx,
+				Name:  it.Label,
+				Start: toDuration(it.Start, beats.SampleRate),
+				End:   toDuration(it.End, beats.SampleRate),
+				Color: it.Color,
+			})
+		}
+	}
+
+	return data, nil
+}
+
+/*
+	The track must have been analyzed by Engine DJ first since the sample rate is
+	needed to convert positions. Only the cue and loop slots set in data are
+	written, the other ones and the main cue are left untouched.
+*/
+func (t *Track) SetPerformanceData(data *music.PerformanceData) error {
+	return t.writePerformance(data, false)
+}
+
+/*
+	Same as SetPerformanceData, the cue and loop slots missing from data are
+	unset and the beat grid falls back to the one of the analysis when data has
+	none. The main cue is left untouched.
+*/
+func (t *Track) ReplacePerformanceData(data *music.PerformanceData) error {
+	return t.writePerformance(data, true)
+}
+
+func (t *Track) writePerformance(data *music.PerformanceData, replace bool) error {
+	entry, err := t.fetchPerformance()
+	if err != nil {
+		return err
+	}
+
+	if len(entry.BeatData) == 0 {
+		return errors.Errorf("track '%s' must be analyzed by Engine DJ before setting its performance data", t.String())
+	}
+
+	beats, err := decodeBeatData(entry.BeatData)
+	if err != nil {
+		return errors.WithMessagef(err, "invalid beat data for track '%s'", t.String())
+	}
+	if beats.SampleRate <= 0 {
+		return errors.Errorf("track '%s' has an invalid sample rate", t.String())
+	}
+
+	cues := quickCues{}
+	if len(entry.QuickCues) > 0 {
+		if cues, err = decodeQuickCues(entry.QuickCues); err != nil {
+			return errors.WithMessagef(err, "invalid quick cues for track '%s'", t.String())
+		}
+	}
+	for len(cues.Cues) < music.PerformanceSlots {
+		cues.Cues = append(cues.Cues, quickCue{Offset: unsetOffset})
+	}
+	if replace {
+		for idx := range cues.Cues {
+			cues.Cues[idx] = quickCue{Offset: unsetOffset}
+		}
+	}
+	for _, it := range data.HotCues {
+		if it.Index < 0 || it.Index >= music.PerformanceSlots {
+			continue
+		}
+		cues.Cues[it.Index] = quickCue{
+			Label:  it.Name,
+			Offset: toOffset(it.Position, beats.SampleRate),
+			Color:  it.Color,
+		}
+	}
+
+	loops := []loop{}
+	if len(entry.Loops) > 0 {
+		if loops, err = decodeLoops(entry.Loops); err != nil {
+			return errors.WithMessagef(err, "invalid loops for track '%s'", t.String())
+		}
+	}
+	for len(loops) < music.PerformanceSlots {
+		loops = append(loops, loop{Start: unsetOffset, End: unsetOffset})
+	}
+	if replace {
+		for idx := range loops {
+			loops[idx] = loop{Start: unsetOffset, End: unsetOffset}
+		}
+	}
+	for _, it := range data.Loops {
+		if it.Index < 0 || it.Index >= music.PerformanceSlots {
+			continue
+		}
+		loops[it.Index] = loop{
+			Label:      it.Name,
+			Start:      toOffset(it.Start, beats.SampleRate),
+			End:        toOffset(it.End, beats.SampleRate),
+			IsStartSet: true,
+			IsEndSet:   true,
+			Color:      it.Color,
+		}
+	}
+
+	if markers := encodeBeatGrid(data, beats.SampleRate, beats.SampleCount); len(markers) > 0 {
+		beats.Adjusted = markers
+		beats.IsSet = true
+	} else if replace {
+		beats.Adjusted = nil
+		beats.IsSet = false
+	}
+
+	return t.runQuery(func(sql *sqlx.DB, trackId int) error {
+		query := `UPDATE Track SET beatData = ?, quickCues = ?, loops = ? WHERE id = ?`
+		_, err := sql.Exec(query, beats.encode(), cues.encode(), encodeLoops(loops), trackId)
+		return errors.Wrapf(err, "failed to set performance data of track '%s'", t.String())
+	})
+}
+
+/*
+	Engine DJ need a marker at each tempo change and one past the end of the track,
+	a single anchor with the track tempo is extended to cover the whole track.
+	Markers whose beat isn't numbered after the previous one are numbered from
+	their position and the tempo of the previous one.
+*/
+func encodeBeatGrid(data *music.PerformanceData, sampleRate float64, sampleCount float64) []beatMarker {
+	grid := append([]music.BeatMarker{}, data.BeatGrid...)
+	if len(grid) == 0 {
+		return nil
+	}
+	for idx := range grid {
+		if grid[idx].BPM <= 0 {
+			grid[idx].BPM = data.BPM
+		}
+		if idx == 0 {
+			continue
+		}
+		previous := grid[idx-1]
+		if grid[idx].Beat <= previous.Beat {
+			beats := int(math.Round((grid[idx].Position - previous.Position).Seconds() * previous.BPM / 60))
+			if beats < 1 {
+				beats = 1
+			}
+			grid[idx].Beat = previous.Beat + beats
+		}
+	}
+
+	if len(grid) == 1 {
+		anchor := grid[0]
+		if anchor.BPM <= 0 {
+			return nil
+		}
+		length := time.Duration(sampleCount / sampleRate * float64(time.Second))
+		beats := int(math.Ceil((length - anchor.Position).Seconds()*anchor.BPM/60)) + 1
+		grid = append(grid, music.BeatMarker{
+			Position: anchor.Position + time.Duration(float64(beats)*60/anchor.BPM*float64(time.Second)),
+			Beat:     anchor.Beat + beats,
+			BPM:      anchor.BPM,
+		})
+	}
+
+	markers := []beatMarker{}
+	for idx, it := range grid {
+		marker := beatMarker{
+			Offset: toOffset(it.Position, sampleRate),
+			Beat:   int64(it.Beat),
+		}
+		if idx+1 < len(grid) {
+			marker.BeatCount = int32(grid[idx+1].Beat - it.Beat)
+		}
+		markers = append(markers, marker)
+	}
+	return markers
+}
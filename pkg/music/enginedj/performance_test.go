@@ -0,0 +1,232 @@
+package enginedj
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"primetools/pkg/music"
+)
+
+func TestBeatDataRoundTrip(t *testing.T) {
+	data := beatData{
+		SampleRate:  44100,
+		SampleCount: 44100 * 60,
+		IsSet:       true,
+		Default:     []beatMarker{{Offset: -100, Beat: -4, BeatCount: 128, Unknown: 0}, {Offset: 2645900, Beat: 124, BeatCount: 0}},
+		Adjusted:    []beatMarker{{Offset: 1000, Beat: 0, BeatCount: 64}, {Offset: 1324000, Beat: 64, BeatCount: 0}},
+	}
+
+	blob := data.encode()
+	if size := binary.BigEndian.Uint32(blob[:4]); size != 8+8+1+2*(8+2*24) {
+		t.Errorf("uncompressed size is %d", size)
+	}
+
+	decoded, err := decodeBeatData(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, data) {
+		t.Errorf("decoded %+v instead of %+v", decoded, data)
+	}
+}
+
+func TestQuickCuesRoundTrip(t *testing.T) {
+	data := quickCues{
+		Cues: []quickCue{
+			{Label: "Intro", Offset: 0, Color: color.RGBA{R: 0xff, A: 0xff}},
+			{Offset: unsetOffset},
+			{Label: "Drop", Offset: 1234567.5, Color: color.RGBA{G: 0x80, B: 0x40, A: 0xff}},
+		},
+		MainCue:        4410,
+		IsMainAdjusted: true,
+		DefaultMainCue: 0,
+	}
+
+	decoded, err := decodeQuickCues(data.encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, data) {
+		t.Errorf("decoded %+v instead of %+v", decoded, data)
+	}
+
+	if _, err = decodeQuickCues(compress([]byte{0xff, 0, 0, 0, 0, 0, 0, 1})); err == nil {
+		t.Error("negative count isn't rejected")
+	}
+}
+
+func TestLoopsRoundTrip(t *testing.T) {
+	loops := []loop{
+		{Label: "Break", Start: 44100, End: 88200, IsStartSet: true, IsEndSet: true, Color: color.RGBA{R: 1, G: 2, B: 3, A: 4}},
+		{Start: unsetOffset, End: unsetOffset},
+	}
+
+	blob := encodeLoops(loops)
+
+	// loops aren't compressed and are little endian, colors are ARGB
+	expected := &bytes.Buffer{}
+	binary.Write(expected, binary.LittleEndian, int64(2))
+	expected.Write(append([]byte{5}, "Break"...))
+	binary.Write(expected, binary.LittleEndian, [2]float64{44100, 88200})
+	expected.Write([]byte{1, 1, 4, 1, 2, 3})
+	expected.WriteByte(0)
+	binary.Write(expected, binary.LittleEndian, [2]float64{unsetOffset, unsetOffset})
+	expected.Write([]byte{0, 0, 0, 0, 0, 0})
+	if !bytes.Equal(blob, expected.Bytes()) {
+		t.Errorf("encoded %x instead of %x", blob, expected.Bytes())
+	}
+
+	decoded, err := decodeLoops(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, loops) {
+		t.Errorf("decoded %+v instead of %+v", decoded, loops)
+	}
+}
+
+func TestUncompressSizeMismatch(t *testing.T) {
+	blob := compress([]byte("content"))
+	binary.BigEndian.PutUint32(blob, 8)
+	if _, err := uncompress(blob); err == nil {
+		t.Error("size mismatch isn't detected")
+	}
+}
+
+func TestEncodeBeatGrid(t *testing.T) {
+	const rate = 44100.0
+	second := func(s float64) time.Duration {
+		return time.Duration(s * float64(time.Second))
+	}
+
+	t.Run("single anchor", func(t *testing.T) {
+		data := &music.PerformanceData{
+			BPM:      120,
+			BeatGrid: []music.BeatMarker{{Position: second(0.5)}},
+		}
+		markers := encodeBeatGrid(data, rate, 10*rate)
+		if len(markers) != 2 {
+			t.Fatalf("%d markers instead of 2", len(markers))
+		}
+		// 19 beats until the end of the track and one more past it
+		if markers[0].BeatCount != 20 || markers[1].Beat != 20 {
+			t.Errorf("markers are %+v", markers)
+		}
+		if markers[1].Offset < 10*rate {
+			t.Errorf("last marker at %v is before the end of the track", markers[1].Offset)
+		}
+	})
+
+	t.Run("unnumbered markers", func(t *testing.T) {
+		// ie: Traktor grids where every marker is at beat 0
+		data := &music.PerformanceData{
+			BPM: 120,
+			BeatGrid: []music.BeatMarker{
+				{Position: 0, BPM: 120},
+				{Position: second(16), BPM: 128},
+				{Position: second(16 + 30), BPM: 128},
+			},
+		}
+		markers := encodeBeatGrid(data, rate, 60*rate)
+		expected := []beatMarker{
+			{Offset: 0, Beat: 0, BeatCount: 32},
+			{Offset: 16 * rate, Beat: 32, BeatCount: 64},
+			{Offset: 46 * rate, Beat: 96, BeatCount: 0},
+		}
+		if !reflect.DeepEqual(markers, expected) {
+			t.Errorf("markers are %+v instead of %+v", markers, expected)
+		}
+	})
+
+	t.Run("numbered markers", func(t *testing.T) {
+		data := &music.PerformanceData{
+			BeatGrid: []music.BeatMarker{
+				{Position: 0, Beat: -1, BPM: 100},
+				{Position: second(60), Beat: 99, BPM: 100},
+			},
+		}
+		markers := encodeBeatGrid(data, rate, 60*rate)
+		if markers[0].BeatCount != 100 || markers[1].Beat != 99 {
+			t.Errorf("markers are %+v", markers)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if markers := encodeBeatGrid(&music.PerformanceData{BPM: 120}, rate, rate); markers != nil {
+			t.Errorf("markers are %+v", markers)
+		}
+	})
+
+	t.Run("tempo read back", func(t *testing.T) {
+		data := &music.PerformanceData{
+			BPM:      127.5,
+			BeatGrid: []music.BeatMarker{{Position: second(0.1)}},
+		}
+		markers := encodeBeatGrid(data, rate, 300*rate)
+		seconds := (markers[1].Offset - markers[0].Offset) / rate
+		if bpm := float64(markers[0].BeatCount) * 60 / seconds; math.Abs(bpm-127.5) > 0.001 {
+			t.Errorf("tempo is %v instead of 127.5", bpm)
+		}
+	})
+}
+
+func TestSetPerformanceDataSlots(t *testing.T) {
+	lib := testLibrary(t, "main")
+	track := addTestTrack(t, lib.main, "../../Music/track.mp3")
+
+	// analyzed by Engine DJ
+	beats := beatData{SampleRate: 44100, SampleCount: 44100 * 60}
+	if _, err := lib.main.sql.Exec(`UPDATE Track SET beatData = ? WHERE id = ?`, beats.encode(), track.entry.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	slots := func() (cues []int, loops []int) {
+		data, err := track.PerformanceData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, it := range data.HotCues {
+			cues = append(cues, it.Index)
+		}
+		for _, it := range data.Loops {
+			loops = append(loops, it.Index)
+		}
+		return
+	}
+
+	err := track.SetPerformanceData(&music.PerformanceData{
+		HotCues: []music.HotCue{{Index: 0, Position: time.Second}, {Index: 3, Position: 2 * time.Second}},
+		Loops:   []music.Loop{{Index: 1, Start: time.Second, End: 2 * time.Second}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the other slots are kept
+	if err = track.SetPerformanceData(&music.PerformanceData{HotCues: []music.HotCue{{Index: 5, Position: 3 * time.Second}}}); err != nil {
+		t.Fatal(err)
+	}
+	if cues, loops := slots(); !reflect.DeepEqual(cues, []int{0, 3, 5}) || !reflect.DeepEqual(loops, []int{1}) {
+		t.Errorf("cues are %v and loops %v after a merge", cues, loops)
+	}
+
+	// the slots missing from the data are unset
+	if err = track.ReplacePerformanceData(&music.PerformanceData{HotCues: []music.HotCue{{Index: 3, Position: 2 * time.Second}}}); err != nil {
+		t.Fatal(err)
+	}
+	if cues, loops := slots(); !reflect.DeepEqual(cues, []int{3}) || len(loops) != 0 {
+		t.Errorf("cues are %v and loops %v after a replace", cues, loops)
+	}
+
+	if err = track.ReplacePerformanceData(&music.PerformanceData{}); err != nil {
+		t.Fatal(err)
+	}
+	if cues, loops := slots(); len(cues) != 0 || len(loops) != 0 {
+		t.Errorf("cues are %v and loops %v after an empty replace", cues, loops)
+	}
+}
@@ -1,7 +1,10 @@
 package enginedj
 
 import (
+	"database/sql"
 	"encoding/json"
+	"fmt"
+	"math"
 	"path/filepath"
 	"sync"
 	"time"
@@ -11,7 +14,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
-	"primetools/pkg/files"
 	"primetools/pkg/music"
 )
 
@@ -44,49 +46,183 @@ func (t *Track) Added() time.Time {
 }
 
 func (t *Track) Modified() time.Time {
-	return t.entry.Added.Time
+	return t.entry.Created.Time
 }
 
 func (t *Track) SetAdded(added time.Time) error {
-	t.entry.Added.Time = added
-	query := ` UPDATE Track SET dateCreated = ? WHERE id = ?`
-	_, err := t.src.sql.Exec(query, t.entry.Added.Time, t.entry.Id)
-	return errors.Wrapf(err, "failed to set added date %v to track '%s'", added, t.String())
+	err := t.writeColumn("dateAdded", added.UTC().Format(DateTimeFormat))
+	if err == nil {
+		t.entry.Added = sql.NullTime{Time: added, Valid: true}
+	}
+	return err
 }
 
-func (t *Track) SetModified(added time.Time) error {
-	return errors.New("not implemented")
+func (t *Track) SetModified(modified time.Time) error {
+	err := t.writeColumn("dateCreated", modified.UTC().Format(DateTimeFormat))
+	if err == nil {
+		t.entry.Created = sql.NullTime{Time: modified, Valid: true}
+	}
+	return err
 }
 
+/*
+	Engine DJ only knows if a track was played, not how many times
+*/
 func (t *Track) PlayCount() int {
+	if t.entry.IsPlayed.Bool {
+		return 1
+	}
 	return 0
 }
 
+func (t *Track) PlayedOnly() {}
+
+/*
+	A played track gets the played indicator of its database and is played now
+	unless it already has a last played time, the others are reset. Nothing is
+	written when the track is already in that state.
+*/
 func (t *Track) SetPlayCount(count int) error {
-	msg := "PlayCount is not implemented in EngineDJ library"
-	logrus.Warnf(msg)
-	return errors.New(msg)
+	if t.entry.IsPlayed.Valid && t.entry.IsPlayed.Bool == (count > 0) {
+		return nil
+	}
+
+	played := sql.NullBool{Bool: count > 0, Valid: true}
+	indicator := sql.NullInt64{}
+	last := sql.NullTime{}
+	if played.Bool {
+		indicator = sql.NullInt64{Int64: t.src.played, Valid: true}
+		last = t.entry.LastPlayed
+		if !last.Valid {
+			last = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+
+	var lastPlayed interface{}
+	if last.Valid {
+		lastPlayed = last.Time.UTC().Format(DateTimeFormat)
+	}
+
+	err := t.runQuery(func(sql *sqlx.DB, trackId int) error {
+		query := `UPDATE Track SET isPlayed = ?, playedIndicator = ?, timeLastPlayed = ? WHERE id = ?`
+		_, err := sql.Exec(query, played, indicator, lastPlayed, trackId)
+		return errors.Wrapf(err, "failed to set play count %d to track '%s'", count, t.String())
+	})
+	if err == nil {
+		t.entry.IsPlayed = played
+		t.entry.PlayedIndicator = indicator
+		t.entry.LastPlayed = last
+	}
+	return err
 }
 
-func (t *Track) FilePath() string {
-	if filepath.IsAbs(t.entry.Path.String) {
-		return t.entry.Path.String
-	} else {
-		return files.NormalizePath(t.src.origin + "/" + t.entry.Path.String)
+func (t *Track) BPM() float64 {
+	if t.entry.BPMAnalyzed.Valid && t.entry.BPMAnalyzed.Float64 > 0 {
+		return t.entry.BPMAnalyzed.Float64
 	}
+	return float64(t.entry.BPM.Int32)
 }
 
-func (t *Track) SetPath(newpath string) error {
-	rpath, err := filepath.Rel(t.src.origin, newpath)
-	if err != nil {
-		rpath = newpath
+func (t *Track) SetBPM(bpm float64) error {
+	err := t.runQuery(func(sql *sqlx.DB, trackId int) error {
+		query := `UPDATE Track SET bpm = ?, bpmAnalyzed = ? WHERE id = ?`
+		_, err := sql.Exec(query, int(math.Round(bpm)), bpm, trackId)
+		return errors.Wrapf(err, "failed to set bpm %v to track '%s'", bpm, t.String())
+	})
+	if err == nil {
+		t.entry.BPM = sql.NullInt32{Int32: int32(math.Round(bpm)), Valid: true}
+		t.entry.BPMAnalyzed = sql.NullFloat64{Float64: bpm, Valid: true}
+	}
+	return err
+}
+
+func (t *Track) Key() music.Key {
+	if !t.entry.Key.Valid {
+		return music.KeyUnknown
 	}
+	return music.KeyFromEngine(int(t.entry.Key.Int32))
+}
 
-	return t.runQuery(func(sql *sqlx.DB, trackId int) error {
-		return writeFilepath(sql, trackId, rpath)
+func (t *Track) SetKey(key music.Key) error {
+	if !key.Valid() {
+		return errors.Errorf("cannot set invalid key to track '%s'", t.String())
+	}
+	err := t.writeColumn("key", key.Engine())
+	if err == nil {
+		t.entry.Key = sql.NullInt32{Int32: int32(key.Engine()), Valid: true}
+	}
+	return err
+}
+
+func (t *Track) ClearKey() error {
+	err := t.writeColumn("key", nil)
+	if err == nil {
+		t.entry.Key = sql.NullInt32{}
+	}
+	return err
+}
+
+func (t *Track) Genre() string {
+	return t.entry.Genre.String
+}
+
+func (t *Track) SetGenre(genre string) error {
+	err := t.writeColumn("genre", genre)
+	if err == nil {
+		t.entry.Genre = sql.NullString{String: genre, Valid: true}
+	}
+	return err
+}
+
+func (t *Track) Comment() string {
+	return t.entry.Comment.String
+}
+
+func (t *Track) SetComment(comment string) error {
+	err := t.writeColumn("comment", comment)
+	if err == nil {
+		t.entry.Comment = sql.NullString{String: comment, Valid: true}
+	}
+	return err
+}
+
+func (t *Track) Duration() time.Duration {
+	return time.Duration(t.entry.Length.Int32) * time.Second
+}
+
+func (t *Track) FilePath() string {
+	return t.src.absPath(t.entry.Path.String)
+}
+
+/*
+	The path is relative to each database of the track, the track reports the
+	new path once written
+*/
+func (t *Track) SetPath(newpath string) error {
+	oldpath := t.FilePath()
+	return t.databases(func(db *EngineDJDB, trackId int) error {
+		rpath := db.relPath(newpath)
+		if err := writeFilepath(db.sql, trackId, rpath); err != nil {
+			logrus.Errorf("%v", err)
+			return err
+		}
+		t.moved(db, trackId, oldpath, rpath)
+		return nil
 	})
 }
 
+/*
+	Update the entries in memory once the new path is written in db, its ids
+	map is still keyed by the previous path of the track
+*/
+func (t *Track) moved(db *EngineDJDB, trackId int, oldpath string, rpath string) {
+	db.moveEntry(trackId, oldpath, rpath)
+	if db == t.src {
+		t.entry.Path = sql.NullString{String: rpath, Valid: true}
+		t.entry.Filename = sql.NullString{String: filepath.Base(rpath), Valid: true}
+	}
+}
+
 func (t *Track) Title() string {
 	return t.entry.Title.String
 }
@@ -143,11 +279,42 @@ func (t *Track) runQuery(fct func(sql *sqlx.DB, trackId int) error) error {
 	return nil
 }
 
+/*
+	Update a single column of the Track table, column name must never come from user input
+*/
+func (t *Track) writeColumn(column string, value interface{}) error {
+	return t.runQuery(func(sql *sqlx.DB, trackId int) error {
+		query := fmt.Sprintf(`UPDATE Track SET %s = ? WHERE id = ?`, column)
+		_, err := sql.Exec(query, value, trackId)
+		return errors.Wrapf(err, "failed to set %s of track '%s'", column, t.String())
+	})
+}
+
+/*
+	Same as runQuery, with the database of each copy of the track
+*/
+func (t *Track) databases(fct func(db *EngineDJDB, trackId int) error) error {
+	if err := fct(t.src, t.entry.Id); err != nil {
+		return err
+	}
+	if t.isExternal() {
+		if db, ok := t.src.lib.dbs[t.entry.OriginDatabaseUuid.String]; ok {
+			return fct(db, int(t.entry.OriginTrackId.Int32))
+		}
+	}
+	return nil
+}
+
+/*
+	Tracks imported from another database keep its uuid as origin, the tracks
+	of the database are their own origin
+*/
 func (t *Track) isExternal() bool {
-	return t.entry.OriginDatabaseUuid.String == t.src.UUID
+	uuid := t.entry.OriginDatabaseUuid.String
+	return uuid != "" && uuid != t.src.UUID
 }
 
-func writeFilepath(sql *sqlx.DB, trackId int, newpath string) error {
+func writeFilepath(sql sqlx.Execer, trackId int, newpath string) error {
 	query := `UPDATE Track SET path = ?, filename = ? WHERE id = ?`
 	fname := filepath.Base(newpath)
 
@@ -2,9 +2,11 @@ package enginedj
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -56,42 +58,11 @@ func (t TrackList) String() string {
 	return "[" + strings.Join(names, ",") + "]"
 }
 
-func (t *TrackList) setParent(parent *TrackList) {
-	logrus.Infof("updating parent for playlist '%s'", t.Path())
-	var err error
-
-	// delete previous entries
-	_, err = t.src.sql.Exec(`DELETE FROM PlaylistAllParent WHERE childListId = ?`, t.entry.Id)
-	if err != nil {
-		logrus.Errorf("failed to update playlist '%s': %v", t.Path(), err)
-		return
-	}
-	_, err = t.src.sql.Exec(`DELETE FROM ListHierarchy WHERE childIdList = ?`, t.entry.Id)
-	if err != nil {
-		logrus.Errorf("failed to update playlist '%s': %v", t.Path(), err)
-		return
-	}
-
-	if parent == nil {
-		parent = t
-	}
-
-	_, err = t.src.sql.Exec(`INSERT INTO ListParentList (listOriginId, listParentId) VALUES (?,?)`,
-		t.entry.Id, parent.entry.Id)
-	if err != nil {
-		logrus.Errorf("failed to update playlist '%s': %v", t.Path(), err)
-		return
-	}
-
-	if t.entry.Id != parent.entry.Id {
-		_, err = t.src.sql.Exec(`INSERT INTO ListHierarchy (listId, listIdChild, listTypeChild) VALUES (?,?)`,
-			parent.entry.Id, t.entry.Id)
-		if err != nil {
-			logrus.Errorf("failed to update playlist '%s': %v", t.Path(), err)
-		}
-	}
-}
-
+/*
+	Entities of a playlist are a linked list through nextEntityId, 0 being the last one.
+	A track can only be once in a playlist. The tracks of another database are
+	looked up by path in the database of the playlist.
+*/
 func (t *TrackList) SetTracks(tracks music.Tracks) error {
 	logrus.Infof("updating tracklist for playlist '%s' in db '%s' with %d entries", t.Path(), t.src.origin, len(tracks))
 
@@ -100,26 +71,64 @@ func (t *TrackList) SetTracks(tracks music.Tracks) error {
 		return errors.Wrapf(err, "failed start db transaction for playlist '%s'", t.Path())
 	}
 
-	query := `DELETE FROM ListTrackList WHERE listId = ?`
+	query := `DELETE FROM PlaylistEntity WHERE listId = ?`
 	_, err = tx.Exec(query, t.entry.Id)
 	if err != nil {
 		tx.Rollback()
 		return errors.Wrapf(err, "failed deleting previous track from playlist '%s'", t.Path())
 	}
 
-	for idx, track := range tracks {
+	previous := int64(0)
+	added := map[int]bool{}
+	for _, track := range tracks {
 		tr, ok := track.(*Track)
 		if !ok {
 			panic("feed tracks from the same library")
 		}
 
-		query = `INSERT INTO ListTrackList (listId, trackId, trackIdInOriginDatabase, databaseUuid, trackNumber) VALUES (?, ?, ?, ?, ?, ?)`
-		_, err = tx.Exec(query, t.entry.Id, tr.entry.Id, tr.entry.OriginTrackId, tr.entry.OriginDatabaseUuid, idx+1)
+		// entities refer to the entry of the track in the database of the list
+		if tr.src != t.src {
+			local, ok := t.src.Track(tr.FilePath()).(*Track)
+			if !ok {
+				tx.Rollback()
+				return errors.Errorf("track '%s' is not in the database of playlist '%s'", tr, t.Path())
+			}
+			tr = local
+		}
+
+		if added[tr.entry.Id] {
+			logrus.Warnf("track '%s' is already in playlist '%s'", tr, t.Path())
+			continue
+		}
+		added[tr.entry.Id] = true
+
+		query = `INSERT INTO PlaylistEntity (listId, trackId, databaseUuid, nextEntityId, membershipReference) VALUES (?, ?, ?, 0, 0)`
+		res, err := tx.Exec(query, t.entry.Id, tr.entry.Id, t.src.UUID)
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "failed to add track to playlist '%s'", t.Path())
+		}
 
+		id, err := res.LastInsertId()
 		if err != nil {
 			tx.Rollback()
 			return errors.Wrapf(err, "failed to add track to playlist '%s'", t.Path())
 		}
+
+		if previous != 0 {
+			_, err = tx.Exec(`UPDATE PlaylistEntity SET nextEntityId = ? WHERE id = ?`, id, previous)
+			if err != nil {
+				tx.Rollback()
+				return errors.Wrapf(err, "failed to link track in playlist '%s'", t.Path())
+			}
+		}
+		previous = id
+	}
+
+	_, err = tx.Exec(`UPDATE Playlist SET lastEditTime = ? WHERE id = ?`, time.Now().UTC().Format(DateTimeFormat), t.entry.Id)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to update playlist '%s'", t.Path())
 	}
 
 	err = tx.Commit()
@@ -136,19 +145,47 @@ func (t *TrackList) Count() int {
 	return count
 }
 
+type listTrackEntry struct {
+	EntityId     int           `db:"entityId"`
+	NextEntityId sql.NullInt32 `db:"nextEntityId"`
+	trackEntry
+}
+
+/*
+	Tracks are ordered by following the nextEntityId chain from the entity
+	no other entity points to
+*/
 func (t *TrackList) Tracks() music.Tracks {
-	tracks := []trackEntry{}
-	query := `select * from PlaylistEntity JOIN Track ON Track.id = PlaylistEntity.trackId WHERE PlaylistEntity.listId = ? ORDER BY playOrder`
+	entries := []listTrackEntry{}
+	query := `SELECT PlaylistEntity.id AS entityId, PlaylistEntity.nextEntityId, Track.* FROM PlaylistEntity JOIN Track ON Track.id = PlaylistEntity.trackId WHERE PlaylistEntity.listId = ?`
 
-	err := t.src.sql.Unsafe().Select(&tracks, query, t.entry.Id)
+	err := t.src.sql.Unsafe().Select(&entries, query, t.entry.Id)
 	if err != nil {
 		logrus.Errorf("fail to fetch track list for playlist '%s': %v", t.Name(), err)
 		return nil
 	}
 
+	byId := map[int]listTrackEntry{}
+	pointed := map[int]bool{}
+	for _, it := range entries {
+		byId[it.EntityId] = it
+		pointed[int(it.NextEntityId.Int32)] = true
+	}
+
 	out := []music.Track{}
-	for _, it := range tracks {
-		out = append(out, newTrack(t.src, it))
+	visited := map[int]bool{}
+	for _, it := range entries {
+		if pointed[it.EntityId] {
+			continue
+		}
+		for entry, ok := it, true; ok && !visited[entry.EntityId]; entry, ok = byId[int(entry.NextEntityId.Int32)] {
+			visited[entry.EntityId] = true
+			out = append(out, newTrack(t.src, entry.trackEntry))
+		}
+	}
+
+	if len(visited) != len(entries) {
+		logrus.Warnf("playlist '%s' has a broken entity chain, %d tracks are unreachable", t.Path(), len(entries)-len(visited))
 	}
 	return out
 }
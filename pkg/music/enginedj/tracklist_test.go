@@ -0,0 +1,47 @@
+package enginedj
+
+import (
+	"path/filepath"
+	"testing"
+
+	"primetools/pkg/music"
+)
+
+func TestSetTracksOfOtherDatabase(t *testing.T) {
+	lib := testLibrary(t, "main", "usb")
+	usb := lib.dbs["usb"]
+
+	local := addTestTrack(t, lib.main, "../../Music/a.mp3")
+	original := addTestTrack(t, usb, "../../Music/b.mp3")
+	rpath, err := filepath.Rel(lib.main.origin, original.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := addTestTrack(t, lib.main, rpath, "usb", original.entry.Id)
+
+	list, err := lib.CreatePlaylist("Warmup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the track of the usb database is added through its copy in the main one
+	if err = list.SetTracks(music.Tracks{local, original}); err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	if err = lib.main.sql.Select(&ids, `SELECT trackId FROM PlaylistEntity ORDER BY id`); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != local.entry.Id || ids[1] != copied.entry.Id {
+		t.Errorf("entities refer to the tracks %v instead of [%d %d]", ids, local.entry.Id, copied.entry.Id)
+	}
+
+	// a track without copy in the main database can't be added
+	external := addTestTrack(t, usb, "../../Music/c.mp3")
+	if err = list.SetTracks(music.Tracks{local, external}); err == nil {
+		t.Error("track of another database is added")
+	}
+	if tracks := list.Tracks(); len(tracks) != 2 {
+		t.Errorf("playlist has %d tracks after a failed update", len(tracks))
+	}
+}
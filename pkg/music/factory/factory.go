@@ -10,8 +10,11 @@ import (
 	"primetools/pkg/music/enginedj"
 	"primetools/pkg/music/files"
 	"primetools/pkg/music/itunes"
+	"primetools/pkg/music/mixxx"
+	"primetools/pkg/music/playlistfile"
 	"primetools/pkg/music/prime"
 	"primetools/pkg/music/rekordbox"
+	"primetools/pkg/music/serato"
 )
 
 /*
@@ -31,6 +34,12 @@ func Open(libtype enums.LibraryType, path string) (music.Library, error) {
 		return enginedj.Open(path)
 	case enums.Traktor:
 		return traktor.Open(path)
+	case enums.Serato:
+		return serato.Open(path)
+	case enums.Mixxx:
+		return mixxx.Open(path)
+	case enums.PlaylistFile:
+		return playlistfile.Open(path)
 	default:
 		return nil, errors.Errorf("invalid library type: %v", libtype)
 	}
@@ -40,6 +49,8 @@ func Create(libtype enums.LibraryType, path string) (music.Library, error) {
 	switch libtype {
 	case enums.Traktor:
 		return traktor.Create(path)
+	case enums.Rekordbox:
+		return rekordbox.Create(path)
 	default:
 		return nil, errors.Errorf("cannot create library type: %v", libtype)
 	}
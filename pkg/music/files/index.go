@@ -0,0 +1,227 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+// sqlite database where the tags of the music files are kept between runs
+var IndexFile = files.ExpandHomePath("~/.primetools/fileindex.db")
+
+// changed entries written at once, so an interrupted scan isn't lost
+const indexBatch = 1000
+
+/*
+	CREATE TABLE IF NOT EXISTS Track (
+		path TEXT PRIMARY KEY,
+		size INTEGER NOT NULL,
+		modified INTEGER NOT NULL,
+		title TEXT,
+		album TEXT,
+		artist TEXT,
+		rating INTEGER,
+		year INTEGER,
+		bpm REAL,
+		key INTEGER,
+		genre TEXT,
+		comment TEXT,
+		duration INTEGER,
+		hash TEXT
+	)
+*/
+type indexEntry struct {
+	Path     string  `db:"path"`
+	Size     int64   `db:"size"`
+	Modified int64   `db:"modified"`
+	Title    string  `db:"title"`
+	Album    string  `db:"album"`
+	Artist   string  `db:"artist"`
+	Rating   int     `db:"rating"`
+	Year     int     `db:"year"`
+	Bpm      float64 `db:"bpm"`
+	Key      int     `db:"key"`
+	Genre    string  `db:"genre"`
+	Comment  string  `db:"comment"`
+	Duration int64   `db:"duration"`
+	Hash     string  `db:"hash"`
+}
+
+const indexSchema = `
+	CREATE TABLE IF NOT EXISTS Track (
+		path TEXT PRIMARY KEY,
+		size INTEGER NOT NULL,
+		modified INTEGER NOT NULL,
+		title TEXT,
+		album TEXT,
+		artist TEXT,
+		rating INTEGER,
+		year INTEGER,
+		bpm REAL,
+		key INTEGER,
+		genre TEXT,
+		comment TEXT,
+		duration INTEGER,
+		hash TEXT
+	)`
+
+const indexInsert = `
+	INSERT OR REPLACE INTO Track (path, size, modified, title, album, artist, rating, year, bpm, key, genre, comment, duration, hash)
+	VALUES (:path, :size, :modified, :title, :album, :artist, :rating, :year, :bpm, :key, :genre, :comment, :duration, :hash)`
+
+/*
+	Parsed tags of every music file seen, an entry is valid as long as the
+	size and modification time of its file don't change. It is loaded once and
+	shared by all the file libraries.
+*/
+var index = struct {
+	sync.Mutex
+	loaded  bool
+	sql     *sqlx.DB
+	entries map[string]*indexEntry
+	dirty   map[string]*indexEntry
+}{}
+
+/*
+	Metadata of the file from the index, false if the file changed since it was
+	indexed
+*/
+func lookupIndex(path string, stat os.FileInfo) (meta metadata, hash string, ok bool) {
+	index.Lock()
+	defer index.Unlock()
+	loadIndex()
+
+	entry := index.entries[files.NormalizePath(path)]
+	if entry == nil || entry.Size != stat.Size() || entry.Modified != stat.ModTime().UnixNano() {
+		return
+	}
+
+	meta = metadata{
+		title:    entry.Title,
+		album:    entry.Album,
+		artist:   entry.Artist,
+		rating:   music.Rating(entry.Rating),
+		year:     entry.Year,
+		bpm:      entry.Bpm,
+		key:      music.Key(entry.Key),
+		genre:    entry.Genre,
+		comment:  entry.Comment,
+		duration: time.Duration(entry.Duration),
+	}
+	return meta, entry.Hash, true
+}
+
+/*
+	Index the metadata of the file, it is written with the next batch
+*/
+func storeIndex(path string, stat os.FileInfo, meta *metadata) string {
+	entry := &indexEntry{
+		Path:     files.NormalizePath(path),
+		Size:     stat.Size(),
+		Modified: stat.ModTime().UnixNano(),
+		Title:    meta.title,
+		Album:    meta.album,
+		Artist:   meta.artist,
+		Rating:   int(meta.rating),
+		Year:     meta.year,
+		Bpm:      meta.bpm,
+		Key:      int(meta.key),
+		Genre:    meta.genre,
+		Comment:  meta.comment,
+		Duration: int64(meta.duration),
+		Hash:     music.MetaHash(meta.title, meta.album, meta.artist, meta.year),
+	}
+
+	index.Lock()
+	defer index.Unlock()
+	loadIndex()
+
+	index.entries[entry.Path] = entry
+	if index.sql != nil {
+		index.dirty[entry.Path] = entry
+		if len(index.dirty) >= indexBatch {
+			if err := flushIndex(); err != nil {
+				logrus.Warnf("%v", err)
+			}
+		}
+	}
+	return entry.Hash
+}
+
+/*
+	Write the entries indexed since the last save to the index file
+*/
+func SaveIndex() error {
+	index.Lock()
+	defer index.Unlock()
+	return flushIndex()
+}
+
+func flushIndex() error {
+	if len(index.dirty) == 0 {
+		return nil
+	}
+
+	tx, err := index.sql.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "fail to start file index transaction")
+	}
+	for _, it := range index.dirty {
+		if _, err = tx.NamedExec(indexInsert, it); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "fail to index file '%s'", it.Path)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "fail to write file index")
+	}
+
+	index.dirty = map[string]*indexEntry{}
+	return nil
+}
+
+func loadIndex() {
+	if index.loaded {
+		return
+	}
+	index.loaded = true
+	index.entries = map[string]*indexEntry{}
+	index.dirty = map[string]*indexEntry{}
+
+	start := time.Now()
+	err := os.MkdirAll(filepath.Dir(IndexFile), 0755)
+	if err == nil {
+		index.sql, err = sqlx.Open("sqlite3", IndexFile)
+	}
+	if err == nil {
+		_, err = index.sql.Exec(indexSchema)
+	}
+
+	entries := []*indexEntry{}
+	if err == nil {
+		err = index.sql.Select(&entries, "SELECT * FROM Track")
+	}
+	if err != nil {
+		// without the index, the tags are read from the files every run
+		logrus.Warnf("ignoring file index '%s': %v", IndexFile, err)
+		if index.sql != nil {
+			index.sql.Close()
+			index.sql = nil
+		}
+		return
+	}
+
+	for _, it := range entries {
+		index.entries[it.Path] = it
+	}
+	logrus.Debugf("loaded %d indexed files in %v", len(entries), time.Since(start))
+}
@@ -5,26 +5,35 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/karrick/godirwalk"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"primetools/pkg/files"
+	"primetools/pkg/fingerprint"
 	"primetools/pkg/music"
 )
 
 type FileLibrary struct {
-	basePath  string
-	cache     map[string]*Track
-	hashCache map[string]*Track
+	basePath     string
+	cache        map[string]*Track
+	hashCache    map[string]*Track
+	listings     map[string][]*Track
+	fingerprints bool
+	printIndex   map[string]*fingerprint.Index
+	mutex        sync.Mutex
 }
 
 func Open(path string) *FileLibrary {
 	f := &FileLibrary{
-		basePath:  path,
-		cache:     map[string]*Track{},
-		hashCache: map[string]*Track{},
+		basePath:   path,
+		cache:      map[string]*Track{},
+		hashCache:  map[string]*Track{},
+		listings:   map[string][]*Track{},
+		printIndex: map[string]*fingerprint.Index{},
 	}
 
 	logrus.Infof("file library created from folder '%s'", path)
@@ -32,10 +41,21 @@ func Open(path string) *FileLibrary {
 }
 
 func (f *FileLibrary) Close() {
+	if err := SaveIndex(); err != nil {
+		logrus.Warnf("%v", err)
+	}
+}
+
+/*
+	Also match tracks by their acoustic fingerprint, which finds them in other
+	formats or bitrates but decodes every music file of the searched directory
+*/
+func (f *FileLibrary) EnableFingerprints() {
+	f.fingerprints = true
 }
 
 func (f *FileLibrary) SupportedExtensions() music.FileExtensions {
-	return music.FileExtensions{} // no import supported
+	return music.FileExtensions(files.MusicExtensions)
 }
 
 func (f *FileLibrary) Crates() []music.Tracklist {
@@ -73,6 +93,9 @@ func (f *FileLibrary) Track(filename string) music.Track {
 
 	ppath := files.NormalizePath(filename)
 
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	if cached, ok := f.cache[ppath]; ok {
 		return cached
 	}
@@ -109,50 +132,134 @@ func (f *FileLibrary) Matches(track music.Track) (matches music.Tracks) {
 
 func (f *FileLibrary) MatchInDirectory(track music.Track, dir string) (matches music.Tracks) {
 	hash := music.TrackHash(track)
+	audioSize, audioErr := files.AudioSize(track.FilePath())
+	content := ""
 
-	// logrus.Infof("scanning for music files at '%s'", dir)
+	for _, cached := range f.listing(dir) {
+		// only check for files with the same file extension
+		if filepath.Ext(cached.path) != filepath.Ext(track.FilePath()) {
+			continue
+		}
 
-	files.WalkMusicFiles(dir, func(path string, directoryEntry *godirwalk.Dirent) error {
-		cached := f.cache[path]
-		if cached == nil {
-			cached = newTrack(path)
-			f.cache[path] = cached
+		if files.Size(cached.path) == track.Size() {
+			// println(track.String(), "\n", music.TrackMeta(track))
+			// println(cached.String(), "\n", music.TrackMeta(cached))
+
+			ithash := cached.metaHash()
+			f.hashCache[ithash] = cached
+			if ithash == hash {
+				matches = append(matches, cached)
+				continue
+			}
 		}
 
-		// only check for files with the same file extension
-		if filepath.Ext(cached.path) != filepath.Ext(track.FilePath()) {
-			return nil
+		// the tags might have been edited, compare the audio content
+		if audioErr == nil {
+			if size, err := files.AudioSize(cached.path); err == nil && size == audioSize {
+				if content == "" {
+					content = music.ContentHash(track)
+				}
+				if content != "" && music.ContentHash(cached) == content {
+					matches = append(matches, cached)
+				}
+			}
 		}
+	}
+
+	// it might have been replaced by another encoding of the same recording
+	if len(matches) == 0 && f.fingerprints {
+		matches = f.MatchFingerprint(track, dir)
+	}
+
+	return
+}
 
-		if files.Size(cached.path) != track.Size() {
+/*
+	Music files of the directory with an acoustic fingerprint similar to the
+	track, best match first
+*/
+func (f *FileLibrary) MatchFingerprint(track music.Track, dir string) (matches music.Tracks) {
+	print, err := fingerprint.Of(track.FilePath())
+	if err != nil {
+		logrus.Debugf("no fingerprint for '%s': %v", track.FilePath(), err)
+		return
+	}
+
+	index, ok := f.printIndex[dir]
+	if !ok {
+		start := time.Now()
+		logrus.Infof("fingerprinting music files at '%s'", dir)
+
+		index = fingerprint.NewIndex()
+		files.WalkMusicFiles(dir, func(path string, directoryEntry *godirwalk.Dirent) error {
+			if !fingerprint.Supported(path) {
+				return nil
+			}
+			if it, err := fingerprint.Of(path); err != nil {
+				logrus.Warnf("%v", err)
+			} else {
+				index.Add(path, it)
+			}
 			return nil
+		})
+		if err = fingerprint.SaveCache(); err != nil {
+			logrus.Warnf("%v", err)
 		}
 
-		// println(track.String(), "\n", music.TrackMeta(track))
-		// println(cached.String(), "\n", music.TrackMeta(cached))
+		logrus.Infof("fingerprinted %d files in %v", index.Len(), time.Since(start))
+		f.printIndex[dir] = index
+	}
 
-		ithash := music.TrackHash(cached)
-		f.hashCache[ithash] = cached
-		if ithash == hash {
-			matches = append(matches, cached)
-		}
+	for _, it := range index.Search(print) {
+		logrus.Infof("'%s' sounds like '%s' (%.0f%%)", it.Key, track.FilePath(), 100*it.Score)
+		matches = append(matches, f.cached(it.Key))
+	}
+	return
+}
+
+/*
+	Music files of the directory, it is only walked once per run
+*/
+func (f *FileLibrary) listing(dir string) []*Track {
+	if tracks, ok := f.listings[dir]; ok {
+		return tracks
+	}
+
+	start := time.Now()
+	tracks := []*Track{}
+	files.WalkMusicFiles(dir, func(path string, directoryEntry *godirwalk.Dirent) error {
+		tracks = append(tracks, f.cached(path))
 		return nil
 	})
+	logrus.Debugf("found %d music files at '%s' in %v", len(tracks), dir, time.Since(start))
 
-	return
+	f.listings[dir] = tracks
+	return tracks
+}
+
+func (f *FileLibrary) cached(path string) *Track {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	cached := f.cache[path]
+	if cached == nil {
+		cached = newTrack(path)
+		f.cache[path] = cached
+	}
+	return cached
 }
 
 func (f *FileLibrary) ForEachTrack(fct music.EachTrackFunc) error {
 	paths := []string{}
 	err := filepath.Walk(f.basePath, func(path string, info os.FileInfo, err error) error {
-		if filepath.Ext(path) != ".mp3" && filepath.Ext(path) != ".flac" {
+		if info == nil || info.IsDir() || !files.IsMusicFile(path) {
 			return nil
 		}
 		paths = append(paths, path)
 		return nil
 	})
 	for i, it := range paths {
-		if e := fct(i, len(paths), newTrack(it)); e != nil {
+		if e := fct(i, len(paths), f.cached(it)); e != nil {
 			return e
 		}
 	}
@@ -0,0 +1,138 @@
+package files
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+/*
+	Metadata read from the tags of a music file, whatever its format
+*/
+type metadata struct {
+	title    string
+	album    string
+	artist   string
+	rating   music.Rating
+	year     int
+	bpm      float64
+	key      music.Key
+	genre    string
+	comment  string
+	duration time.Duration
+}
+
+// field which can be written in the tags
+type tagField int
+
+const (
+	ratingField tagField = iota
+	bpmField
+	keyField
+	genreField
+	commentField
+)
+
+/*
+	Tags container of an audio file format, write only updates the given field
+	and keeps every other tags of the file
+*/
+type tagFormat interface {
+	read(path string, meta *metadata) error
+	write(path string, meta *metadata, field tagField) error
+}
+
+func formatOf(path string) tagFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		return id3Format{}
+	case ".flac":
+		return flacFormat{}
+	case ".ogg", ".oga", ".opus":
+		return oggFormat{}
+	case ".m4a", ".mp4":
+		return mp4Format{}
+	case ".aif", ".aiff":
+		return chunkFormat{aiff: true}
+	case ".wav":
+		return chunkFormat{}
+	default:
+		return nil
+	}
+}
+
+/*
+	Replace the content of a file, keeping its permissions
+*/
+func rewriteFile(path string, content []byte) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, "fail to stat file '%s'", path)
+	}
+	if err = files.WriteFileAtomic(path, content); err != nil {
+		return err
+	}
+	return os.Chmod(path, stat.Mode())
+}
+
+/*
+	Year from a date tag, ie: 2005-03-01
+*/
+func parseYear(value string) int {
+	value = strings.TrimSpace(value)
+	if len(value) < 4 {
+		return 0
+	}
+	year, _ := strconv.Atoi(value[:4])
+	return year
+}
+
+func parseBPM(value string) float64 {
+	bpm, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return bpm
+}
+
+func formatBPM(bpm float64) string {
+	return strconv.FormatFloat(math.Round(bpm*100)/100, 'f', -1, 64)
+}
+
+/*
+	FMPS_RATING is a ratio between 0 and 1
+*/
+func parseFmpsRating(value string) (music.Rating, bool) {
+	ratio, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, false
+	}
+	return music.Rating(math.Round(ratio * 5)), true
+}
+
+func formatFmpsRating(rating music.Rating) string {
+	return strconv.FormatFloat(float64(rating)/5, 'f', 1, 64)
+}
+
+/*
+	RATING is either a number of stars or a percentage depending on the software
+*/
+func parsePercentRating(value string) (music.Rating, bool) {
+	rating, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, false
+	}
+	if rating > 5 {
+		rating = int(math.Round(float64(rating) / 20))
+	}
+	return music.Rating(rating), true
+}
+
+func formatPercentRating(rating music.Rating) string {
+	return strconv.Itoa(int(rating) * 20)
+}
@@ -0,0 +1,230 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/bogem/id3v2"
+	"github.com/pkg/errors"
+)
+
+/*
+	AIFF and WAV files are a list of chunks, the tags are an ID3v2 tag stored
+	in a chunk named "ID3 " (or "id3 "). AIFF is big endian and WAV little endian.
+*/
+type chunkFormat struct {
+	aiff bool
+}
+
+type chunk struct {
+	id   string
+	data []byte
+}
+
+type chunkFile struct {
+	// FORM or RIFF header, without its size
+	kind   string
+	form   string
+	chunks []chunk
+}
+
+func (c chunkFormat) read(path string, meta *metadata) error {
+	file, err := c.open(path)
+	if err != nil {
+		return err
+	}
+
+	meta.duration = file.duration()
+
+	if idx := file.id3(); idx >= 0 {
+		tags, err := id3v2.ParseReader(bytes.NewReader(file.chunks[idx].data), id3v2.Options{
+			Parse:       true,
+			ParseFrames: id3Frames,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "could not parse id3 tags of file '%s'", path)
+		}
+		readID3(path, tags, meta)
+	}
+	return nil
+}
+
+func (c chunkFormat) write(path string, meta *metadata, field tagField) error {
+	file, err := c.open(path)
+	if err != nil {
+		return err
+	}
+
+	tags := id3v2.NewEmptyTag()
+	idx := file.id3()
+	if idx >= 0 {
+		tags, err = id3v2.ParseReader(bytes.NewReader(file.chunks[idx].data), id3v2.Options{Parse: true})
+		if err != nil {
+			return errors.Wrapf(err, "could not parse id3 tags of file '%s'", path)
+		}
+	}
+
+	writeID3(tags, meta, field)
+
+	buf := &bytes.Buffer{}
+	if _, err = tags.WriteTo(buf); err != nil {
+		return errors.Wrapf(err, "fail to write id3 tags of file '%s'", path)
+	}
+
+	id := "id3 "
+	if c.aiff {
+		id = "ID3 "
+	}
+
+	switch {
+	case idx >= 0:
+		file.chunks[idx].data = buf.Bytes()
+	case buf.Len() > 0:
+		file.chunks = append(file.chunks, chunk{id: id, data: buf.Bytes()})
+	}
+
+	return rewriteFile(path, file.bytes(c.order()))
+}
+
+func (c chunkFormat) order() binary.ByteOrder {
+	if c.aiff {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+func (c chunkFormat) open(path string) (*chunkFile, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to read file '%s'", path)
+	}
+
+	kind, forms := "RIFF", []string{"WAVE"}
+	if c.aiff {
+		kind, forms = "FORM", []string{"AIFF", "AIFC"}
+	}
+
+	if len(content) < 12 || string(content[:4]) != kind {
+		return nil, errors.Errorf("file '%s' is not a %s file", path, kind)
+	}
+
+	file := &chunkFile{
+		kind: kind,
+		form: string(content[8:12]),
+	}
+	valid := false
+	for _, it := range forms {
+		valid = valid || it == file.form
+	}
+	if !valid {
+		return nil, errors.Errorf("file '%s' has an unsupported %s type '%s'", path, kind, file.form)
+	}
+
+	// ignore anything after the declared size
+	end := 8 + int(c.order().Uint32(content[4:8]))
+	if end > len(content) {
+		end = len(content)
+	}
+
+	pos := 12
+	for pos+8 <= end {
+		id := string(content[pos : pos+4])
+		size := int(c.order().Uint32(content[pos+4 : pos+8]))
+		pos += 8
+		if pos+size > end {
+			return nil, errors.Errorf("invalid file '%s', truncated chunk '%s'", path, id)
+		}
+		file.chunks = append(file.chunks, chunk{id: id, data: content[pos : pos+size]})
+		// chunks are padded to an even size
+		pos += size + size%2
+	}
+	return file, nil
+}
+
+func (f *chunkFile) id3() int {
+	for idx, it := range f.chunks {
+		if strings.EqualFold(it.id, "id3 ") {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (f *chunkFile) find(id string) []byte {
+	for _, it := range f.chunks {
+		if it.id == id {
+			return it.data
+		}
+	}
+	return nil
+}
+
+/*
+	Duration from the AIFF common chunk or the WAV format and data chunks
+*/
+func (f *chunkFile) duration() time.Duration {
+	seconds := 0.0
+	if f.kind == "FORM" {
+		comm := f.find("COMM")
+		if len(comm) < 18 {
+			return 0
+		}
+		frames := binary.BigEndian.Uint32(comm[2:6])
+		rate := extendedFloat(comm[8:18])
+		if rate == 0 {
+			return 0
+		}
+		seconds = float64(frames) / rate
+	} else {
+		format := f.find("fmt ")
+		if len(format) < 12 {
+			return 0
+		}
+		byteRate := binary.LittleEndian.Uint32(format[8:12])
+		if byteRate == 0 {
+			return 0
+		}
+		seconds = float64(len(f.find("data"))) / float64(byteRate)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func (f *chunkFile) bytes(order binary.ByteOrder) []byte {
+	body := &bytes.Buffer{}
+	body.WriteString(f.form)
+	for _, it := range f.chunks {
+		body.WriteString(it.id)
+		binary.Write(body, order, uint32(len(it.data)))
+		body.Write(it.data)
+		if len(it.data)%2 == 1 {
+			body.WriteByte(0)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	out.WriteString(f.kind)
+	binary.Write(out, order, uint32(body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+/*
+	80 bits IEEE 754 extended precision float used by AIFF for the sample rate
+*/
+func extendedFloat(data []byte) float64 {
+	exponent := int(binary.BigEndian.Uint16(data[0:2]))
+	mantissa := binary.BigEndian.Uint64(data[2:10])
+	sign := 1.0
+	if exponent&0x8000 != 0 {
+		sign = -1
+		exponent &= 0x7fff
+	}
+	if exponent == 0 && mantissa == 0 {
+		return 0
+	}
+	return sign * float64(mantissa) * math.Pow(2, float64(exponent-16383-63))
+}
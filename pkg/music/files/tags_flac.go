@@ -0,0 +1,191 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	flacStreamInfo    = 0
+	flacPadding       = 1
+	flacVorbisComment = 4
+
+	flacLastBlock = 0x80
+)
+
+var flacMagic = []byte("fLaC")
+
+/*
+	FLAC file, the tags are in the VORBIS_COMMENT metadata block
+*/
+type flacFormat struct{}
+
+type flacBlock struct {
+	kind byte
+	data []byte
+}
+
+type flacFile struct {
+	// data before the metadata blocks, ie: an ID3 tag
+	prefix []byte
+	blocks []flacBlock
+	audio  []byte
+}
+
+func (flacFormat) read(path string, meta *metadata) error {
+	file, err := readFlac(path)
+	if err != nil {
+		return err
+	}
+
+	for _, it := range file.blocks {
+		switch it.kind {
+		case flacStreamInfo:
+			meta.duration = flacDuration(it.data)
+		case flacVorbisComment:
+			comment, err := parseVorbisComment(it.data)
+			if err != nil {
+				return errors.Wrapf(err, "invalid flac file '%s'", path)
+			}
+			comment.read(meta)
+		}
+	}
+	return nil
+}
+
+func (flacFormat) write(path string, meta *metadata, field tagField) error {
+	file, err := readFlac(path)
+	if err != nil {
+		return err
+	}
+
+	idx := -1
+	comment := &vorbisComment{vendor: "primetools"}
+	for i, it := range file.blocks {
+		if it.kind == flacVorbisComment {
+			idx = i
+			if comment, err = parseVorbisComment(it.data); err != nil {
+				return errors.Wrapf(err, "invalid flac file '%s'", path)
+			}
+		}
+	}
+
+	comment.write(meta, field)
+	block := flacBlock{kind: flacVorbisComment, data: comment.bytes()}
+
+	delta := len(block.data)
+	if idx >= 0 {
+		delta -= len(file.blocks[idx].data)
+		file.blocks[idx] = block
+	} else {
+		// right after the STREAMINFO which must be the first block
+		delta += 4
+		file.blocks = append(file.blocks[:1], append([]flacBlock{block}, file.blocks[1:]...)...)
+	}
+
+	// use the padding so the audio doesn't move if possible
+	for i, it := range file.blocks {
+		if it.kind == flacPadding && len(it.data) >= delta {
+			file.blocks[i].data = make([]byte, len(it.data)-delta)
+			break
+		}
+	}
+
+	return rewriteFile(path, file.bytes())
+}
+
+func readFlac(path string) (*flacFile, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to read flac file '%s'", path)
+	}
+
+	file := &flacFile{}
+	start := skipID3(content)
+	if len(content) < start+4 || !bytes.Equal(content[start:start+4], flacMagic) {
+		return nil, errors.Errorf("file '%s' is not a flac file", path)
+	}
+	file.prefix = content[:start]
+
+	pos := start + 4
+	for {
+		if pos+4 > len(content) {
+			return nil, errors.Errorf("invalid flac file '%s', truncated metadata", path)
+		}
+		header := content[pos]
+		length := int(content[pos+1])<<16 | int(content[pos+2])<<8 | int(content[pos+3])
+		pos += 4
+		if pos+length > len(content) {
+			return nil, errors.Errorf("invalid flac file '%s', truncated metadata", path)
+		}
+
+		file.blocks = append(file.blocks, flacBlock{
+			kind: header &^ flacLastBlock,
+			data: content[pos : pos+length],
+		})
+		pos += length
+
+		if header&flacLastBlock != 0 {
+			break
+		}
+	}
+
+	if len(file.blocks) == 0 || file.blocks[0].kind != flacStreamInfo {
+		return nil, errors.Errorf("invalid flac file '%s', missing stream info", path)
+	}
+
+	file.audio = content[pos:]
+	return file, nil
+}
+
+func (f *flacFile) bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.Write(f.prefix)
+	buf.Write(flacMagic)
+	for idx, it := range f.blocks {
+		header := it.kind
+		if idx == len(f.blocks)-1 {
+			header |= flacLastBlock
+		}
+		length := len(it.data)
+		buf.Write([]byte{header, byte(length >> 16), byte(length >> 8), byte(length)})
+		buf.Write(it.data)
+	}
+	buf.Write(f.audio)
+	return buf.Bytes()
+}
+
+/*
+	STREAMINFO has the sample rate on 20 bits and the total samples on 36 bits
+*/
+func flacDuration(info []byte) time.Duration {
+	if len(info) < 18 {
+		return 0
+	}
+	packed := binary.BigEndian.Uint64(info[10:18])
+	rate := packed >> 44
+	samples := packed & (1<<36 - 1)
+	if rate == 0 {
+		return 0
+	}
+	return time.Duration(float64(samples) / float64(rate) * float64(time.Second))
+}
+
+/*
+	Offset after an ID3v2 tag at the start of the content, 0 if there is none
+*/
+func skipID3(content []byte) int {
+	if len(content) < 10 || !bytes.Equal(content[:3], []byte("ID3")) {
+		return 0
+	}
+	size := int(content[6])<<21 | int(content[7])<<14 | int(content[8])<<7 | int(content[9])
+	if content[5]&0x10 != 0 {
+		// footer
+		size += 10
+	}
+	return 10 + size
+}
@@ -0,0 +1,176 @@
+package files
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bogem/id3v2"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/music"
+)
+
+const TracktorEmail = "traktor@native-instruments.de"
+
+var id3Frames = []string{
+	"Title", "Artist", "Year", "Genre", "POPM", "Album", "TALB", "TBPM", "TKEY", "COMM", "TLEN",
+}
+
+/*
+	ID3v2 tags at the start of the file, ie: mp3
+*/
+type id3Format struct{}
+
+func (id3Format) read(path string, meta *metadata) error {
+	tags, err := id3v2.Open(path, id3v2.Options{
+		Parse:       true,
+		ParseFrames: id3Frames,
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not open id3 tags")
+	}
+	defer tags.Close()
+
+	readID3(path, tags, meta)
+	return nil
+}
+
+/*
+	Open the id3 tags of the file, apply the modification and save them back as id3v2.4
+*/
+func (id3Format) write(path string, meta *metadata, field tagField) error {
+	tags, err := id3v2.Open(path, id3v2.Options{
+		Parse: true,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "fail to open id3 tags for file %s", path)
+	}
+	defer tags.Close()
+
+	writeID3(tags, meta, field)
+	return tags.Save()
+}
+
+func readID3(path string, tags *id3v2.Tag, meta *metadata) {
+	var err error
+
+	if !tags.HasFrames() {
+		logrus.Warnf("file '%s' doesn't have any id3 meta data", path)
+		return
+	}
+
+	meta.title = tags.Title()
+	meta.album = tags.Album()
+	meta.artist = tags.Artist()
+	if meta.title == "" {
+		logrus.Warnf("file '%s' doesn't have any id3 title data", path)
+	}
+
+	for _, frame := range tags.GetFrames("POPM") {
+		if popm, ok := frame.(id3v2.PopularimeterFrame); ok {
+			if popm.Email == TracktorEmail {
+				meta.rating = music.Rating(popm.Rating / 51)
+			}
+		}
+	}
+
+	meta.genre = tags.Genre()
+
+	if bpm := tags.GetTextFrame("TBPM").Text; bpm != "" {
+		meta.bpm, err = strconv.ParseFloat(strings.TrimSpace(bpm), 64)
+		if err != nil {
+			logrus.Errorf("could not parse bpm tags in file '%s': %v", path, err)
+		}
+	}
+
+	if key := tags.GetTextFrame("TKEY").Text; key != "" {
+		meta.key, err = music.ParseKey(key)
+		if err != nil {
+			logrus.Warnf("could not parse key tags in file '%s': %v", path, err)
+		}
+	}
+
+	if length := tags.GetTextFrame("TLEN").Text; length != "" {
+		if ms, err := strconv.Atoi(strings.TrimSpace(length)); err == nil {
+			meta.duration = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	for _, frame := range tags.GetFrames("COMM") {
+		if comm, ok := frame.(id3v2.CommentFrame); ok {
+			meta.comment = comm.Text
+			break
+		}
+	}
+
+	yearstr := tags.Year()
+	if yearstr != "" && len(yearstr) >= 4 {
+		meta.year, err = strconv.Atoi(yearstr[:4])
+		if err != nil {
+			logrus.Errorf("could not parse year tags in file '%s': %v", path, err)
+		}
+	}
+}
+
+func writeID3(tags *id3v2.Tag, meta *metadata, field tagField) {
+	switch field {
+	case ratingField:
+		var popframe *id3v2.PopularimeterFrame
+
+		for _, frame := range tags.GetFrames("POPM") {
+			if popm, ok := frame.(id3v2.PopularimeterFrame); ok {
+				if popm.Email == TracktorEmail {
+					popframe = &popm
+				}
+			}
+		}
+		if popframe == nil {
+			popframe = &id3v2.PopularimeterFrame{
+				Email:   TracktorEmail,
+				Counter: &big.Int{},
+			}
+		}
+		popframe.Rating = uint8(meta.rating) * 51
+		tags.AddFrame("POPM", popframe)
+	case bpmField:
+		// TBPM is defined as an integer by the id3 specification
+		tags.AddTextFrame("TBPM", tags.DefaultEncoding(), strconv.Itoa(int(math.Round(meta.bpm))))
+	case keyField:
+		tags.AddTextFrame("TKEY", tags.DefaultEncoding(), meta.key.String())
+	case genreField:
+		tags.SetGenre(meta.genre)
+	case commentField:
+		tags.DeleteFrames("COMM")
+		tags.AddCommentFrame(id3v2.CommentFrame{
+			Encoding: tags.DefaultEncoding(),
+			Language: "eng",
+			Text:     meta.comment,
+		})
+	}
+
+	tags.SetVersion(4)
+	enc := tags.DefaultEncoding()
+
+	for id, framer := range tags.AllFrames() {
+		for _, it := range framer {
+			switch frame := it.(type) {
+			case id3v2.UnsynchronisedLyricsFrame:
+				frame.Encoding = enc
+				tags.AddUnsynchronisedLyricsFrame(frame)
+			case id3v2.UserDefinedTextFrame:
+				frame.Encoding = enc
+				tags.AddFrame(id, frame)
+			case id3v2.CommentFrame:
+				frame.Encoding = enc
+				tags.AddCommentFrame(frame)
+			case id3v2.TextFrame:
+				frame.Encoding = enc
+				tags.AddTextFrame(id, enc, frame.Text)
+			}
+		}
+	}
+}
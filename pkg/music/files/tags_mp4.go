@@ -0,0 +1,454 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"primetools/pkg/music"
+)
+
+const (
+	mp4Title   = "\xa9nam"
+	mp4Artist  = "\xa9ART"
+	mp4Album   = "\xa9alb"
+	mp4Year    = "\xa9day"
+	mp4Genre   = "\xa9gen"
+	mp4Comment = "\xa9cmt"
+	mp4Tempo   = "tmpo"
+
+	// genre as an id3v1 index, replaced by the text genre when written
+	mp4GenreIndex = "gnre"
+
+	// freeform items are identified by a mean and a name
+	mp4Freeform     = "----"
+	mp4FreeformMean = "com.apple.iTunes"
+
+	mp4TypeUtf8    = 1
+	mp4TypeInteger = 21
+)
+
+// atoms which only contains other atoms, ilst items are handled separately
+var mp4Containers = map[string]bool{
+	"moov": true, "trak": true, "mdia": true, "minf": true, "stbl": true,
+	"udta": true, "meta": true, "ilst": true, "edts": true, "dinf": true,
+}
+
+/*
+	MPEG-4 audio files (m4a), the tags are items of the moov.udta.meta.ilst atom
+*/
+type mp4Format struct{}
+
+type mp4Atom struct {
+	kind      string
+	container bool
+	// version and flags of full atoms containers, ie: meta
+	prefix   []byte
+	data     []byte
+	children []*mp4Atom
+
+	// position in the parsed content
+	offset int
+	size   int
+}
+
+func (mp4Format) read(path string, meta *metadata) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "fail to read mp4 file '%s'", path)
+	}
+
+	atoms, err := parseMp4Atoms(content, "", 0)
+	if err != nil {
+		return errors.Wrapf(err, "invalid mp4 file '%s'", path)
+	}
+
+	moov := findMp4Atom(atoms, "moov")
+	if moov == nil {
+		return errors.Errorf("invalid mp4 file '%s', missing moov atom", path)
+	}
+
+	meta.duration = mp4Duration(moov.child("mvhd"))
+
+	ilst := moov.find("udta", "meta", "ilst")
+	if ilst == nil {
+		return nil
+	}
+
+	meta.title = mp4Text(ilst, mp4Title)
+	meta.artist = mp4Text(ilst, mp4Artist)
+	meta.album = mp4Text(ilst, mp4Album)
+	meta.year = parseYear(mp4Text(ilst, mp4Year))
+	meta.genre = mp4Text(ilst, mp4Genre)
+	meta.comment = mp4Text(ilst, mp4Comment)
+
+	if tempo := mp4Value(ilst.child(mp4Tempo)); len(tempo) >= 2 {
+		meta.bpm = float64(binary.BigEndian.Uint16(tempo))
+	}
+
+	if key := mp4FreeformText(ilst, "initialkey", "KEY"); key != "" {
+		meta.key, _ = music.ParseKey(key)
+	}
+
+	if rating, ok := parseFmpsRating(mp4FreeformText(ilst, "FMPS_Rating")); ok {
+		meta.rating = rating
+	} else if rating, ok := parsePercentRating(mp4FreeformText(ilst, "RATING")); ok {
+		meta.rating = rating
+	}
+	return nil
+}
+
+func (mp4Format) write(path string, meta *metadata, field tagField) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "fail to read mp4 file '%s'", path)
+	}
+
+	atoms, err := parseMp4Atoms(content, "", 0)
+	if err != nil {
+		return errors.Wrapf(err, "invalid mp4 file '%s'", path)
+	}
+
+	if findMp4Atom(atoms, "moof") != nil {
+		return errors.Errorf("fragmented mp4 file '%s' is not supported", path)
+	}
+
+	moov := findMp4Atom(atoms, "moov")
+	if moov == nil {
+		return errors.Errorf("invalid mp4 file '%s', missing moov atom", path)
+	}
+
+	ilst := moov.ilst()
+	switch field {
+	case ratingField:
+		ilst.setFreeform("FMPS_Rating", formatFmpsRating(meta.rating))
+		ilst.setFreeform("RATING", formatPercentRating(meta.rating))
+	case bpmField:
+		tempo := make([]byte, 2)
+		binary.BigEndian.PutUint16(tempo, uint16(math.Round(meta.bpm)))
+		ilst.setItem(mp4Tempo, mp4TypeInteger, tempo)
+	case keyField:
+		key := ""
+		if meta.key.Valid() {
+			key = meta.key.String()
+		}
+		ilst.setFreeform("initialkey", key)
+	case genreField:
+		ilst.remove(mp4GenreIndex)
+		ilst.setItem(mp4Genre, mp4TypeUtf8, []byte(meta.genre))
+	case commentField:
+		ilst.setItem(mp4Comment, mp4TypeUtf8, []byte(meta.comment))
+	}
+
+	// the chunk offsets point into mdat, which moves if it is after moov
+	delta := len(moov.bytes()) - moov.size
+	if mdat := findMp4Atom(atoms, "mdat"); mdat != nil && mdat.offset > moov.offset && delta != 0 {
+		if err = moov.shiftChunkOffsets(delta); err != nil {
+			return errors.Wrapf(err, "fail to update mp4 file '%s'", path)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(content[:moov.offset])
+	buf.Write(moov.bytes())
+	buf.Write(content[moov.offset+moov.size:])
+	return rewriteFile(path, buf.Bytes())
+}
+
+func parseMp4Atoms(content []byte, parent string, base int) ([]*mp4Atom, error) {
+	atoms := []*mp4Atom{}
+	pos := 0
+	for pos < len(content) {
+		if pos+8 > len(content) {
+			return nil, errors.Errorf("truncated atom in '%s'", parent)
+		}
+
+		size := uint64(binary.BigEndian.Uint32(content[pos:]))
+		kind := string(content[pos+4 : pos+8])
+		header := 8
+		if size == 1 {
+			if pos+16 > len(content) {
+				return nil, errors.Errorf("truncated atom '%s'", kind)
+			}
+			size = binary.BigEndian.Uint64(content[pos+8:])
+			header = 16
+		} else if size == 0 {
+			// up to the end of the file
+			size = uint64(len(content) - pos)
+		}
+		if size < uint64(header) || uint64(pos)+size > uint64(len(content)) {
+			return nil, errors.Errorf("invalid size for atom '%s'", kind)
+		}
+
+		atom := &mp4Atom{
+			kind:   kind,
+			offset: base + pos,
+			size:   int(size),
+		}
+		payload := content[pos+header : pos+int(size)]
+
+		if mp4Containers[kind] || parent == "ilst" {
+			atom.container = true
+			// meta is a full atom, except in some quicktime files
+			if kind == "meta" && !(len(payload) >= 8 && string(payload[4:8]) == "hdlr") {
+				if len(payload) < 4 {
+					return nil, errors.New("truncated meta atom")
+				}
+				atom.prefix = payload[:4]
+				payload = payload[4:]
+			}
+			children, err := parseMp4Atoms(payload, kind, base+pos+header+len(atom.prefix))
+			if err != nil {
+				return nil, err
+			}
+			atom.children = children
+		} else {
+			atom.data = payload
+		}
+
+		atoms = append(atoms, atom)
+		pos += int(size)
+	}
+	return atoms, nil
+}
+
+func findMp4Atom(atoms []*mp4Atom, kind string) *mp4Atom {
+	for _, it := range atoms {
+		if it.kind == kind {
+			return it
+		}
+	}
+	return nil
+}
+
+func (a *mp4Atom) child(kind string) *mp4Atom {
+	if a == nil {
+		return nil
+	}
+	return findMp4Atom(a.children, kind)
+}
+
+func (a *mp4Atom) find(kinds ...string) *mp4Atom {
+	for _, it := range kinds {
+		a = a.child(it)
+	}
+	return a
+}
+
+/*
+	Return the moov.udta.meta.ilst atom, created if missing
+*/
+func (a *mp4Atom) ilst() *mp4Atom {
+	udta := a.child("udta")
+	if udta == nil {
+		udta = &mp4Atom{kind: "udta", container: true}
+		a.children = append(a.children, udta)
+	}
+
+	meta := udta.child("meta")
+	if meta == nil {
+		meta = &mp4Atom{kind: "meta", container: true, prefix: make([]byte, 4)}
+		meta.children = append(meta.children, &mp4Atom{
+			kind: "hdlr",
+			data: append(append(make([]byte, 8), "mdirappl"...), make([]byte, 9)...),
+		})
+		udta.children = append(udta.children, meta)
+	}
+
+	ilst := meta.child("ilst")
+	if ilst == nil {
+		ilst = &mp4Atom{kind: "ilst", container: true}
+		meta.children = append(meta.children, ilst)
+	}
+	return ilst
+}
+
+func (a *mp4Atom) remove(kind string) {
+	out := []*mp4Atom{}
+	for _, it := range a.children {
+		if it.kind != kind {
+			out = append(out, it)
+		}
+	}
+	a.children = out
+}
+
+/*
+	Replace an item of ilst, an empty value removes it
+*/
+func (a *mp4Atom) setItem(kind string, typ uint32, value []byte) {
+	if len(value) == 0 {
+		a.remove(kind)
+		return
+	}
+
+	item := &mp4Atom{kind: kind, container: true, children: []*mp4Atom{newMp4Data(typ, value)}}
+	for idx, it := range a.children {
+		if it.kind == kind {
+			a.children[idx] = item
+			return
+		}
+	}
+	a.children = append(a.children, item)
+}
+
+func (a *mp4Atom) setFreeform(name string, value string) {
+	out := []*mp4Atom{}
+	for _, it := range a.children {
+		if it.kind == mp4Freeform && strings.EqualFold(mp4FreeformName(it), name) {
+			continue
+		}
+		out = append(out, it)
+	}
+
+	if value != "" {
+		out = append(out, &mp4Atom{
+			kind:      mp4Freeform,
+			container: true,
+			children: []*mp4Atom{
+				{kind: "mean", data: append(make([]byte, 4), mp4FreeformMean...)},
+				{kind: "name", data: append(make([]byte, 4), name...)},
+				newMp4Data(mp4TypeUtf8, []byte(value)),
+			},
+		})
+	}
+	a.children = out
+}
+
+func newMp4Data(typ uint32, value []byte) *mp4Atom {
+	data := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint32(data, typ)
+	return &mp4Atom{kind: "data", data: append(data, value...)}
+}
+
+/*
+	Add delta to the chunk offsets of every track
+*/
+func (a *mp4Atom) shiftChunkOffsets(delta int) error {
+	for _, it := range a.children {
+		switch it.kind {
+		case "stco", "co64":
+			if len(it.data) < 8 {
+				return errors.Errorf("truncated %s atom", it.kind)
+			}
+			data := append([]byte{}, it.data...)
+			count := int(binary.BigEndian.Uint32(data[4:8]))
+			width := 4
+			if it.kind == "co64" {
+				width = 8
+			}
+			if len(data) < 8+count*width {
+				return errors.Errorf("truncated %s atom", it.kind)
+			}
+			for idx := 0; idx < count; idx++ {
+				pos := 8 + idx*width
+				if width == 4 {
+					offset := int64(binary.BigEndian.Uint32(data[pos:])) + int64(delta)
+					if offset < 0 || offset > math.MaxUint32 {
+						return errors.New("chunk offset overflow")
+					}
+					binary.BigEndian.PutUint32(data[pos:], uint32(offset))
+				} else {
+					binary.BigEndian.PutUint64(data[pos:], uint64(int64(binary.BigEndian.Uint64(data[pos:]))+int64(delta)))
+				}
+			}
+			it.data = data
+		default:
+			if it.container {
+				if err := it.shiftChunkOffsets(delta); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (a *mp4Atom) bytes() []byte {
+	body := &bytes.Buffer{}
+	if a.container {
+		body.Write(a.prefix)
+		for _, it := range a.children {
+			body.Write(it.bytes())
+		}
+	} else {
+		body.Write(a.data)
+	}
+
+	out := &bytes.Buffer{}
+	if body.Len()+8 > math.MaxUint32 {
+		binary.Write(out, binary.BigEndian, uint32(1))
+		out.WriteString(a.kind)
+		binary.Write(out, binary.BigEndian, uint64(body.Len()+16))
+	} else {
+		binary.Write(out, binary.BigEndian, uint32(body.Len()+8))
+		out.WriteString(a.kind)
+	}
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+/*
+	Value of the data atom of an item, after its type and locale
+*/
+func mp4Value(item *mp4Atom) []byte {
+	data := item.child("data")
+	if data == nil || len(data.data) < 8 {
+		return nil
+	}
+	return data.data[8:]
+}
+
+func mp4Text(ilst *mp4Atom, kind string) string {
+	return string(mp4Value(ilst.child(kind)))
+}
+
+func mp4FreeformName(item *mp4Atom) string {
+	name := item.child("name")
+	if name == nil || len(name.data) < 4 {
+		return ""
+	}
+	return string(name.data[4:])
+}
+
+func mp4FreeformText(ilst *mp4Atom, names ...string) string {
+	for _, name := range names {
+		for _, it := range ilst.children {
+			if it.kind == mp4Freeform && strings.EqualFold(mp4FreeformName(it), name) {
+				return string(mp4Value(it))
+			}
+		}
+	}
+	return ""
+}
+
+/*
+	Duration of the movie header, its timescale is in units per second
+*/
+func mp4Duration(mvhd *mp4Atom) time.Duration {
+	if mvhd == nil || len(mvhd.data) < 20 {
+		return 0
+	}
+
+	var timescale, duration uint64
+	if mvhd.data[0] == 1 {
+		if len(mvhd.data) < 32 {
+			return 0
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mvhd.data[20:24]))
+		duration = binary.BigEndian.Uint64(mvhd.data[24:32])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(mvhd.data[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(mvhd.data[16:20]))
+	}
+
+	if timescale == 0 {
+		return 0
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
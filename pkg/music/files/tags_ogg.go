@@ -0,0 +1,336 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	oggHeaderSize   = 27
+	oggContinued    = 0x01
+	oggMaxSegments  = 255
+	opusSampleRate  = 48000
+	oggVorbisHeader = 3
+	oggOpusHeader   = 2
+)
+
+var (
+	oggMagic          = []byte("OggS")
+	vorbisIdHeader    = []byte("\x01vorbis")
+	vorbisCommentHead = []byte("\x03vorbis")
+	opusIdHeader      = []byte("OpusHead")
+	opusCommentHead   = []byte("OpusTags")
+)
+
+/*
+	Ogg Vorbis and Opus files, the tags are in the comment header which is
+	the second packet of the stream
+*/
+type oggFormat struct{}
+
+type oggPage struct {
+	flags    byte
+	granule  uint64
+	serial   uint32
+	sequence uint32
+	segments []byte
+	data     []byte
+}
+
+type oggFile struct {
+	pages []oggPage
+
+	// header packets of the first stream, and the index of the first page after them
+	headers   [][]byte
+	audioPage int
+}
+
+func (oggFormat) read(path string, meta *metadata) error {
+	file, err := readOgg(path)
+	if err != nil {
+		return err
+	}
+
+	comment, err := file.comment()
+	if err != nil {
+		return errors.Wrapf(err, "invalid ogg file '%s'", path)
+	}
+	comment.read(meta)
+	meta.duration = file.duration()
+	return nil
+}
+
+func (oggFormat) write(path string, meta *metadata, field tagField) error {
+	file, err := readOgg(path)
+	if err != nil {
+		return err
+	}
+
+	comment, err := file.comment()
+	if err != nil {
+		return errors.Wrapf(err, "invalid ogg file '%s'", path)
+	}
+	comment.write(meta, field)
+
+	packet := []byte{}
+	if file.isOpus() {
+		packet = append(append(packet, opusCommentHead...), comment.bytes()...)
+	} else {
+		// vorbis comment header ends with the framing bit
+		packet = append(append(packet, vorbisCommentHead...), comment.bytes()...)
+		packet = append(packet, 1)
+	}
+	file.headers[1] = packet
+
+	return rewriteFile(path, file.bytes())
+}
+
+func readOgg(path string) (*oggFile, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to read ogg file '%s'", path)
+	}
+
+	file := &oggFile{}
+	pos := 0
+	for pos < len(content) {
+		page, size, err := parseOggPage(content[pos:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid ogg file '%s'", path)
+		}
+		file.pages = append(file.pages, page)
+		pos += size
+	}
+
+	if len(file.pages) == 0 {
+		return nil, errors.Errorf("file '%s' is not an ogg file", path)
+	}
+
+	// collect the header packets of the first stream
+	serial := file.pages[0].serial
+	packet := []byte{}
+	count := oggVorbisHeader
+	for idx, page := range file.pages {
+		if page.serial != serial {
+			return nil, errors.Errorf("multiplexed ogg file '%s' is not supported", path)
+		}
+
+		offset := 0
+		for _, it := range page.segments {
+			packet = append(packet, page.data[offset:offset+int(it)]...)
+			offset += int(it)
+			if it < oggMaxSegments {
+				file.headers = append(file.headers, packet)
+				packet = []byte{}
+			}
+		}
+
+		if idx == 0 {
+			if len(file.headers) != 1 || len(packet) > 0 {
+				return nil, errors.Errorf("invalid ogg file '%s', first page must only contain the identification header", path)
+			}
+			if bytes.HasPrefix(file.headers[0], opusIdHeader) {
+				count = oggOpusHeader
+			}
+		}
+		if len(file.headers) >= count {
+			if len(file.headers) > count || len(packet) > 0 {
+				return nil, errors.Errorf("invalid ogg file '%s', audio data shares a page with the headers", path)
+			}
+			file.audioPage = idx + 1
+			break
+		}
+	}
+
+	if len(file.headers) < count {
+		return nil, errors.Errorf("invalid ogg file '%s', missing headers", path)
+	}
+	return file, nil
+}
+
+func parseOggPage(content []byte) (oggPage, int, error) {
+	page := oggPage{}
+	if len(content) < oggHeaderSize || !bytes.Equal(content[:4], oggMagic) {
+		return page, 0, errors.New("missing page capture pattern")
+	}
+
+	page.flags = content[5]
+	page.granule = binary.LittleEndian.Uint64(content[6:14])
+	page.serial = binary.LittleEndian.Uint32(content[14:18])
+	page.sequence = binary.LittleEndian.Uint32(content[18:22])
+
+	count := int(content[26])
+	if len(content) < oggHeaderSize+count {
+		return page, 0, errors.New("truncated page")
+	}
+	page.segments = content[oggHeaderSize : oggHeaderSize+count]
+
+	size := 0
+	for _, it := range page.segments {
+		size += int(it)
+	}
+	start := oggHeaderSize + count
+	if len(content) < start+size {
+		return page, 0, errors.New("truncated page")
+	}
+	page.data = content[start : start+size]
+	return page, start + size, nil
+}
+
+func (f *oggFile) isOpus() bool {
+	return bytes.HasPrefix(f.headers[0], opusIdHeader)
+}
+
+func (f *oggFile) comment() (*vorbisComment, error) {
+	head := vorbisCommentHead
+	if f.isOpus() {
+		head = opusCommentHead
+	}
+	if !bytes.HasPrefix(f.headers[1], head) {
+		return nil, errors.New("second packet is not a comment header")
+	}
+	return parseVorbisComment(f.headers[1][len(head):])
+}
+
+/*
+	Granule position of the last page is the number of samples
+*/
+func (f *oggFile) duration() time.Duration {
+	serial := f.pages[0].serial
+	var granule uint64
+	for _, it := range f.pages {
+		if it.serial == serial && it.granule != ^uint64(0) {
+			granule = it.granule
+		}
+	}
+
+	id := f.headers[0]
+	rate := uint64(0)
+	if f.isOpus() && len(id) >= 12 {
+		rate = opusSampleRate
+		// samples to skip at the start of the stream
+		preskip := uint64(binary.LittleEndian.Uint16(id[10:12]))
+		if granule > preskip {
+			granule -= preskip
+		}
+	} else if bytes.HasPrefix(id, vorbisIdHeader) && len(id) >= 16 {
+		rate = uint64(binary.LittleEndian.Uint32(id[12:16]))
+	}
+
+	if rate == 0 {
+		return 0
+	}
+	return time.Duration(float64(granule) / float64(rate) * float64(time.Second))
+}
+
+/*
+	Serialize the file with the header packets paged again, the following pages
+	of the stream are renumbered
+*/
+func (f *oggFile) bytes() []byte {
+	first := f.pages[0]
+	pages := []oggPage{first}
+
+	// the first page only contains the identification header
+	segments := []byte{}
+	data := []byte{}
+	flags := byte(0)
+	flush := func() {
+		pages = append(pages, oggPage{
+			flags:    flags,
+			serial:   first.serial,
+			sequence: first.sequence + uint32(len(pages)),
+			segments: segments,
+			data:     data,
+		})
+		segments, data = []byte{}, []byte{}
+	}
+
+	for _, packet := range f.headers[1:] {
+		remaining := len(packet)
+		offset := 0
+		for {
+			if len(segments) == oggMaxSegments {
+				flush()
+				flags = 0
+				if offset > 0 {
+					flags = oggContinued
+				}
+			}
+			size := remaining
+			if size > oggMaxSegments {
+				size = oggMaxSegments
+			}
+			segments = append(segments, byte(size))
+			data = append(data, packet[offset:offset+size]...)
+			offset += size
+			remaining -= size
+			if size < oggMaxSegments {
+				break
+			}
+		}
+	}
+	// audio must start on a new page
+	flush()
+
+	delta := uint32(0)
+	if f.audioPage < len(f.pages) {
+		delta = first.sequence + uint32(len(pages)) - f.pages[f.audioPage].sequence
+	}
+	for _, it := range f.pages[f.audioPage:] {
+		if it.serial == first.serial {
+			it.sequence += delta
+		}
+		pages = append(pages, it)
+	}
+
+	buf := &bytes.Buffer{}
+	for _, it := range pages {
+		buf.Write(it.bytes())
+	}
+	return buf.Bytes()
+}
+
+func (p oggPage) bytes() []byte {
+	out := make([]byte, oggHeaderSize, oggHeaderSize+len(p.segments)+len(p.data))
+	copy(out, oggMagic)
+	out[5] = p.flags
+	binary.LittleEndian.PutUint64(out[6:14], p.granule)
+	binary.LittleEndian.PutUint32(out[14:18], p.serial)
+	binary.LittleEndian.PutUint32(out[18:22], p.sequence)
+	out[26] = byte(len(p.segments))
+	out = append(out, p.segments...)
+	out = append(out, p.data...)
+	binary.LittleEndian.PutUint32(out[22:26], oggCrc(out))
+	return out
+}
+
+var oggCrcTable = func() (table [256]uint32) {
+	for idx := range table {
+		crc := uint32(idx) << 24
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[idx] = crc
+	}
+	return
+}()
+
+/*
+	Ogg uses a non reflected crc32 computed with the checksum field set to zero
+*/
+func oggCrc(page []byte) uint32 {
+	crc := uint32(0)
+	for _, it := range page {
+		crc = crc<<8 ^ oggCrcTable[byte(crc>>24)^it]
+	}
+	return crc
+}
@@ -0,0 +1,247 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+/*
+	The fixtures of testdata are tiny synthetic files, only their containers
+	are valid and the audio is random bytes
+*/
+func fixture(t *testing.T, name string) string {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err = ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func audioHash(t *testing.T, path string) string {
+	hash, err := files.AudioHash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func readTags(t *testing.T, path string) metadata {
+	meta := metadata{}
+	if err := formatOf(path).read(path, &meta); err != nil {
+		t.Fatal(err)
+	}
+	return meta
+}
+
+func TestTagsRoundTrip(t *testing.T) {
+	files.AudioHashCache = filepath.Join(t.TempDir(), "audiohash.json")
+
+	tests := []struct {
+		fixture  string
+		title    string
+		duration time.Duration
+	}{
+		{"tagged.flac", "Fixture", time.Second},
+		{"bare.flac", "", time.Second},
+		{"tagged.ogg", "Fixture", time.Second},
+		{"tagged.opus", "Fixture", time.Second},
+		{"tagged.m4a", "Fixture", time.Second},
+		{"tagged.wav", "Fixture", time.Second},
+		{"bare.aiff", "", time.Second},
+	}
+
+	key, _ := music.ParseKey("Am")
+	fields := []struct {
+		field  tagField
+		update func(meta *metadata)
+	}{
+		{ratingField, func(meta *metadata) { meta.rating = 4 }},
+		{bpmField, func(meta *metadata) { meta.bpm = 124 }},
+		{keyField, func(meta *metadata) { meta.key = key }},
+		{genreField, func(meta *metadata) { meta.genre = "House" }},
+		{commentField, func(meta *metadata) { meta.comment = strings.Repeat("Warmup ", 10) }},
+	}
+
+	for _, it := range tests {
+		t.Run(it.fixture, func(t *testing.T) {
+			hash := audioHash(t, filepath.Join("testdata", it.fixture))
+			path := fixture(t, it.fixture)
+
+			meta := readTags(t, path)
+			if meta.title != it.title {
+				t.Fatalf("title is '%s' instead of '%s'", meta.title, it.title)
+			}
+
+			for _, f := range fields {
+				f.update(&meta)
+				if err := formatOf(path).write(path, &meta, f.field); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			read := readTags(t, path)
+			if read != meta {
+				t.Errorf("read %+v instead of %+v", read, meta)
+			}
+			if read.duration != it.duration {
+				t.Errorf("duration is %v instead of %v", read.duration, it.duration)
+			}
+			if audioHash(t, path) != hash {
+				t.Error("audio changed")
+			}
+		})
+	}
+}
+
+func TestFlacPadding(t *testing.T) {
+	path := fixture(t, "tagged.flac")
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := readTags(t, path)
+	meta.genre = "Deep House"
+	if err = formatOf(path).write(path, &meta, genreField); err != nil {
+		t.Fatal(err)
+	}
+
+	// the longer comment is taken from the padding so the audio doesn't move
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() != stat.Size() {
+		t.Errorf("size is %d instead of %d", after.Size(), stat.Size())
+	}
+
+	file, err := readFlac(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := []byte{}
+	for _, it := range file.blocks {
+		kinds = append(kinds, it.kind)
+	}
+	if !bytes.Equal(kinds, []byte{flacStreamInfo, flacVorbisComment, flacPadding}) {
+		t.Errorf("blocks are %v", kinds)
+	}
+}
+
+func TestOggPaging(t *testing.T) {
+	files.AudioHashCache = filepath.Join(t.TempDir(), "audiohash.json")
+
+	for _, name := range []string{"tagged.ogg", "tagged.opus"} {
+		t.Run(name, func(t *testing.T) {
+			path := fixture(t, name)
+			hash := audioHash(t, filepath.Join("testdata", name))
+
+			// the comment header spans several pages
+			meta := readTags(t, path)
+			meta.comment = strings.Repeat("x", 70000)
+			if err := formatOf(path).write(path, &meta, commentField); err != nil {
+				t.Fatal(err)
+			}
+
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pos := 0
+			for sequence := uint32(0); pos < len(content); sequence++ {
+				page, size, err := parseOggPage(content[pos:])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if page.sequence != sequence {
+					t.Errorf("page %d has the sequence number %d", sequence, page.sequence)
+				}
+				raw := append([]byte{}, content[pos:pos+size]...)
+				checksum := binary.LittleEndian.Uint32(raw[22:26])
+				copy(raw[22:26], []byte{0, 0, 0, 0})
+				if oggCrc(raw) != checksum {
+					t.Errorf("page %d has an invalid checksum", sequence)
+				}
+				pos += size
+			}
+
+			if read := readTags(t, path); read.comment != meta.comment || read.title != "Fixture" {
+				t.Errorf("read comment of %d bytes and title '%s'", len(read.comment), read.title)
+			}
+			if audioHash(t, path) != hash {
+				t.Error("audio changed")
+			}
+		})
+	}
+}
+
+func TestMp4ChunkOffsets(t *testing.T) {
+	path := fixture(t, "tagged.m4a")
+	original, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := readTags(t, path)
+	meta.comment = "Warmup"
+	if err = formatOf(path).write(path, &meta, commentField); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) <= len(original) {
+		t.Fatal("moov didn't grow")
+	}
+
+	offsets := func(content []byte) []int64 {
+		atoms, err := parseMp4Atoms(content, "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := []int64{}
+		for _, trak := range findMp4Atom(atoms, "moov").children {
+			if trak.kind != "trak" {
+				continue
+			}
+			stbl := trak.find("mdia", "minf", "stbl")
+			if stco := stbl.child("stco"); stco != nil {
+				for idx := 0; idx < int(binary.BigEndian.Uint32(stco.data[4:])); idx++ {
+					out = append(out, int64(binary.BigEndian.Uint32(stco.data[8+idx*4:])))
+				}
+			}
+			if co64 := stbl.child("co64"); co64 != nil {
+				for idx := 0; idx < int(binary.BigEndian.Uint32(co64.data[4:])); idx++ {
+					out = append(out, int64(binary.BigEndian.Uint64(co64.data[8+idx*8:])))
+				}
+			}
+		}
+		return out
+	}
+
+	// the offsets still point to the same audio data
+	before, after := offsets(original), offsets(content)
+	if len(before) != 4 || len(after) != 4 {
+		t.Fatalf("found %d and %d chunk offsets", len(before), len(after))
+	}
+	for idx := range before {
+		if !bytes.Equal(original[before[idx]:before[idx]+16], content[after[idx]:after[idx]+16]) {
+			t.Errorf("chunk %d moved from %d to %d without its data", idx, before[idx], after[idx])
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"primetools/pkg/music"
+)
+
+/*
+	Vorbis comments used by FLAC and Ogg files, a vendor string followed by
+	KEY=value entries, all lengths are 32 bits little endian
+*/
+type vorbisComment struct {
+	vendor   string
+	comments []string
+}
+
+func parseVorbisComment(data []byte) (*vorbisComment, error) {
+	rd := bytes.NewReader(data)
+
+	readString := func() (string, error) {
+		var length uint32
+		if err := binary.Read(rd, binary.LittleEndian, &length); err != nil {
+			return "", err
+		}
+		if int64(length) > int64(rd.Len()) {
+			return "", errors.New("comment length is past the end of the block")
+		}
+		buf := make([]byte, length)
+		_, err := io.ReadFull(rd, buf)
+		return string(buf), err
+	}
+
+	v := &vorbisComment{}
+	var err error
+	if v.vendor, err = readString(); err != nil {
+		return nil, errors.Wrap(err, "invalid vorbis comment vendor")
+	}
+
+	var count uint32
+	if err = binary.Read(rd, binary.LittleEndian, &count); err != nil {
+		return nil, errors.Wrap(err, "invalid vorbis comment count")
+	}
+
+	for idx := uint32(0); idx < count; idx++ {
+		comment, err := readString()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid vorbis comment")
+		}
+		v.comments = append(v.comments, comment)
+	}
+	return v, nil
+}
+
+func (v *vorbisComment) bytes() []byte {
+	buf := &bytes.Buffer{}
+	writeString := func(value string) {
+		binary.Write(buf, binary.LittleEndian, uint32(len(value)))
+		buf.WriteString(value)
+	}
+
+	writeString(v.vendor)
+	binary.Write(buf, binary.LittleEndian, uint32(len(v.comments)))
+	for _, it := range v.comments {
+		writeString(it)
+	}
+	return buf.Bytes()
+}
+
+/*
+	First value of a key, keys are case insensitive
+*/
+func (v *vorbisComment) get(keys ...string) string {
+	for _, key := range keys {
+		for _, it := range v.comments {
+			if idx := strings.IndexByte(it, '='); idx > 0 && strings.EqualFold(it[:idx], key) {
+				return it[idx+1:]
+			}
+		}
+	}
+	return ""
+}
+
+/*
+	Replace all values of a key, an empty value removes the key
+*/
+func (v *vorbisComment) set(key string, value string) {
+	out := []string{}
+	for _, it := range v.comments {
+		if idx := strings.IndexByte(it, '='); idx > 0 && strings.EqualFold(it[:idx], key) {
+			continue
+		}
+		out = append(out, it)
+	}
+	if value != "" {
+		out = append(out, key+"="+value)
+	}
+	v.comments = out
+}
+
+func (v *vorbisComment) read(meta *metadata) {
+	meta.title = v.get("TITLE")
+	meta.artist = v.get("ARTIST")
+	meta.album = v.get("ALBUM")
+	meta.year = parseYear(v.get("DATE", "YEAR"))
+	meta.genre = v.get("GENRE")
+	meta.comment = v.get("COMMENT", "DESCRIPTION")
+	meta.bpm = parseBPM(v.get("BPM", "TEMPO"))
+
+	if key := v.get("INITIALKEY", "KEY"); key != "" {
+		meta.key, _ = music.ParseKey(key)
+	}
+
+	if rating, ok := parseFmpsRating(v.get("FMPS_RATING")); ok {
+		meta.rating = rating
+	} else if rating, ok := parsePercentRating(v.get("RATING")); ok {
+		meta.rating = rating
+	}
+}
+
+func (v *vorbisComment) write(meta *metadata, field tagField) {
+	switch field {
+	case ratingField:
+		v.set("FMPS_RATING", formatFmpsRating(meta.rating))
+		v.set("RATING", formatPercentRating(meta.rating))
+	case bpmField:
+		v.set("BPM", formatBPM(meta.bpm))
+	case keyField:
+		key := ""
+		if meta.key.Valid() {
+			key = meta.key.String()
+		}
+		v.set("INITIALKEY", key)
+	case genreField:
+		v.set("GENRE", meta.genre)
+	case commentField:
+		v.set("COMMENT", meta.comment)
+	}
+}
@@ -2,14 +2,11 @@ package files
 
 import (
 	"encoding/json"
-	"math/big"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/bogem/id3v2"
 	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -20,18 +17,13 @@ import (
 )
 
 type Track struct {
+	metadata
 	path   string
-	title  string
-	album  string
-	artist string
-	rating music.Rating
-	year   int
+	hash   string
 	mutex  sync.Mutex
 	loaded bool
 }
 
-const TracktorEmail = "traktor@native-instruments.de"
-
 func newTrack(path string) *Track {
 	return &Track{
 		path: path,
@@ -53,58 +45,58 @@ func (t *Track) Rating() music.Rating {
 }
 
 func (t *Track) SetRating(rating music.Rating) error {
-	tags, err := id3v2.Open(t.path, id3v2.Options{
-		Parse: true,
+	return t.writeField(ratingField, func(meta *metadata) {
+		meta.rating = rating
 	})
-	if err != nil {
-		logrus.Errorf("fail to open id3 tags for file %s: %v", t.path, err)
-	}
-	defer tags.Close()
+}
 
-	var popframe *id3v2.PopularimeterFrame
+func (t *Track) BPM() float64 {
+	t.readMetadata()
+	return t.bpm
+}
 
-	for _, frame := range tags.GetFrames("POPM") {
-		if popm, ok := frame.(id3v2.PopularimeterFrame); ok {
-			if popm.Email == TracktorEmail {
-				popframe = &popm
-			}
-		}
-	}
-	if popframe == nil {
-		popframe = &id3v2.PopularimeterFrame{
-			Email: TracktorEmail,
-			Counter: &big.Int{},
-		}
-	}
-	popframe.Rating = uint8(rating) * 51
-	tags.AddFrame("POPM", popframe)
-
-	tags.SetVersion(4)
-	enc := tags.DefaultEncoding()
-
-	for id, framer := range tags.AllFrames() {
-		for _, it := range framer {
-			switch frame := it.(type) {
-			case id3v2.UnsynchronisedLyricsFrame:
-				frame.Encoding = enc
-				tags.AddUnsynchronisedLyricsFrame(frame)
-			case id3v2.UserDefinedTextFrame:
-				// fmt.Printf("USER:%s: %s\n", id, frame.Value)
-				frame.Encoding = enc
-				tags.AddFrame(id, frame)
-			case id3v2.CommentFrame:
-				// fmt.Printf("COMMENT:%s: %s\n", id, frame.Text)
-				frame.Encoding = enc
-				tags.AddCommentFrame(frame)
-			case id3v2.TextFrame:
-				// fmt.Printf("TEXT:%s: %s\n", id, frame.Text)
-				frame.Encoding = enc
-				tags.AddTextFrame(id, enc, frame.Text)
-			}
-		}
-	}
+func (t *Track) SetBPM(bpm float64) error {
+	return t.writeField(bpmField, func(meta *metadata) {
+		meta.bpm = bpm
+	})
+}
+
+func (t *Track) Key() music.Key {
+	t.readMetadata()
+	return t.key
+}
 
-	return tags.Save()
+func (t *Track) SetKey(key music.Key) error {
+	return t.writeField(keyField, func(meta *metadata) {
+		meta.key = key
+	})
+}
+
+func (t *Track) Genre() string {
+	t.readMetadata()
+	return t.genre
+}
+
+func (t *Track) SetGenre(genre string) error {
+	return t.writeField(genreField, func(meta *metadata) {
+		meta.genre = genre
+	})
+}
+
+func (t *Track) Comment() string {
+	t.readMetadata()
+	return t.comment
+}
+
+func (t *Track) SetComment(comment string) error {
+	return t.writeField(commentField, func(meta *metadata) {
+		meta.comment = comment
+	})
+}
+
+func (t *Track) Duration() time.Duration {
+	t.readMetadata()
+	return t.duration
 }
 
 func (t *Track) Modified() time.Time {
@@ -123,15 +115,22 @@ func (t *Track) Added() time.Time {
 		logrus.Errorf("failed to get creation time for file %s: %v", t.path, err)
 		return time.Time{}
 	}
+	if !tim.HasBirthTime() {
+		// not all filesystems keep the creation time
+		return tim.ModTime()
+	}
 	return tim.BirthTime()
 }
 
+/*
+	Files doesn't keep any play count
+*/
 func (t *Track) PlayCount() int {
-	panic("Playcount not implemented for files")
+	return 0
 }
 
 func (t *Track) SetPlayCount(count int) error {
-	panic("Playcount not implemented for files")
+	return errors.New("SetPlayCount operation is not supported for files")
 }
 
 func (t *Track) FilePath() string {
@@ -162,6 +161,7 @@ func (t *Track) Size() int64 {
 	st, err := os.Stat(t.path)
 	if err != nil {
 		logrus.Errorf("could not read stat for file '%s': %v", t.path, err)
+		return 0
 	}
 	return st.Size()
 }
@@ -178,6 +178,55 @@ func (t *Track) MarshalTOML() ([]byte, error) {
 	return toml.Marshal(music.NewMarchalTrack(t))
 }
 
+/*
+	Apply the modification to a copy of the metadata and write the field in the
+	tags of the file, the track is only updated if the write succeed
+*/
+func (t *Track) writeField(field tagField, update func(meta *metadata)) error {
+	format := formatOf(t.path)
+	if format == nil {
+		return errors.Errorf("tags of file '%s' are not supported", t.path)
+	}
+
+	t.readMetadata()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	meta := t.metadata
+	update(&meta)
+	if err := format.write(t.path, &meta, field); err != nil {
+		return err
+	}
+	t.metadata = meta
+
+	if stat, err := os.Stat(t.path); err == nil {
+		t.hash = storeIndex(t.path, stat, &meta)
+	}
+	return nil
+}
+
+/*
+	Same as music.TrackHash, without reading the tags when the file is indexed
+*/
+func (t *Track) metaHash() string {
+	t.readMetadata()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.hash == "" {
+		t.hash = music.MetaHash(t.title, t.album, t.artist, t.year)
+	}
+	return t.hash
+}
+
+/*
+	Read the tags of the file now instead of on the first access
+*/
+func (t *Track) Load() {
+	t.readMetadata()
+}
+
 func (t *Track) readMetadata() {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
@@ -187,43 +236,28 @@ func (t *Track) readMetadata() {
 	}
 	t.loaded = true
 
-	tags, err := id3v2.Open(t.path, id3v2.Options{
-		Parse: true,
-		ParseFrames: []string{
-			"Title", "Artist", "Year", "Genre", "POPM", "Album", "TALB",
-		},
-	})
-	if err != nil {
-		logrus.Warnf("could not open id3 tags for file '%s': %v", t.path, err)
-		return
-	}
-	defer tags.Close()
-
-	if !tags.HasFrames() {
-		logrus.Warnf("file '%s' doesn't have any id3 meta data", t.path)
+	format := formatOf(t.path)
+	if format == nil {
+		logrus.Warnf("tags of file '%s' are not supported", t.path)
 		return
 	}
 
-	t.title = tags.Title()
-	t.album = tags.Album()
-	t.artist = tags.Artist()
-	if t.title == "" {
-		logrus.Warnf("file '%s' doesn't have any id3 title data", t.path)
+	stat, err := os.Stat(t.path)
+	if err == nil {
+		if meta, hash, ok := lookupIndex(t.path, stat); ok {
+			t.metadata, t.hash = meta, hash
+			return
+		}
 	}
 
-	for _, frame := range tags.GetFrames("POPM") {
-		if popm, ok := frame.(id3v2.PopularimeterFrame); ok {
-			if popm.Email == TracktorEmail {
-				t.rating = music.Rating(popm.Rating / 51)
-			}
-		}
+	meta := metadata{}
+	if err := format.read(t.path, &meta); err != nil {
+		logrus.Warnf("could not read tags for file '%s': %v", t.path, err)
+		return
 	}
+	t.metadata = meta
 
-	yearstr := tags.Year()
-	if yearstr != "" && len(yearstr) >= 4 {
-		t.year, err = strconv.Atoi(yearstr[:4])
-		if err != nil {
-			logrus.Errorf("could not parse year tags in file '%s': %v", t.path, err)
-		}
+	if stat != nil {
+		t.hash = storeIndex(t.path, stat, &meta)
 	}
 }
@@ -8,6 +8,6 @@ import (
 	"github.com/pkg/errors"
 )
 
-func (t Track) SetAdded(added time.Time) error {
+func (t *Track) SetAdded(added time.Time) error {
 	return errors.New("not implemented")
 }
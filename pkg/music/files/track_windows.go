@@ -8,7 +8,7 @@ import (
 	"github.com/pkg/errors"
 )
 
-func (t Track) SetAdded(added time.Time) error {
+func (t *Track) SetAdded(added time.Time) error {
 	fd, err := syscall.Open(t.path, os.O_RDWR, 0755)
 	if err != nil {
 		return errors.Wrapf(err, "could not open file %s", t.path)
@@ -22,6 +22,7 @@ type Library struct {
 	trackById       map[int]*itl.Track
 	playlistPerId   map[string]*itl.Playlist
 	metaHashes      map[string]*Track
+	contentIndex    music.ContentIndex
 	writer          itunes_writer
 	info            string
 	mutex           sync.Mutex
@@ -193,6 +194,11 @@ func (i *Library) Matches(track music.Track) (matches music.Tracks) {
 		matches = append(matches, found)
 	}
 
+	// the metadata might have been edited since, fallback on the audio content
+	if len(matches) == 0 {
+		matches = append(matches, i.contentIndex.Matches(i, track)...)
+	}
+
 	return matches.Dedupe()
 }
 
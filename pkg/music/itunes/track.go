@@ -68,6 +68,42 @@ func (t *Track) SetPlayCount(count int) error {
 	return t.lib.getCreateWriter().setPlayCount(t.itrack.PersistentID, count)
 }
 
+func (t *Track) BPM() float64 {
+	return float64(t.itrack.BPM)
+}
+
+func (t *Track) SetBPM(bpm float64) error {
+	return errors.New("cannot set bpm in iTunes")
+}
+
+func (t *Track) Key() music.Key {
+	return music.KeyUnknown
+}
+
+func (t *Track) SetKey(key music.Key) error {
+	return errors.New("iTunes doesn't have musical key")
+}
+
+func (t *Track) Genre() string {
+	return html.UnescapeString(t.itrack.Genre)
+}
+
+func (t *Track) SetGenre(genre string) error {
+	return errors.New("cannot set genre in iTunes")
+}
+
+func (t *Track) Comment() string {
+	return html.UnescapeString(t.itrack.Comments)
+}
+
+func (t *Track) SetComment(comment string) error {
+	return errors.New("cannot set comment in iTunes")
+}
+
+func (t *Track) Duration() time.Duration {
+	return time.Duration(t.itrack.TotalTime) * time.Millisecond
+}
+
 func (t *Track) Title() string {
 	return html.UnescapeString(t.itrack.Name)
 }
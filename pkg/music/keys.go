@@ -0,0 +1,202 @@
+package music
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	Musical key of a track, zero value means the key is unknown.
+
+	Internally the key is stored as 1 + pitch class (C = 0 ... B = 11), offset by 12 for
+	minor keys.
+*/
+type Key int
+
+const (
+	KeyUnknown = Key(0)
+)
+
+var (
+	keyNames      = []string{"C", "Db", "D", "Eb", "E", "F", "F#", "G", "Ab", "A", "Bb", "B"}
+	keyMinorNames = []string{"Cm", "C#m", "Dm", "Ebm", "Em", "Fm", "F#m", "Gm", "G#m", "Am", "Bbm", "Bm"}
+
+	keyAliases = map[string]int{
+		"c": 0, "b#": 0,
+		"c#": 1, "db": 1,
+		"d":  2,
+		"d#": 3, "eb": 3,
+		"e": 4, "fb": 4,
+		"f": 5, "e#": 5,
+		"f#": 6, "gb": 6,
+		"g":  7,
+		"g#": 8, "ab": 8,
+		"a":  9,
+		"a#": 10, "bb": 10,
+		"b": 11, "cb": 11,
+	}
+)
+
+func NewKey(pitch int, minor bool) Key {
+	pitch = ((pitch % 12) + 12) % 12
+	if minor {
+		pitch += 12
+	}
+	return Key(pitch + 1)
+}
+
+/*
+	Parse a key written in standard (Am, F#, Dbm), Camelot (8A) or Open Key (1m) notation
+*/
+func ParseKey(value string) (Key, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return KeyUnknown, nil
+	}
+
+	lower := strings.ToLower(value)
+	suffix := lower[len(lower)-1:]
+
+	// camelot & open key notation, ie: 8A, 12b, 1m, 6d
+	if num, err := strconv.Atoi(lower[:len(lower)-1]); err == nil && num >= 1 && num <= 12 {
+		switch suffix {
+		case "d":
+			return keyFromFifths(num-1, false), nil
+		case "m":
+			return keyFromFifths(num-1, true), nil
+		case "b":
+			return keyFromFifths(num+4, false), nil
+		case "a":
+			return keyFromFifths(num+4, true), nil
+		}
+	}
+
+	minor := false
+	switch {
+	case strings.HasSuffix(lower, "min"):
+		minor = true
+		lower = strings.TrimSuffix(lower, "min")
+	case strings.HasSuffix(lower, "maj"):
+		lower = strings.TrimSuffix(lower, "maj")
+	case strings.HasSuffix(lower, "m"):
+		minor = true
+		lower = strings.TrimSuffix(lower, "m")
+	}
+	lower = strings.Replace(lower, "♯", "#", -1)
+	lower = strings.Replace(lower, "♭", "b", -1)
+
+	if pitch, ok := keyAliases[strings.TrimSpace(lower)]; ok {
+		return NewKey(pitch, minor), nil
+	}
+
+	return KeyUnknown, fmt.Errorf("'%s' is not a valid musical key", value)
+}
+
+/*
+	Construct a key from its position in the circle of fifths where C/Am is 0,
+	which is the open key number minus one
+*/
+func keyFromFifths(steps int, minor bool) Key {
+	pitch := (steps * 7) % 12
+	if minor {
+		pitch += 9
+	}
+	return NewKey(pitch, minor)
+}
+
+func (k Key) Valid() bool {
+	return k > KeyUnknown && k <= Key(24)
+}
+
+func (k Key) Pitch() int {
+	return (int(k) - 1) % 12
+}
+
+func (k Key) IsMinor() bool {
+	return k.Valid() && int(k) > 12
+}
+
+/*
+	Position in the circle of fifths, starting with C/Am at 0
+*/
+func (k Key) fifths() int {
+	pitch := k.Pitch()
+	if k.IsMinor() {
+		pitch += 3
+	}
+	return (pitch * 7) % 12
+}
+
+/*
+	Key in Open Key notation (ie: 1d, 1m)
+*/
+func (k Key) OpenKey() string {
+	if !k.Valid() {
+		return ""
+	}
+	if k.IsMinor() {
+		return fmt.Sprintf("%dm", k.fifths()+1)
+	}
+	return fmt.Sprintf("%dd", k.fifths()+1)
+}
+
+/*
+	Key in Camelot notation (ie: 8B, 8A)
+*/
+func (k Key) Camelot() string {
+	if !k.Valid() {
+		return ""
+	}
+	num := (k.fifths()+7)%12 + 1
+	if k.IsMinor() {
+		return fmt.Sprintf("%dA", num)
+	}
+	return fmt.Sprintf("%dB", num)
+}
+
+/*
+	Index used by Engine PRIME and Engine DJ databases, which follows the circle of
+	fifths with major keys at even position and their relative minor right after
+	(0 = C, 1 = Am, 2 = G, 3 = Em, ...)
+*/
+func (k Key) Engine() int {
+	if !k.Valid() {
+		return -1
+	}
+	idx := k.fifths() * 2
+	if k.IsMinor() {
+		idx++
+	}
+	return idx
+}
+
+func KeyFromEngine(value int) Key {
+	if value < 0 || value > 23 {
+		return KeyUnknown
+	}
+	return keyFromFifths(value/2, value%2 == 1)
+}
+
+func (k Key) String() string {
+	if !k.Valid() {
+		return ""
+	}
+	if k.IsMinor() {
+		return keyMinorNames[k.Pitch()]
+	}
+	return keyNames[k.Pitch()]
+}
+
+func (k Key) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *Key) UnmarshalText(text []byte) error {
+	key, err := ParseKey(string(text))
+	if err != nil {
+		return err
+	}
+	*k = key
+	return nil
+}
@@ -0,0 +1,21 @@
+package music
+
+/*
+	Library which finds and removes what refers to something which doesn't
+	exist anymore. Each check returns a description of what it found, which is
+	only removed when fix is set.
+*/
+type LibraryCleaner interface {
+	Library
+
+	// remove the tracks and their playlist and crate entries
+	RemoveTracks(tracks Tracks) error
+
+	// playlist and crate entries of deleted tracks
+	BrokenEntries(fix bool) ([]string, error)
+	// playlists and crates without any track, folders without any child
+	EmptyLists(fix bool) ([]string, error)
+	// tracks referencing a database which isn't attached, the drive might
+	// only be unplugged so only the ones of the given databases are detached
+	DetachedTracks(detach []string) ([]string, error)
+}
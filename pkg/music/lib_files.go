@@ -0,0 +1,11 @@
+package music
+
+/*
+	Library which keeps its data in files that can be backed up before writing
+*/
+type LibraryFiles interface {
+	Library
+
+	// return the files written by this library, some might not exist yet
+	DatabaseFiles() []string
+}
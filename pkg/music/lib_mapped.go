@@ -0,0 +1,238 @@
+package music
+
+import (
+	"github.com/pkg/errors"
+
+	"primetools/pkg/files"
+)
+
+/*
+	Library whose track lookups and moves go through path rewrite rules, so
+	paths of another machine or drive are found in it. The paths of its own
+	tracks are unchanged.
+
+	It forwards every optional interface, the ones the wrapped library really
+	implements are found with AsEditor, AsMerger, AsCleaner, AsRelocator and
+	AsFiles.
+*/
+type MappedLibrary struct {
+	Library
+	paths files.PathMap
+}
+
+type mappedTrack struct {
+	Track
+	path string
+}
+
+/*
+	Wrap the library when there is any rule, otherwise return it as is
+*/
+func MapPaths(lib Library, paths files.PathMap) Library {
+	if len(paths) == 0 {
+		return lib
+	}
+	return &MappedLibrary{
+		Library: lib,
+		paths:   paths,
+	}
+}
+
+/*
+	The wrapped library
+*/
+func (m *MappedLibrary) Unwrap() Library {
+	return m.Library
+}
+
+func (m *MappedLibrary) Track(filename string) Track {
+	return m.Library.Track(m.paths.Apply(filename))
+}
+
+func (m *MappedLibrary) Matches(track Track) Tracks {
+	if track == nil {
+		return nil
+	}
+	return m.Library.Matches(&mappedTrack{Track: track, path: m.paths.Apply(track.FilePath())})
+}
+
+func (m *MappedLibrary) AddFile(path string) (Track, error) {
+	editor, err := m.editor("AddFile")
+	if err != nil {
+		return nil, err
+	}
+	return editor.AddFile(m.paths.Apply(path))
+}
+
+func (m *MappedLibrary) CreatePlaylist(path string) (Tracklist, error) {
+	editor, err := m.editor("CreatePlaylist")
+	if err != nil {
+		return nil, err
+	}
+	return editor.CreatePlaylist(path)
+}
+
+func (m *MappedLibrary) CreateCrate(path string) (Tracklist, error) {
+	editor, err := m.editor("CreateCrate")
+	if err != nil {
+		return nil, err
+	}
+	return editor.CreateCrate(path)
+}
+
+func (m *MappedLibrary) MoveTrack(track Track, newpath string) error {
+	editor, err := m.editor("MoveTrack")
+	if err != nil {
+		return err
+	}
+	return editor.MoveTrack(track, m.paths.Apply(newpath))
+}
+
+func (m *MappedLibrary) MoveTracks(moves []TrackMove) ([]TrackMove, error) {
+	editor, err := m.editor("MoveTracks")
+	if err != nil {
+		return nil, err
+	}
+	mapped := make([]TrackMove, len(moves))
+	originals := map[Track]TrackMove{}
+	for i, it := range moves {
+		mapped[i] = TrackMove{Track: it.Track, Path: m.paths.Apply(it.Path)}
+		originals[it.Track] = it
+	}
+
+	// the moves done are reported with their path before the rewrite
+	done, err := MoveTracks(editor, mapped)
+	moved := make([]TrackMove, len(done))
+	for i, it := range done {
+		moved[i] = originals[it.Track]
+	}
+	return moved, err
+}
+
+func (m *MappedLibrary) MergeTracks(survivor Track, duplicates Tracks) error {
+	merger, ok := m.Library.(LibraryMerger)
+	if !ok {
+		return errors.Errorf("MergeTracks operation is not supported for %s", m.Library)
+	}
+	return merger.MergeTracks(survivor, duplicates)
+}
+
+func (m *MappedLibrary) RemoveTracks(tracks Tracks) error {
+	cleaner, err := m.cleaner("RemoveTracks")
+	if err != nil {
+		return err
+	}
+	return cleaner.RemoveTracks(tracks)
+}
+
+func (m *MappedLibrary) BrokenEntries(fix bool) ([]string, error) {
+	cleaner, err := m.cleaner("BrokenEntries")
+	if err != nil {
+		return nil, err
+	}
+	return cleaner.BrokenEntries(fix)
+}
+
+func (m *MappedLibrary) EmptyLists(fix bool) ([]string, error) {
+	cleaner, err := m.cleaner("EmptyLists")
+	if err != nil {
+		return nil, err
+	}
+	return cleaner.EmptyLists(fix)
+}
+
+func (m *MappedLibrary) DetachedTracks(detach []string) ([]string, error) {
+	cleaner, err := m.cleaner("DetachedTracks")
+	if err != nil {
+		return nil, err
+	}
+	return cleaner.DetachedTracks(detach)
+}
+
+func (m *MappedLibrary) SupportedExtensions() FileExtensions {
+	if editor, ok := m.Library.(LibraryEditor); ok {
+		return editor.SupportedExtensions()
+	}
+	return nil
+}
+
+func (m *MappedLibrary) DatabaseFiles() []string {
+	if lib, ok := m.Library.(LibraryFiles); ok {
+		return lib.DatabaseFiles()
+	}
+	return nil
+}
+
+func (m *MappedLibrary) editor(operation string) (LibraryEditor, error) {
+	editor, ok := m.Library.(LibraryEditor)
+	if !ok {
+		return nil, errors.Errorf("%s operation is not supported for %s", operation, m.Library)
+	}
+	return editor, nil
+}
+
+func (m *MappedLibrary) cleaner(operation string) (LibraryCleaner, error) {
+	cleaner, ok := m.Library.(LibraryCleaner)
+	if !ok {
+		return nil, errors.Errorf("%s operation is not supported for %s", operation, m.Library)
+	}
+	return cleaner, nil
+}
+
+func (t *mappedTrack) FilePath() string {
+	return t.path
+}
+
+/*
+	The library beneath the path rewrite rules, whose interfaces tell what is
+	supported
+*/
+func unwrap(lib Library) Library {
+	for {
+		wrapper, ok := lib.(interface{ Unwrap() Library })
+		if !ok {
+			return lib
+		}
+		lib = wrapper.Unwrap()
+	}
+}
+
+func AsEditor(lib Library) (LibraryEditor, bool) {
+	if _, ok := unwrap(lib).(LibraryEditor); !ok {
+		return nil, false
+	}
+	editor, ok := lib.(LibraryEditor)
+	return editor, ok
+}
+
+func AsMerger(lib Library) (LibraryMerger, bool) {
+	if _, ok := unwrap(lib).(LibraryMerger); !ok {
+		return nil, false
+	}
+	merger, ok := lib.(LibraryMerger)
+	return merger, ok
+}
+
+func AsCleaner(lib Library) (LibraryCleaner, bool) {
+	if _, ok := unwrap(lib).(LibraryCleaner); !ok {
+		return nil, false
+	}
+	cleaner, ok := lib.(LibraryCleaner)
+	return cleaner, ok
+}
+
+func AsRelocator(lib Library) (LibraryRelocator, bool) {
+	if _, ok := unwrap(lib).(LibraryRelocator); !ok {
+		return nil, false
+	}
+	relocator, ok := lib.(LibraryRelocator)
+	return relocator, ok
+}
+
+func AsFiles(lib Library) (LibraryFiles, bool) {
+	if _, ok := unwrap(lib).(LibraryFiles); !ok {
+		return nil, false
+	}
+	files, ok := lib.(LibraryFiles)
+	return files, ok
+}
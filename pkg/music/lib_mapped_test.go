@@ -0,0 +1,88 @@
+package music
+
+import (
+	"errors"
+	"testing"
+
+	"primetools/pkg/files"
+)
+
+type readOnlyLibrary struct{}
+
+func (readOnlyLibrary) Close()                           {}
+func (readOnlyLibrary) Track(filename string) Track      { return nil }
+func (readOnlyLibrary) Matches(track Track) Tracks       { return nil }
+func (readOnlyLibrary) Playlists() []Tracklist           { return nil }
+func (readOnlyLibrary) Crates() []Tracklist              { return nil }
+func (readOnlyLibrary) ForEachTrack(EachTrackFunc) error { return nil }
+func (readOnlyLibrary) String() string                   { return "read only" }
+
+type editorLibrary struct {
+	readOnlyLibrary
+	moved map[string]string
+}
+
+func (l *editorLibrary) AddFile(path string) (Track, error)            { return nil, nil }
+func (l *editorLibrary) CreatePlaylist(path string) (Tracklist, error) { return nil, nil }
+func (l *editorLibrary) CreateCrate(path string) (Tracklist, error)    { return nil, nil }
+func (l *editorLibrary) SupportedExtensions() FileExtensions           { return nil }
+func (l *editorLibrary) MoveTrack(track Track, newpath string) error {
+	if newpath == "/to/missing.mp3" {
+		return errors.New("missing")
+	}
+	l.moved[track.FilePath()] = newpath
+	return nil
+}
+
+func TestMappedCapabilities(t *testing.T) {
+	paths, err := files.ParsePathMap([]string{"/from=/to"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lib := MapPaths(readOnlyLibrary{}, paths)
+	if _, ok := lib.(*MappedLibrary); !ok {
+		t.Fatal("library isn't wrapped")
+	}
+	if _, ok := AsEditor(lib); ok {
+		t.Error("read only library is an editor")
+	}
+	if _, ok := AsMerger(lib); ok {
+		t.Error("read only library is a merger")
+	}
+	if _, ok := AsCleaner(lib); ok {
+		t.Error("read only library is a cleaner")
+	}
+	if _, ok := AsFiles(lib); ok {
+		t.Error("read only library has database files")
+	}
+
+	editor := &editorLibrary{moved: map[string]string{}}
+	lib = MapPaths(editor, paths)
+	mapped, ok := AsEditor(lib)
+	if !ok {
+		t.Fatal("editor library isn't an editor")
+	}
+	if _, ok := AsRelocator(lib); ok {
+		t.Error("editor library is a relocator")
+	}
+
+	// moves go one by one through the rewrite rules until the first failure
+	moved, err := MoveTracks(mapped, []TrackMove{
+		{Track: &mappedTrack{path: "/to/a.mp3"}, Path: "/from/b.mp3"},
+		{Track: &mappedTrack{path: "/to/c.mp3"}, Path: "/from/missing.mp3"},
+		{Track: &mappedTrack{path: "/to/d.mp3"}, Path: "/from/e.mp3"},
+	})
+	if err == nil {
+		t.Error("failing move isn't reported")
+	}
+	if len(moved) != 1 || moved[0].Path != "/from/b.mp3" {
+		t.Errorf("moves done are %+v instead of the first one", moved)
+	}
+	if editor.moved["/to/a.mp3"] != "/to/b.mp3" {
+		t.Errorf("track moved to '%s' instead of '/to/b.mp3'", editor.moved["/to/a.mp3"])
+	}
+	if _, ok := editor.moved["/to/d.mp3"]; ok {
+		t.Error("track moved after the failure")
+	}
+}
@@ -0,0 +1,12 @@
+package music
+
+/*
+	Library which folds duplicate tracks into one, the playlist and crate
+	entries of the duplicates point to the surviving track before the
+	duplicates are removed
+*/
+type LibraryMerger interface {
+	Library
+
+	MergeTracks(survivor Track, duplicates Tracks) error
+}
@@ -0,0 +1,39 @@
+package music
+
+/*
+	New path of a track
+*/
+type TrackMove struct {
+	Track Track
+	Path  string
+}
+
+/*
+	Library editor which moves many tracks at once, either all of them are
+	moved or none. A library of many databases (ie: the drives attached to
+	PRIME or Engine DJ) commits each of them on its own, so a commit failing
+	on one database doesn't undo the ones already committed. Returns the moves
+	committed, even on failure.
+*/
+type LibraryRelocator interface {
+	LibraryEditor
+
+	MoveTracks(moves []TrackMove) ([]TrackMove, error)
+}
+
+/*
+	Move the tracks at once when the library supports it, otherwise one by one
+	stopping at the first failure. Returns the moves done, in the order of the
+	list.
+*/
+func MoveTracks(lib LibraryEditor, moves []TrackMove) ([]TrackMove, error) {
+	if relocator, ok := AsRelocator(lib); ok {
+		return relocator.MoveTracks(moves)
+	}
+	for idx, it := range moves {
+		if err := lib.MoveTrack(it.Track, it.Path); err != nil {
+			return moves[:idx], err
+		}
+	}
+	return moves, nil
+}
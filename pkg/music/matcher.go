@@ -0,0 +1,356 @@
+package music
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+)
+
+const (
+	// default minimum score of a fuzzy match
+	DefaultMatchThreshold = 0.8
+
+	// candidates closer than this to the best score are considered a tie
+	tieMargin = 0.01
+)
+
+var (
+	originalRegex = regexp.MustCompile(`[(\[]\s*original(\s+(mix|version|edit))?\s*[)\]]|\s-\s*original(\s+(mix|version|edit))?\s*$`)
+	featRegex     = regexp.MustCompile(`\b(featuring|feat|ft)\b\.?`)
+	remixRegex    = regexp.MustCompile(`\b(rmx|remixed)\b`)
+	andRegex      = regexp.MustCompile(`\s*&\s*|\s+\+\s+`)
+)
+
+type fieldWeight struct {
+	name   string
+	weight float64
+}
+
+var matchWeights = []fieldWeight{
+	{"title", 0.45},
+	{"artist", 0.35},
+	{"album", 0.1},
+	{"year", 0.1},
+}
+
+/*
+	Fuzzy match of tracks on their metadata, unlike Matches of the libraries it
+	is not an exact hash so "Artist feat. X" matches "Artist ft. X" and
+	"Title (Original Mix)" matches "Title".
+*/
+type Matcher struct {
+	// minimum score between 0 and 1 of a candidate
+	Threshold float64
+
+	lib    Library
+	once   sync.Once
+	tracks []normalized
+}
+
+type normalized struct {
+	track  Track
+	title  string
+	artist string
+	album  string
+}
+
+/*
+	Candidate track with its score and an explanation of the score
+*/
+type Candidate struct {
+	Track   Track
+	Score   float64
+	Reasons []string
+}
+
+type Candidates []Candidate
+
+/*
+	Match against all the tracks of the library, they are only loaded on the
+	first search
+*/
+func NewMatcher(lib Library, threshold float64) *Matcher {
+	return &Matcher{
+		Threshold: threshold,
+		lib:       lib,
+	}
+}
+
+/*
+	Normalized form of a title, artist or album: lower case without accents or
+	punctuation, "ft." and "featuring" are "feat" and "(Original Mix)" is removed
+*/
+func Normalize(value string) string {
+	value = strings.ToLower(files.RemoveAccent(value))
+	value = originalRegex.ReplaceAllString(value, "")
+	value = featRegex.ReplaceAllString(value, " feat ")
+	value = remixRegex.ReplaceAllString(value, "remix")
+	value = andRegex.ReplaceAllString(value, " and ")
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, value)
+	return strings.Join(strings.Fields(value), " ")
+}
+
+func normalize(track Track) normalized {
+	out := normalized{
+		track:  track,
+		title:  Normalize(track.Title()),
+		artist: Normalize(track.Artist()),
+		album:  Normalize(track.Album()),
+	}
+
+	// the featured artists are either in the title or the artist
+	if idx := strings.Index(" "+out.title+" ", " feat "); idx >= 0 {
+		featured := strings.TrimSpace(out.title[idx:])
+		out.title = strings.TrimSpace(out.title[:idx])
+		if !strings.Contains(out.artist, featured) {
+			out.artist = strings.TrimSpace(out.artist + " " + featured)
+		}
+	}
+	return out
+}
+
+/*
+	Score how likely both tracks are the same, between 0 and 1
+*/
+func (m *Matcher) Score(track Track, other Track) Candidate {
+	return m.score(normalize(track), normalize(other))
+}
+
+/*
+	Tracks of the library scoring at least the threshold, best first. Equal
+	scores are ordered by their difference in duration then file size.
+*/
+func (m *Matcher) Match(track Track) (candidates Candidates) {
+	m.once.Do(m.load)
+
+	left := normalize(track)
+	for _, it := range m.tracks {
+		if m.bound(left, it) < m.Threshold {
+			continue
+		}
+		if c := m.score(left, it); c.Score >= m.Threshold {
+			candidates = append(candidates, c)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if math.Abs(a.Score-b.Score) > tieMargin {
+			return a.Score > b.Score
+		}
+		if da, db := durationDelta(track, a.Track), durationDelta(track, b.Track); da != db {
+			// unknown durations last
+			return db < 0 || (da >= 0 && da < db)
+		}
+		return sizeDelta(track, a.Track) < sizeDelta(track, b.Track)
+	})
+	return
+}
+
+func (m *Matcher) load() {
+	start := time.Now()
+	logrus.Infof("loading tracks of %s for fuzzy matching", m.lib)
+
+	err := m.lib.ForEachTrack(func(index int, total int, track Track) error {
+		m.tracks = append(m.tracks, normalize(track))
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("%v", err)
+	}
+	logrus.Infof("loaded %d tracks in %v", len(m.tracks), time.Since(start))
+}
+
+func (m *Matcher) score(left normalized, right normalized) Candidate {
+	c := Candidate{Track: right.track}
+
+	total, weights := 0.0, 0.0
+	add := func(name string, weight float64, score float64, reason string) {
+		total += weight * score
+		weights += weight
+		c.Reasons = append(c.Reasons, fmt.Sprintf("%s %s", name, reason))
+	}
+
+	for _, it := range matchWeights {
+		switch it.name {
+		case "year":
+			ly, ry := left.track.Year(), right.track.Year()
+			if ly == 0 || ry == 0 {
+				continue
+			}
+			score := 0.0
+			switch ly - ry {
+			case 0:
+				score = 1
+			case -1, 1:
+				score = 0.5
+			}
+			add(it.name, it.weight, score, fmt.Sprintf("%d/%d", ly, ry))
+		default:
+			l, r := left.field(it.name), right.field(it.name)
+			if l == "" || r == "" {
+				continue
+			}
+			score := similarity(l, r)
+			add(it.name, it.weight, score, fmt.Sprintf("%.0f%%", 100*score))
+		}
+	}
+
+	if weights > 0 {
+		c.Score = total / weights
+	}
+
+	if delta := durationDelta(left.track, right.track); delta >= 0 {
+		c.Reasons = append(c.Reasons, fmt.Sprintf("duration ±%v", delta.Round(time.Second)))
+	}
+	return c
+}
+
+/*
+	Upper bound of the score, the edit distance is at least the difference
+	of length of the strings
+*/
+func (m *Matcher) bound(left normalized, right normalized) float64 {
+	total, weights := 0.0, 0.0
+	for _, it := range matchWeights {
+		if it.name == "year" {
+			if left.track.Year() != 0 && right.track.Year() != 0 {
+				total += it.weight
+				weights += it.weight
+			}
+			continue
+		}
+		l, r := utf8.RuneCountInString(left.field(it.name)), utf8.RuneCountInString(right.field(it.name))
+		if l == 0 || r == 0 {
+			continue
+		}
+		longest := math.Max(float64(l), float64(r))
+		total += it.weight * (1 - math.Abs(float64(l-r))/longest)
+		weights += it.weight
+	}
+	if weights == 0 {
+		return 0
+	}
+	return total / weights
+}
+
+func (n *normalized) field(name string) string {
+	switch name {
+	case "title":
+		return n.title
+	case "artist":
+		return n.artist
+	case "album":
+		return n.album
+	}
+	return ""
+}
+
+/*
+	Candidates with a score tied with the best one
+*/
+func (c Candidates) Ties() Candidates {
+	if len(c) == 0 {
+		return nil
+	}
+	out := Candidates{c[0]}
+	for _, it := range c[1:] {
+		if c[0].Score-it.Score <= tieMargin {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+func (c Candidates) Tracks() (tracks Tracks) {
+	for _, it := range c {
+		tracks = append(tracks, it.Track)
+	}
+	return
+}
+
+func (c Candidate) String() string {
+	return fmt.Sprintf("%s [%.0f%%: %s]", c.Track.FilePath(), 100*c.Score, strings.Join(c.Reasons, ", "))
+}
+
+/*
+	1 minus the edit distance relative to the longest string
+*/
+func similarity(a string, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a []rune, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	out := values[0]
+	for _, it := range values[1:] {
+		if it < out {
+			out = it
+		}
+	}
+	return out
+}
+
+/*
+	Absolute difference of duration, -1 if either is unknown
+*/
+func durationDelta(a Track, b Track) time.Duration {
+	da, db := a.Duration(), b.Duration()
+	if da == 0 || db == 0 {
+		return -1
+	}
+	if da > db {
+		return da - db
+	}
+	return db - da
+}
+
+func sizeDelta(a Track, b Track) int64 {
+	sa, sb := a.Size(), b.Size()
+	if sa > sb {
+		return sa - sb
+	}
+	return sb - sa
+}
@@ -0,0 +1,86 @@
+package mixxx
+
+import (
+	"database/sql"
+)
+
+/*
+CREATE TABLE library (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  artist varchar(64),
+  title varchar(64),
+  album varchar(64),
+  year varchar(16),
+  genre varchar(64),
+  tracknumber varchar(3),
+  location integer REFERENCES track_locations(location),
+  comment varchar(256),
+  url varchar(256),
+  duration float,
+  bitrate integer,
+  samplerate integer,
+  cuepoint integer,
+  bpm float,
+  wavesummaryhex blob,
+  channels integer,
+  datetime_added DEFAULT CURRENT_TIMESTAMP,
+  mixxx_deleted integer,
+  played integer,
+  header_parsed integer DEFAULT 0,
+  filetype varchar(8) DEFAULT "?",
+  replaygain float DEFAULT 0,
+  timesplayed integer DEFAULT 0,
+  rating integer DEFAULT 0,
+  key varchar(8) DEFAULT "",
+  ...
+  key_id INTEGER DEFAULT 0,
+  ...
+)
+*/
+type trackEntry struct {
+	Id          int             `db:"id"`
+	Title       sql.NullString  `db:"title"`
+	Artist      sql.NullString  `db:"artist"`
+	Album       sql.NullString  `db:"album"`
+	Year        sql.NullString  `db:"year"`
+	Genre       sql.NullString  `db:"genre"`
+	Comment     sql.NullString  `db:"comment"`
+	Duration    sql.NullFloat64 `db:"duration"`
+	BPM         sql.NullFloat64 `db:"bpm"`
+	Key         sql.NullString  `db:"key"`
+	KeyId       sql.NullInt32   `db:"key_id"`
+	Rating      sql.NullInt32   `db:"rating"`
+	TimesPlayed sql.NullInt32   `db:"timesplayed"`
+	Added       sql.NullString  `db:"datetime_added"`
+	LocationId  sql.NullInt32   `db:"location"`
+
+	// from track_locations
+	Path sql.NullString `db:"path"`
+	Size sql.NullInt64  `db:"filesize"`
+}
+
+/*
+CREATE TABLE Playlists (
+  id INTEGER PRIMARY KEY,
+  name varchar(48),
+  position INTEGER,
+  hidden INTEGER DEFAULT 0 NOT NULL,
+  date_created datetime,
+  date_modified datetime,
+  locked integer DEFAULT 0
+)
+
+CREATE TABLE crates (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  name varchar(48) UNIQUE NOT NULL,
+  count integer DEFAULT 0,
+  show integer DEFAULT 1,
+  locked integer DEFAULT 0,
+  autodj_source integer DEFAULT 0
+)
+*/
+type listEntry struct {
+	Id     int            `db:"id"`
+	Name   sql.NullString `db:"name"`
+	Locked sql.NullBool   `db:"locked"`
+}
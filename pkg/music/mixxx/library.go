@@ -0,0 +1,398 @@
+package mixxx
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+	flib "primetools/pkg/music/files"
+)
+
+const (
+	// Mixxx store its dates as UTC ISO 8601 strings
+	DateFormat = "2006-01-02T15:04:05.000Z"
+
+	trackQuery = `SELECT library.*, track_locations.location AS path, track_locations.filesize
+		FROM library JOIN track_locations ON library.location = track_locations.id`
+)
+
+type ListType int
+
+const (
+	ListPlaylist = ListType(iota)
+	ListCrate
+)
+
+type Library struct {
+	sql          *sqlx.DB
+	path         string
+	info         string
+	trackIds     map[string]int
+	hashCache    map[string]music.Tracks
+	contentIndex music.ContentIndex
+	filelib      *flib.FileLibrary
+}
+
+func Open(path string) (music.Library, error) {
+	if path == "" {
+		path = defaultPath()
+	} else if files.IsDir(path) {
+		path = filepath.Join(path, "mixxxdb.sqlite")
+	}
+
+	logrus.Infof("opening Mixxx database located at '%s'", path)
+
+	if !files.Exists(path) {
+		return nil, errors.Errorf("mixxx database '%s' doesn't exists", path)
+	}
+
+	db, err := sqlx.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	l := &Library{
+		sql:      db,
+		path:     path,
+		trackIds: map[string]int{},
+	}
+
+	version := ""
+	err = db.Get(&version, `SELECT value FROM settings WHERE name = 'mixxx.schema.version'`)
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "failed to fetch mixxx database schema version")
+	}
+
+	if err = l.buildIdsMap(); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	l.info = fmt.Sprintf("Mixxx: Schema Version: %v, Track Count: %d, Path: %v", version, len(l.trackIds), path)
+	logrus.Info(l.info)
+
+	return l, nil
+}
+
+func defaultPath() string {
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(os.Getenv("LOCALAPPDATA"), "Mixxx", "mixxxdb.sqlite")
+	case "darwin":
+		return files.ExpandHomePath("~/Library/Application Support/Mixxx/mixxxdb.sqlite")
+	default:
+		return files.ExpandHomePath("~/.mixxx/mixxxdb.sqlite")
+	}
+}
+
+func (l *Library) buildIdsMap() error {
+	entries := []trackEntry{}
+	err := l.sql.Unsafe().Select(&entries, trackQuery+` WHERE library.mixxx_deleted = 0`)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch track ids")
+	}
+	for _, e := range entries {
+		fpath := files.RemoveAccent(files.NormalizePath(e.Path.String))
+		if _, ok := l.trackIds[fpath]; ok {
+			logrus.Warnf("duplicate entry in sqlite for path '%s'", fpath)
+		}
+		l.trackIds[fpath] = e.Id
+	}
+	return nil
+}
+
+func (l *Library) Close() {
+	if l.sql != nil {
+		l.sql.Close()
+	}
+	logrus.Infof("Mixxx library '%s' closed", l.path)
+}
+
+func (l *Library) fetchTrack(id int) (*Track, error) {
+	entry := trackEntry{}
+	err := l.sql.Unsafe().Get(&entry, trackQuery+` WHERE library.id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	return newTrack(l, entry), nil
+}
+
+func (l *Library) Track(filename string) music.Track {
+	id, ok := l.trackIds[files.RemoveAccent(files.NormalizePath(filename))]
+	if !ok {
+		return nil
+	}
+
+	track, err := l.fetchTrack(id)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logrus.Errorf("failed query: %v", err)
+		}
+		return nil
+	}
+	return track
+}
+
+func (l *Library) Matches(track music.Track) (matches music.Tracks) {
+	if track == nil {
+		return
+	}
+
+	if found := l.Track(track.FilePath()); found != nil {
+		matches = append(matches, found)
+	}
+
+	if l.hashCache == nil {
+		start := time.Now()
+		logrus.Info("constructing track hashes from Mixxx library metadata")
+
+		l.hashCache = map[string]music.Tracks{}
+		err := l.ForEachTrack(func(index int, total int, track music.Track) error {
+			h := music.TrackHash(track)
+			if dupe, ok := l.hashCache[h]; ok {
+				list := dupe.Filepaths()
+				logrus.Warnf("duplicate metadata for '%s': \n  %s", track.String(), strings.Join(append(list, track.FilePath()), "\n  "))
+			}
+			l.hashCache[h] = append(l.hashCache[h], track)
+			return nil
+		})
+		if err != nil {
+			logrus.Errorf("%v", err)
+		}
+		logrus.Infof("processed %d tracks in %v", len(l.hashCache), time.Since(start))
+	}
+
+	if match, ok := l.hashCache[music.TrackHash(track)]; ok {
+		matches = append(matches, match...)
+	}
+
+	// the metadata might have been edited since, fallback on the audio content
+	if len(matches) == 0 {
+		matches = append(matches, l.contentIndex.Matches(l, track)...)
+	}
+
+	return matches.Dedupe()
+}
+
+func (l *Library) Playlists() []music.Tracklist {
+	// hidden playlists are the auto dj queue and the history
+	return l.fetchLists(ListPlaylist, `SELECT id, name, locked FROM Playlists WHERE hidden = 0 ORDER BY position`)
+}
+
+func (l *Library) Crates() []music.Tracklist {
+	return l.fetchLists(ListCrate, `SELECT id, name, locked FROM crates ORDER BY name`)
+}
+
+func (l *Library) fetchLists(listType ListType, query string) []music.Tracklist {
+	entries := []listEntry{}
+	err := l.sql.Select(&entries, query)
+	if err != nil {
+		logrus.Errorf("failed to fetch %s from Mixxx database: %v", listType, err)
+		return nil
+	}
+
+	out := []music.Tracklist{}
+	for _, it := range entries {
+		out = append(out, newList(l, listType, it))
+	}
+	return out
+}
+
+func (l *Library) fetchList(path string, listType ListType) (*TrackList, error) {
+	query := `SELECT id, name, locked FROM crates WHERE name = ?`
+	if listType == ListPlaylist {
+		query = `SELECT id, name, locked FROM Playlists WHERE name = ? AND hidden = 0`
+	}
+
+	entry := listEntry{}
+	err := l.sql.Get(&entry, query, path)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return nil, errors.Wrapf(err, "fail to fetch %v '%s'", listType, path)
+		}
+		return nil, nil
+	}
+	return newList(l, listType, entry), nil
+}
+
+/*
+	Mixxx doesn't have folders, the full path is used as the playlist name
+*/
+func (l *Library) CreatePlaylist(path string) (music.Tracklist, error) {
+	if list, err := l.fetchList(path, ListPlaylist); list != nil || err != nil {
+		return list, err
+	}
+
+	logrus.Infof("creating playlist '%s' in Mixxx database", path)
+
+	now := time.Now().UTC().Format(DateFormat)
+	query := `INSERT INTO Playlists (name, position, hidden, date_created, date_modified, locked)
+		VALUES (?, (SELECT IFNULL(MAX(position), 0) + 1 FROM Playlists), 0, ?, ?, 0)`
+	_, err := l.sql.Exec(query, path, now, now)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create playlist '%s'", path)
+	}
+
+	return l.fetchList(path, ListPlaylist)
+}
+
+func (l *Library) CreateCrate(path string) (music.Tracklist, error) {
+	if list, err := l.fetchList(path, ListCrate); list != nil || err != nil {
+		return list, err
+	}
+
+	logrus.Infof("creating crate '%s' in Mixxx database", path)
+
+	query := `INSERT INTO crates (name, count, show, locked, autodj_source) VALUES (?, 0, 1, 0, 0)`
+	_, err := l.sql.Exec(query, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create crate '%s'", path)
+	}
+
+	return l.fetchList(path, ListCrate)
+}
+
+func (l *Library) SupportedExtensions() music.FileExtensions {
+	return music.FileExtensions{
+		".aac",
+		".aif",
+		".aiff",
+		".flac",
+		".m4a",
+		".mp3",
+		".mp4",
+		".ogg",
+		".opus",
+		".wav",
+	}
+}
+
+func (l *Library) AddFile(path string) (music.Track, error) {
+	if existing := l.Track(path); existing != nil {
+		return existing, nil
+	}
+
+	if l.filelib == nil {
+		l.filelib = flib.Open("")
+	}
+	meta := l.filelib.Track(path)
+	if meta == nil {
+		return nil, errors.Errorf("file '%s' doesn't exists", path)
+	}
+
+	path = filepath.ToSlash(path)
+
+	tx, err := l.sql.Beginx()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed start db transaction to add '%s'", path)
+	}
+
+	query := `INSERT INTO track_locations (location, filename, directory, filesize, fs_deleted, needs_verification) VALUES (?, ?, ?, ?, 0, 0)`
+	res, err := tx.Exec(query, path, filepath.Base(path), filepath.ToSlash(filepath.Dir(path)), files.Size(path))
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to add location of '%s'", path)
+	}
+	locationId, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to add location of '%s'", path)
+	}
+
+	year := ""
+	if meta.Year() > 0 {
+		year = fmt.Sprint(meta.Year())
+	}
+
+	// header_parsed = 0 will make Mixxx parse the file tags on next start
+	query = `INSERT INTO library (artist, title, album, year, genre, comment, duration, bpm, location, datetime_added,
+		mixxx_deleted, played, header_parsed, filetype, timesplayed, rating)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, 0, 0, ?, 0, 0)`
+	res, err = tx.Exec(query, meta.Artist(), meta.Title(), meta.Album(), year, meta.Genre(), meta.Comment(),
+		meta.Duration().Seconds(), meta.BPM(), locationId, time.Now().UTC().Format(DateFormat),
+		strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."))
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to add track '%s'", path)
+	}
+	trackId, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to add track '%s'", path)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Wrapf(err, "fail to commit transaction for '%s'", path)
+	}
+
+	l.trackIds[files.RemoveAccent(files.NormalizePath(path))] = int(trackId)
+	l.hashCache = nil
+
+	return l.fetchTrack(int(trackId))
+}
+
+func (l *Library) MoveTrack(track music.Track, newpath string) error {
+	itrack, ok := track.(*Track)
+	if !ok {
+		panic("invalid track type parameter")
+	}
+
+	oldpath := files.RemoveAccent(itrack.FilePath())
+	if err := itrack.SetPath(newpath); err != nil {
+		return err
+	}
+
+	delete(l.trackIds, oldpath)
+	l.trackIds[files.RemoveAccent(itrack.FilePath())] = itrack.entry.Id
+	return nil
+}
+
+func (l *Library) ForEachTrack(fct music.EachTrackFunc) error {
+	entries := []trackEntry{}
+	err := l.sql.Unsafe().Select(&entries, trackQuery+` WHERE library.mixxx_deleted = 0`)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch tracks")
+	}
+
+	for idx, it := range entries {
+		if err := fct(idx, len(entries), newTrack(l, it)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Library) DatabaseFiles() []string {
+	return []string{l.path}
+}
+
+func (l *Library) String() string {
+	return l.info
+}
+
+func (l ListType) String() string {
+	switch l {
+	case ListPlaylist:
+		return "playlist"
+	case ListCrate:
+		return "crate"
+	}
+	panic("unknown list type")
+}
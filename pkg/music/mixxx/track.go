@@ -0,0 +1,240 @@
+package mixxx
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+type Track struct {
+	entry trackEntry
+	lib   *Library
+}
+
+func newTrack(lib *Library, entry trackEntry) *Track {
+	return &Track{
+		entry: entry,
+		lib:   lib,
+	}
+}
+
+func (t *Track) Title() string {
+	return t.entry.Title.String
+}
+
+func (t *Track) Album() string {
+	return t.entry.Album.String
+}
+
+func (t *Track) Artist() string {
+	return t.entry.Artist.String
+}
+
+func (t *Track) Year() int {
+	year := t.entry.Year.String
+	if len(year) < 4 {
+		return 0
+	}
+	value, err := strconv.Atoi(year[:4])
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+/*
+	Mixxx store the star count directly, 0 to 5
+*/
+func (t *Track) Rating() music.Rating {
+	return music.Rating(t.entry.Rating.Int32)
+}
+
+func (t *Track) SetRating(rating music.Rating) error {
+	if err := t.writeColumn("rating", int(rating)); err != nil {
+		return err
+	}
+	t.entry.Rating = sql.NullInt32{Int32: int32(rating), Valid: true}
+	return nil
+}
+
+func (t *Track) Modified() time.Time {
+	return files.ModifiedTime(t.FilePath())
+}
+
+func (t *Track) SetModified(modified time.Time) error {
+	return errors.New("SetModified operation is not supported for mixxx")
+}
+
+func (t *Track) Added() time.Time {
+	added := t.entry.Added.String
+	for _, layout := range []string{DateFormat, time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if value, err := time.Parse(layout, added); err == nil {
+			return value.UTC()
+		}
+	}
+	if added != "" {
+		logrus.Warnf("failed to parse added date '%s' of track '%s'", added, t)
+	}
+	return time.Time{}
+}
+
+func (t *Track) SetAdded(added time.Time) error {
+	value := added.UTC().Format(DateFormat)
+	if err := t.writeColumn("datetime_added", value); err != nil {
+		return err
+	}
+	t.entry.Added = sql.NullString{String: value, Valid: true}
+	return nil
+}
+
+func (t *Track) PlayCount() int {
+	return int(t.entry.TimesPlayed.Int32)
+}
+
+func (t *Track) SetPlayCount(count int) error {
+	played := 0
+	if count > 0 {
+		played = 1
+	}
+
+	query := `UPDATE library SET timesplayed = ?, played = ? WHERE id = ?`
+	_, err := t.lib.sql.Exec(query, count, played, t.entry.Id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set play count %d to track '%s'", count, t.String())
+	}
+	t.entry.TimesPlayed = sql.NullInt32{Int32: int32(count), Valid: true}
+	return nil
+}
+
+func (t *Track) BPM() float64 {
+	return t.entry.BPM.Float64
+}
+
+func (t *Track) SetBPM(bpm float64) error {
+	if err := t.writeColumn("bpm", bpm); err != nil {
+		return err
+	}
+	t.entry.BPM = sql.NullFloat64{Float64: bpm, Valid: true}
+	return nil
+}
+
+/*
+	Mixxx key_id use the same encoding as music.Key, the text column is only used
+	for display and as a fallback for old databases
+*/
+func (t *Track) Key() music.Key {
+	if t.entry.KeyId.Valid && t.entry.KeyId.Int32 > 0 {
+		return music.Key(t.entry.KeyId.Int32)
+	}
+	key, err := music.ParseKey(t.entry.Key.String)
+	if err != nil {
+		logrus.Warnf("failed to parse key '%s' of track '%s'", t.entry.Key.String, t)
+	}
+	return key
+}
+
+func (t *Track) SetKey(key music.Key) error {
+	query := `UPDATE library SET key_id = ?, key = ? WHERE id = ?`
+	_, err := t.lib.sql.Exec(query, int(key), key.String(), t.entry.Id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set key %v to track '%s'", key, t.String())
+	}
+	t.entry.KeyId = sql.NullInt32{Int32: int32(key), Valid: true}
+	t.entry.Key = sql.NullString{String: key.String(), Valid: true}
+	return nil
+}
+
+func (t *Track) Genre() string {
+	return t.entry.Genre.String
+}
+
+func (t *Track) SetGenre(genre string) error {
+	if err := t.writeColumn("genre", genre); err != nil {
+		return err
+	}
+	t.entry.Genre = sql.NullString{String: genre, Valid: true}
+	return nil
+}
+
+func (t *Track) Comment() string {
+	return t.entry.Comment.String
+}
+
+func (t *Track) SetComment(comment string) error {
+	if err := t.writeColumn("comment", comment); err != nil {
+		return err
+	}
+	t.entry.Comment = sql.NullString{String: comment, Valid: true}
+	return nil
+}
+
+func (t *Track) Duration() time.Duration {
+	return time.Duration(t.entry.Duration.Float64 * float64(time.Second))
+}
+
+func (t *Track) FilePath() string {
+	return files.NormalizePath(t.entry.Path.String)
+}
+
+func (t *Track) SetPath(newpath string) error {
+	newpath = filepath.ToSlash(newpath)
+
+	query := `UPDATE track_locations SET location = ?, filename = ?, directory = ? WHERE id = ?`
+	_, err := t.lib.sql.Exec(query, newpath, filepath.Base(newpath), filepath.ToSlash(filepath.Dir(newpath)), t.entry.LocationId.Int32)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update location of track '%s' in Mixxx db", t.String())
+	}
+	logrus.Infof("path for '%v' updated in Mixxx db", t.entry.Id)
+
+	t.entry.Path = sql.NullString{String: newpath, Valid: true}
+	return nil
+}
+
+func (t *Track) Size() int64 {
+	if t.entry.Size.Valid {
+		return t.entry.Size.Int64
+	}
+	return files.Size(t.FilePath())
+}
+
+func (t *Track) String() string {
+	if t.entry.Title.String != "" {
+		return t.entry.Title.String
+	}
+	return filepath.Base(t.entry.Path.String)
+}
+
+func (t *Track) MarshalYAML() (interface{}, error) {
+	return music.NewMarchalTrack(t), nil
+}
+
+func (t *Track) MarshalJSON() ([]byte, error) {
+	return json.Marshal(music.NewMarchalTrack(t))
+}
+
+func (t *Track) MarshalTOML() ([]byte, error) {
+	return toml.Marshal(music.NewMarchalTrack(t))
+}
+
+/*
+	Update a single column of the library table, column name must never come from user input
+*/
+func (t *Track) writeColumn(column string, value interface{}) error {
+	query := fmt.Sprintf(`UPDATE library SET %s = ? WHERE id = ?`, column)
+	_, err := t.lib.sql.Exec(query, value, t.entry.Id)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to set %s of track '%s'", column, t.String())
+		logrus.Errorf("%v", err)
+	}
+	return err
+}
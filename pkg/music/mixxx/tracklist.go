@@ -0,0 +1,144 @@
+package mixxx
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/music"
+)
+
+type TrackList struct {
+	entry    listEntry
+	lib      *Library
+	listType ListType
+}
+
+func newList(lib *Library, listType ListType, entry listEntry) *TrackList {
+	return &TrackList{
+		entry:    entry,
+		lib:      lib,
+		listType: listType,
+	}
+}
+
+func (t *TrackList) Name() string {
+	split := strings.Split(t.entry.Name.String, "/")
+	return split[len(split)-1]
+}
+
+func (t *TrackList) Path() string {
+	return t.entry.Name.String
+}
+
+func (t *TrackList) String() string {
+	names := []string{}
+	for _, track := range t.Tracks() {
+		names = append(names, track.String())
+	}
+	return "[" + strings.Join(names, ",") + "]"
+}
+
+func (t *TrackList) Count() int {
+	count := 0
+	query := `SELECT COUNT(*) FROM crate_tracks WHERE crate_id = ?`
+	if t.listType == ListPlaylist {
+		query = `SELECT COUNT(*) FROM PlaylistTracks WHERE playlist_id = ?`
+	}
+	_ = t.lib.sql.Get(&count, query, t.entry.Id)
+	return count
+}
+
+func (t *TrackList) Tracks() music.Tracks {
+	query := trackQuery + ` JOIN crate_tracks ON crate_tracks.track_id = library.id
+		WHERE crate_tracks.crate_id = ? ORDER BY library.artist, library.title`
+	if t.listType == ListPlaylist {
+		query = trackQuery + ` JOIN PlaylistTracks ON PlaylistTracks.track_id = library.id
+			WHERE PlaylistTracks.playlist_id = ? ORDER BY PlaylistTracks.position`
+	}
+
+	entries := []trackEntry{}
+	err := t.lib.sql.Unsafe().Select(&entries, query, t.entry.Id)
+	if err != nil {
+		logrus.Errorf("fail to fetch track list for %s '%s': %v", t.listType, t.Path(), err)
+		return nil
+	}
+
+	out := music.Tracks{}
+	for _, it := range entries {
+		out = append(out, newTrack(t.lib, it))
+	}
+	return out
+}
+
+func (t *TrackList) SetTracks(tracks music.Tracks) error {
+	if t.entry.Locked.Bool {
+		return errors.Errorf("%s '%s' is locked in Mixxx", t.listType, t.Path())
+	}
+
+	logrus.Infof("updating tracklist for %s '%s' with %d entries", t.listType, t.Path(), len(tracks))
+
+	tx, err := t.lib.sql.Beginx()
+	if err != nil {
+		return errors.Wrapf(err, "failed start db transaction for %s '%s'", t.listType, t.Path())
+	}
+
+	query := `DELETE FROM crate_tracks WHERE crate_id = ?`
+	if t.listType == ListPlaylist {
+		query = `DELETE FROM PlaylistTracks WHERE playlist_id = ?`
+	}
+	_, err = tx.Exec(query, t.entry.Id)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed deleting previous track from %s '%s'", t.listType, t.Path())
+	}
+
+	now := time.Now().UTC().Format(DateFormat)
+	for idx, track := range tracks {
+		tr, ok := track.(*Track)
+		if !ok || tr.lib != t.lib {
+			tx.Rollback()
+			return errors.New("cannot save track object which are not from the same library")
+		}
+
+		if t.listType == ListPlaylist {
+			query = `INSERT INTO PlaylistTracks (playlist_id, track_id, position, pl_datetime_added) VALUES (?, ?, ?, ?)`
+			_, err = tx.Exec(query, t.entry.Id, tr.entry.Id, idx+1, now)
+		} else {
+			// crates are unordered sets
+			query = `INSERT OR IGNORE INTO crate_tracks (crate_id, track_id) VALUES (?, ?)`
+			_, err = tx.Exec(query, t.entry.Id, tr.entry.Id)
+		}
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "failed to add track to %s '%s'", t.listType, t.Path())
+		}
+	}
+
+	if t.listType == ListPlaylist {
+		_, err = tx.Exec(`UPDATE Playlists SET date_modified = ? WHERE id = ?`, now, t.entry.Id)
+	} else {
+		_, err = tx.Exec(`UPDATE crates SET count = (SELECT COUNT(*) FROM crate_tracks WHERE crate_id = ?) WHERE id = ?`, t.entry.Id, t.entry.Id)
+	}
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to update %s '%s'", t.listType, t.Path())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrapf(err, "fail to commit transaction for %s '%s'", t.listType, t.Path())
+	}
+	return nil
+}
+
+func (t *TrackList) MarshalYAML() (interface{}, error) {
+	return music.NewMarshallTracklist(t), nil
+}
+
+func (t *TrackList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(music.NewMarshallTracklist(t))
+}
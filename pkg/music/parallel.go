@@ -0,0 +1,99 @@
+package music
+
+import (
+	"runtime"
+)
+
+// default number of workers loading the tracks in ForEachTrackParallel
+var DefaultWorkers = runtime.NumCPU()
+
+/*
+	Track whose metadata is read lazily, Load reads it ahead of time
+*/
+type LoadableTrack interface {
+	Track
+
+	Load()
+}
+
+/*
+	Read the metadata of the track now if it is read lazily
+*/
+func Load(track Track) {
+	if it, ok := track.(LoadableTrack); ok {
+		it.Load()
+	}
+}
+
+/*
+	Same as Library.ForEachTrack, but the tracks are loaded ahead by a pool of
+	workers which also call prefetch (if any) for each of them. fct is still
+	called in order from the calling goroutine so it doesn't need any locking,
+	only prefetch must be safe for concurrent use.
+*/
+func ForEachTrackParallel(lib Library, workers int, prefetch EachTrackFunc, fct EachTrackFunc) error {
+	load := func(index int, total int, track Track) error {
+		Load(track)
+		if prefetch != nil {
+			return prefetch(index, total, track)
+		}
+		return nil
+	}
+
+	if workers <= 1 {
+		return lib.ForEachTrack(func(index int, total int, track Track) error {
+			if err := load(index, total, track); err != nil {
+				return err
+			}
+			return fct(index, total, track)
+		})
+	}
+
+	tracks := Tracks{}
+	err := lib.ForEachTrack(func(index int, total int, track Track) error {
+		tracks = append(tracks, track)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	total := len(tracks)
+	loaded := make([]chan error, total)
+	for i := range loaded {
+		loaded[i] = make(chan error, 1)
+	}
+
+	jobs := make(chan int)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		defer close(jobs)
+		for i := range tracks {
+			select {
+			case jobs <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				loaded[i] <- load(i, total, tracks[i])
+			}
+		}()
+	}
+
+	for i, track := range tracks {
+		if err := <-loaded[i]; err != nil {
+			return err
+		}
+		if err := fct(i, total, track); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package music
+
+import (
+	"image/color"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// number of hot cues and saved loops slots on most hardware
+const PerformanceSlots = 8
+
+/*
+	Portable version of the performance data each DJ software keeps per track,
+	positions are relative to the start of the audio
+*/
+type PerformanceData struct {
+	HotCues  []HotCue     `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
+	Loops    []Loop       `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
+	BeatGrid []BeatMarker `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
+
+	// main tempo of the track, markers can define tempo changes
+	BPM float64 `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
+}
+
+type HotCue struct {
+	Index    int
+	Name     string `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
+	Position time.Duration
+	Color    color.RGBA
+}
+
+type Loop struct {
+	Index int
+	Name  string `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
+	Start time.Duration
+	End   time.Duration
+	Color color.RGBA
+}
+
+/*
+	Beat anchor of the grid, the tempo is valid until the next marker
+*/
+type BeatMarker struct {
+	Position time.Duration
+	Beat     int
+	BPM      float64
+}
+
+/*
+	Track which can read and write its performance data
+*/
+type PerformanceTrack interface {
+	Track
+
+	PerformanceData() (*PerformanceData, error)
+	SetPerformanceData(data *PerformanceData) error
+}
+
+/*
+	Performance track whose data can be written back exactly, SetPerformanceData
+	only writes the slots set in data and keeps the other ones
+*/
+type PerformanceReplacer interface {
+	PerformanceTrack
+
+	ReplacePerformanceData(data *PerformanceData) error
+}
+
+func (p *PerformanceData) Empty() bool {
+	return p == nil || (len(p.HotCues) == 0 && len(p.Loops) == 0 && len(p.BeatGrid) == 0)
+}
+
+func (p *PerformanceData) Equal(other *PerformanceData) bool {
+	if p.Empty() || other.Empty() {
+		return p.Empty() == other.Empty()
+	}
+	return reflect.DeepEqual(p.normalized(), other.normalized())
+}
+
+/*
+	Sort slices and round positions to the millisecond since not all software
+	have the same precision
+*/
+func (p *PerformanceData) normalized() PerformanceData {
+	out := PerformanceData{
+		BPM: float64(int(p.BPM*100+0.5)) / 100,
+	}
+	for _, it := range p.HotCues {
+		it.Position = it.Position.Round(time.Millisecond)
+		out.HotCues = append(out.HotCues, it)
+	}
+	for _, it := range p.Loops {
+		it.Start = it.Start.Round(time.Millisecond)
+		it.End = it.End.Round(time.Millisecond)
+		out.Loops = append(out.Loops, it)
+	}
+	for _, it := range p.BeatGrid {
+		it.Position = it.Position.Round(time.Millisecond)
+		it.BPM = float64(int(it.BPM*100+0.5)) / 100
+		out.BeatGrid = append(out.BeatGrid, it)
+	}
+	out.Sort()
+	return out
+}
+
+func (p *PerformanceData) Sort() {
+	sort.Slice(p.HotCues, func(i, j int) bool {
+		return p.HotCues[i].Index < p.HotCues[j].Index
+	})
+	sort.Slice(p.Loops, func(i, j int) bool {
+		return p.Loops[i].Index < p.Loops[j].Index
+	})
+	sort.Slice(p.BeatGrid, func(i, j int) bool {
+		return p.BeatGrid[i].Position < p.BeatGrid[j].Position
+	})
+}
+
+/*
+	Beatgrid anchor, the first marker of the grid
+*/
+func (p *PerformanceData) Anchor() (BeatMarker, bool) {
+	if len(p.BeatGrid) == 0 {
+		return BeatMarker{}, false
+	}
+	anchor := p.BeatGrid[0]
+	for _, it := range p.BeatGrid {
+		if it.Position < anchor.Position {
+			anchor = it
+		}
+	}
+	if anchor.BPM == 0 {
+		anchor.BPM = p.BPM
+	}
+	return anchor, true
+}
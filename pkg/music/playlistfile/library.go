@@ -0,0 +1,301 @@
+package playlistfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+	flib "primetools/pkg/music/files"
+)
+
+// format of the playlists created in the library
+const defaultExtension = ".m3u8"
+
+/*
+	Folder of m3u, m3u8, pls and xspf files. Its tracks are the ones of the
+	playlists, which are also its crates since the files don't make any
+	difference.
+*/
+type Library struct {
+	root         string
+	lists        []*TrackList
+	tracks       map[string]*Track
+	filelib      *flib.FileLibrary
+	hashCache    map[string]music.Tracks
+	contentIndex music.ContentIndex
+}
+
+func Open(path string) (music.Library, error) {
+	start := time.Now()
+
+	if path == "" {
+		path = files.ExpandHomePath("~/Music/Playlists")
+	}
+	if !files.IsDir(path) {
+		return nil, errors.Errorf("'%s' is not a folder of playlist files", path)
+	}
+
+	logrus.Infof("opening playlist files of '%s'", path)
+
+	lib := &Library{
+		root:    path,
+		tracks:  map[string]*Track{},
+		filelib: flib.Open(""),
+	}
+
+	err := filepath.Walk(path, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if _, ok := formats[strings.ToLower(filepath.Ext(fpath))]; !ok {
+			return nil
+		}
+		list, err := lib.readList(fpath)
+		if err != nil {
+			logrus.Warnf("%v", err)
+			return nil
+		}
+		lib.lists = append(lib.lists, list)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to list playlist files of '%s'", path)
+	}
+
+	logrus.Infof("sucessfully loaded %d playlist files in %s", len(lib.lists), time.Since(start))
+
+	return lib, nil
+}
+
+func (l *Library) readList(file string) (*TrackList, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to open playlist '%s'", file)
+	}
+	defer fd.Close()
+
+	entries, err := formats[strings.ToLower(filepath.Ext(file))].read(fd)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to read playlist '%s'", file)
+	}
+
+	list := &TrackList{
+		lib:  l,
+		path: l.listPath(file),
+		file: file,
+	}
+	for _, it := range entries {
+		path := resolve(it.location, filepath.Dir(file))
+		if path == "" {
+			logrus.Warnf("playlist '%s' refers to '%s' which isn't a local file", list.path, it.location)
+			continue
+		}
+		list.tracks = append(list.tracks, l.track(path, it))
+	}
+	return list, nil
+}
+
+func (l *Library) listPath(file string) string {
+	rel, err := filepath.Rel(l.root, file)
+	if err != nil {
+		rel = filepath.Base(file)
+	}
+	return strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+}
+
+/*
+	The track of the path, the first playlist referring to it gives its
+	metadata
+*/
+func (l *Library) track(path string, e entry) *Track {
+	if it, ok := l.tracks[path]; ok {
+		return it
+	}
+	it := l.newTrack(path, e)
+	l.tracks[path] = it
+
+	// the new track must be matched
+	l.hashCache = nil
+	l.contentIndex.Reset()
+	return it
+}
+
+func (l *Library) newTrack(path string, e entry) *Track {
+	return &Track{
+		path:  path,
+		entry: e,
+		file:  l.filelib.Track(path),
+	}
+}
+
+func (l *Library) Close() {
+	l.filelib.Close()
+}
+
+func (l *Library) Track(filename string) music.Track {
+	if it, ok := l.tracks[files.NormalizePath(filename)]; ok {
+		return it
+	}
+	return nil
+}
+
+/*
+	Playlists refer to files, so the file of the track is its match even when
+	it isn't in any playlist yet
+*/
+func (l *Library) Matches(track music.Track) (matches music.Tracks) {
+	if track == nil {
+		return
+	}
+
+	if found := l.Track(track.FilePath()); found != nil {
+		return music.Tracks{found}
+	} else if files.Exists(track.FilePath()) {
+		return music.Tracks{l.newTrack(files.NormalizePath(track.FilePath()), entry{})}
+	}
+
+	if l.hashCache == nil {
+		start := time.Now()
+		logrus.Info("constructing track hashes from playlist files metadata")
+
+		l.hashCache = map[string]music.Tracks{}
+		err := l.ForEachTrack(func(index int, total int, track music.Track) error {
+			h := music.TrackHash(track)
+			l.hashCache[h] = append(l.hashCache[h], track)
+			return nil
+		})
+		if err != nil {
+			logrus.Errorf("%v", err)
+		}
+		logrus.Infof("processed %d tracks in %v", len(l.hashCache), time.Since(start))
+	}
+
+	if match, ok := l.hashCache[music.TrackHash(track)]; ok {
+		matches = append(matches, match...)
+	}
+
+	// the metadata might have been edited since, fallback on the audio content
+	if len(matches) == 0 {
+		matches = append(matches, l.contentIndex.Matches(l, track)...)
+	}
+
+	return matches.Dedupe()
+}
+
+func (l *Library) Playlists() []music.Tracklist {
+	lists := []music.Tracklist{}
+	for _, it := range l.lists {
+		lists = append(lists, it)
+	}
+	return lists
+}
+
+func (l *Library) Crates() []music.Tracklist {
+	return l.Playlists()
+}
+
+func (l *Library) ForEachTrack(fct music.EachTrackFunc) error {
+	paths := []string{}
+	for path := range l.tracks {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for idx, path := range paths {
+		if err := fct(idx, len(paths), l.tracks[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Library) SupportedExtensions() music.FileExtensions {
+	return music.FileExtensions(files.MusicExtensions)
+}
+
+func (l *Library) AddFile(path string) (music.Track, error) {
+	return nil, errors.New("AddFile operation is not supported for playlist files, tracks are added to a playlist")
+}
+
+/*
+	The playlist file is written by SetTracks, so nothing is written in dry run
+*/
+func (l *Library) CreatePlaylist(path string) (music.Tracklist, error) {
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	for _, it := range l.lists {
+		if it.path == path {
+			return it, nil
+		}
+	}
+
+	list := &TrackList{
+		lib:  l,
+		path: path,
+		file: filepath.Join(l.root, filepath.FromSlash(path)) + defaultExtension,
+	}
+	l.lists = append(l.lists, list)
+	return list, nil
+}
+
+func (l *Library) CreateCrate(path string) (music.Tracklist, error) {
+	return l.CreatePlaylist(path)
+}
+
+/*
+	Rewrite the playlists referring to the track with its new path
+*/
+func (l *Library) MoveTrack(track music.Track, newpath string) error {
+	old, ok := l.tracks[files.NormalizePath(track.FilePath())]
+	if !ok {
+		return errors.Errorf("'%s' is not in any playlist", track.FilePath())
+	}
+	moved := l.track(files.NormalizePath(newpath), old.entry)
+	if moved == old {
+		return nil
+	}
+
+	for _, list := range l.lists {
+		changed := false
+		for idx, it := range list.tracks {
+			if it == old {
+				list.tracks[idx] = moved
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := list.write(); err != nil {
+			return err
+		}
+		logrus.Infof("'%s' moved to '%s' in playlist '%s'", old.path, moved.path, list.path)
+	}
+
+	delete(l.tracks, old.path)
+	return nil
+}
+
+func (l *Library) DatabaseFiles() []string {
+	out := []string{}
+	for _, it := range l.lists {
+		if files.Exists(it.file) {
+			out = append(out, it.file)
+		}
+	}
+	return out
+}
+
+func (l *Library) String() string {
+	return fmt.Sprintf("Playlist files: Path: %s, Playlist Count: %d, Track Count: %d", l.root, len(l.lists), len(l.tracks))
+}
@@ -0,0 +1,295 @@
+package playlistfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"primetools/pkg/files"
+)
+
+const xspfNamespace = "http://xspf.org/ns/0/"
+
+/*
+	Track of a playlist file, location is the path written in the file which can
+	be relative to it
+*/
+type entry struct {
+	location string
+	title    string
+	artist   string
+	album    string
+	duration time.Duration
+}
+
+type format struct {
+	read  func(r io.Reader) ([]entry, error)
+	write func(w io.Writer, entries []entry) error
+}
+
+var formats = map[string]format{
+	".m3u":  {read: readM3U, write: writeM3U},
+	".m3u8": {read: readM3U, write: writeM3U},
+	".pls":  {read: readPLS, write: writePLS},
+	".xspf": {read: readXSPF, write: writeXSPF},
+}
+
+/*
+	Lines of the file without the UTF-8 BOM, the trailing spaces and the blank
+	lines
+*/
+func readLines(r io.Reader, fct func(line string)) error {
+	scanner := bufio.NewScanner(r)
+	first := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+		line = strings.TrimSpace(line)
+		if line != "" {
+			fct(line)
+		}
+	}
+	return scanner.Err()
+}
+
+/*
+	Extended M3U: each location can be preceded by its #EXTINF:<seconds>,<artist> - <title>
+	line, the other directives are ignored
+*/
+func readM3U(r io.Reader) ([]entry, error) {
+	out := []entry{}
+	current := entry{}
+	err := readLines(r, func(line string) {
+		if strings.HasPrefix(line, "#EXTINF:") {
+			current = parseExtInf(strings.TrimPrefix(line, "#EXTINF:"))
+			return
+		}
+		if strings.HasPrefix(line, "#") {
+			return
+		}
+		current.location = line
+		out = append(out, current)
+		current = entry{}
+	})
+	return out, errors.Wrap(err, "fail to read m3u playlist")
+}
+
+/*
+	The duration can be followed by attributes (ie: -1 tvg-id="..."), the title
+	is after the first comma
+*/
+func parseExtInf(value string) entry {
+	out := entry{}
+	info := value
+	if idx := strings.Index(value, ","); idx >= 0 {
+		info = value[:idx]
+		out.artist, out.title = splitTitle(value[idx+1:])
+	}
+	if fields := strings.Fields(info); len(fields) > 0 {
+		if seconds, err := strconv.Atoi(fields[0]); err == nil && seconds > 0 {
+			out.duration = time.Duration(seconds) * time.Second
+		}
+	}
+	return out
+}
+
+func writeM3U(w io.Writer, entries []entry) error {
+	buf := bytes.Buffer{}
+	buf.WriteString("#EXTM3U\n")
+	for _, it := range entries {
+		fmt.Fprintf(&buf, "#EXTINF:%d,%s\n%s\n", seconds(it.duration), joinTitle(it), it.location)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+/*
+	PLS is an ini file where the entries are numbered from 1:
+	FileN, TitleN and LengthN
+*/
+func readPLS(r io.Reader) ([]entry, error) {
+	byIndex := map[int]*entry{}
+	get := func(idx int) *entry {
+		if _, ok := byIndex[idx]; !ok {
+			byIndex[idx] = &entry{}
+		}
+		return byIndex[idx]
+	}
+
+	err := readLines(r, func(line string) {
+		idx := strings.Index(line, "=")
+		if idx < 0 {
+			return
+		}
+		key, value := strings.ToLower(strings.TrimSpace(line[:idx])), strings.TrimSpace(line[idx+1:])
+
+		for _, prefix := range []string{"file", "title", "length"} {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			num, err := strconv.Atoi(strings.TrimPrefix(key, prefix))
+			if err != nil {
+				return
+			}
+			it := get(num)
+			switch prefix {
+			case "file":
+				it.location = value
+			case "title":
+				it.artist, it.title = splitTitle(value)
+			case "length":
+				if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+					it.duration = time.Duration(seconds) * time.Second
+				}
+			}
+			return
+		}
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to read pls playlist")
+	}
+
+	indexes := []int{}
+	for idx, it := range byIndex {
+		if it.location != "" {
+			indexes = append(indexes, idx)
+		}
+	}
+	sort.Ints(indexes)
+
+	out := []entry{}
+	for _, idx := range indexes {
+		out = append(out, *byIndex[idx])
+	}
+	return out, nil
+}
+
+func writePLS(w io.Writer, entries []entry) error {
+	buf := bytes.Buffer{}
+	buf.WriteString("[playlist]\n")
+	for idx, it := range entries {
+		fmt.Fprintf(&buf, "File%d=%s\n", idx+1, it.location)
+		fmt.Fprintf(&buf, "Title%d=%s\n", idx+1, joinTitle(it))
+		fmt.Fprintf(&buf, "Length%d=%d\n", idx+1, seconds(it.duration))
+	}
+	fmt.Fprintf(&buf, "NumberOfEntries=%d\n", len(entries))
+	buf.WriteString("Version=2\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Xmlns   string      `xml:"xmlns,attr,omitempty"`
+	Version string      `xml:"version,attr"`
+	Title   string      `xml:"title,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Locations []string `xml:"location"`
+	Title     string   `xml:"title,omitempty"`
+	Creator   string   `xml:"creator,omitempty"`
+	Album     string   `xml:"album,omitempty"`
+	Duration  int64    `xml:"duration,omitempty"`
+}
+
+/*
+	Locations are URIs, the relative ones are unescaped into a path and the
+	file:// ones are kept as they are
+*/
+func readXSPF(r io.Reader) ([]entry, error) {
+	playlist := xspfPlaylist{}
+	if err := xml.NewDecoder(r).Decode(&playlist); err != nil {
+		return nil, errors.Wrap(err, "fail to parse xspf playlist")
+	}
+
+	out := []entry{}
+	for _, it := range playlist.Tracks {
+		if len(it.Locations) == 0 {
+			continue
+		}
+		location := strings.TrimSpace(it.Locations[0])
+		if u, err := url.Parse(location); err == nil && u.Scheme == "" {
+			location = u.Path
+		}
+		out = append(out, entry{
+			location: location,
+			title:    it.Title,
+			artist:   it.Creator,
+			album:    it.Album,
+			duration: time.Duration(it.Duration) * time.Millisecond,
+		})
+	}
+	return out, nil
+}
+
+func writeXSPF(w io.Writer, entries []entry) error {
+	playlist := xspfPlaylist{
+		Xmlns:   xspfNamespace,
+		Version: "1",
+	}
+	for _, it := range entries {
+		location := (&url.URL{Path: it.location}).String()
+		if isAbs(it.location) {
+			location = files.ConvertFilePathToUrl(it.location)
+		}
+		playlist.Tracks = append(playlist.Tracks, xspfTrack{
+			Locations: []string{location},
+			Title:     it.title,
+			Creator:   it.artist,
+			Album:     it.album,
+			Duration:  it.duration.Milliseconds(),
+		})
+	}
+
+	content, err := xml.MarshalIndent(playlist, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "fail to serialize xspf playlist")
+	}
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	_, err = w.Write(append(content, '\n'))
+	return err
+}
+
+/*
+	Titles of M3U and PLS are usually "<artist> - <title>"
+*/
+func splitTitle(value string) (string, string) {
+	value = strings.TrimSpace(value)
+	if idx := strings.Index(value, " - "); idx >= 0 {
+		return strings.TrimSpace(value[:idx]), strings.TrimSpace(value[idx+3:])
+	}
+	return "", value
+}
+
+func joinTitle(it entry) string {
+	if it.artist == "" {
+		return it.title
+	}
+	return it.artist + " - " + it.title
+}
+
+/*
+	-1 is the unknown duration of M3U and PLS
+*/
+func seconds(duration time.Duration) int {
+	if duration <= 0 {
+		return -1
+	}
+	return int(duration.Round(time.Second).Seconds())
+}
@@ -0,0 +1,175 @@
+package playlistfile
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+/*
+	Entry of the playlists, its metadata is read from the tags of the file and
+	from the playlist (ie: #EXTINF) when the file is missing or untagged.
+	Playlists only refer to files, so the metadata cannot be written.
+*/
+type Track struct {
+	path  string
+	entry entry
+	file  music.Track
+}
+
+func (t *Track) Title() string {
+	if t.file != nil && t.file.Title() != "" {
+		return t.file.Title()
+	}
+	return t.entry.title
+}
+
+func (t *Track) Album() string {
+	if t.file != nil && t.file.Album() != "" {
+		return t.file.Album()
+	}
+	return t.entry.album
+}
+
+func (t *Track) Artist() string {
+	if t.file != nil && t.file.Artist() != "" {
+		return t.file.Artist()
+	}
+	return t.entry.artist
+}
+
+func (t *Track) Year() int {
+	if t.file != nil {
+		return t.file.Year()
+	}
+	return 0
+}
+
+func (t *Track) Rating() music.Rating {
+	if t.file != nil {
+		return t.file.Rating()
+	}
+	return music.Rating(0)
+}
+
+func (t *Track) SetRating(rating music.Rating) error {
+	return errors.New("SetRating operation is not supported for playlist files")
+}
+
+func (t *Track) Modified() time.Time {
+	if t.file != nil {
+		return t.file.Modified()
+	}
+	return time.Time{}
+}
+
+func (t *Track) SetModified(modified time.Time) error {
+	return errors.New("SetModified operation is not supported for playlist files")
+}
+
+func (t *Track) Added() time.Time {
+	if t.file != nil {
+		return t.file.Added()
+	}
+	return time.Time{}
+}
+
+func (t *Track) SetAdded(added time.Time) error {
+	return errors.New("SetAdded operation is not supported for playlist files")
+}
+
+func (t *Track) PlayCount() int {
+	return 0
+}
+
+func (t *Track) SetPlayCount(count int) error {
+	return errors.New("SetPlayCount operation is not supported for playlist files")
+}
+
+func (t *Track) BPM() float64 {
+	if t.file != nil {
+		return t.file.BPM()
+	}
+	return 0
+}
+
+func (t *Track) SetBPM(bpm float64) error {
+	return errors.New("SetBPM operation is not supported for playlist files")
+}
+
+func (t *Track) Key() music.Key {
+	if t.file != nil {
+		return t.file.Key()
+	}
+	return music.KeyUnknown
+}
+
+func (t *Track) SetKey(key music.Key) error {
+	return errors.New("SetKey operation is not supported for playlist files")
+}
+
+func (t *Track) Genre() string {
+	if t.file != nil {
+		return t.file.Genre()
+	}
+	return ""
+}
+
+func (t *Track) SetGenre(genre string) error {
+	return errors.New("SetGenre operation is not supported for playlist files")
+}
+
+func (t *Track) Comment() string {
+	if t.file != nil {
+		return t.file.Comment()
+	}
+	return ""
+}
+
+func (t *Track) SetComment(comment string) error {
+	return errors.New("SetComment operation is not supported for playlist files")
+}
+
+func (t *Track) Duration() time.Duration {
+	if t.file != nil && t.file.Duration() != 0 {
+		return t.file.Duration()
+	}
+	return t.entry.duration
+}
+
+func (t *Track) FilePath() string {
+	return t.path
+}
+
+func (t *Track) Size() int64 {
+	return files.Size(t.path)
+}
+
+func (t *Track) String() string {
+	if title := t.Title(); title != "" {
+		if artist := t.Artist(); artist != "" {
+			return artist + " - " + title
+		}
+		return title
+	}
+	return strings.TrimSuffix(filepath.Base(t.path), filepath.Ext(t.path))
+}
+
+func (t *Track) MarshalYAML() (interface{}, error) {
+	return music.NewMarchalTrack(t), nil
+}
+
+func (t *Track) MarshalJSON() ([]byte, error) {
+	return json.Marshal(music.NewMarchalTrack(t))
+}
+
+func (t *Track) MarshalTOML() ([]byte, error) {
+	return toml.Marshal(music.NewMarchalTrack(t))
+}
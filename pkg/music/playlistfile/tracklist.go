@@ -0,0 +1,160 @@
+package playlistfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"os"
+	fpath "path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+/*
+	A playlist file, its path is the one of the file in the library folder
+	without extension, ie: Techno/Warmup for Techno/Warmup.m3u8
+*/
+type TrackList struct {
+	lib    *Library
+	path   string
+	file   string
+	tracks []*Track
+}
+
+func (t *TrackList) Name() string {
+	return fpath.Base(t.path)
+}
+
+func (t *TrackList) Path() string {
+	return t.path
+}
+
+func (t *TrackList) Count() int {
+	return len(t.tracks)
+}
+
+func (t *TrackList) Tracks() music.Tracks {
+	tracks := music.Tracks{}
+	for _, it := range t.tracks {
+		tracks = append(tracks, it)
+	}
+	return tracks
+}
+
+func (t *TrackList) SetTracks(tracks music.Tracks) error {
+	list := []*Track{}
+	for _, it := range tracks {
+		list = append(list, t.lib.track(files.NormalizePath(it.FilePath()), entry{
+			title:    it.Title(),
+			artist:   it.Artist(),
+			album:    it.Album(),
+			duration: it.Duration(),
+		}))
+	}
+
+	logrus.Infof("updating tracklist for playlist '%s' with %d entries", t.path, len(list))
+	t.tracks = list
+	return t.write()
+}
+
+/*
+	Write the playlist in the format of its extension, the tracks below its
+	folder are written relative to it
+*/
+func (t *TrackList) write() error {
+	format, ok := formats[strings.ToLower(filepath.Ext(t.file))]
+	if !ok {
+		return errors.Errorf("unsupported playlist format '%s'", t.file)
+	}
+
+	entries := []entry{}
+	for _, it := range t.tracks {
+		entries = append(entries, entry{
+			location: t.location(it.path),
+			title:    it.Title(),
+			artist:   it.Artist(),
+			album:    it.Album(),
+			duration: it.Duration(),
+		})
+	}
+
+	buf := bytes.Buffer{}
+	if err := format.write(&buf, entries); err != nil {
+		return errors.Wrapf(err, "fail to serialize playlist '%s'", t.path)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.file), 0755); err != nil {
+		return errors.Wrapf(err, "fail to create folder of playlist '%s'", t.file)
+	}
+	return files.WriteFileAtomic(t.file, buf.Bytes())
+}
+
+func (t *TrackList) location(path string) string {
+	rel, err := filepath.Rel(filepath.Dir(t.file), filepath.FromSlash(path))
+	if err != nil {
+		return path
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return path
+	}
+	return rel
+}
+
+func (t *TrackList) MarshalYAML() (interface{}, error) {
+	return music.NewMarshallTracklist(t), nil
+}
+
+func (t *TrackList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(music.NewMarshallTracklist(t))
+}
+
+/*
+	Path of a playlist entry, relative entries are in the folder of the
+	playlist. Empty for the entries which aren't local files, ie: http streams.
+*/
+func resolve(location string, dir string) string {
+	if strings.HasPrefix(strings.ToLower(location), "file:") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return ""
+		}
+		location = u.Path
+		// file:///C:/Music/track.mp3
+		if drive := strings.TrimPrefix(location, "/"); isDrivePath(drive) {
+			location = drive
+		}
+	} else if strings.Contains(location, "://") {
+		return ""
+	}
+
+	location = strings.ReplaceAll(location, `\`, "/")
+	if isDrivePath(location) && !filepath.IsAbs(location) {
+		// windows path read on another system
+		return location
+	}
+	if !isAbs(location) {
+		location = filepath.Join(dir, filepath.FromSlash(location))
+	}
+	return files.NormalizePath(location)
+}
+
+func isAbs(path string) bool {
+	return filepath.IsAbs(path) || strings.HasPrefix(path, "/") || isDrivePath(path)
+}
+
+/*
+	ie: C:/Music or C:\Music
+*/
+func isDrivePath(path string) bool {
+	if len(path) < 3 || path[1] != ':' || (path[2] != '/' && path[2] != '\\') {
+		return false
+	}
+	c := path[0]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
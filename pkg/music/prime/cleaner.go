@@ -0,0 +1,238 @@
+package prime
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/music"
+)
+
+type brokenEntry struct {
+	Title   sql.NullString `db:"title"`
+	Type    ListType       `db:"listType"`
+	TrackId int            `db:"trackId"`
+}
+
+/*
+	Remove the tracks from their databases, with their metadata and list entries
+*/
+func (l *Library) RemoveTracks(tracks music.Tracks) error {
+	byDB := map[*PrimeDB][]*Track{}
+	for _, it := range tracks {
+		track, ok := it.(*Track)
+		if !ok {
+			return errors.Errorf("invalid track type for '%s'", it)
+		}
+		byDB[track.src] = append(byDB[track.src], track)
+	}
+
+	// the removed tracks must not be matched anymore
+	l.hashCache = nil
+	l.contentIndex.Reset()
+
+	for db, list := range byDB {
+		if err := db.removeTracks(list); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Library) BrokenEntries(fix bool) ([]string, error) {
+	return l.check(fix, (*PrimeDB).brokenEntries)
+}
+
+func (l *Library) EmptyLists(fix bool) ([]string, error) {
+	return l.check(fix, (*PrimeDB).emptyLists)
+}
+
+/*
+	The databases of the other drives are only opened for a library which
+	isn't an export, otherwise all of them would look detached. A database
+	which isn't attached might only be on an unplugged drive, so its tracks are
+	only reported unless its uuid is in detach.
+*/
+func (l *Library) DetachedTracks(detach []string) ([]string, error) {
+	if l.main.IsExported() {
+		return nil, errors.Errorf("'%s' is an export, the databases of the other drives are not opened", l.main.origin)
+	}
+	uuids := map[string]bool{}
+	for _, it := range detach {
+		uuids[it] = true
+	}
+	return l.check(len(uuids) > 0, func(db *PrimeDB, fix bool) ([]string, error) {
+		return db.detachedTracks(uuids)
+	})
+}
+
+func (l *Library) check(fix bool, fct func(db *PrimeDB, fix bool) ([]string, error)) ([]string, error) {
+	out, err := fct(l.main, fix)
+	if err != nil {
+		return out, err
+	}
+	for _, db := range l.dbs {
+		found, err := fct(db, fix)
+		out = append(out, found...)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func (l *PrimeDB) removeTracks(tracks []*Track) error {
+	tx, err := l.sql.Beginx()
+	if err != nil {
+		return errors.Wrapf(err, "fail to start transaction on '%s'", l.path)
+	}
+
+	for _, it := range tracks {
+		if err = deleteTrack(tx, it.entry.Id); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "fail to remove '%s'", it)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrapf(err, "fail to commit removal on '%s'", l.path)
+	}
+
+	for _, it := range tracks {
+		l.forgetTrack(it.entry.Id)
+	}
+	return l.removePerformanceData(tracks)
+}
+
+func (l *PrimeDB) brokenEntries(fix bool) ([]string, error) {
+	entries := []brokenEntry{}
+	query := `SELECT List.title, ListTrackList.listType, ListTrackList.trackId FROM ListTrackList LEFT JOIN List ON List.id = ListTrackList.listId AND List.type = ListTrackList.listType WHERE ListTrackList.trackId NOT IN (SELECT id FROM Track)`
+	if err := l.sql.Select(&entries, query); err != nil {
+		return nil, errors.Wrapf(err, "fail to fetch list entries of '%s'", l.path)
+	}
+
+	out := []string{}
+	for _, it := range entries {
+		out = append(out, fmt.Sprintf("%v '%s' refers to the deleted track %d in '%s'", it.Type, it.Title.String, it.TrackId, l.origin))
+	}
+
+	if fix && len(entries) > 0 {
+		_, err := l.sql.Exec(`DELETE FROM ListTrackList WHERE trackId NOT IN (SELECT id FROM Track)`)
+		if err != nil {
+			return out, errors.Wrapf(err, "fail to delete list entries of '%s'", l.path)
+		}
+	}
+	return out, nil
+}
+
+/*
+	Removing the empty lists can leave their folder empty, so they are looked
+	up again until none is left
+*/
+func (l *PrimeDB) emptyLists(fix bool) ([]string, error) {
+	out := []string{}
+	for {
+		entries := []listEntry{}
+		query := `SELECT id, type, title, path, isFolder FROM List WHERE type IN (?, ?) AND (
+			(isFolder = 0 AND NOT EXISTS (SELECT 1 FROM ListTrackList WHERE listId = List.id AND listType = List.type)) OR
+			(isFolder = 1 AND NOT EXISTS (SELECT 1 FROM ListHierarchy WHERE listId = List.id AND listType = List.type)))`
+		if err := l.sql.Select(&entries, query, ListPlayList, ListCrate); err != nil {
+			return out, errors.Wrapf(err, "fail to fetch lists of '%s'", l.path)
+		}
+
+		for _, it := range entries {
+			kind := it.Type.String()
+			if it.Folder {
+				kind += " folder"
+			}
+			out = append(out, fmt.Sprintf("empty %s '%s' in '%s'", kind, newList(l, it).Path(), l.origin))
+		}
+
+		if !fix || len(entries) == 0 {
+			return out, nil
+		}
+		if err := l.deleteLists(entries); err != nil {
+			return out, err
+		}
+	}
+}
+
+func (l *PrimeDB) deleteLists(entries []listEntry) error {
+	tx, err := l.sql.Beginx()
+	if err != nil {
+		return errors.Wrapf(err, "fail to start transaction on '%s'", l.path)
+	}
+
+	queries := []string{
+		`DELETE FROM ListTrackList WHERE listId = ? AND listType = ?`,
+		`DELETE FROM ListHierarchy WHERE listIdChild = ? AND listTypeChild = ?`,
+		`DELETE FROM ListParentList WHERE listOriginId = ? AND listOriginType = ?`,
+		`DELETE FROM List WHERE id = ? AND type = ?`,
+	}
+	for _, it := range entries {
+		for _, query := range queries {
+			if _, err = tx.Exec(query, it.Id, it.Type); err != nil {
+				tx.Rollback()
+				return errors.Wrapf(err, "fail to delete %v '%s'", it.Type, it.Title)
+			}
+		}
+		logrus.Infof("deleted %v '%s' from PRIME database", it.Type, it.Title)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrapf(err, "fail to commit list removal on '%s'", l.path)
+	}
+	return nil
+}
+
+/*
+	Tracks referencing a database which isn't attached, the ones of the
+	databases in detach become tracks of their own database
+*/
+func (l *PrimeDB) detachedTracks(detach map[string]bool) ([]string, error) {
+	entries := []trackEntry{}
+	query := `SELECT * FROM Track WHERE isExternalTrack = 1 AND uuidOfExternalDatabase IS NOT NULL`
+	if err := l.sql.Unsafe().Select(&entries, query); err != nil {
+		return nil, errors.Wrapf(err, "fail to fetch external tracks of '%s'", l.path)
+	}
+
+	out := []string{}
+	detached := []trackEntry{}
+	for _, it := range entries {
+		uuid := it.ExternalDbId.String
+		if _, ok := l.lib.dbs[uuid]; ok || uuid == l.lib.main.UUID {
+			continue
+		}
+		out = append(out, fmt.Sprintf("'%s' refers to the database %s which isn't attached", newTrack(l, it).FilePath(), uuid))
+		if detach[uuid] {
+			detached = append(detached, it)
+		}
+	}
+
+	if len(detached) == 0 {
+		return out, nil
+	}
+
+	tx, err := l.sql.Beginx()
+	if err != nil {
+		return out, errors.Wrapf(err, "fail to start transaction on '%s'", l.path)
+	}
+	for _, it := range detached {
+		queries := []string{
+			`UPDATE Track SET isExternalTrack = 0, idTrackInExternalDatabase = NULL, uuidOfExternalDatabase = NULL WHERE id = ?`,
+			`UPDATE ListTrackList SET trackIdInOriginDatabase = NULL, databaseUuid = NULL WHERE trackId = ?`,
+		}
+		for _, query := range queries {
+			if _, err = tx.Exec(query, it.Id); err != nil {
+				tx.Rollback()
+				return out, errors.Wrapf(err, "fail to detach track %d of '%s'", it.Id, l.path)
+			}
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return out, errors.Wrapf(err, "fail to commit detached tracks on '%s'", l.path)
+	}
+	return out, nil
+}
@@ -3,8 +3,10 @@ package prime
 import (
 	"database/sql"
 	"fmt"
+	"math"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
@@ -16,6 +18,7 @@ import (
 
 type PrimeDB struct {
 	UUID     string
+	path     string
 	origin   string
 	info     string
 	sql      *sqlx.DB
@@ -28,6 +31,7 @@ func OpenDB(path string, lib *Library) (*PrimeDB, error) {
 	p := &PrimeDB{
 		trackIds: map[string]trackEntry{},
 		lib:      lib,
+		path:     path,
 	}
 
 	logrus.Infof("opening PRIME database located at '%s'", path)
@@ -65,6 +69,13 @@ func OpenDB(path string, lib *Library) (*PrimeDB, error) {
 	return p, nil
 }
 
+/*
+	The database and its performance data companion (p.db)
+*/
+func (l *PrimeDB) databaseFiles() []string {
+	return []string{l.path, filepath.Join(filepath.Dir(l.path), "p.db")}
+}
+
 func (l *PrimeDB) IsExported() bool {
 	query := `select count(trackId) from CopiedTrack`
 	count := 0
@@ -153,11 +164,7 @@ func (l *PrimeDB) buildIdsMap() error {
 		return errors.Wrapf(err, "failed to fetch track ids")
 	}
 	for _, e := range entries {
-		fpath := e.Path.String
-		if !filepath.IsAbs(fpath) {
-			fpath = files.NormalizePath(l.origin + "/" + fpath)
-		}
-		fpath = files.RemoveAccent(fpath)
+		fpath := files.RemoveAccent(l.absPath(e.Path.String))
 		if _, ok := l.trackIds[fpath]; ok {
 			logrus.Warnf("duplicate entry in sqlite for path '%s'", fpath)
 		}
@@ -166,6 +173,125 @@ func (l *PrimeDB) buildIdsMap() error {
 	return nil
 }
 
+/*
+	Paths of the tracks are relative to the database folder
+*/
+func (l *PrimeDB) absPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return files.NormalizePath(l.origin + "/" + path)
+}
+
+/*
+	Path of the file relative to the database, as stored in the Track table
+*/
+func (l *PrimeDB) relPath(path string) string {
+	rpath, err := filepath.Rel(l.origin, path)
+	if err != nil {
+		return path
+	}
+	return rpath
+}
+
+/*
+	Key the entry of a moved track by its new path
+*/
+func (l *PrimeDB) moveEntry(trackId int, oldpath string, rpath string) {
+	entry, ok := l.trackIds[files.RemoveAccent(oldpath)]
+	if !ok || entry.Id != trackId {
+		return
+	}
+	delete(l.trackIds, files.RemoveAccent(oldpath))
+	entry.Path = sql.NullString{String: rpath, Valid: true}
+	entry.Filename = sql.NullString{String: filepath.Base(rpath), Valid: true}
+	l.trackIds[files.RemoveAccent(l.absPath(rpath))] = entry
+}
+
+/*
+	Tell if the file is on the drive of the database, which is in the
+	"Engine Library" folder at its root
+*/
+func (l *PrimeDB) onDrive(path string) bool {
+	root := filepath.Dir(l.origin)
+	rel, err := filepath.Rel(root, files.NormalizePath(path))
+	rel = filepath.ToSlash(rel)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+/*
+	Insert the track and its metadata, read from the tags of the file
+*/
+func (l *PrimeDB) addTrack(path string, meta music.Track) (*Track, error) {
+	path = files.NormalizePath(path)
+	rpath, err := filepath.Rel(l.origin, path)
+	if err != nil {
+		rpath = path
+	}
+	rpath = filepath.ToSlash(rpath)
+
+	tx, err := l.sql.Beginx()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed start db transaction to add '%s'", path)
+	}
+
+	duration := meta.Duration()
+	query := `INSERT INTO Track (length, bpm, bpmAnalyzed, year, path, filename, fileBytes, isExternalTrack, trackType) VALUES (?, ?, ?, ?, ?, ?, ?, 0, 1)`
+	res, err := tx.Exec(query, int(duration.Seconds()), int(math.Round(meta.BPM())), meta.BPM(), meta.Year(), rpath, filepath.Base(path), files.Size(path))
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to add track '%s'", path)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "failed to add track '%s'", path)
+	}
+	trackId := int(id)
+
+	strs := map[MetaStringType]string{
+		MetaTitle:         meta.Title(),
+		MetaArtist:        meta.Artist(),
+		MetaAlbum:         meta.Album(),
+		MetaGenre:         meta.Genre(),
+		MetaComment:       meta.Comment(),
+		MetaDuration:      fmt.Sprintf("%02d:%02d", int(duration.Minutes()), int(duration.Seconds())%60),
+		MetaFileExtension: strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."),
+	}
+	for typ, value := range strs {
+		if err = writeMetaString(tx, trackId, typ, value); err != nil {
+			tx.Rollback()
+			return nil, errors.Wrapf(err, "failed to add %s of '%s'", typ, path)
+		}
+	}
+
+	ints := map[MetaIntType]int64{
+		MetaAdded:   time.Now().Unix(),
+		MetaCreated: meta.Modified().Unix(),
+	}
+	if key := meta.Key(); key.Valid() {
+		ints[MetaKey] = int64(key.Engine())
+	}
+	for typ, value := range ints {
+		if err = writeMetaInt(tx, trackId, typ, value); err != nil {
+			tx.Rollback()
+			return nil, errors.Wrapf(err, "failed to add %s of '%s'", typ, path)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Wrapf(err, "fail to commit transaction for '%s'", path)
+	}
+	logrus.Infof("added '%s' to PRIME database '%s'", path, l.origin)
+
+	entry := trackEntry{}
+	if err = l.sql.Unsafe().Get(&entry, `SELECT * FROM Track WHERE id = ?`, trackId); err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch added track '%s'", path)
+	}
+	l.trackIds[files.RemoveAccent(l.absPath(rpath))] = entry
+	return newTrack(l, entry), nil
+}
+
 func (l *PrimeDB) Track(filename string) music.Track {
 	// if filename == "m:/techno/-= ambient =-/arutani/arutani - the mermaid girl ft. ăvem.mp3" {
 	// 	println("qawewqeq")
@@ -203,5 +329,138 @@ func (l *PrimeDB) Tracks() ([]*Track, error) {
 		tracks = append(tracks, newTrack(l, *e))
 	}
 
+	if err = l.prefetchMeta(tracks); err != nil {
+		// they are read per track when accessed
+		logrus.Warnf("%v", err)
+	}
+
 	return tracks, nil
 }
+
+/*
+	Read the meta strings and integers of all the tracks with one query each,
+	instead of two queries per track when their metadata is first accessed
+*/
+func (l *PrimeDB) prefetchMeta(tracks []*Track) error {
+	strs := metaStringEntries{}
+	query := `SELECT * FROM MetaData`
+	if err := l.sql.Select(&strs, query); err != nil {
+		return errors.Wrapf(err, "query '%s' failed", query)
+	}
+
+	ints := metaIntEntries{}
+	query = `SELECT * FROM MetaDataInteger`
+	if err := l.sql.Select(&ints, query); err != nil {
+		return errors.Wrapf(err, "query '%s' failed", query)
+	}
+
+	byId := map[int]*Track{}
+	for _, it := range tracks {
+		it.metaStrings = metaStringEntries{}
+		it.metaInts = metaIntEntries{}
+		byId[it.entry.Id] = it
+	}
+	for _, it := range strs {
+		if track, ok := byId[it.Id]; ok {
+			track.metaStrings = append(track.metaStrings, it)
+		}
+	}
+	for _, it := range ints {
+		if track, ok := byId[it.Id]; ok {
+			track.metaInts = append(track.metaInts, it)
+		}
+	}
+	return nil
+}
+
+/*
+	Point the list entries of the duplicates to the track and delete them, a
+	list which already contains the track only loses the duplicate
+*/
+func (l *PrimeDB) mergeTracks(track *Track, duplicates []*Track) error {
+	tx, err := l.sql.Beginx()
+	if err != nil {
+		return errors.Wrapf(err, "fail to start transaction on '%s'", l.path)
+	}
+
+	for _, it := range duplicates {
+		queries := []struct {
+			query string
+			args  []interface{}
+		}{
+			{`DELETE FROM ListTrackList WHERE trackId = ? AND EXISTS (SELECT 1 FROM ListTrackList AS other WHERE other.listId = ListTrackList.listId AND other.listType = ListTrackList.listType AND other.trackId = ?)`,
+				[]interface{}{it.entry.Id, track.entry.Id}},
+			{`UPDATE ListTrackList SET trackId = ?, trackIdInOriginDatabase = ?, databaseUuid = ? WHERE trackId = ?`,
+				[]interface{}{track.entry.Id, track.entry.ExternalId, track.entry.ExternalDbId, it.entry.Id}},
+		}
+
+		for _, q := range queries {
+			if _, err = tx.Exec(q.query, q.args...); err != nil {
+				tx.Rollback()
+				return errors.Wrapf(err, "fail to merge '%s' into '%s'", it, track)
+			}
+		}
+		if err = deleteTrack(tx, it.entry.Id); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "fail to merge '%s' into '%s'", it, track)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrapf(err, "fail to commit merge on '%s'", l.path)
+	}
+
+	for _, it := range duplicates {
+		l.forgetTrack(it.entry.Id)
+	}
+	return l.removePerformanceData(duplicates)
+}
+
+/*
+	Delete the track row with its metadata and list entries
+*/
+func deleteTrack(sql sqlx.Execer, trackId int) error {
+	queries := []string{
+		`DELETE FROM ListTrackList WHERE trackId = ?`,
+		`DELETE FROM MetaData WHERE id = ?`,
+		`DELETE FROM MetaDataInteger WHERE id = ?`,
+		`DELETE FROM Track WHERE id = ?`,
+	}
+	for _, query := range queries {
+		if _, err := sql.Exec(query, trackId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *PrimeDB) forgetTrack(id int) {
+	for path, it := range l.trackIds {
+		if it.Id == id {
+			delete(l.trackIds, path)
+		}
+	}
+}
+
+/*
+	The cues and beat grids of the tracks are in p.db, next to the database
+*/
+func (l *PrimeDB) removePerformanceData(tracks []*Track) error {
+	path := filepath.Join(filepath.Dir(l.path), "p.db")
+	if !files.Exists(path) {
+		return nil
+	}
+
+	db, err := sqlx.Open("sqlite3", path)
+	if err != nil {
+		return errors.Wrapf(err, "fail to open performance data '%s'", path)
+	}
+	defer db.Close()
+
+	for _, it := range tracks {
+		if _, err = db.Exec(`DELETE FROM PerformanceData WHERE id = ?`, it.entry.Id); err != nil {
+			return errors.Wrapf(err, "fail to delete performance data of '%s'", it)
+		}
+	}
+	return nil
+}
@@ -5,17 +5,18 @@ import (
 )
 
 type trackEntry struct {
-	Id           int            `db:"id"`
-	Length       sql.NullInt32  `db:"length"`
-	BPM          sql.NullInt32  `db:"bpm"`
-	Year         sql.NullInt32  `db:"year"`
-	Path         sql.NullString `db:"path"`
-	Filename     sql.NullString `db:"filename"`
-	Bitrate      sql.NullInt32  `db:"bitrate"`
-	Size         sql.NullInt32  `db:"fileBytes"`
-	External     sql.NullBool   `db:"isExternalTrack"`
-	ExternalId   sql.NullInt32  `db:"idTrackInExternalDatabase"`
-	ExternalDbId sql.NullString `db:"uuidOfExternalDatabase"`
+	Id           int             `db:"id"`
+	Length       sql.NullInt32   `db:"length"`
+	BPM          sql.NullInt32   `db:"bpm"`
+	BPMAnalyzed  sql.NullFloat64 `db:"bpmAnalyzed"`
+	Year         sql.NullInt32   `db:"year"`
+	Path         sql.NullString  `db:"path"`
+	Filename     sql.NullString  `db:"filename"`
+	Bitrate      sql.NullInt32   `db:"bitrate"`
+	Size         sql.NullInt32   `db:"fileBytes"`
+	External     sql.NullBool    `db:"isExternalTrack"`
+	ExternalId   sql.NullInt32   `db:"idTrackInExternalDatabase"`
+	ExternalDbId sql.NullString  `db:"uuidOfExternalDatabase"`
 	// TrackType  int            `db:"trackType"`
 }
 
@@ -75,10 +76,15 @@ func (m metaStringEntries) Get(typed MetaStringType) string {
 }
 
 func (m metaIntEntries) Get(typed MetaIntType) int64 {
+	value, _ := m.Lookup(typed)
+	return value
+}
+
+func (m metaIntEntries) Lookup(typed MetaIntType) (int64, bool) {
 	for _, it := range m {
 		if it.Type == typed {
-			return it.Value.Int64
+			return it.Value.Int64, it.Value.Valid
 		}
 	}
-	return 0
+	return 0, false
 }
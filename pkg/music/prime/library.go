@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/sirupsen/logrus"
 
@@ -12,12 +13,15 @@ import (
 
 	"primetools/pkg/files"
 	"primetools/pkg/music"
+	flib "primetools/pkg/music/files"
 )
 
 type Library struct {
-	main      *PrimeDB
-	dbs       map[string]*PrimeDB
-	hashCache map[string]music.Tracks
+	main         *PrimeDB
+	dbs          map[string]*PrimeDB
+	hashCache    map[string]music.Tracks
+	contentIndex music.ContentIndex
+	filelib      *flib.FileLibrary
 }
 
 func Open(path string) (music.Library, error) {
@@ -170,24 +174,54 @@ func (l *Library) CreateCrate(path string) (music.Tracklist, error) {
 }
 
 func (i *Library) SupportedExtensions() music.FileExtensions {
-	return music.FileExtensions{} // no import supported for now
-	// return []string{
-	// 	".aac",
-	// 	".aiff",
-	// 	".aif",
-	// 	".flac",
-	// 	".mp3",
-	// 	".mp4",
-	// 	".ogg",
-	// 	".wav",
-	// 	".alac",
-	// }
+	return []string{
+		".aac",
+		".aiff",
+		".aif",
+		".flac",
+		".mp3",
+		".mp4",
+		".m4a",
+		".ogg",
+		".wav",
+		".alac",
+	}
 }
 
+/*
+	Files on the drive of another database are added to it, with a path
+	relative to it, other files are added to the main database
+*/
 func (l *Library) AddFile(path string) (music.Track, error) {
-	msg := "AddFile is not implemented in Library library"
-	logrus.Warnf(msg)
-	return nil, errors.New(msg)
+	if existing := l.Track(path); existing != nil {
+		return existing, nil
+	}
+
+	if l.filelib == nil {
+		l.filelib = flib.Open("")
+	}
+	meta := l.filelib.Track(path)
+	if meta == nil {
+		return nil, errors.Errorf("file '%s' doesn't exists", path)
+	}
+
+	db := l.main
+	for _, it := range l.dbs {
+		if it.onDrive(path) {
+			db = it
+			break
+		}
+	}
+
+	track, err := db.addTrack(path, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	// the new track must be matched
+	l.hashCache = nil
+	l.contentIndex.Reset()
+	return track, nil
 }
 
 func (l *Library) MoveTrack(track music.Track, newpath string) error {
@@ -199,6 +233,106 @@ func (l *Library) MoveTrack(track music.Track, newpath string) error {
 	return itrack.SetPath(newpath)
 }
 
+/*
+	Move all the tracks in one transaction per database, the path is relative
+	to each database like SetPath. The databases are committed in the order of
+	the moves, returns the moves written in at least one committed database.
+*/
+func (l *Library) MoveTracks(moves []music.TrackMove) ([]music.TrackMove, error) {
+	txs := map[*PrimeDB]*sqlx.Tx{}
+	order := []*PrimeDB{}
+	updates := map[*PrimeDB][]func(){}
+	rollback := func() {
+		for _, it := range txs {
+			it.Rollback()
+		}
+	}
+
+	committed := make([]bool, len(moves))
+	for idx, it := range moves {
+		idx, it := idx, it
+		track, ok := it.Track.(*Track)
+		if !ok {
+			rollback()
+			return nil, errors.Errorf("invalid track type for '%s'", it.Track)
+		}
+
+		oldpath := track.FilePath()
+		err := track.databases(func(db *PrimeDB, trackId int) error {
+			tx, ok := txs[db]
+			if !ok {
+				var err error
+				if tx, err = db.sql.Beginx(); err != nil {
+					return errors.Wrapf(err, "fail to start transaction on '%s'", db.path)
+				}
+				txs[db] = tx
+				order = append(order, db)
+			}
+
+			rpath := db.relPath(it.Path)
+			if err := writeFilepath(tx, trackId, rpath); err != nil {
+				return err
+			}
+			updates[db] = append(updates[db], func() {
+				track.moved(db, trackId, oldpath, rpath)
+				committed[idx] = true
+			})
+			return nil
+		})
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+	}
+
+	var err error
+	for _, db := range order {
+		if err = txs[db].Commit(); err != nil {
+			rollback()
+			err = errors.Wrapf(err, "fail to commit moves on '%s'", db.path)
+			break
+		}
+		for _, it := range updates[db] {
+			it()
+		}
+	}
+
+	done := []music.TrackMove{}
+	for idx, it := range moves {
+		if committed[idx] {
+			done = append(done, it)
+		}
+	}
+	return done, err
+}
+
+/*
+	Fold the duplicates into the survivor, they must be in its database
+*/
+func (l *Library) MergeTracks(survivor music.Track, duplicates music.Tracks) error {
+	track, ok := survivor.(*Track)
+	if !ok {
+		return errors.Errorf("invalid track type for '%s'", survivor)
+	}
+
+	list := []*Track{}
+	for _, it := range duplicates {
+		dupe, ok := it.(*Track)
+		if !ok {
+			return errors.Errorf("invalid track type for '%s'", it)
+		}
+		if dupe.src != track.src {
+			return errors.Errorf("'%s' and '%s' are not in the same database", dupe, track)
+		}
+		list = append(list, dupe)
+	}
+
+	// the removed tracks must not be matched anymore
+	l.hashCache = nil
+	l.contentIndex.Reset()
+	return track.src.mergeTracks(track, list)
+}
+
 func (l *Library) Track(filename string) music.Track {
 	if track := l.main.Track(filename); track != nil {
 		return track
@@ -230,7 +364,7 @@ func (l *Library) Matches(track music.Track) (matches music.Tracks) {
 			return nil
 		})
 		if err != nil {
-			logrus.Error("%v", err)
+			logrus.Errorf("%v", err)
 		}
 		logrus.Infof("processed %d tracks in %v", len(l.hashCache), time.Since(start))
 	}
@@ -240,43 +374,56 @@ func (l *Library) Matches(track music.Track) (matches music.Tracks) {
 		matches = append(matches, match...)
 	}
 
-	h := music.TrackHash(track)
-	return l.hashCache[h]
+	// the metadata might have been edited since, fallback on the audio content
+	if len(matches) == 0 {
+		matches = append(matches, l.contentIndex.Matches(l, track)...)
+	}
+
+	return matches.Dedupe()
 }
 
 func (l *Library) uniqueTracks() ([]*Track, error) {
-	mainTracks, err := l.main.Tracks()
+	list, err := l.main.Tracks()
 	if err != nil {
 		return nil, err
 	}
 
-	mape := map[string]*Track{}
-	for _, it := range mainTracks {
-		mape[music.TrackHash(it)] = it
+	// the same metadata doesn't mean the same file, ie: a duplicate
+	byHash := map[string][]*Track{}
+	for _, it := range list {
+		hash := music.TrackHash(it)
+		byHash[hash] = append(byHash[hash], it)
 	}
 
 	for _, db := range l.dbs {
-		if list, err := db.Tracks(); err == nil {
-			for _, it := range list {
-				hash := music.TrackHash(it)
-				// ignore duplicate in sub db which already exists in the main DB since they have a reference to
-				// the origin DB
-				if dupe, found := mape[hash]; !found || !music.IsSameFile(dupe, it) {
-					mape[hash] = it
-				}
-			}
-		} else {
+		tracks, err := db.Tracks()
+		if err != nil {
 			return nil, err
 		}
-	}
 
-	list := []*Track{}
-	for _, v := range mape {
-		list = append(list, v)
+		for _, it := range tracks {
+			hash := music.TrackHash(it)
+			// ignore duplicate in sub db which already exists in the main DB since they have a reference to
+			// the origin DB
+			if sameFile(byHash[hash], it) {
+				continue
+			}
+			byHash[hash] = append(byHash[hash], it)
+			list = append(list, it)
+		}
 	}
 	return list, nil
 }
 
+func sameFile(tracks []*Track, track *Track) bool {
+	for _, it := range tracks {
+		if music.IsSameFile(it, track) {
+			return true
+		}
+	}
+	return false
+}
+
 func (l *Library) ForEachTrack(fct music.EachTrackFunc) error {
 	list, err := l.uniqueTracks()
 	if err != nil {
@@ -291,6 +438,14 @@ func (l *Library) ForEachTrack(fct music.EachTrackFunc) error {
 	return nil
 }
 
+func (l *Library) DatabaseFiles() []string {
+	out := l.main.databaseFiles()
+	for _, it := range l.dbs {
+		out = append(out, it.databaseFiles()...)
+	}
+	return out
+}
+
 func (l *Library) String() string {
 	return l.main.info
 }
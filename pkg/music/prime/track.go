@@ -1,7 +1,9 @@
 package prime
 
 import (
+	"database/sql"
 	"encoding/json"
+	"math"
 	"path/filepath"
 	"sync"
 	"time"
@@ -11,7 +13,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
-	"primetools/pkg/files"
 	"primetools/pkg/music"
 )
 
@@ -25,10 +26,8 @@ type Track struct {
 
 func newTrack(src *PrimeDB, entry trackEntry) *Track {
 	return &Track{
-		src:         src,
-		entry:       entry,
-		metaStrings: metaStringEntries{},
-		metaInts:    metaIntEntries{},
+		src:   src,
+		entry: entry,
 	}
 }
 
@@ -81,25 +80,109 @@ func (t *Track) SetPlayCount(count int) error {
 	return errors.New(msg)
 }
 
-func (t *Track) FilePath() string {
-	if filepath.IsAbs(t.entry.Path.String) {
-		return t.entry.Path.String
-	} else {
-		return files.NormalizePath(t.src.origin + "/" + t.entry.Path.String)
+func (t *Track) BPM() float64 {
+	if t.entry.BPMAnalyzed.Valid && t.entry.BPMAnalyzed.Float64 > 0 {
+		return t.entry.BPMAnalyzed.Float64
 	}
+	return float64(t.entry.BPM.Int32)
 }
 
-func (t *Track) SetPath(newpath string) error {
-	rpath, err := filepath.Rel(t.src.origin, newpath)
-	if err != nil {
-		rpath = newpath
+func (t *Track) SetBPM(bpm float64) error {
+	err := t.runQuery(func(sql *sqlx.DB, trackId int) error {
+		return writeBPM(sql, trackId, bpm)
+	})
+	if err == nil {
+		t.entry.BPM.Int32 = int32(math.Round(bpm))
+		t.entry.BPMAnalyzed.Float64 = bpm
+		t.entry.BPMAnalyzed.Valid = true
+	}
+	return err
+}
+
+func (t *Track) Key() music.Key {
+	t.readMetaInts()
+	if key, ok := t.metaInts.Lookup(MetaKey); ok {
+		return music.KeyFromEngine(int(key))
 	}
+	return music.KeyUnknown
+}
+
+func (t *Track) SetKey(key music.Key) error {
+	if !key.Valid() {
+		return errors.Errorf("cannot set invalid key to track '%s'", t.String())
+	}
+	return t.writeMetaIntCascade(MetaKey, int64(key.Engine()))
+}
+
+func (t *Track) ClearKey() error {
+	err := t.runQuery(func(sql *sqlx.DB, trackId int) error {
+		query := `DELETE FROM MetaDataInteger WHERE id = ? AND type = ?`
+		_, err := sql.Exec(query, trackId, MetaKey)
+		return errors.Wrapf(err, "failed to clear key of track '%s'", t.String())
+	})
+
+	// force a reload of the meta ints on next read
+	t.mutex.Lock()
+	t.metaInts = nil
+	t.mutex.Unlock()
+	return err
+}
 
-	return t.runQuery(func(sql *sqlx.DB, trackId int) error {
-		return writeFilepath(sql, trackId, rpath)
+func (t *Track) Genre() string {
+	t.readMetaString()
+	return t.metaStrings.Get(MetaGenre)
+}
+
+func (t *Track) SetGenre(genre string) error {
+	return t.writeMetaStringCascade(MetaGenre, genre)
+}
+
+func (t *Track) Comment() string {
+	t.readMetaString()
+	return t.metaStrings.Get(MetaComment)
+}
+
+func (t *Track) SetComment(comment string) error {
+	return t.writeMetaStringCascade(MetaComment, comment)
+}
+
+func (t *Track) Duration() time.Duration {
+	return time.Duration(t.entry.Length.Int32) * time.Second
+}
+
+func (t *Track) FilePath() string {
+	return t.src.absPath(t.entry.Path.String)
+}
+
+/*
+	The path is relative to each database of the track, the track reports the
+	new path once written
+*/
+func (t *Track) SetPath(newpath string) error {
+	oldpath := t.FilePath()
+	return t.databases(func(db *PrimeDB, trackId int) error {
+		rpath := db.relPath(newpath)
+		if err := writeFilepath(db.sql, trackId, rpath); err != nil {
+			logrus.Errorf("%v", err)
+			return err
+		}
+		t.moved(db, trackId, oldpath, rpath)
+		return nil
 	})
 }
 
+/*
+	Update the entries in memory once the new path is written in db, its ids
+	map is still keyed by the previous path of the track
+*/
+func (t *Track) moved(db *PrimeDB, trackId int, oldpath string, rpath string) {
+	db.moveEntry(trackId, oldpath, rpath)
+	if db == t.src {
+		t.entry.Path = sql.NullString{String: rpath, Valid: true}
+		t.entry.Filename = sql.NullString{String: filepath.Base(rpath), Valid: true}
+	}
+}
+
 func (t *Track) Title() string {
 	t.readMetaString()
 	return t.metaStrings.Title()
@@ -160,11 +243,26 @@ func (t *Track) runQuery(fct func(sql *sqlx.DB, trackId int) error) error {
 	return nil
 }
 
+/*
+	Same as runQuery, with the database of each copy of the track
+*/
+func (t *Track) databases(fct func(db *PrimeDB, trackId int) error) error {
+	if err := fct(t.src, t.entry.Id); err != nil {
+		return err
+	}
+	if t.isExternal() {
+		if db, ok := t.src.lib.dbs[t.entry.ExternalDbId.String]; ok {
+			return fct(db, int(t.entry.ExternalId.Int32))
+		}
+	}
+	return nil
+}
+
 func (t *Track) isExternal() bool {
 	return t.entry.External.Bool && t.entry.ExternalDbId.Valid && t.entry.ExternalId.Valid
 }
 
-func writeFilepath(sql *sqlx.DB, trackId int, newpath string) error {
+func writeFilepath(sql sqlx.Execer, trackId int, newpath string) error {
 	query := `UPDATE Track SET path = ?, filename = ? WHERE id = ?`
 	fname := filepath.Base(newpath)
 
@@ -176,16 +274,54 @@ func writeFilepath(sql *sqlx.DB, trackId int, newpath string) error {
 	return nil
 }
 
+func writeBPM(sql *sqlx.DB, trackId int, bpm float64) error {
+	query := `UPDATE Track SET bpm = ?, bpmAnalyzed = ? WHERE id = ?`
+
+	_, err := sql.Exec(query, int(math.Round(bpm)), bpm, trackId)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set bpm of track '%v' in PRIME db", trackId)
+	}
+	return nil
+}
+
+func (t *Track) writeMetaStringCascade(meta MetaStringType, value string) error {
+	err := t.runQuery(func(sql *sqlx.DB, trackId int) error {
+		return writeMetaString(sql, trackId, meta, value)
+	})
+
+	// force a reload of the meta strings on next read
+	t.mutex.Lock()
+	t.metaStrings = nil
+	t.mutex.Unlock()
+	return err
+}
+
+func writeMetaString(sql sqlx.Execer, trackId int, meta MetaStringType, value string) error {
+	query := `INSERT OR REPLACE INTO MetaData (text, id, type) VALUES (?, ?, ?)`
+
+	_, err := sql.Exec(query, value, trackId, meta)
+	if err != nil {
+		return errors.Wrapf(err, "running query '%s'", query)
+	}
+	return nil
+}
+
 func (t *Track) writeMetaIntCascade(meta MetaIntType, value int64) error {
-	return t.runQuery(func(sql *sqlx.DB, trackId int) error {
+	err := t.runQuery(func(sql *sqlx.DB, trackId int) error {
 		// if strings.Contains(t.String(), "Alina (Microtrauma Remix)") {
 		// 	logrus.Print(t.String())
 		// }
 		return writeMetaInt(sql, trackId, meta, value)
 	})
+
+	// force a reload of the meta ints on next read
+	t.mutex.Lock()
+	t.metaInts = nil
+	t.mutex.Unlock()
+	return err
 }
 
-func writeMetaInt(sql *sqlx.DB, trackId int, meta MetaIntType, value int64) error {
+func writeMetaInt(sql sqlx.Execer, trackId int, meta MetaIntType, value int64) error {
 
 	// query := `UPDATE MetaDataInteger SET value = ? WHERE id = ? AND type = ?`
 	query := `INSERT OR REPLACE INTO MetaDataInteger (value, id, type) VALUES (?, ?, ?)`
@@ -206,27 +342,33 @@ func writeMetaInt(sql *sqlx.DB, trackId int, meta MetaIntType, value int64) erro
 func (t *Track) readMetaString() {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	if len(t.metaStrings) > 0 {
+	if t.metaStrings != nil {
 		return
 	}
 
+	entries := metaStringEntries{}
 	query := `select * from MetaData WHERE id = ?`
-	err := t.src.sql.Select(&t.metaStrings, query, t.entry.Id)
+	err := t.src.sql.Select(&entries, query, t.entry.Id)
 	if err != nil {
 		logrus.Errorf("failed to read meta strings from sqlite: %v", err)
+		return
 	}
+	t.metaStrings = entries
 }
 
 func (t *Track) readMetaInts() {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	if len(t.metaInts) > 0 {
+	if t.metaInts != nil {
 		return
 	}
 
+	entries := metaIntEntries{}
 	query := `select * from MetaDataInteger WHERE id = ?`
-	err := t.src.sql.Select(&t.metaInts, query, t.entry.Id)
+	err := t.src.sql.Select(&entries, query, t.entry.Id)
 	if err != nil {
 		logrus.Errorf("failed to read meta ints from sqlite: %v", err)
+		return
 	}
+	t.metaInts = entries
 }
@@ -0,0 +1,144 @@
+package rekordbox
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+
+	"primetools/pkg/music"
+)
+
+/*
+	Remove the tracks from the collection and the playlists, the xml is written
+	back at once
+*/
+func (l *Library) RemoveTracks(tracks music.Tracks) error {
+	removed := map[int]bool{}
+	for _, it := range tracks {
+		track, ok := it.(*Track)
+		if !ok {
+			return errors.Errorf("invalid track type for '%s'", it)
+		}
+		removed[track.xml.TrackID] = true
+		delete(l.keyToTrack, track.xml.TrackID)
+		delete(l.pathToTrack, track.FilePath())
+	}
+
+	kept := []XmlTrack{}
+	for _, it := range l.xml.Collection.Tracks {
+		if !removed[it.TrackID] {
+			kept = append(kept, it)
+		}
+	}
+	l.xml.Collection.Tracks = kept
+	l.hashCache = nil
+	l.contentIndex.Reset()
+
+	// their playlist entries are now broken
+	if _, err := l.brokenEntries(true); err != nil {
+		return err
+	}
+	return l.Export()
+}
+
+/*
+	Playlist entries whose key isn't in the collection
+*/
+func (l *Library) BrokenEntries(fix bool) ([]string, error) {
+	out, err := l.brokenEntries(fix)
+	if err != nil || !fix || len(out) == 0 {
+		return out, err
+	}
+	return out, l.Export()
+}
+
+func (l *Library) brokenEntries(fix bool) ([]string, error) {
+	out := []string{}
+
+	var walk func(node *XmlPlaylistNode, parentName string)
+	walk = func(node *XmlPlaylistNode, parentName string) {
+		name := nodePath(node, parentName)
+
+		kept := []XmlPlaylistTrack{}
+		for _, it := range node.Tracks {
+			if _, ok := l.keyToTrack[it.Key]; ok {
+				kept = append(kept, it)
+			} else {
+				out = append(out, fmt.Sprintf("playlist '%s' refer to a invalid track key %d", name, it.Key))
+			}
+		}
+		if fix && len(kept) != len(node.Tracks) {
+			node.Tracks = kept
+		}
+
+		for idx := range node.Childs {
+			walk(&node.Childs[idx], name)
+		}
+	}
+
+	for idx := range l.xml.Nodes {
+		walk(&l.xml.Nodes[idx], "")
+	}
+	return out, nil
+}
+
+/*
+	Playlists without tracks and folders without childs, a folder whose childs
+	are all empty is empty as well. The ROOT folder is always kept.
+*/
+func (l *Library) EmptyLists(fix bool) ([]string, error) {
+	out := []string{}
+
+	var prune func(node *XmlPlaylistNode, parentName string) bool
+	prune = func(node *XmlPlaylistNode, parentName string) bool {
+		if node.Type == nodePlaylist {
+			return len(node.Tracks) == 0
+		}
+
+		name := nodePath(node, parentName)
+		kept := []XmlPlaylistNode{}
+		for idx := range node.Childs {
+			child := &node.Childs[idx]
+			if prune(child, name) {
+				kind := "playlist"
+				if child.Type == nodeFolder {
+					kind = "folder"
+				}
+				out = append(out, fmt.Sprintf("empty %s '%s'", kind, nodePath(child, name)))
+				continue
+			}
+			kept = append(kept, *child)
+		}
+		if fix {
+			node.Childs = kept
+		}
+		return len(kept) == 0
+	}
+
+	for idx := range l.xml.Nodes {
+		prune(&l.xml.Nodes[idx], "")
+	}
+
+	if fix && len(out) > 0 {
+		return out, l.Export()
+	}
+	return out, nil
+}
+
+/*
+	A rekordbox xml doesn't refer to other databases
+*/
+func (l *Library) DetachedTracks(detach []string) ([]string, error) {
+	return nil, nil
+}
+
+/*
+	Same path as the tracklists, the ROOT folder isn't part of it
+*/
+func nodePath(node *XmlPlaylistNode, parentName string) string {
+	if parentName == "ROOT" {
+		return node.Name
+	}
+	return path.Join(parentName, node.Name)
+}
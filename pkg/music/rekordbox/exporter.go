@@ -0,0 +1,120 @@
+package rekordbox
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+func (l *Library) AddTrack(track music.Track) error {
+	xmltrack := XmlTrack{}
+	xmltrack.CopyFromTrack(track)
+
+	if existing, ok := l.pathToTrack[track.FilePath()].(*Track); ok {
+		for idx := range l.xml.Collection.Tracks {
+			if l.xml.Collection.Tracks[idx].TrackID == existing.xml.TrackID {
+				xmltrack.TrackID = existing.xml.TrackID
+				l.xml.Collection.Tracks[idx] = xmltrack
+				break
+			}
+		}
+	} else {
+		l.lastKey++
+		xmltrack.TrackID = l.lastKey
+		l.xml.Collection.Tracks = append(l.xml.Collection.Tracks, xmltrack)
+	}
+
+	added := newTrack(xmltrack)
+	l.keyToTrack[xmltrack.TrackID] = added
+	l.pathToTrack[track.FilePath()] = added
+	l.hashCache = nil
+	return nil
+}
+
+/*
+	Playlist path folders are created as needed, an existing playlist with the
+	same path gets its tracks replaced
+*/
+func (l *Library) AddPlaylist(tracklist music.Tracklist) error {
+	if len(l.xml.Nodes) == 0 {
+		l.xml.Nodes = []XmlPlaylistNode{{Type: nodeFolder, Name: "ROOT"}}
+	}
+
+	split := strings.Split(tracklist.Path(), "/")
+	node := &l.xml.Nodes[0]
+	for _, name := range split[:len(split)-1] {
+		node = node.child(name, nodeFolder)
+		if node == nil {
+			return errors.Errorf("cannot create folder '%s' for playlist '%s', a playlist already has that name", name, tracklist.Path())
+		}
+	}
+
+	playlist := node.child(split[len(split)-1], nodePlaylist)
+	if playlist == nil {
+		return errors.Errorf("cannot create playlist '%s', a folder already has that name", tracklist.Path())
+	}
+	playlist.KeyType = keyTypeTrackID
+	playlist.Tracks = nil
+
+	for _, track := range tracklist.Tracks() {
+		if _, ok := l.pathToTrack[track.FilePath()]; !ok {
+			if err := l.AddTrack(track); err != nil {
+				return err
+			}
+		}
+		existing := l.pathToTrack[track.FilePath()].(*Track)
+		playlist.Tracks = append(playlist.Tracks, XmlPlaylistTrack{Key: existing.xml.TrackID})
+	}
+
+	logrus.Infof("added playlist '%s' with %d tracks", tracklist.Path(), len(playlist.Tracks))
+	return nil
+}
+
+/*
+	Find or create the child node with that name, nil is returned if the existing
+	node isn't of the same type
+*/
+func (x *XmlPlaylistNode) child(name string, nodeType int) *XmlPlaylistNode {
+	for idx := range x.Childs {
+		if x.Childs[idx].Name == name {
+			if x.Childs[idx].Type != nodeType {
+				return nil
+			}
+			return &x.Childs[idx]
+		}
+	}
+	x.Childs = append(x.Childs, XmlPlaylistNode{Type: nodeType, Name: name})
+	return &x.Childs[len(x.Childs)-1]
+}
+
+func (l *Library) Export() error {
+	if l.path == "" {
+		return errors.New("rekordbox export require a target path")
+	}
+
+	start := time.Now()
+
+	l.xml.Collection.Entries = len(l.xml.Collection.Tracks)
+	for idx := range l.xml.Nodes {
+		l.xml.Nodes[idx].updateCounts()
+	}
+
+	content, err := xml.MarshalIndent(&l.xml, "", "  ")
+	if err != nil {
+		return errors.WithMessage(err, "failed to encode into xml")
+	}
+	content = append([]byte(xml.Header), content...)
+
+	if err = files.WriteFileAtomic(l.path, content); err != nil {
+		return err
+	}
+
+	logrus.Infof("successfully exported %d tracks to file '%s' in %v", l.xml.Collection.Entries, l.path, time.Since(start))
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
-	"path"
 	"strings"
 	"time"
 
@@ -18,14 +17,36 @@ import (
 type Library struct {
 	xml  XmlLibrary
 	info string
+	path string
 
-	keyToTrack  map[int]music.Track
-	pathToTrack map[string]music.Track
-	hashCache   map[string]music.Tracks
+	keyToTrack   map[int]music.Track
+	lastKey      int
+	pathToTrack  map[string]music.Track
+	hashCache    map[string]music.Tracks
+	contentIndex music.ContentIndex
+}
+
+/*
+	Create an empty rekordbox xml library which will be written to path on Export
+*/
+func Create(path string) (music.Library, error) {
+	lib := &Library{
+		path:        path,
+		keyToTrack:  map[int]music.Track{},
+		pathToTrack: map[string]music.Track{},
+	}
+	lib.xml.Version = "1.0.0"
+	lib.xml.Product.Name = "rekordbox"
+	lib.xml.Product.Version = "6.0.0"
+	lib.xml.Product.Company = "AlphaTheta"
+	lib.xml.Nodes = []XmlPlaylistNode{{Type: nodeFolder, Name: "ROOT"}}
+	lib.info = fmt.Sprintf("%v: Version: %v, Company: %s, Path: %v", lib.xml.Product.Name, lib.xml.Product.Version, lib.xml.Product.Company, path)
+	return lib, nil
 }
 
 func Open(path string) (*Library, error) {
 	lib := &Library{
+		path:        path,
 		keyToTrack:  map[int]music.Track{},
 		pathToTrack: map[string]music.Track{},
 	}
@@ -52,13 +73,16 @@ func Open(path string) (*Library, error) {
 		return nil, errors.Errorf("rekordbox library file looks invalid, empty product id")
 	}
 
-	for _, it := range lib.xml.Tracks {
+	for _, it := range lib.xml.Collection.Tracks {
 		track := newTrack(it)
 		lib.keyToTrack[it.TrackID] = track
+		if it.TrackID > lib.lastKey {
+			lib.lastKey = it.TrackID
+		}
 		lib.pathToTrack[track.FilePath()] = track
 	}
 
-	lib.info = fmt.Sprintf("%v: Version: %v, Company: %s, Track Count: %d", lib.xml.Product.Name, lib.xml.Product.Version, lib.xml.Product.Company, len(lib.xml.Tracks))
+	lib.info = fmt.Sprintf("%v: Version: %v, Company: %s, Track Count: %d", lib.xml.Product.Name, lib.xml.Product.Version, lib.xml.Product.Company, len(lib.xml.Collection.Tracks))
 	logrus.Infof("sucessfully loaded rekordbox library in %s", time.Since(start))
 
 	return lib, nil
@@ -103,7 +127,7 @@ func (l *Library) Matches(track music.Track) (matches music.Tracks) {
 			return nil
 		})
 		if err != nil {
-			logrus.Error("%v", err)
+			logrus.Errorf("%v", err)
 		}
 		logrus.Infof("processed %d tracks in %v", len(l.hashCache), time.Since(start))
 	}
@@ -113,8 +137,12 @@ func (l *Library) Matches(track music.Track) (matches music.Tracks) {
 		matches = append(matches, match...)
 	}
 
-	h := music.TrackHash(track)
-	return l.hashCache[h]
+	// the metadata might have been edited since, fallback on the audio content
+	if len(matches) == 0 {
+		matches = append(matches, l.contentIndex.Matches(l, track)...)
+	}
+
+	return matches.Dedupe()
 }
 
 func (l *Library) Playlists() []music.Tracklist {
@@ -135,12 +163,9 @@ func (l *Library) tracklists() []music.Tracklist {
 }
 
 func (l *Library) flatten(node XmlPlaylistNode, parentName string) (lists []music.Tracklist) {
-	pat := node.Name
-	if parentName != "ROOT" {
-		pat = path.Join(parentName, node.Name)
-	}
+	pat := nodePath(&node, parentName)
 
-	if node.Type == 1 {
+	if node.Type == nodePlaylist {
 		if len(node.Tracks) > 0 {
 			lists = append(lists, &TrackList{
 				lib:  l,
@@ -157,8 +182,8 @@ func (l *Library) flatten(node XmlPlaylistNode, parentName string) (lists []musi
 }
 
 func (l *Library) ForEachTrack(fct music.EachTrackFunc) error {
-	count := len(l.xml.Tracks)
-	for idx, it := range l.xml.Tracks {
+	count := len(l.xml.Collection.Tracks)
+	for idx, it := range l.xml.Collection.Tracks {
 		track := &Track{it}
 		err := fct(idx, count, track)
 		if err != nil {
@@ -168,6 +193,10 @@ func (l *Library) ForEachTrack(fct music.EachTrackFunc) error {
 	return nil
 }
 
+func (l *Library) DatabaseFiles() []string {
+	return []string{l.path}
+}
+
 func (l *Library) String() string {
 	return l.info
 }
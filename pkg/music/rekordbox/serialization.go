@@ -1,43 +1,128 @@
 package rekordbox
 
 import (
+	"encoding/xml"
+	"image/color"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"github.com/sirupsen/logrus"
 
+	"primetools/pkg/files"
 	"primetools/pkg/music"
 )
 
+const (
+	DateFormat = "2006-01-02"
+
+	nodeFolder   = 0
+	nodePlaylist = 1
+
+	// playlist entries refer to the TrackID of the collection
+	keyTypeTrackID = "0"
+
+	markTypeCue  = 0
+	markTypeLoop = 4
+
+	// memory cues are not assigned to a hot cue slot
+	memoryCue = -1
+)
+
 type XmlLibrary struct {
+	XMLName xml.Name `xml:"DJ_PLAYLISTS"`
+	Version string   `xml:"Version,attr"`
+
 	Product struct {
 		Name    string `xml:"Name,attr"`
 		Version string `xml:"Version,attr"`
 		Company string `xml:"Company,attr"`
 	} `xml:"PRODUCT"`
 
-	Tracks []XmlTrack        `xml:"COLLECTION>TRACK"`
-	Nodes  []XmlPlaylistNode `xml:"PLAYLISTS>NODE"`
+	Collection struct {
+		Entries int        `xml:"Entries,attr"`
+		Tracks  []XmlTrack `xml:"TRACK"`
+	} `xml:"COLLECTION"`
+
+	Nodes []XmlPlaylistNode `xml:"PLAYLISTS>NODE"`
 }
 
+/*
+<TRACK TrackID="1" Name="Enter The Lovely" Artist="Bluetech" Album="Sines And Singularities" Genre="Chill"
+       Kind="MP3 File" Size="14639" TotalTime="494" Year="2005" AverageBpm="120.00" DateAdded="2009-01-07"
+       PlayCount="2" Rating="153" Tonality="Am" Comments="Aleph Zero"
+       Location="file://localhost/M:/Techno/Bluetech/01%20-%20Enter%20The%20Lovely.mp3"/>
+*/
 type XmlTrack struct {
-	TrackID   int    `xml:"TrackID,attr"`
-	Name      string `xml:"Name,attr"`
-	Album     string `xml:"Album,attr"`
-	Artist    string `xml:"Artist,attr"`
-	Genre     string `xml:"Genre,attr"`
-	Year      int    `xml:"Year,attr"`
-	Size      int64  `xml:"Size,attr"`
-	Rating    int    `xml:"Rating,attr"`
-	DateAdded string `xml:"DateAdded,attr"`
-	PlayCount int    `xml:"PlayCount,attr"`
-	Location  string `xml:"Location,attr"`
+	TrackID    int     `xml:"TrackID,attr"`
+	Name       string  `xml:"Name,attr"`
+	Artist     string  `xml:"Artist,attr"`
+	Album      string  `xml:"Album,attr"`
+	Genre      string  `xml:"Genre,attr"`
+	Kind       string  `xml:"Kind,attr,omitempty"`
+	Year       int     `xml:"Year,attr"`
+	Size       int64   `xml:"Size,attr"`
+	TotalTime  int     `xml:"TotalTime,attr"`
+	AverageBpm float64 `xml:"AverageBpm,attr"`
+	Tonality   string  `xml:"Tonality,attr"`
+	Comments   string  `xml:"Comments,attr"`
+	Rating     int     `xml:"Rating,attr"`
+	DateAdded  string  `xml:"DateAdded,attr"`
+	PlayCount  int     `xml:"PlayCount,attr"`
+	Location   string  `xml:"Location,attr"`
+
+	Tempos        []XmlTempo        `xml:"TEMPO"`
+	PositionMarks []XmlPositionMark `xml:"POSITION_MARK"`
+}
+
+/*
+<TEMPO Inizio="0.025" Bpm="128.00" Metro="4/4" Battito="1"/>
+
+	Inizio is the position in seconds, Battito the beat number in the bar (1 to 4)
+*/
+type XmlTempo struct {
+	Inizio  float64 `xml:"Inizio,attr"`
+	Bpm     float64 `xml:"Bpm,attr"`
+	Metro   string  `xml:"Metro,attr"`
+	Battito int     `xml:"Battito,attr"`
 }
 
+/*
+<POSITION_MARK Name="Drop" Type="0" Start="64.025" Num="1" Red="40" Green="226" Blue="20"/>
+
+	Positions are in seconds, Num is the hot cue slot or -1 for memory cues
+*/
+type XmlPositionMark struct {
+	Name  string   `xml:"Name,attr"`
+	Type  int      `xml:"Type,attr"`
+	Start float64  `xml:"Start,attr"`
+	End   *float64 `xml:"End,attr"`
+	Num   int      `xml:"Num,attr"`
+	Red   *int     `xml:"Red,attr"`
+	Green *int     `xml:"Green,attr"`
+	Blue  *int     `xml:"Blue,attr"`
+}
+
+/*
+<NODE Type="0" Name="ROOT" Count="1">
+	<NODE Type="1" Name="all.best" KeyType="0" Entries="1">
+		<TRACK Key="1"/>
+	</NODE>
+</NODE>
+*/
 type XmlPlaylistNode struct {
-	Type   int    `xml:"Type,attr"`
-	Name   string `xml:"Name,attr"`
-	Tracks []struct {
-		Key int `xml:"Key,attr"`
-	} `xml:"TRACK"`
-	Childs []XmlPlaylistNode `xml:"NODE"`
+	Type    int                `xml:"Type,attr"`
+	Name    string             `xml:"Name,attr"`
+	Count   *int               `xml:"Count,attr"`
+	KeyType string             `xml:"KeyType,attr,omitempty"`
+	Entries *int               `xml:"Entries,attr"`
+	Tracks  []XmlPlaylistTrack `xml:"TRACK"`
+	Childs  []XmlPlaylistNode  `xml:"NODE"`
+}
+
+type XmlPlaylistTrack struct {
+	Key int `xml:"Key,attr"`
 }
 
 func (x XmlPlaylistNode) toTracks(library *Library) (tracks music.Tracks) {
@@ -50,3 +135,179 @@ func (x XmlPlaylistNode) toTracks(library *Library) (tracks music.Tracks) {
 		}
 		return
 }
+
+/*
+	Update counts attributes, folders count their childs and playlists their tracks
+*/
+func (x *XmlPlaylistNode) updateCounts() {
+	count := 0
+	if x.Type == nodeFolder {
+		for idx := range x.Childs {
+			x.Childs[idx].updateCounts()
+		}
+		count = len(x.Childs)
+		x.Count = &count
+		x.Entries = nil
+	} else {
+		count = len(x.Tracks)
+		x.Entries = &count
+		x.Count = nil
+	}
+}
+
+func (x *XmlTrack) CopyFromTrack(track music.Track) {
+	x.Name = track.Title()
+	x.Artist = track.Artist()
+	x.Album = track.Album()
+	x.Genre = track.Genre()
+	x.Kind = fileKind(track.FilePath())
+	x.Year = track.Year()
+	x.Size = track.Size()
+	x.TotalTime = int(track.Duration().Seconds())
+	x.AverageBpm = track.BPM()
+	x.Tonality = ""
+	if key := track.Key(); key.Valid() {
+		x.Tonality = key.String()
+	}
+	x.Comments = track.Comment()
+	x.Rating = int(track.Rating()) * 51
+	x.DateAdded = track.Added().Format(DateFormat)
+	x.PlayCount = track.PlayCount()
+	x.Location = files.ConvertFilePathToUrl(track.FilePath())
+
+	if ptrack, ok := track.(music.PerformanceTrack); ok {
+		data, err := ptrack.PerformanceData()
+		if err != nil {
+			logrus.Warnf("failed to read performance data of '%s': %v", track, err)
+		} else if !data.Empty() {
+			x.setPerformanceData(data)
+		}
+	}
+}
+
+func (x XmlTrack) performanceData() *music.PerformanceData {
+	data := &music.PerformanceData{
+		BPM: x.AverageBpm,
+	}
+
+	beat := 0
+	for idx, it := range x.Tempos {
+		if idx == 0 {
+			beat = it.Battito - 1
+		} else {
+			// count beats elapsed since the previous marker
+			previous := x.Tempos[idx-1]
+			beat += int(math.Round((it.Inizio - previous.Inizio) * previous.Bpm / 60))
+		}
+		data.BeatGrid = append(data.BeatGrid, music.BeatMarker{
+			Position: seconds(it.Inizio),
+			Beat:     beat,
+			BPM:      it.Bpm,
+		})
+	}
+
+	for _, it := range x.PositionMarks {
+		if it.Num == memoryCue {
+			continue
+		}
+		if it.Type == markTypeLoop && it.End != nil {
+			data.Loops = append(data.Loops, music.Loop{
+				Index: it.Num,
+				Name:  it.Name,
+				Start: seconds(it.Start),
+				End:   seconds(*it.End),
+				Color: it.color(),
+			})
+		} else {
+			data.HotCues = append(data.HotCues, music.HotCue{
+				Index:    it.Num,
+				Name:     it.Name,
+				Position: seconds(it.Start),
+				Color:    it.color(),
+			})
+		}
+	}
+	data.Sort()
+	return data
+}
+
+func (x *XmlTrack) setPerformanceData(data *music.PerformanceData) {
+	x.Tempos = nil
+	x.PositionMarks = nil
+
+	for _, it := range data.BeatGrid {
+		bpm := it.BPM
+		if bpm <= 0 {
+			bpm = data.BPM
+		}
+		beat := it.Beat % 4
+		if beat < 0 {
+			beat += 4
+		}
+		x.Tempos = append(x.Tempos, XmlTempo{
+			Inizio:  it.Position.Seconds(),
+			Bpm:     bpm,
+			Metro:   "4/4",
+			Battito: beat + 1,
+		})
+	}
+
+	for _, it := range data.HotCues {
+		mark := XmlPositionMark{
+			Name:  it.Name,
+			Type:  markTypeCue,
+			Start: it.Position.Seconds(),
+			Num:   it.Index,
+		}
+		mark.setColor(it.Color)
+		x.PositionMarks = append(x.PositionMarks, mark)
+	}
+
+	for _, it := range data.Loops {
+		end := it.End.Seconds()
+		mark := XmlPositionMark{
+			Name:  it.Name,
+			Type:  markTypeLoop,
+			Start: it.Start.Seconds(),
+			End:   &end,
+			Num:   it.Index,
+		}
+		mark.setColor(it.Color)
+		x.PositionMarks = append(x.PositionMarks, mark)
+	}
+}
+
+func (x XmlPositionMark) color() color.RGBA {
+	if x.Red == nil || x.Green == nil || x.Blue == nil {
+		return color.RGBA{}
+	}
+	return color.RGBA{R: uint8(*x.Red), G: uint8(*x.Green), B: uint8(*x.Blue), A: 0xFF}
+}
+
+func (x *XmlPositionMark) setColor(c color.RGBA) {
+	if c == (color.RGBA{}) {
+		return
+	}
+	red, green, blue := int(c.R), int(c.G), int(c.B)
+	x.Red, x.Green, x.Blue = &red, &green, &blue
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
+
+/*
+	Kind attribute as displayed by rekordbox, ie: MP3 File
+*/
+func fileKind(path string) string {
+	ext := strings.TrimPrefix(strings.ToUpper(filepath.Ext(path)), ".")
+	switch ext {
+	case "":
+		return ""
+	case "AIF":
+		ext = "AIFF"
+	case "MP4":
+		ext = "M4A"
+	}
+	return ext + " File"
+}
@@ -73,6 +73,54 @@ func (t Track) SetPlayCount(count int) error {
 	return errors.New("SetPlayCount operation is not supported for rekordbox")
 }
 
+func (t Track) BPM() float64 {
+	return t.xml.AverageBpm
+}
+
+func (t Track) SetBPM(bpm float64) error {
+	return errors.New("SetBPM operation is not supported for rekordbox")
+}
+
+func (t Track) Key() music.Key {
+	key, err := music.ParseKey(t.xml.Tonality)
+	if err != nil {
+		logrus.Warnf("failed to parse tonality '%s'", t.xml.Tonality)
+	}
+	return key
+}
+
+func (t Track) SetKey(key music.Key) error {
+	return errors.New("SetKey operation is not supported for rekordbox")
+}
+
+func (t Track) Genre() string {
+	return t.xml.Genre
+}
+
+func (t Track) SetGenre(genre string) error {
+	return errors.New("SetGenre operation is not supported for rekordbox")
+}
+
+func (t Track) Comment() string {
+	return t.xml.Comments
+}
+
+func (t Track) SetComment(comment string) error {
+	return errors.New("SetComment operation is not supported for rekordbox")
+}
+
+func (t Track) Duration() time.Duration {
+	return time.Duration(t.xml.TotalTime) * time.Second
+}
+
+func (t Track) PerformanceData() (*music.PerformanceData, error) {
+	return t.xml.performanceData(), nil
+}
+
+func (t Track) SetPerformanceData(data *music.PerformanceData) error {
+	return errors.New("SetPerformanceData operation is not supported for rekordbox")
+}
+
 func (t Track) FilePath() string {
 	return files.ConvertUrlFilePath(t.xml.Location)
 }
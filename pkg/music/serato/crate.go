@@ -0,0 +1,181 @@
+package serato
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+const (
+	crateExtension = ".crate"
+	crateSeparator = "%%"
+	crateVersion   = "1.0/Serato ScratchLive Crate"
+)
+
+type Crate struct {
+	lib    *Library
+	path   string
+	header []*field
+	tracks []string
+	dirty  bool
+}
+
+func newCrate(lib *Library, path string) *Crate {
+	return &Crate{
+		lib:  lib,
+		path: path,
+		header: []*field{
+			{Tag: tagVersion, Data: encodeText(crateVersion)},
+			{Tag: tagSorting, Fields: []*field{
+				textField(tagColumnName, "song"),
+				{Tag: tagReverse, Data: []byte{0}},
+			}},
+			{Tag: tagColumn, Fields: []*field{
+				textField(tagColumnName, "song"),
+				textField(tagColumnWidth, "0"),
+			}},
+			{Tag: tagColumn, Fields: []*field{
+				textField(tagColumnName, "artist"),
+				textField(tagColumnWidth, "0"),
+			}},
+			{Tag: tagColumn, Fields: []*field{
+				textField(tagColumnName, "bpm"),
+				textField(tagColumnWidth, "0"),
+			}},
+		},
+		dirty: true,
+	}
+}
+
+func openCrate(lib *Library, filename string) (*Crate, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read crate '%s'", filename)
+	}
+
+	fields, err := readFields(content)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to parse crate '%s'", filename)
+	}
+
+	c := &Crate{
+		lib:  lib,
+		path: crateNameToPath(filepath.Base(filename)),
+	}
+
+	for _, it := range fields {
+		if it.Tag == tagTrack {
+			if path := it.text(tagCrateTrack); path != "" {
+				c.tracks = append(c.tracks, path)
+			}
+		} else {
+			c.header = append(c.header, it)
+		}
+	}
+	return c, nil
+}
+
+/*
+	Crate file name use '%%' as folder separator, ie: Parent%%Child.crate
+*/
+func crateNameToPath(filename string) string {
+	name := strings.TrimSuffix(filename, crateExtension)
+	return strings.Replace(name, crateSeparator, "/", -1)
+}
+
+func cratePathToName(path string) string {
+	return strings.Replace(path, "/", crateSeparator, -1) + crateExtension
+}
+
+func (c *Crate) Name() string {
+	split := strings.Split(c.path, "/")
+	return split[len(split)-1]
+}
+
+func (c *Crate) Path() string {
+	return c.path
+}
+
+func (c *Crate) Tracks() music.Tracks {
+	tracks := music.Tracks{}
+	for _, it := range c.tracks {
+		if track := c.lib.Track(c.lib.absolutePath(it)); track != nil {
+			tracks = append(tracks, track)
+		} else {
+			logrus.Warnf("crate '%s' refer to a track not in database '%s'", c.path, it)
+		}
+	}
+	return tracks
+}
+
+func (c *Crate) Count() int {
+	return len(c.tracks)
+}
+
+func (c *Crate) SetTracks(tracks music.Tracks) error {
+	paths := []string{}
+	for _, it := range tracks {
+		track, ok := it.(*Track)
+		if !ok || track.lib != c.lib {
+			return errors.New("cannot save track object which are not from the same library")
+		}
+		paths = append(paths, track.entry.text(tagFilePath))
+	}
+
+	logrus.Infof("updating tracklist for crate '%s' with %d entries", c.path, len(paths))
+	c.tracks = paths
+	c.dirty = true
+	return nil
+}
+
+func (c *Crate) filename() string {
+	return filepath.Join(c.lib.path, "Subcrates", cratePathToName(c.path))
+}
+
+func (c *Crate) save() error {
+	if !c.dirty {
+		return nil
+	}
+
+	fields := append([]*field{}, c.header...)
+	for _, it := range c.tracks {
+		fields = append(fields, &field{
+			Tag:    tagTrack,
+			Fields: []*field{textField(tagCrateTrack, it)},
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeFields(buf, fields); err != nil {
+		return errors.Wrapf(err, "failed to serialize crate '%s'", c.path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.filename()), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create subcrates folder")
+	}
+
+	if err := files.WriteFileAtomic(c.filename(), buf.Bytes()); err != nil {
+		return errors.Wrapf(err, "failed to write crate '%s'", c.path)
+	}
+
+	logrus.Infof("crate '%s' saved to '%s'", c.path, c.filename())
+	c.dirty = false
+	return nil
+}
+
+func (c *Crate) MarshalYAML() (interface{}, error) {
+	return music.NewMarshallTracklist(c), nil
+}
+
+func (c *Crate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(music.NewMarshallTracklist(c))
+}
@@ -0,0 +1,376 @@
+package serato
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+	flib "primetools/pkg/music/files"
+)
+
+const (
+	seratoFolder = "_Serato_"
+	databaseName = "database V2"
+)
+
+type Library struct {
+	path         string
+	root         string
+	fields       []*field
+	tracks       map[string]*Track
+	crates       map[string]*Crate
+	hashCache    map[string]music.Tracks
+	contentIndex music.ContentIndex
+	filelib      *flib.FileLibrary
+	info         string
+	dirty        bool
+}
+
+func Open(path string) (music.Library, error) {
+	if path == "" {
+		path = files.ExpandHomePath("~/Music/" + seratoFolder)
+	} else if filepath.Base(path) == databaseName {
+		path = filepath.Dir(path)
+	} else if filepath.Base(path) != seratoFolder && files.IsDir(filepath.Join(path, seratoFolder)) {
+		path = filepath.Join(path, seratoFolder)
+	}
+
+	lib := &Library{
+		path:   path,
+		root:   volumeRoot(path),
+		tracks: map[string]*Track{},
+		crates: map[string]*Crate{},
+	}
+
+	start := time.Now()
+
+	dbpath := filepath.Join(path, databaseName)
+	logrus.Infof("opening serato database '%s'", dbpath)
+
+	content, err := ioutil.ReadFile(dbpath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read serato database '%s'", dbpath)
+	}
+
+	lib.fields, err = readFields(content)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to parse serato database '%s'", dbpath)
+	}
+
+	version := ""
+	for _, it := range lib.fields {
+		switch it.Tag {
+		case tagVersion:
+			version = decodeText(it.Data)
+		case tagTrack:
+			track := newTrack(lib, it)
+			fpath := files.RemoveAccent(track.FilePath())
+			if _, ok := lib.tracks[fpath]; ok {
+				logrus.Warnf("file '%s' seems to be duplicated in serato database", fpath)
+			}
+			lib.tracks[fpath] = track
+		}
+	}
+
+	if version == "" {
+		return nil, errors.Errorf("serato database '%s' looks invalid, no version field", dbpath)
+	}
+
+	crates, err := filepath.Glob(filepath.Join(path, "Subcrates", "*"+crateExtension))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list serato crates")
+	}
+	for _, it := range crates {
+		crate, err := openCrate(lib, it)
+		if err != nil {
+			logrus.Errorf("%v", err)
+			continue
+		}
+		lib.crates[crate.path] = crate
+	}
+
+	lib.info = fmt.Sprintf("Serato: Version: %v, Track Count: %d, Crate Count: %d, Path: %v", version, len(lib.tracks), len(lib.crates), path)
+	logrus.Infof("sucessfully loaded serato library in %s", time.Since(start))
+	logrus.Info(lib.info)
+
+	return lib, nil
+}
+
+/*
+	Track paths are relative to the root of the volume the _Serato_ folder is on,
+	the main library inside the user's Music folder refer to the system drive
+*/
+func volumeRoot(path string) string {
+	parent := filepath.Dir(path)
+	if home, err := os.UserHomeDir(); err == nil {
+		if strings.HasPrefix(files.NormalizePath(parent), files.NormalizePath(home)) {
+			return filepath.VolumeName(parent) + "/"
+		}
+	}
+	return parent
+}
+
+func (l *Library) absolutePath(path string) string {
+	return files.NormalizePath(filepath.Join(l.root, filepath.FromSlash(path)))
+}
+
+func (l *Library) relativePath(path string) (string, error) {
+	rpath, err := filepath.Rel(l.root, path)
+	if err != nil || strings.HasPrefix(rpath, "..") {
+		return "", errors.Errorf("file '%s' is not on the same volume as serato library '%s'", path, l.root)
+	}
+	return filepath.ToSlash(rpath), nil
+}
+
+func (l *Library) Close() {
+	if err := l.save(); err != nil {
+		logrus.Errorf("failed to save serato library: %v", err)
+	}
+	logrus.Info("Serato library closed")
+}
+
+func (l *Library) save() error {
+	if l.dirty {
+		buf := &bytes.Buffer{}
+		if err := writeFields(buf, l.fields); err != nil {
+			return errors.Wrap(err, "failed to serialize serato database")
+		}
+
+		dbpath := filepath.Join(l.path, databaseName)
+		if err := files.WriteFileAtomic(dbpath, buf.Bytes()); err != nil {
+			return err
+		}
+		logrus.Infof("serato database saved to '%s'", dbpath)
+		l.dirty = false
+	}
+
+	for _, it := range l.crates {
+		if err := it.save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Library) Track(filename string) music.Track {
+	filename = files.NormalizePath(filename)
+	filename = files.RemoveAccent(filename)
+	if t, ok := l.tracks[filename]; ok {
+		return t
+	}
+	return nil
+}
+
+func (l *Library) Matches(track music.Track) (matches music.Tracks) {
+	if track == nil {
+		return
+	}
+
+	if found := l.Track(track.FilePath()); found != nil {
+		matches = append(matches, found)
+	}
+
+	if l.hashCache == nil {
+		start := time.Now()
+		logrus.Info("constructing track hashes from Serato library metadata")
+
+		l.hashCache = map[string]music.Tracks{}
+		for _, it := range l.tracks {
+			h := music.TrackHash(it)
+			l.hashCache[h] = append(l.hashCache[h], it)
+		}
+		logrus.Infof("processed %d tracks in %v", len(l.hashCache), time.Since(start))
+	}
+
+	if match, ok := l.hashCache[music.TrackHash(track)]; ok {
+		matches = append(matches, match...)
+	}
+
+	// the metadata might have been edited since, fallback on the audio content
+	if len(matches) == 0 {
+		matches = append(matches, l.contentIndex.Matches(l, track)...)
+	}
+
+	return matches.Dedupe()
+}
+
+/*
+	Serato doesn't have playlists, crates are returned instead
+*/
+func (l *Library) Playlists() []music.Tracklist {
+	logrus.Warnf("Serato doesn't have playlists, getting crates insteads")
+	return l.Crates()
+}
+
+func (l *Library) Crates() []music.Tracklist {
+	list := []music.Tracklist{}
+	for _, it := range l.crates {
+		list = append(list, it)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Path() < list[j].Path()
+	})
+	return list
+}
+
+func (l *Library) ForEachTrack(fct music.EachTrackFunc) error {
+	count := 0
+	for _, it := range l.tracks {
+		if err := fct(count, len(l.tracks), it); err != nil {
+			return err
+		}
+		count++
+	}
+	return nil
+}
+
+func (l *Library) SupportedExtensions() music.FileExtensions {
+	return music.FileExtensions{
+		".aac",
+		".aif",
+		".aiff",
+		".alac",
+		".flac",
+		".m4a",
+		".mp3",
+		".mp4",
+		".ogg",
+		".wav",
+	}
+}
+
+func (l *Library) AddFile(path string) (music.Track, error) {
+	if existing := l.Track(path); existing != nil {
+		return existing, nil
+	}
+
+	rpath, err := l.relativePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.filelib == nil {
+		l.filelib = flib.Open("")
+	}
+	meta := l.filelib.Track(path)
+	if meta == nil {
+		return nil, errors.Errorf("file '%s' doesn't exists", path)
+	}
+
+	now := uint32(time.Now().Unix())
+	entry := &field{
+		Tag: tagTrack,
+		Fields: []*field{
+			textField(tagFileType, strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")),
+			textField(tagFilePath, rpath),
+			textField(tagTitle, meta.Title()),
+			textField(tagArtist, meta.Artist()),
+			textField(tagAlbum, meta.Album()),
+			textField(tagGenre, meta.Genre()),
+			textField(tagComment, meta.Comment()),
+			textField(tagKey, meta.Key().String()),
+			textField(tagAddedStr, unixText(now)),
+		},
+	}
+	if meta.Year() > 0 {
+		entry.setText(tagYear, fmt.Sprint(meta.Year()))
+	}
+	if meta.Duration() > 0 {
+		entry.setText(tagLength, formatLength(meta.Duration()))
+	}
+	if meta.BPM() > 0 {
+		entry.setText(tagBPM, fmt.Sprintf("%.2f", meta.BPM()))
+	}
+	entry.setUint32(tagAdded, now)
+	entry.setBool(tagMissing, false)
+
+	l.fields = append(l.fields, entry)
+	l.dirty = true
+
+	track := newTrack(l, entry)
+	l.tracks[files.RemoveAccent(track.FilePath())] = track
+	l.hashCache = nil
+
+	logrus.Infof("added '%s' to serato database", track.FilePath())
+	return track, nil
+}
+
+func (l *Library) CreatePlaylist(path string) (music.Tracklist, error) {
+	logrus.Warnf("Serato doesn't have playlists, creating a crate insteads")
+	return l.CreateCrate(path)
+}
+
+/*
+	Create the crate and any missing parent crate, subcrates are only displayed by Serato
+	when all their parents exist
+*/
+func (l *Library) CreateCrate(path string) (music.Tracklist, error) {
+	if strings.Contains(path, crateSeparator) {
+		return nil, errors.Errorf("crate name '%s' cannot contains '%s'", path, crateSeparator)
+	}
+
+	split := strings.Split(path, "/")
+	for idx := range split {
+		pathname := strings.Join(split[:idx+1], "/")
+		if _, ok := l.crates[pathname]; !ok {
+			logrus.Infof("creating crate '%s' in serato library", pathname)
+			l.crates[pathname] = newCrate(l, pathname)
+		}
+	}
+
+	return l.crates[path], nil
+}
+
+func (l *Library) MoveTrack(track music.Track, newpath string) error {
+	itrack, ok := track.(*Track)
+	if !ok || itrack.lib != l {
+		return errors.New("invalid track type parameter")
+	}
+
+	oldpath := itrack.entry.text(tagFilePath)
+	oldkey := files.RemoveAccent(itrack.FilePath())
+
+	if err := itrack.setPath(newpath); err != nil {
+		return err
+	}
+	newrel := itrack.entry.text(tagFilePath)
+
+	delete(l.tracks, oldkey)
+	l.tracks[files.RemoveAccent(itrack.FilePath())] = itrack
+
+	// crates refer to tracks by path
+	for _, crate := range l.crates {
+		for idx, it := range crate.tracks {
+			if it == oldpath {
+				crate.tracks[idx] = newrel
+				crate.dirty = true
+			}
+		}
+	}
+	return nil
+}
+
+/*
+	The database and every crate files
+*/
+func (l *Library) DatabaseFiles() []string {
+	out := []string{filepath.Join(l.path, databaseName)}
+	for _, it := range l.crates {
+		out = append(out, it.filename())
+	}
+	return out
+}
+
+func (l *Library) String() string {
+	return l.info
+}
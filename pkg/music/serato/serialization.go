@@ -0,0 +1,216 @@
+package serato
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+/*
+	Serato database V2 and crate files are a flat list of tagged fields:
+
+	  4 bytes ascii tag | 4 bytes big endian length | payload
+
+	The first letter of the tag tell the type of the payload:
+
+	  o: nested list of fields (ie: otrk, osrt, ovct)
+	  t: UTF-16 big endian text (ie: tsng, tart, tbpm)
+	  p: UTF-16 big endian path (ie: pfil, ptrk)
+	  u: 32 bits big endian unsigned integer (ie: uadd, utme)
+	  s: 16 bits big endian unsigned integer
+	  b: single byte boolean (ie: bmis, bply)
+*/
+type field struct {
+	Tag    string
+	Data   []byte
+	Fields []*field
+}
+
+const (
+	tagVersion = "vrsn"
+	tagTrack   = "otrk"
+
+	// database V2 track fields
+	tagFileType = "ttyp"
+	tagFilePath = "pfil"
+	tagTitle    = "tsng"
+	tagArtist   = "tart"
+	tagAlbum    = "talb"
+	tagGenre    = "tgen"
+	tagComment  = "tcom"
+	tagLength   = "tlen"
+	tagBPM      = "tbpm"
+	tagKey      = "tkey"
+	tagYear     = "ttyr"
+	tagAdded    = "uadd"
+	tagAddedStr = "tadd"
+	tagModified = "utme"
+	tagPlayed   = "bply"
+	tagMissing  = "bmis"
+
+	// crate fields
+	tagCrateTrack  = "ptrk"
+	tagSorting     = "osrt"
+	tagColumn      = "ovct"
+	tagColumnName  = "tvcn"
+	tagColumnWidth = "tvcw"
+	tagReverse     = "brev"
+)
+
+func (f *field) isContainer() bool {
+	return len(f.Tag) == 4 && f.Tag[0] == 'o'
+}
+
+func readFields(data []byte) ([]*field, error) {
+	out := []*field{}
+
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errors.Errorf("truncated field header (%d bytes left)", len(data))
+		}
+
+		f := &field{
+			Tag: string(data[0:4]),
+		}
+		size := binary.BigEndian.Uint32(data[4:8])
+		data = data[8:]
+
+		if uint32(len(data)) < size {
+			return nil, errors.Errorf("field '%s' length %d exceed remaining data (%d bytes)", f.Tag, size, len(data))
+		}
+
+		payload := data[:size]
+		data = data[size:]
+
+		if f.isContainer() {
+			children, err := readFields(payload)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "failed to parse field '%s'", f.Tag)
+			}
+			f.Fields = children
+		} else {
+			f.Data = payload
+		}
+		out = append(out, f)
+	}
+
+	return out, nil
+}
+
+func writeFields(w io.Writer, fields []*field) error {
+	for _, f := range fields {
+		payload := f.Data
+		if f.isContainer() {
+			buf := &bytes.Buffer{}
+			if err := writeFields(buf, f.Fields); err != nil {
+				return err
+			}
+			payload = buf.Bytes()
+		}
+
+		header := make([]byte, 8)
+		copy(header, f.Tag)
+		binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		if _, err := w.Write(payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *field) child(tag string) *field {
+	for _, it := range f.Fields {
+		if it.Tag == tag {
+			return it
+		}
+	}
+	return nil
+}
+
+/*
+	Return the child field with the given tag, creating it at the end if it doesn't exists
+*/
+func (f *field) ensureChild(tag string) *field {
+	if c := f.child(tag); c != nil {
+		return c
+	}
+	c := &field{Tag: tag}
+	f.Fields = append(f.Fields, c)
+	return c
+}
+
+func (f *field) text(tag string) string {
+	if c := f.child(tag); c != nil {
+		return decodeText(c.Data)
+	}
+	return ""
+}
+
+func (f *field) setText(tag string, value string) {
+	f.ensureChild(tag).Data = encodeText(value)
+}
+
+func (f *field) uint32(tag string) (uint32, bool) {
+	if c := f.child(tag); c != nil && len(c.Data) == 4 {
+		return binary.BigEndian.Uint32(c.Data), true
+	}
+	return 0, false
+}
+
+func (f *field) setUint32(tag string, value uint32) {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, value)
+	f.ensureChild(tag).Data = data
+}
+
+func (f *field) bool(tag string) bool {
+	if c := f.child(tag); c != nil && len(c.Data) == 1 {
+		return c.Data[0] != 0
+	}
+	return false
+}
+
+func (f *field) setBool(tag string, value bool) {
+	data := []byte{0}
+	if value {
+		data[0] = 1
+	}
+	f.ensureChild(tag).Data = data
+}
+
+func textField(tag string, value string) *field {
+	return &field{Tag: tag, Data: encodeText(value)}
+}
+
+func decodeText(data []byte) string {
+	chars := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		chars = append(chars, binary.BigEndian.Uint16(data[i:]))
+	}
+	// some fields are null terminated
+	for len(chars) > 0 && chars[len(chars)-1] == 0 {
+		chars = chars[:len(chars)-1]
+	}
+	return string(utf16.Decode(chars))
+}
+
+func encodeText(value string) []byte {
+	chars := utf16.Encode([]rune(value))
+	data := make([]byte, len(chars)*2)
+	for i, c := range chars {
+		binary.BigEndian.PutUint16(data[i*2:], c)
+	}
+	return data
+}
+
+func unixText(value uint32) string {
+	return strconv.FormatUint(uint64(value), 10)
+}
@@ -0,0 +1,221 @@
+package serato
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/files"
+	"primetools/pkg/music"
+)
+
+type Track struct {
+	entry *field
+	lib   *Library
+}
+
+func newTrack(lib *Library, entry *field) *Track {
+	return &Track{
+		entry: entry,
+		lib:   lib,
+	}
+}
+
+func (t *Track) Title() string {
+	return t.entry.text(tagTitle)
+}
+
+func (t *Track) Album() string {
+	return t.entry.text(tagAlbum)
+}
+
+func (t *Track) Artist() string {
+	return t.entry.text(tagArtist)
+}
+
+func (t *Track) Year() int {
+	year := t.entry.text(tagYear)
+	if len(year) < 4 {
+		return 0
+	}
+	value, err := strconv.Atoi(year[:4])
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func (t *Track) Rating() music.Rating {
+	return music.Zero
+}
+
+func (t *Track) SetRating(rating music.Rating) error {
+	return errors.New("SetRating operation is not supported for serato")
+}
+
+func (t *Track) Modified() time.Time {
+	if modified, ok := t.entry.uint32(tagModified); ok {
+		return time.Unix(int64(modified), 0).UTC()
+	}
+	return files.ModifiedTime(t.FilePath())
+}
+
+func (t *Track) SetModified(modified time.Time) error {
+	t.entry.setUint32(tagModified, uint32(modified.Unix()))
+	t.lib.dirty = true
+	return nil
+}
+
+func (t *Track) Added() time.Time {
+	if added, ok := t.entry.uint32(tagAdded); ok {
+		return time.Unix(int64(added), 0).UTC()
+	}
+	if added, err := strconv.ParseInt(t.entry.text(tagAddedStr), 10, 64); err == nil {
+		return time.Unix(added, 0).UTC()
+	}
+	return time.Time{}
+}
+
+func (t *Track) SetAdded(added time.Time) error {
+	t.entry.setUint32(tagAdded, uint32(added.Unix()))
+	t.entry.setText(tagAddedStr, unixText(uint32(added.Unix())))
+	t.lib.dirty = true
+	return nil
+}
+
+/*
+	Serato only keep track if a track was played or not
+*/
+func (t *Track) PlayCount() int {
+	if t.entry.bool(tagPlayed) {
+		return 1
+	}
+	return 0
+}
+
+func (t *Track) SetPlayCount(count int) error {
+	t.entry.setBool(tagPlayed, count > 0)
+	t.lib.dirty = true
+	return nil
+}
+
+func (t *Track) BPM() float64 {
+	bpm := t.entry.text(tagBPM)
+	if bpm == "" {
+		return 0
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(bpm), 64)
+	if err != nil {
+		logrus.Warnf("failed to parse bpm '%s' of track '%s'", bpm, t)
+	}
+	return value
+}
+
+func (t *Track) SetBPM(bpm float64) error {
+	t.entry.setText(tagBPM, strconv.FormatFloat(bpm, 'f', 2, 64))
+	t.lib.dirty = true
+	return nil
+}
+
+func (t *Track) Key() music.Key {
+	key, err := music.ParseKey(t.entry.text(tagKey))
+	if err != nil {
+		logrus.Warnf("failed to parse key '%s' of track '%s'", t.entry.text(tagKey), t)
+	}
+	return key
+}
+
+func (t *Track) SetKey(key music.Key) error {
+	t.entry.setText(tagKey, key.String())
+	t.lib.dirty = true
+	return nil
+}
+
+func (t *Track) Genre() string {
+	return t.entry.text(tagGenre)
+}
+
+func (t *Track) SetGenre(genre string) error {
+	t.entry.setText(tagGenre, genre)
+	t.lib.dirty = true
+	return nil
+}
+
+func (t *Track) Comment() string {
+	return t.entry.text(tagComment)
+}
+
+func (t *Track) SetComment(comment string) error {
+	t.entry.setText(tagComment, comment)
+	t.lib.dirty = true
+	return nil
+}
+
+/*
+	Length is stored as a display string, ie: 04:32.12
+*/
+func (t *Track) Duration() time.Duration {
+	length := t.entry.text(tagLength)
+	split := strings.SplitN(length, ":", 2)
+	if len(split) != 2 {
+		return 0
+	}
+	minutes, err := strconv.Atoi(split[0])
+	if err != nil {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(split[1], 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second))
+}
+
+func (t *Track) FilePath() string {
+	return t.lib.absolutePath(t.entry.text(tagFilePath))
+}
+
+func (t *Track) setPath(newpath string) error {
+	rpath, err := t.lib.relativePath(newpath)
+	if err != nil {
+		return err
+	}
+	t.entry.setText(tagFilePath, rpath)
+	t.lib.dirty = true
+	return nil
+}
+
+func (t *Track) Size() int64 {
+	return files.Size(t.FilePath())
+}
+
+func (t *Track) String() string {
+	if title := t.Title(); title != "" {
+		return title
+	}
+	return t.entry.text(tagFilePath)
+}
+
+func (t *Track) MarshalYAML() (interface{}, error) {
+	return music.NewMarchalTrack(t), nil
+}
+
+func (t *Track) MarshalJSON() ([]byte, error) {
+	return json.Marshal(music.NewMarchalTrack(t))
+}
+
+func (t *Track) MarshalTOML() ([]byte, error) {
+	return toml.Marshal(music.NewMarchalTrack(t))
+}
+
+func formatLength(duration time.Duration) string {
+	seconds := duration.Seconds()
+	minutes := int(seconds / 60)
+	return fmt.Sprintf("%02d:%05.2f", minutes, seconds-float64(minutes*60))
+}
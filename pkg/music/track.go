@@ -34,6 +34,20 @@ type Track interface {
 	PlayCount() int
 	SetPlayCount(count int) error
 
+	BPM() float64
+	SetBPM(bpm float64) error
+
+	Key() Key
+	SetKey(key Key) error
+
+	Genre() string
+	SetGenre(genre string) error
+
+	Comment() string
+	SetComment(comment string) error
+
+	Duration() time.Duration
+
 	FilePath() string
 
 	Size() int64
@@ -44,6 +58,25 @@ type Track interface {
 	json.Marshaler
 }
 
+/*
+	Track whose key can be removed, the libraries implementing it only accept a
+	valid key in SetKey
+*/
+type KeyClearer interface {
+	Track
+
+	ClearKey() error
+}
+
+/*
+	Track which only records whether it was played, its play count is 0 or 1
+*/
+type PlayedOnlyTrack interface {
+	Track
+
+	PlayedOnly()
+}
+
 func TrackMeta(track Track) string {
 	msg := ""
 	msg += fmt.Sprintf("Impl: %v\n", reflect.TypeOf(track).Elem().Name())
@@ -79,12 +112,19 @@ func IsSameFile(left Track, right Track) bool {
 }
 
 func TrackHash(track Track) string {
+	return MetaHash(track.Title(), track.Album(), track.Artist(), track.Year())
+}
+
+/*
+	Same as TrackHash, for metadata which isn't a track yet
+*/
+func MetaHash(title string, album string, artist string, year int) string {
 	hash := sha1.New()
 
-	hash.Write([]byte(files.RemoveAccent(track.Title())))
-	hash.Write([]byte(files.RemoveAccent(track.Album())))
-	hash.Write([]byte(files.RemoveAccent(track.Artist())))
-	hash.Write([]byte(strconv.Itoa(track.Year())))
+	hash.Write([]byte(files.RemoveAccent(title)))
+	hash.Write([]byte(files.RemoveAccent(album)))
+	hash.Write([]byte(files.RemoveAccent(artist)))
+	hash.Write([]byte(strconv.Itoa(year)))
 
 	res := hash.Sum(nil)
 	return fmt.Sprintf("%x", res)
@@ -19,7 +19,12 @@ type MarshalTrack struct {
 	Modified  time.Time `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
 	Added     time.Time `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
 	Rating    Rating    `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
-	PlayCount int       `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
+	PlayCount int           `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
+	BPM       float64       `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
+	Key       Key           `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
+	Genre     string        `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
+	Comment   string        `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
+	Duration  time.Duration `json:",omitempty" yaml:",omitempty" toml:",omitempty"`
 	Size      int64
 }
 
@@ -37,6 +42,11 @@ func NewMarchalTrack(track Track) MarshalTrack {
 		Modified:  track.Modified(),
 		Rating:    track.Rating(),
 		PlayCount: track.PlayCount(),
+		BPM:       track.BPM(),
+		Key:       track.Key(),
+		Genre:     track.Genre(),
+		Comment:   track.Comment(),
+		Duration:  track.Duration(),
 		Size:      track.Size(),
 	}
 }
@@ -110,6 +120,46 @@ func (m *marshalTrackAdapter) SetPlayCount(count int) error {
 	return nil
 }
 
+func (m marshalTrackAdapter) BPM() float64 {
+	return m.track.BPM
+}
+
+func (m *marshalTrackAdapter) SetBPM(bpm float64) error {
+	m.track.BPM = bpm
+	return nil
+}
+
+func (m marshalTrackAdapter) Key() Key {
+	return m.track.Key
+}
+
+func (m *marshalTrackAdapter) SetKey(key Key) error {
+	m.track.Key = key
+	return nil
+}
+
+func (m marshalTrackAdapter) Genre() string {
+	return m.track.Genre
+}
+
+func (m *marshalTrackAdapter) SetGenre(genre string) error {
+	m.track.Genre = genre
+	return nil
+}
+
+func (m marshalTrackAdapter) Comment() string {
+	return m.track.Comment
+}
+
+func (m *marshalTrackAdapter) SetComment(comment string) error {
+	m.track.Comment = comment
+	return nil
+}
+
+func (m marshalTrackAdapter) Duration() time.Duration {
+	return m.track.Duration
+}
+
 func (m marshalTrackAdapter) FilePath() string {
 	return m.track.FilePath
 }
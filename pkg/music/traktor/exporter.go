@@ -2,21 +2,24 @@ package traktor
 
 import (
 	"encoding/xml"
-	"os"
+	"strings"
 	"time"
 
+	"primetools/pkg/files"
 	"primetools/pkg/music"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>` + "\n"
+
 func (l *Library) AddTrack(track music.Track) error {
-	xmltrack := &XmlTrack{}
+	xmltrack := XmlTrack{}
 
-	existing := l.track(track.FilePath())
-	if existing != nil {
-		xmltrack = &existing.xml
+	idx, exists := l.pathToIdx[files.NormalizePath(track.FilePath())]
+	if exists {
+		xmltrack = l.xml.Collection.Entries[idx]
 	}
 
 	err := xmltrack.CopyFromTrack(track)
@@ -24,31 +27,115 @@ func (l *Library) AddTrack(track music.Track) error {
 		return err
 	}
 
-	if existing == nil {
-		l.xml.Collection.Count++
-		l.xml.Collection.Entries = append(l.xml.Collection.Entries, *xmltrack)
+	if exists {
+		delete(l.keyToIdx, l.xml.Collection.Entries[idx].PrimaryKey())
+		l.xml.Collection.Entries[idx] = xmltrack
+	} else {
+		idx = len(l.xml.Collection.Entries)
+		l.xml.Collection.Entries = append(l.xml.Collection.Entries, xmltrack)
+		l.xml.Collection.Count = len(l.xml.Collection.Entries)
 	}
-	
+
+	l.pathToIdx[xmltrack.Filepath()] = idx
+	l.keyToIdx[xmltrack.PrimaryKey()] = idx
+	l.hashCache = nil
 	return nil
 }
 
+/*
+	Folders of the playlist path are created under $ROOT as needed, an existing
+	playlist with the same path gets its entries replaced
+*/
 func (l *Library) AddPlaylist(tracklist music.Tracklist) error {
-	return nil
+	root := l.root()
+
+	split := strings.Split(tracklist.Path(), "/")
+	node := root
+	for _, name := range split[:len(split)-1] {
+		node = node.child(name, nodeFolder)
+		if node == nil {
+			return errors.Errorf("cannot create folder '%s' for playlist '%s', a playlist already has that name", name, tracklist.Path())
+		}
+	}
+
+	node = node.child(split[len(split)-1], nodePlaylist)
+	if node == nil {
+		return errors.Errorf("cannot create playlist '%s', a folder already has that name", tracklist.Path())
+	}
+
+	for _, track := range tracklist.Tracks() {
+		if l.track(track.FilePath()) == nil {
+			if err := l.AddTrack(track); err != nil {
+				return err
+			}
+		}
+	}
+
+	list := &TrackList{lib: l, path: tracklist.Path(), xml: node}
+	return list.SetTracks(tracklist.Tracks())
 }
 
-func (l *Library) Export() error {
-	file, err := os.Create(l.path)
-	if err != nil {
-		return errors.WithMessagef(err, "could not create file '%s'", l.path)
+func (l *Library) root() *XmlPlaylistNode {
+	for idx := range l.xml.Playlists {
+		if l.xml.Playlists[idx].Name == rootNode {
+			return &l.xml.Playlists[idx]
+		}
 	}
+	l.xml.Playlists = append(l.xml.Playlists, XmlPlaylistNode{
+		Type:     nodeFolder,
+		Name:     rootNode,
+		SubNodes: &XmlSubNodes{},
+	})
+	return &l.xml.Playlists[len(l.xml.Playlists)-1]
+}
 
-	encoder := xml.NewEncoder(file)
+/*
+	Find or create the child node with that name, nil is returned if the existing
+	node isn't of the same type
+*/
+func (x *XmlPlaylistNode) child(name string, nodeType string) *XmlPlaylistNode {
+	if x.SubNodes == nil {
+		x.SubNodes = &XmlSubNodes{}
+	}
+
+	for idx := range x.SubNodes.Nodes {
+		if x.SubNodes.Nodes[idx].Name == name {
+			if x.SubNodes.Nodes[idx].Type != nodeType {
+				return nil
+			}
+			return &x.SubNodes.Nodes[idx]
+		}
+	}
+
+	node := XmlPlaylistNode{Type: nodeType, Name: name}
+	if nodeType == nodePlaylist {
+		node.Playlist = &XmlPlaylist{Type: "LIST", Id: newUUID()}
+	} else {
+		node.SubNodes = &XmlSubNodes{}
+	}
+	x.SubNodes.Nodes = append(x.SubNodes.Nodes, node)
+	x.SubNodes.Count = len(x.SubNodes.Nodes)
+	return &x.SubNodes.Nodes[len(x.SubNodes.Nodes)-1]
+}
+
+func (l *Library) Export() error {
+	if l.path == "" {
+		return errors.New("traktor export require a target path")
+	}
 
 	start := time.Now()
-	err = encoder.Encode(&l.xml)
+
+	l.xml.Collection.Count = len(l.xml.Collection.Entries)
+
+	content, err := xml.MarshalIndent(&l.xml, "", "  ")
 	if err != nil {
 		return errors.WithMessage(err, "failed to encode into xml")
 	}
+	content = append([]byte(xmlHeader), content...)
+
+	if err = files.WriteFileAtomic(l.path, content); err != nil {
+		return err
+	}
 
 	logrus.Infof("successfully exported to file '%s' in %v", l.path, time.Since(start))
 	return nil
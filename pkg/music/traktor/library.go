@@ -4,6 +4,8 @@ import (
 	"encoding/xml"
 	"fmt"
 	"os"
+	"path"
+	"strings"
 	"time"
 
 	"primetools/pkg/files"
@@ -13,16 +15,22 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const rootNode = "$ROOT"
+
 type Library struct {
-	xml        XmlLibrary
-	pathHashes map[string]*XmlTrack
-	path       string
+	xml          XmlLibrary
+	pathToIdx    map[string]int
+	keyToIdx     map[string]int
+	hashCache    map[string]music.Tracks
+	contentIndex music.ContentIndex
+	path         string
 }
 
 func Create(path string) (music.Library, error) {
 	lib := &Library{
-		path:       path,
-		pathHashes: map[string]*XmlTrack{},
+		path:      path,
+		pathToIdx: map[string]int{},
+		keyToIdx:  map[string]int{},
 	}
 	lib.xml.Version = "19"
 	lib.xml.Header.Program = "Traktor"
@@ -51,12 +59,15 @@ func Open(path string) (music.Library, error) {
 	}
 
 	lib := &Library{
-		xml:        xmllib,
-		pathHashes: map[string]*XmlTrack{},
+		xml:       xmllib,
+		path:      path,
+		pathToIdx: map[string]int{},
+		keyToIdx:  map[string]int{},
 	}
 
-	for _, it := range xmllib.Collection.Entries {
-		lib.pathHashes[it.Filepath()] = &it
+	for idx, it := range xmllib.Collection.Entries {
+		lib.pathToIdx[it.Filepath()] = idx
+		lib.keyToIdx[it.PrimaryKey()] = idx
 	}
 
 	logrus.Infof("sucessfully loaded traktor library in %s", time.Since(start))
@@ -64,44 +75,128 @@ func Open(path string) (music.Library, error) {
 	return lib, nil
 }
 
-func (l Library) Close() {}
+func (l *Library) Close() {}
 
-func (l Library) Track(filename string) music.Track {
-	return l.track(filename)
+func (l *Library) Track(filename string) music.Track {
+	if track := l.track(filename); track != nil {
+		return track
+	}
+	return nil
 }
 
-func (l Library) track(filename string) *Track {
+func (l *Library) track(filename string) *Track {
 	filename = files.NormalizePath(filename)
-	if track, ok := l.pathHashes[filename]; ok && track != nil {
-		return newTrack(*track)
+	if idx, ok := l.pathToIdx[filename]; ok {
+		return newTrack(l.xml.Collection.Entries[idx])
+	}
+	return nil
+}
+
+/*
+	Playlist entries refer to collection entries by their primary key, ie: M:/:Music/:track.mp3
+*/
+func (l *Library) trackByKey(key string) *Track {
+	if idx, ok := l.keyToIdx[key]; ok {
+		return newTrack(l.xml.Collection.Entries[idx])
 	}
 	return nil
 }
 
-func (l Library) Matches(track music.Track) music.Tracks {
-	// TODO implement me
-	panic("implement me")
+func (l *Library) Matches(track music.Track) (matches music.Tracks) {
+	if track == nil {
+		return
+	}
+
+	if found := l.Track(track.FilePath()); found != nil {
+		matches = append(matches, found)
+	}
+
+	if l.hashCache == nil {
+		start := time.Now()
+		logrus.Info("constructing track hashes from Traktor library metadata")
+
+		l.hashCache = map[string]music.Tracks{}
+		err := l.ForEachTrack(func(index int, total int, track music.Track) error {
+			h := music.TrackHash(track)
+			if dupe, ok := l.hashCache[h]; ok {
+				list := dupe.Filepaths()
+				logrus.Warnf("duplicate metadata for '%s': \n  %s", track.String(), strings.Join(append(list, track.FilePath()), "\n  "))
+			}
+			l.hashCache[h] = append(l.hashCache[h], track)
+			return nil
+		})
+		if err != nil {
+			logrus.Errorf("%v", err)
+		}
+		logrus.Infof("processed %d tracks in %v", len(l.hashCache), time.Since(start))
+	}
+
+	if match, ok := l.hashCache[music.TrackHash(track)]; ok {
+		matches = append(matches, match...)
+	}
+
+	// the metadata might have been edited since, fallback on the audio content
+	if len(matches) == 0 {
+		matches = append(matches, l.contentIndex.Matches(l, track)...)
+	}
+
+	return matches.Dedupe()
+}
+
+func (l *Library) Playlists() []music.Tracklist {
+	list := []music.Tracklist{}
+	for idx := range l.xml.Playlists {
+		list = append(list, l.flatten(&l.xml.Playlists[idx], "")...)
+	}
+	return list
 }
 
-func (l Library) Playlists() []music.Tracklist {
-	// TODO implement me
-	panic("implement me")
+/*
+	Folders are flattened into the playlist path, the $ROOT folder is omitted
+*/
+func (l *Library) flatten(node *XmlPlaylistNode, parent string) (lists []music.Tracklist) {
+	pat := parent
+	if node.Name != rootNode {
+		pat = path.Join(parent, node.Name)
+	}
+
+	switch node.Type {
+	case nodePlaylist:
+		if node.Playlist != nil {
+			lists = append(lists, &TrackList{
+				lib:  l,
+				path: pat,
+				xml:  node,
+			})
+		}
+	case nodeFolder:
+		if node.SubNodes != nil {
+			for idx := range node.SubNodes.Nodes {
+				lists = append(lists, l.flatten(&node.SubNodes.Nodes[idx], pat)...)
+			}
+		}
+	}
+	return
 }
 
-func (l Library) Crates() []music.Tracklist {
+func (l *Library) Crates() []music.Tracklist {
 	logrus.Warn("traktor library doesn't have any crates")
 	return nil
 }
 
-func (l Library) ForEachTrack(fct music.EachTrackFunc) error {
+func (l *Library) ForEachTrack(fct music.EachTrackFunc) error {
 	for idx, track := range l.xml.Collection.Entries {
-		if err := fct(idx, l.xml.Collection.Count, newTrack(track)); err != nil {
+		if err := fct(idx, len(l.xml.Collection.Entries), newTrack(track)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (l Library) String() string {
+func (l *Library) DatabaseFiles() []string {
+	return []string{l.path}
+}
+
+func (l *Library) String() string {
 	return fmt.Sprintf("%v: Version: %v, Company: %s, Track Count: %d", l.xml.Header.Program, l.xml.Version, l.xml.Header.Company, l.xml.Collection.Count)
 }
@@ -2,17 +2,33 @@ package traktor
 
 import (
 	"encoding/xml"
+	"math"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"primetools/pkg/files"
 	"primetools/pkg/music"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
-const DateFormat = "2006/1/2"
+const (
+	DateFormat = "2006/1/2"
+
+	nodeFolder   = "FOLDER"
+	nodePlaylist = "PLAYLIST"
+
+	cueTypeCue     = 0
+	cueTypeFadeIn  = 1
+	cueTypeFadeOut = 2
+	cueTypeLoad    = 3
+	cueTypeGrid    = 4
+	cueTypeLoop    = 5
+)
 
 type XmlLibrary struct {
 	XMLName xml.Name `xml:"NML"`
@@ -109,17 +125,24 @@ type XmlTrack struct {
 		AnalyzedDB  float32 `xml:"ANALYZED_DB,attr"`
 	} `xml:"LOUDNESS"`
 
-	MusicalKey string `xml:"MUSICAL_KEY>VALUE"`
-
-	Cue []struct {
-		Name        string  `xml:"NAME,attr"`
-		DiplayOrder int     `xml:"DISPL_ORDER,attr"`
-		Type        int     `xml:"TYPE,attr"`
-		Start       float32 `xml:"START,attr"`
-		Length      float32 `xml:"LEN,attr"`
-		Repeats     int     `xml:"REPEATS,attr"`
-		Hotcue      int     `xml:"HOTCUE,attr"`
-	} `xml:"CUE_V2"`
+	MusicalKey *struct {
+		Value int `xml:"VALUE,attr"`
+	} `xml:"MUSICAL_KEY"`
+
+	Cue []XmlCue `xml:"CUE_V2"`
+}
+
+/*
+	Positions are in milliseconds, HOTCUE is the hot cue slot or -1 when not assigned
+*/
+type XmlCue struct {
+	Name        string  `xml:"NAME,attr"`
+	DiplayOrder int     `xml:"DISPL_ORDER,attr"`
+	Type        int     `xml:"TYPE,attr"`
+	Start       float64 `xml:"START,attr"`
+	Length      float64 `xml:"LEN,attr"`
+	Repeats     int     `xml:"REPEATS,attr"`
+	Hotcue      int     `xml:"HOTCUE,attr"`
 }
 
 func (x XmlTrack) Filepath() string {
@@ -129,6 +152,13 @@ func (x XmlTrack) Filepath() string {
 	return files.NormalizePath(path)
 }
 
+/*
+	Key used by playlists entries to refer to a collection entry, ie: M:/:Techno/:track.mp3
+*/
+func (x XmlTrack) PrimaryKey() string {
+	return x.Location.Volume + x.Location.Directory + x.Location.File
+}
+
 /*
 <NODE TYPE="FOLDER" NAME="$ROOT">
 	<SUBNODES COUNT="1">
@@ -146,17 +176,20 @@ type XmlPlaylistNode struct {
 	Type string `xml:"TYPE,attr"`
 	Name string `xml:"NAME,attr"`
 
-	SubNodes *struct {
-		Count int               `xml:"COUNT,attr"`
-		Nodes []XmlPlaylistNode `xml:"NODE"`
-	} `xml:"SUBNODES"`
-
-	Playlist *struct {
-		Type    string               `xml:"TYPE,attr"`
-		Count   int                  `xml:"ENTRIES,attr"`
-		Id      string               `xml:"UUID,attr"`
-		Entries []XmlPlaylistEntries `xml:"ENTRY>PRIMARYKEY"`
-	} `xml:"PLAYLIST"`
+	SubNodes *XmlSubNodes `xml:"SUBNODES"`
+	Playlist *XmlPlaylist `xml:"PLAYLIST"`
+}
+
+type XmlSubNodes struct {
+	Count int               `xml:"COUNT,attr"`
+	Nodes []XmlPlaylistNode `xml:"NODE"`
+}
+
+type XmlPlaylist struct {
+	Type    string             `xml:"TYPE,attr"`
+	Count   int                `xml:"ENTRIES,attr"`
+	Id      string             `xml:"UUID,attr"`
+	Entries []XmlPlaylistEntry `xml:"ENTRY"`
 }
 
 /*
@@ -164,6 +197,10 @@ type XmlPlaylistNode struct {
 	<PRIMARYKEY TYPE="TRACK" KEY="M:/:Techno/:-= Prog.Trance =-/:Lish/:2011 - Miles Away/:09 - Lish - Feel Good.mp3"></PRIMARYKEY>
 </ENTRY>
 */
+type XmlPlaylistEntry struct {
+	PrimaryKey XmlPlaylistEntries `xml:"PRIMARYKEY"`
+}
+
 type XmlPlaylistEntries struct {
 	Type string `xml:"TYPE,attr"`
 	Key  string `xml:"KEY,attr"`
@@ -197,25 +234,147 @@ func (x *XmlTrack) CopyFromTrack(track music.Track) error {
 	// x.Loudness.PeakDB =
 	// x.Loudness.PerceivedDB =
 	// x.Modification.AuthorType =
-	// x.Tempo.BPM = track.be
-	// x.Tempo.BPMQuality = 100
+	x.Tempo.BPM = float32(track.BPM())
+	x.Tempo.BPMQuality = 100
 
-	x.Location.Directory = filepath.Dir(track.FilePath())
+	fpath := filepath.ToSlash(track.FilePath())
+	x.Location.Volume = filepath.VolumeName(fpath)
+	x.Location.Directory = strings.TrimPrefix(path.Dir(fpath), x.Location.Volume)
+	x.Location.Directory = strings.TrimSuffix(x.Location.Directory, "/") + "/"
 	x.Location.Directory = strings.Replace(x.Location.Directory, "/", "/:", -1)
-	x.Location.File = filepath.Base(track.FilePath())
-	// x.Location.Volume = ""
+	x.Location.File = path.Base(fpath)
 	// x.Location.VolumeID = ""
 
 	x.Info.PlayCount = track.PlayCount()
 	x.Info.FileSize = filestats.Size()
 	x.Info.Ranking = int(track.Rating()) * 51
 	x.Info.ImportDate = track.Added().Format(DateFormat)
-	// x.Info.Key = track.Key()
-	// x.Info.Genre = track.Genre()
-	// x.Info.Comment = track.Comment()
+	x.Info.Key = track.Key().OpenKey()
+	x.Info.Genre = track.Genre()
+	x.Info.Comment = track.Comment()
 	// x.Info.Bitrate = track.Bitrate()
-	// x.Info.Playtime
-	// x.Info.PlaytimeF
+	x.Info.Playtime = int(track.Duration().Seconds())
+	x.Info.PlaytimeF = float32(track.Duration().Seconds())
+
+	if key := track.Key(); key.Valid() {
+		x.MusicalKey = &struct {
+			Value int `xml:"VALUE,attr"`
+		}{keyToTraktor(key)}
+	}
+
+	if ptrack, ok := track.(music.PerformanceTrack); ok {
+		data, err := ptrack.PerformanceData()
+		if err != nil {
+			logrus.Warnf("failed to read performance data of '%s': %v", track, err)
+		} else if !data.Empty() {
+			x.setPerformanceData(data)
+		}
+	}
 
 	return nil
 }
+
+func (x XmlTrack) performanceData() *music.PerformanceData {
+	data := &music.PerformanceData{
+		BPM: float64(x.Tempo.BPM),
+	}
+
+	for _, it := range x.Cue {
+		position := time.Duration(it.Start * float64(time.Millisecond))
+		switch {
+		case it.Type == cueTypeGrid:
+			beat := 0
+			if count := len(data.BeatGrid); count > 0 {
+				// count beats elapsed since the previous marker
+				previous := data.BeatGrid[count-1]
+				beat = previous.Beat + int(math.Round((position-previous.Position).Seconds()*data.BPM/60))
+			}
+			data.BeatGrid = append(data.BeatGrid, music.BeatMarker{
+				Position: position,
+				Beat:     beat,
+				BPM:      data.BPM,
+			})
+		case it.Hotcue < 0:
+			// cue not assigned to a slot
+		case it.Type == cueTypeLoop:
+			data.Loops = append(data.Loops, music.Loop{
+				Index: it.Hotcue,
+				Name:  it.Name,
+				Start: position,
+				End:   position + time.Duration(it.Length*float64(time.Millisecond)),
+			})
+		default:
+			data.HotCues = append(data.HotCues, music.HotCue{
+				Index:    it.Hotcue,
+				Name:     it.Name,
+				Position: position,
+			})
+		}
+	}
+	data.Sort()
+	return data
+}
+
+/*
+	Traktor doesn't store cue colors, they are derived from the cue type
+*/
+func (x *XmlTrack) setPerformanceData(data *music.PerformanceData) {
+	x.Cue = nil
+
+	if anchor, ok := data.Anchor(); ok {
+		x.Cue = append(x.Cue, XmlCue{
+			Name:    "AutoGrid",
+			Type:    cueTypeGrid,
+			Start:   float64(anchor.Position) / float64(time.Millisecond),
+			Repeats: -1,
+			Hotcue:  -1,
+		})
+		if anchor.BPM > 0 {
+			x.Tempo.BPM = float32(anchor.BPM)
+		}
+	}
+
+	for _, it := range data.HotCues {
+		x.Cue = append(x.Cue, XmlCue{
+			Name:    it.Name,
+			Type:    cueTypeCue,
+			Start:   float64(it.Position) / float64(time.Millisecond),
+			Repeats: -1,
+			Hotcue:  it.Index,
+		})
+	}
+
+	for _, it := range data.Loops {
+		x.Cue = append(x.Cue, XmlCue{
+			Name:    it.Name,
+			Type:    cueTypeLoop,
+			Start:   float64(it.Start) / float64(time.Millisecond),
+			Length:  float64(it.End-it.Start) / float64(time.Millisecond),
+			Repeats: -1,
+			Hotcue:  it.Index,
+		})
+	}
+
+	for idx := range x.Cue {
+		x.Cue[idx].DiplayOrder = idx
+	}
+}
+
+/*
+	Traktor MUSICAL_KEY values are chromatic, major keys from 0 (C) to 11 (B)
+	followed by minor keys from 12 (Cm) to 23 (Bm)
+*/
+func keyFromTraktor(value int) music.Key {
+	if value < 0 || value > 23 {
+		return music.KeyUnknown
+	}
+	return music.NewKey(value%12, value >= 12)
+}
+
+func keyToTraktor(key music.Key) int {
+	value := key.Pitch()
+	if key.IsMinor() {
+		value += 12
+	}
+	return value
+}
@@ -86,6 +86,60 @@ func (t Track) SetPlayCount(count int) error {
 	return errors.New("SetPlayCount operation is not supported for traktor")
 }
 
+func (t Track) BPM() float64 {
+	return float64(t.xml.Tempo.BPM)
+}
+
+func (t Track) SetBPM(bpm float64) error {
+	return errors.New("SetBPM operation is not supported for traktor")
+}
+
+func (t Track) Key() music.Key {
+	if t.xml.MusicalKey != nil {
+		return keyFromTraktor(t.xml.MusicalKey.Value)
+	}
+	key, err := music.ParseKey(t.xml.Info.Key)
+	if err != nil {
+		logrus.Warnf("failed to parse key '%s'", t.xml.Info.Key)
+	}
+	return key
+}
+
+func (t Track) SetKey(key music.Key) error {
+	return errors.New("SetKey operation is not supported for traktor")
+}
+
+func (t Track) Genre() string {
+	return t.xml.Info.Genre
+}
+
+func (t Track) SetGenre(genre string) error {
+	return errors.New("SetGenre operation is not supported for traktor")
+}
+
+func (t Track) Comment() string {
+	return t.xml.Info.Comment
+}
+
+func (t Track) SetComment(comment string) error {
+	return errors.New("SetComment operation is not supported for traktor")
+}
+
+func (t Track) Duration() time.Duration {
+	if t.xml.Info.PlaytimeF > 0 {
+		return time.Duration(float64(t.xml.Info.PlaytimeF) * float64(time.Second))
+	}
+	return time.Duration(t.xml.Info.Playtime) * time.Second
+}
+
+func (t Track) PerformanceData() (*music.PerformanceData, error) {
+	return t.xml.performanceData(), nil
+}
+
+func (t Track) SetPerformanceData(data *music.PerformanceData) error {
+	return errors.New("SetPerformanceData operation is not supported for traktor")
+}
+
 func (t Track) FilePath() string {
 	return t.xml.Filepath()
 }
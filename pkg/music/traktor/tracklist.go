@@ -0,0 +1,79 @@
+package traktor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"primetools/pkg/music"
+)
+
+type TrackList struct {
+	lib  *Library
+	path string
+	xml  *XmlPlaylistNode
+}
+
+func (t *TrackList) Name() string {
+	return t.xml.Name
+}
+
+func (t *TrackList) Path() string {
+	return t.path
+}
+
+func (t *TrackList) Count() int {
+	return len(t.xml.Playlist.Entries)
+}
+
+func (t *TrackList) Tracks() music.Tracks {
+	tracks := music.Tracks{}
+	for _, it := range t.xml.Playlist.Entries {
+		if track := t.lib.trackByKey(it.PrimaryKey.Key); track != nil {
+			tracks = append(tracks, track)
+		} else {
+			logrus.Warnf("playlist '%s' refer to a track not in collection '%s'", t.path, it.PrimaryKey.Key)
+		}
+	}
+	return tracks
+}
+
+func (t *TrackList) SetTracks(tracks music.Tracks) error {
+	entries := []XmlPlaylistEntry{}
+	for _, it := range tracks {
+		track := t.lib.track(it.FilePath())
+		if track == nil {
+			return errors.Errorf("track '%s' is not in traktor collection", it.FilePath())
+		}
+		entries = append(entries, XmlPlaylistEntry{
+			PrimaryKey: XmlPlaylistEntries{Type: "TRACK", Key: track.xml.PrimaryKey()},
+		})
+	}
+
+	logrus.Infof("updating tracklist for playlist '%s' with %d entries", t.path, len(entries))
+	t.xml.Playlist.Entries = entries
+	t.xml.Playlist.Count = len(entries)
+	return nil
+}
+
+func (t *TrackList) MarshalYAML() (interface{}, error) {
+	return music.NewMarshallTracklist(t), nil
+}
+
+func (t *TrackList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(music.NewMarshallTracklist(t))
+}
+
+/*
+	Traktor playlist UUID are 32 lowercase hexadecimal characters
+*/
+func newUUID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}